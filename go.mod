@@ -11,11 +11,12 @@ require (
 	github.com/go-gorp/gorp v2.2.0+incompatible // indirect
 	github.com/go-ldap/ldap v3.0.3+incompatible // indirect
 	github.com/go-redis/redis v6.15.7+incompatible // indirect
-	github.com/go-sql-driver/mysql v1.5.0 // indirect
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
 	github.com/gorilla/websocket v1.4.2
 	github.com/hashicorp/go-plugin v1.2.2 // indirect
-	github.com/lib/pq v1.5.2 // indirect
+	github.com/lib/pq v1.5.2
+	github.com/mattermost/go-i18n v1.11.0
 	github.com/mattermost/mattermost-server/v5 v5.18.0
 	github.com/mattn/go-sqlite3 v2.0.3+incompatible // indirect
 	github.com/nicksnyder/go-i18n v1.10.1
@@ -30,7 +31,7 @@ require (
 	github.com/ziutek/mymysql v1.5.4 // indirect
 	go.uber.org/zap v1.15.0 // indirect
 	golang.org/x/crypto v0.0.0-20200510223506-06a226fb4e37
-	golang.org/x/image v0.0.0-20200430140353-33d19683fad8 // indirect
+	golang.org/x/image v0.0.0-20200430140353-33d19683fad8
 	golang.org/x/text v0.3.2 // indirect
 	gopkg.in/asn1-ber.v1 v1.0.0-20181015200546-f715ec2f112d // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0 // indirect
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mattermost/mattermost-server/v5/model"
@@ -15,7 +20,21 @@ import (
 
 // OnActivate is called by mattermost when this plugin is started
 func (p *Plugin) OnActivate() error {
-	teams, errApp := p.API.GetTeamsForUser(p.BotUserID)
+	if p.clock == nil {
+		p.clock = realClock{}
+	}
+	p.journal.clock = clockFunc(p.now)
+
+	botID, err := p.ensureBot()
+	if err != nil {
+		return err
+	}
+	p.BotUserID = botID
+	if err := p.applyBotProfile(p.getConfiguration()); err != nil {
+		p.API.LogWarn("can't apply bot profile", "err", err.Error())
+	}
+
+	teams, errApp := p.API.GetTeams()
 	if errApp != nil {
 		return errors.Wrap(errApp, "failed to query teams OnActivate")
 	}
@@ -26,9 +45,36 @@ func (p *Plugin) OnActivate() error {
 		}
 	}
 
+	bundlePath, err := p.API.GetBundlePath()
+	if err != nil {
+		return errors.Wrap(err, "can't get bundle path")
+	}
+	if p.translations, err = loadTranslations(filepath.Join(bundlePath, translationsDirectory)); err != nil {
+		return err
+	}
+
+	p.nodeID = clusterNodeID()
+	if err := p.refreshLeadership(); err != nil {
+		p.API.LogError("can't refresh cluster leadership", "err", err.Error())
+	}
 	if err := p.retreiveData(); err != nil {
 		return err
 	}
+	journalDirectory := p.getConfiguration().JournalDirectory
+	var daily []JournalEvent
+	if journalDirectory != "" {
+		var err error
+		if daily, err = p.replayJournal(journalDirectory); err != nil {
+			p.API.LogError("can't replay journal", "err", err.Error())
+		}
+	}
+	if err := p.journal.Open(journalDirectory); err != nil {
+		return err
+	}
+	p.replayDaily(daily)
+
+	p.writeBufferStop = make(chan struct{})
+	go p.runWriteBuffer(p.writeBufferStop)
 
 	c, err := NewCron(p)
 	if err != nil {
@@ -36,6 +82,15 @@ func (p *Plugin) OnActivate() error {
 	}
 	p.cron = c
 
+	scheduler, err := NewScheduler(p)
+	if err != nil {
+		return err
+	}
+	p.scheduler = scheduler
+
+	go p.warnAboutConflicts()
+	go p.requestConsents()
+
 	return nil
 }
 
@@ -44,9 +99,10 @@ func (p *Plugin) registerCommand(teamID string) error {
 		TeamId:           teamID,
 		Trigger:          CommandTrigger,
 		AutoComplete:     true,
-		AutoCompleteDesc: "Display analytics of this channel",
-		DisplayName:      "Analytics of this channel",
-		Description:      "A command used to show analytics of this channel.",
+		AutoCompleteDesc: "Post analytics in this channel, see `/analytics help`",
+		AutoCompleteHint: "[week|month|channel ~name|help]",
+		DisplayName:      "Analytics",
+		Description:      "A command used to pull analytics reports in this channel.",
 	}); err != nil {
 		return errors.Wrap(err, "failed to register command")
 	}
@@ -67,7 +123,17 @@ func (p *Plugin) OnDeactivate() error {
 		}
 	}
 
+	p.scheduler.Stop()
 	p.cron.Stop()
+	// the last flush is journaled so its increments aren't replayed on next activation
+	close(p.writeBufferStop)
+	if err := p.flushWriteBuffer(); err != nil {
+		p.API.LogError("can't flush daily analytics", "err", err.Error())
+	}
+	if err := p.journal.Close(); err != nil {
+		p.API.LogError("can't close journal", "err", err.Error())
+	}
+	p.closeDailyStore()
 
 	return nil
 }
@@ -82,20 +148,97 @@ func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Req
 		p.handlePie(w, r)
 	case "/bar.svg":
 		p.handleBar(w, r)
+	case "/consent":
+		err = p.handleConsent(w, r)
+	case "/survey":
+		err = p.handleSurvey(w, r)
+	case "/api/v1/settings/teams":
+		err = p.handleSettingsTeams(w, r)
+	case "/api/v1/settings/channels":
+		err = p.handleSettingsChannels(w, r)
+	case "/api/v1/branding/logo":
+		err = p.handleBrandLogo(w, r)
+	case "/api/v1/catalog":
+		err = p.handleCatalog(w, r)
+	case "/api/v1/analytics/channels":
+		err = p.handleAnalyticsChannels(w, r)
+	case "/api/v1/analytics/users":
+		err = p.handleAnalyticsUsers(w, r)
+	case "/api/v1/analytics/days":
+		err = p.handleAnalyticsDays(w, r)
+	case "/api/v1/analytics/series":
+		err = p.handleAnalyticsSeries(w, r)
+	case "/api/v1/thread":
+		err = p.handleThread(w, r)
+	case "/api/v1/users/forget":
+		err = p.handleForgetUser(w, r)
+	case "/api/v1/audit-report/verify":
+		err = p.handleAuditReportVerify(w, r)
+	case "/api/v1/export/channel":
+		err = p.handleChannelExport(w, r)
+	case "/api/v1/changes":
+		err = p.handleChanges(w, r)
+	case "/api/v1/export.csv":
+		err = p.handleExport(w, r, exportFormatCSV)
+	case "/api/v1/export.parquet":
+		err = p.handleExport(w, r, exportFormatParquet)
+	case "/api/v1/export.arrow":
+		err = p.handleExport(w, r, exportFormatArrow)
+	case "/metrics":
+		err = p.handleMetrics(w, r)
+	case "/api/v1/runtime":
+		err = p.handleRuntimeStats(w, r)
+	case "/api/v1/collectors":
+		err = p.handleExternalCollectors(c, w, r)
+	case "/api/v1/collectors/values":
+		err = p.handleExternalValues(c, w, r)
+	case "/api/v1/collectors/export.csv":
+		err = p.handleExternalExportCSV(w, r)
 	default:
-		http.NotFound(w, r)
+		if strings.HasPrefix(r.URL.Path, pprofPathPrefix) {
+			err = p.handlePprof(w, r)
+		} else if strings.HasPrefix(r.URL.Path, sharedPathPrefix) {
+			err = p.handleSharedReport(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
 	}
 	if err != nil {
-		p.API.LogError("Error rendering chart", "err", err.Error())
+		p.API.LogError("Error serving http", "path", r.URL.Path, "err", err.Error())
 	}
 }
 
 func (p *Plugin) handleLine(w http.ResponseWriter, r *http.Request) error {
+	graph, err := p.lineChart(r.URL.Query())
+	if err != nil {
+		return err
+	}
+	return p.writeChartSVG(w, graph, graph.Width, r.URL.Query().Get(rtlChartParameter) != "")
+}
+
+// renderableChart is a line, pie or bar chart
+type renderableChart interface {
+	Render(rp chart.RendererProvider, w io.Writer) error
+}
+
+// writeChartSVG render a chart of width as svg with the logo of the BrandLogo setting
+func (p *Plugin) writeChartSVG(w http.ResponseWriter, graph renderableChart, width int, rtl bool) error {
+	var content bytes.Buffer
+	if err := graph.Render(chart.SVG, &content); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", chart.ContentTypeSVG)
+	_, err := w.Write(p.getConfiguration().brandSVG(content.Bytes(), width, rtl))
+	return err
+}
+
+// lineChart build a time series chart from query values, date are unix timestamps and other keys are series
+func (p *Plugin) lineChart(query url.Values) (*chart.Chart, error) {
 	times := make([]time.Time, 0)
 	yvalues := make(map[string][]float64, 0)
 	max := -1.0
-	for key, values := range r.URL.Query() {
-		if key == "amp" {
+	for key, values := range query {
+		if isChartParameter(key) {
 			continue
 		} else if key == "date" {
 			for _, stringDate := range values {
@@ -108,7 +251,7 @@ func (p *Plugin) handleLine(w http.ResponseWriter, r *http.Request) error {
 			for _, value := range values {
 				v, err := strconv.ParseFloat(value, 64)
 				if err != nil {
-					p.API.LogError("can't parse value", "value", value, "url", r.URL.String())
+					p.API.LogError("can't parse value", "value", value, "query", query.Encode())
 					v = 0
 				}
 				if v > max {
@@ -121,7 +264,7 @@ func (p *Plugin) handleLine(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	if len(times) < 2 {
-		return fmt.Errorf("Not enought time to draw a chart %d for url %s", len(times), r.URL.String())
+		return nil, fmt.Errorf("Not enought time to draw a chart %d for query %s", len(times), query.Encode())
 	}
 
 	chartSeries := make([]chart.Series, 0)
@@ -137,17 +280,20 @@ func (p *Plugin) handleLine(w http.ResponseWriter, r *http.Request) error {
 				},
 			)
 		} else {
-			p.API.LogDebug("Not enought data to draw line", "name", key, "nbTimes", nbTimes, "nbData", nbYValue, "url", r.URL.String())
+			p.API.LogDebug("Not enought data to draw line", "name", key, "nbTimes", nbTimes, "nbData", nbYValue, "query", query.Encode())
 		}
 	}
 
 	if len(chartSeries) < 1 {
-		return fmt.Errorf("Not enought data to draw a chart %d for url %s", len(chartSeries), r.URL.String())
+		return nil, fmt.Errorf("Not enought data to draw a chart %d for query %s", len(chartSeries), query.Encode())
 	}
 
-	graph := chart.Chart{
-		Width:  800,
-		Height: 300,
+	config := p.getConfiguration()
+	graph := &chart.Chart{
+		Width:        800,
+		Height:       300,
+		ColorPalette: config.chartPalette(),
+		Background:   config.chartBackground(),
 		XAxis: chart.XAxis{
 			Style: chart.StyleShow(),
 		},
@@ -159,17 +305,22 @@ func (p *Plugin) handleLine(w http.ResponseWriter, r *http.Request) error {
 	}
 
 	graph.Elements = []chart.Renderable{
-		chart.Legend(&graph),
+		chart.Legend(graph),
 	}
-
-	w.Header().Set("Content-Type", chart.ContentTypeSVG)
-	return graph.Render(chart.SVG, w)
+	if query.Get(rtlChartParameter) != "" {
+		// time flows from right to left with the legend on the right
+		graph.XAxis.Range = &chart.ContinuousRange{Descending: true}
+		graph.Elements = []chart.Renderable{
+			legendRTL(graph),
+		}
+	}
+	return graph, nil
 }
 
 func (p *Plugin) handlePie(w http.ResponseWriter, r *http.Request) {
 	values := make([]chart.Value, 0)
 	for key, value := range r.URL.Query() {
-		if key == "amp" {
+		if isChartParameter(key) {
 			continue
 		}
 		v, _ := strconv.ParseFloat(value[0], 64)
@@ -178,24 +329,34 @@ func (p *Plugin) handlePie(w http.ResponseWriter, r *http.Request) {
 	sort.Slice(values, func(i, j int) bool {
 		return values[i].Label < values[j].Label
 	})
+	config := p.getConfiguration()
 	graph := chart.PieChart{
-		Width:  300,
-		Height: 300,
-		Values: values,
+		Width:        300,
+		Height:       300,
+		Values:       values,
+		ColorPalette: config.chartPalette(),
+		Background:   config.chartBackground(),
 	}
 
-	w.Header().Set("Content-Type", chart.ContentTypeSVG)
-	err := graph.Render(chart.SVG, w)
-	if err != nil {
+	if err := p.writeChartSVG(w, graph, graph.Width, r.URL.Query().Get(rtlChartParameter) != ""); err != nil {
 		p.API.LogError("Error rendering pie chart", "err", err.Error())
 	}
 }
 
 func (p *Plugin) handleBar(w http.ResponseWriter, r *http.Request) {
+	graph := barChart(r.URL.Query(), p.getConfiguration())
+	if err := p.writeChartSVG(w, graph, graph.Width, r.URL.Query().Get(rtlChartParameter) != ""); err != nil {
+		p.API.LogError("Error rendering bar chart", "err", err.Error())
+	}
+}
+
+// barChart build a bar chart from query values, each key is a bar sorted by key, from right to left for rtl, with
+// the theme of config
+func barChart(query url.Values, config *configuration) *chart.BarChart {
 	values := make([]chart.Value, 0)
 	max := -1.0
-	for key, value := range r.URL.Query() {
-		if key != "amp" {
+	for key, value := range query {
+		if !isChartParameter(key) {
 			v, _ := strconv.ParseFloat(value[0], 64)
 			if v > max {
 				max = v
@@ -203,20 +364,25 @@ func (p *Plugin) handleBar(w http.ResponseWriter, r *http.Request) {
 			values = append(values, chart.Value{Value: v, Label: key})
 		}
 	}
-	graph := chart.BarChart{
-		Width:  600,
-		Height: 300,
-		XAxis:  chart.StyleShow(),
+	// query values have no order, bars are sorted by label like pie slices before being reversed for rtl
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Label < values[j].Label
+	})
+	if query.Get(rtlChartParameter) != "" {
+		for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	return &chart.BarChart{
+		Width:        600,
+		Height:       300,
+		ColorPalette: config.chartPalette(),
+		Background:   config.chartBackground(),
+		XAxis:        chart.StyleShow(),
 		YAxis: chart.YAxis{
 			Style: chart.StyleShow(),
 			Range: &chart.ContinuousRange{Min: 0, Max: max},
 		},
 		Bars: values,
 	}
-
-	w.Header().Set("Content-Type", chart.ContentTypeSVG)
-	err := graph.Render(chart.SVG, w)
-	if err != nil {
-		p.API.LogError("Error rendering bar chart", "err", err.Error())
-	}
 }
@@ -6,7 +6,7 @@ import (
 )
 
 // Analytic is a session of metrics to generate a report.
-// See `NewAnalytic()` to build one
+// See `NewAnalytic(start)` to build one
 type Analytic struct {
 	lock sync.RWMutex
 	// Start of recording
@@ -25,26 +25,73 @@ type Analytic struct {
 	FilesNb int64
 	// FilesSize store weigth of files uploaded
 	FilesSize int64
+	// ChannelsFilesSize store weigth of files posted by channels id
+	ChannelsFilesSize map[string]int64
+	// Hourly store number of messages by hour (formatted as 2006-01-02T15)
+	Hourly map[string]int64
+	// Threads store number of replies by root post id
+	Threads map[string]int64
+	// ChannelsWords store number of words by channels id
+	ChannelsWords map[string]int64
+	// Scripts store number of messages by writing system (e.g. Latin, Japanese)
+	Scripts map[string]int64
+	// ChannelsUsers store number of messages by channel and user id (formatted as channelID:userID)
+	ChannelsUsers map[string]int64
+	// ChannelsUsersReply store number of replies by channel and user id (formatted as channelID:userID)
+	ChannelsUsersReply map[string]int64
+	// Mentions store number of messages mentioning a username
+	Mentions map[string]int64
+	// ChannelsBroadcasts store number of messages with a broadcast mention by channel id and mention (formatted as channelID:here)
+	ChannelsBroadcasts map[string]int64
+	// Lengths store number of messages by length class (e.g. emoji, short, code)
+	Lengths map[string]int64
+	// ChannelsEdits store number of edits of messages by channels id
+	ChannelsEdits map[string]int64
+	// ChannelsDeletions store number of deleted messages by channels id
+	ChannelsDeletions map[string]int64
+	// DirectMessages store number of direct and group messages, without channel nor participants
+	DirectMessages int64
+	// External store values of collectors registered by other plugins by collector key
+	External map[string]int64
+	// RetractedThreads store number of replies deleted from threads spilled to kv by root post id, they are retracted
+	// when spilled threads are restored
+	RetractedThreads map[string]int64 `json:",omitempty"`
 }
 
-// NewAnalytic return a struct to store all data needed to generate a report
-func NewAnalytic() *Analytic {
+// NewAnalytic return a struct to store all data needed to generate a report from start
+func NewAnalytic(start time.Time) *Analytic {
 	return &Analytic{
 		lock:          sync.RWMutex{},
-		Start:         time.Now(),
+		Start:         start,
 		Channels:      make(map[string]int64),
 		ChannelsReply: make(map[string]int64),
 		Users:         make(map[string]int64),
 		UsersReply:    make(map[string]int64),
 		FilesNb:       int64(0),
 		FilesSize:     int64(0),
+
+		ChannelsFilesSize: make(map[string]int64),
+		Hourly:            make(map[string]int64),
+		Threads:           make(map[string]int64),
+		ChannelsWords:     make(map[string]int64),
+		Scripts:           make(map[string]int64),
+		ChannelsUsers:     make(map[string]int64),
+
+		ChannelsUsersReply: make(map[string]int64),
+		Mentions:           make(map[string]int64),
+		ChannelsBroadcasts: make(map[string]int64),
+		Lengths:            make(map[string]int64),
+		ChannelsEdits:      make(map[string]int64),
+		ChannelsDeletions:  make(map[string]int64),
+		External:           make(map[string]int64),
+		RetractedThreads:   make(map[string]int64),
 	}
 }
 
-// Init reinitialize an analytic to zero
+// Init reinitialize an analytic to zero, starting at start
 // TODO : remove me if possible
-func (a *Analytic) Init() {
-	a.Start = time.Now()
+func (a *Analytic) Init(start time.Time) {
+	a.Start = start
 	a.End = time.Time{}
 	a.Channels = make(map[string]int64)
 	a.ChannelsReply = make(map[string]int64)
@@ -52,7 +99,21 @@ func (a *Analytic) Init() {
 	a.UsersReply = make(map[string]int64)
 	a.FilesNb = int64(0)
 	a.FilesSize = int64(0)
-
+	a.DirectMessages = int64(0)
+	a.ChannelsFilesSize = make(map[string]int64)
+	a.Hourly = make(map[string]int64)
+	a.Threads = make(map[string]int64)
+	a.ChannelsWords = make(map[string]int64)
+	a.Scripts = make(map[string]int64)
+	a.ChannelsUsers = make(map[string]int64)
+	a.ChannelsUsersReply = make(map[string]int64)
+	a.Mentions = make(map[string]int64)
+	a.ChannelsBroadcasts = make(map[string]int64)
+	a.Lengths = make(map[string]int64)
+	a.ChannelsEdits = make(map[string]int64)
+	a.ChannelsDeletions = make(map[string]int64)
+	a.External = make(map[string]int64)
+	a.RetractedThreads = make(map[string]int64)
 }
 
 // WLock to lock this analytic in write
@@ -75,8 +136,51 @@ func (a *Analytic) RUnlock() {
 	a.lock.RUnlock()
 }
 
-// Close this analytic by adding an End date
-func (a *Analytic) Close() *Analytic {
-	a.End = time.Now()
+// Close this analytic by adding its end date
+func (a *Analytic) Close(end time.Time) *Analytic {
+	a.End = end
 	return a
 }
+
+// mergeAnalytics sum sessions into a new analytic starting at the oldest start and ending at the latest end
+func mergeAnalytics(sessions []*Analytic) *Analytic {
+	merged := NewAnalytic(time.Time{})
+	for _, session := range sessions {
+		session.RLock()
+		if merged.Start.IsZero() || session.Start.Before(merged.Start) {
+			merged.Start = session.Start
+		}
+		if session.End.After(merged.End) {
+			merged.End = session.End
+		}
+		mergeCounters(merged.Channels, session.Channels)
+		mergeCounters(merged.ChannelsReply, session.ChannelsReply)
+		mergeCounters(merged.Users, session.Users)
+		mergeCounters(merged.UsersReply, session.UsersReply)
+		mergeCounters(merged.ChannelsFilesSize, session.ChannelsFilesSize)
+		mergeCounters(merged.Hourly, session.Hourly)
+		mergeCounters(merged.Threads, session.Threads)
+		mergeCounters(merged.ChannelsWords, session.ChannelsWords)
+		mergeCounters(merged.Scripts, session.Scripts)
+		mergeCounters(merged.ChannelsUsers, session.ChannelsUsers)
+		mergeCounters(merged.ChannelsUsersReply, session.ChannelsUsersReply)
+		mergeCounters(merged.Mentions, session.Mentions)
+		mergeCounters(merged.ChannelsBroadcasts, session.ChannelsBroadcasts)
+		mergeCounters(merged.Lengths, session.Lengths)
+		mergeCounters(merged.ChannelsEdits, session.ChannelsEdits)
+		mergeCounters(merged.ChannelsDeletions, session.ChannelsDeletions)
+		mergeCounters(merged.External, session.External)
+		mergeCounters(merged.RetractedThreads, session.RetractedThreads)
+		merged.FilesNb += session.FilesNb
+		merged.DirectMessages += session.DirectMessages
+		merged.FilesSize += session.FilesSize
+		session.RUnlock()
+	}
+	return merged
+}
+
+func mergeCounters(to map[string]int64, from map[string]int64) {
+	for key, nb := range from {
+		to[key] += nb
+	}
+}
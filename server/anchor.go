@@ -0,0 +1,52 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const anchorKeyPrefix = "anchor_"
+
+// monthlyAnchor return the id of the anchor post of the month in a channel, creating it if needed
+// scheduled digests are posted as replies of this post when ThreadedDigests is enabled
+func (p *Plugin) monthlyAnchor(channelID string, date time.Time) (string, error) {
+	key := anchorKeyPrefix + channelID + "_" + date.Format("2006-01")
+	j, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "can't get anchor post from kv")
+	}
+	if j != nil {
+		if _, appErr := p.API.GetPost(string(j)); appErr == nil {
+			return string(j), nil
+		}
+		// anchor post was deleted, create a new one
+		if appErr := p.API.KVDelete(key); appErr != nil {
+			return "", errors.Wrap(appErr, "can't delete deleted anchor post from kv")
+		}
+	}
+
+	T := p.channelTranslate(channelID)
+	post, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.BotUserID,
+		ChannelId: channelID,
+		Message:   T("report.anchor", map[string]interface{}{"Month": date.Format(T("report.month_layout"))}),
+	})
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "can't create anchor post")
+	}
+
+	claimed, appErr := p.API.KVCompareAndSet(key, nil, []byte(post.Id))
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "can't save anchor post")
+	}
+	if !claimed {
+		// another server of the cluster created the anchor at the same time, keep its one
+		if appErr := p.API.DeletePost(post.Id); appErr != nil {
+			p.API.LogWarn("can't delete duplicated anchor post", "post", post.Id, "err", appErr.Error())
+		}
+		return p.monthlyAnchor(channelID, date)
+	}
+	return post.Id, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// anomalyCheckedKey store the last day checked for anomalies so a day is alerted only once
+	anomalyCheckedKey = "anomaly_checked"
+	// anomalyBaselineWeeks is the number of same weekdays before a day forming the baseline of a channel
+	anomalyBaselineWeeks = 8
+	// minAnomalyActiveDays is the number of active days in the baseline needed to check a channel
+	minAnomalyActiveDays = 4
+)
+
+// anomaly is a day whose number of messages in a channel is far from the baseline of the channel
+type anomaly struct {
+	channelID string
+	messages  float64
+	mean      float64
+	zScore    float64
+}
+
+// anomalyBaselines return the messages of each channel on the same weekday of the anomalyBaselineWeeks weeks before day
+// days without bucket count 0 messages
+func anomalyBaselines(buckets []dailyBucket, day time.Time) map[string][]float64 {
+	weeks := make(map[string]int, anomalyBaselineWeeks)
+	for week := 1; week <= anomalyBaselineWeeks; week++ {
+		weeks[day.AddDate(0, 0, -7*week).Format(dailyKeyFormat)] = week - 1
+	}
+	baselines := make(map[string][]float64)
+	for _, bucket := range buckets {
+		week, ok := weeks[bucket.Date.Format(dailyKeyFormat)]
+		if !ok {
+			continue
+		}
+		if _, ok := baselines[bucket.ID]; !ok {
+			baselines[bucket.ID] = make([]float64, anomalyBaselineWeeks)
+		}
+		baselines[bucket.ID][week] += float64(bucket.Counters.Messages)
+	}
+	return baselines
+}
+
+// detectAnomalies return channels whose messages on a day are at least stdDevs standard deviations away from their
+// baseline, sorted by absolute z-score, channels with less than minAnomalyActiveDays active days in their baseline
+// are ignored and the change must reach threshold
+func detectAnomalies(day map[string]float64, baselines map[string][]float64, stdDevs float64, threshold deltaThreshold) []anomaly {
+	anomalies := make([]anomaly, 0)
+	for channelID, baseline := range baselines {
+		active := 0
+		for _, messages := range baseline {
+			if messages > 0 {
+				active++
+			}
+		}
+		if active < minAnomalyActiveDays {
+			continue
+		}
+		z, mean := zScore(day[channelID], baseline)
+		change := math.Abs(day[channelID] - mean)
+		if math.Abs(z) < stdDevs || change < float64(threshold.Absolute) || (mean > 0 && change*100/mean < float64(threshold.Percent)) {
+			continue
+		}
+		anomalies = append(anomalies, anomaly{channelID: channelID, messages: day[channelID], mean: mean, zScore: z})
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		if math.Abs(anomalies[i].zScore) == math.Abs(anomalies[j].zScore) {
+			return anomalies[i].channelID < anomalies[j].channelID
+		}
+		return math.Abs(anomalies[i].zScore) > math.Abs(anomalies[j].zScore)
+	})
+	return anomalies
+}
+
+// checkAnomalies alert once about channels with unusual activity the day before now, in the anomaly alert channel
+// or by DM to system admins, direct and group messages are never checked
+func (p *Plugin) checkAnomalies(now time.Time) error {
+	stdDevs := p.getConfiguration().AnomalyStdDevs
+	if stdDevs <= 0 {
+		return nil
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := today.AddDate(0, 0, -1)
+	checked, appErr := p.API.KVGet(anomalyCheckedKey)
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't get last day checked for anomalies")
+	}
+	if string(checked) >= day.Format(dailyKeyFormat) {
+		return nil
+	}
+
+	buckets, err := p.dailyBuckets(dailyScopeChannel, day.AddDate(0, 0, -7*anomalyBaselineWeeks), day)
+	if err != nil {
+		return err
+	}
+	messages := make(map[string]float64)
+	for _, bucket := range buckets {
+		if bucket.Date.Equal(day) {
+			messages[bucket.ID] += float64(bucket.Counters.Messages)
+		}
+	}
+	baselines := anomalyBaselines(buckets, day)
+	for channelID := range baselines {
+		if teamID, err := p.getChannelTeamID(channelID); err != nil || teamID == "" {
+			delete(baselines, channelID)
+		}
+	}
+
+	anomalies := detectAnomalies(messages, baselines, float64(stdDevs), p.getConfiguration().deltaThreshold())
+	if len(anomalies) > 0 {
+		if err := p.sendAnomalyAlert(p.getAnomalyDescription(anomalies, day)); err != nil {
+			return err
+		}
+	}
+	if appErr := p.API.KVSet(anomalyCheckedKey, []byte(day.Format(dailyKeyFormat))); appErr != nil {
+		return errors.Wrap(appErr, "can't save last day checked for anomalies")
+	}
+	return nil
+}
+
+// getAnomalyDescription render channels with unusual activity on day
+func (p *Plugin) getAnomalyDescription(anomalies []anomaly, day time.Time) string {
+	m := fmt.Sprintf(":warning: Unusual activity on %s compared to the same day of the last %d weeks:\n", day.Format("Monday, January 2"), anomalyBaselineWeeks)
+	for index, a := range anomalies {
+		if index == maxChannelsToDisplay {
+			break
+		}
+		_, displayName, link, err := p.getChannelName(a.channelID)
+		if err != nil {
+			continue
+		}
+		trend := "spike"
+		if a.zScore < 0 {
+			trend = "drop"
+		}
+		m += fmt.Sprintf("* [~%s](%s): %s to **%.0f** messages, usually %.1f *(%.1f standard deviations)*\n", displayName, link, trend, a.messages, a.mean, math.Abs(a.zScore))
+	}
+	return m
+}
+
+// sendAnomalyAlert post the alert in the anomaly alert channel if configured, else send it by DM to system admins
+func (p *Plugin) sendAnomalyAlert(message string) error {
+	if p.AnomalyChannelID != "" {
+		post := &model.Post{
+			UserId:    p.BotUserID,
+			ChannelId: p.AnomalyChannelID,
+			Message:   message,
+		}
+		if _, appErr := p.API.CreatePost(post); appErr != nil {
+			return errors.Wrap(appErr, "can't post anomaly alert")
+		}
+		return nil
+	}
+	admins, err := p.getSystemAdmins()
+	if err != nil {
+		return err
+	}
+	for _, adminID := range admins {
+		if err := p.sendDirectMessage(adminID, message, nil); err != nil {
+			p.API.LogError("can't send anomaly alert to admin", "user", adminID, "err", err.Error())
+		}
+	}
+	return nil
+}
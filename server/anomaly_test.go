@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalyBaselines(t *testing.T) {
+	assert := assert.New(t)
+
+	day := time.Date(2020, time.March, 16, 0, 0, 0, 0, time.UTC)
+	buckets := []dailyBucket{
+		{Date: day, ID: "channel1", Counters: DailyCounters{Messages: 50}},
+		{Date: day.AddDate(0, 0, -7), ID: "channel1", Counters: DailyCounters{Messages: 10}},
+		{Date: day.AddDate(0, 0, -8), ID: "channel1", Counters: DailyCounters{Messages: 99}},
+		{Date: day.AddDate(0, 0, -14), ID: "channel1", Counters: DailyCounters{Messages: 12}},
+		{Date: day.AddDate(0, 0, -56), ID: "channel2", Counters: DailyCounters{Messages: 3}},
+		{Date: day.AddDate(0, 0, -63), ID: "channel2", Counters: DailyCounters{Messages: 4}},
+	}
+
+	assert.Equal(map[string][]float64{
+		"channel1": {10, 12, 0, 0, 0, 0, 0, 0},
+		"channel2": {0, 0, 0, 0, 0, 0, 0, 3},
+	}, anomalyBaselines(buckets, day))
+	assert.Empty(anomalyBaselines(nil, day))
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	assert := assert.New(t)
+
+	baselines := map[string][]float64{
+		"steady":  {20, 22, 18, 20, 21, 19, 20, 20},
+		"dying":   {30, 28, 32, 30, 29, 31, 30, 30},
+		"usual":   {20, 25, 15, 20, 22, 18, 20, 20},
+		"sparse":  {0, 0, 0, 0, 0, 2, 3, 1},
+		"tiny":    {1, 1, 1, 1, 1, 1, 1, 1},
+		"missing": {10, 10, 10, 10, 10, 10, 10, 10},
+	}
+	day := map[string]float64{"steady": 80, "dying": 0, "usual": 26, "sparse": 40, "tiny": 5, "new": 100}
+
+	anomalies := detectAnomalies(day, baselines, 3, deltaThreshold{Percent: 10, Absolute: 5})
+	assert.Len(anomalies, 3)
+	assert.Equal("steady", anomalies[0].channelID)
+	assert.Equal(80.0, anomalies[0].messages)
+	assert.InDelta(20, anomalies[0].mean, 0.01)
+	assert.True(anomalies[0].zScore > 3)
+	assert.Equal("dying", anomalies[1].channelID)
+	assert.True(anomalies[1].zScore < -3)
+	assert.Equal("missing", anomalies[2].channelID)
+
+	assert.Len(detectAnomalies(day, baselines, 3, deltaThreshold{}), 4)
+	assert.Empty(detectAnomalies(day, nil, 3, deltaThreshold{}))
+}
@@ -0,0 +1,483 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxAPIRangeDays = 366
+	// maxAPISeries is the maximum number of series overlaid in a comparison
+	maxAPISeries = 4
+	// defaultAPIPerPage and maxAPIPerPage bound the number of channels or users of a page
+	defaultAPIPerPage = 50
+	maxAPIPerPage     = 200
+)
+
+// APIChannel is the activity of a channel returned by the REST API
+type APIChannel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Messages    int64  `json:"messages"`
+	Replies     int64  `json:"replies"`
+	FilesSize   int64  `json:"files_size"`
+}
+
+// APIUser is the activity of a user returned by the REST API
+type APIUser struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Messages  int64  `json:"messages"`
+	Replies   int64  `json:"replies"`
+	FilesSize int64  `json:"files_size"`
+}
+
+// APIDay is the activity of a day returned by the REST API
+type APIDay struct {
+	Date        string `json:"date"`
+	Messages    int64  `json:"messages"`
+	Replies     int64  `json:"replies"`
+	ActiveUsers int64  `json:"active_users"`
+}
+
+// APISeries is the number of messages of each day of a channel, or of all channels, returned by the REST API
+// series of a comparison have the same number of days so they can be overlaid
+type APISeries struct {
+	Name     string  `json:"name"`
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Messages []int64 `json:"messages"`
+}
+
+// APIResponse is the envelope of REST API responses
+type APIResponse struct {
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	Channels []*APIChannel `json:"channels,omitempty"`
+	Users    []*APIUser    `json:"users,omitempty"`
+	Days     []*APIDay     `json:"days,omitempty"`
+	Series   []*APISeries  `json:"series,omitempty"`
+	// Total is the number of channels or users matching the search, before pagination
+	Total   int `json:"total,omitempty"`
+	Page    int `json:"page,omitempty"`
+	PerPage int `json:"per_page,omitempty"`
+	// Degraded is true when the database fails and counters come from the kv store, they may be behind
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// listParams are sorting, search and pagination query parameters of channels and users lists
+type listParams struct {
+	Sort    string
+	Desc    bool
+	Search  string
+	Page    int
+	PerPage int
+}
+
+// parseListParams read sort (messages, replies, files_size or name), order (asc or desc), q, page (from 0)
+// and per_page query parameters, by default the first page sorted by messages descending
+func parseListParams(r *http.Request) (listParams, error) {
+	query := r.URL.Query()
+	params := listParams{Sort: "messages", Desc: true, Search: strings.ToLower(strings.TrimSpace(query.Get("q"))), PerPage: defaultAPIPerPage}
+	switch value := query.Get("sort"); value {
+	case "":
+	case "messages", "replies", "files_size":
+		params.Sort = value
+	case "name":
+		params.Sort = value
+		params.Desc = false
+	default:
+		return params, fmt.Errorf("bad sort %s, expected messages, replies, files_size or name", value)
+	}
+	switch value := query.Get("order"); value {
+	case "":
+	case "asc", "desc":
+		params.Desc = value == "desc"
+	default:
+		return params, fmt.Errorf("bad order %s, expected asc or desc", value)
+	}
+	if value := query.Get("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 0 {
+			return params, fmt.Errorf("bad page %s, expected a positive number", value)
+		}
+		params.Page = page
+	}
+	if value := query.Get("per_page"); value != "" {
+		perPage, err := strconv.Atoi(value)
+		if err != nil || perPage < 1 || perPage > maxAPIPerPage {
+			return params, fmt.Errorf("bad per_page %s, expected a number between 1 and %d", value, maxAPIPerPage)
+		}
+		params.PerPage = perPage
+	}
+	return params, nil
+}
+
+// matches return true if one of the names contains the search, case insensitive
+func (params listParams) matches(names ...string) bool {
+	if params.Search == "" {
+		return true
+	}
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), params.Search) {
+			return true
+		}
+	}
+	return false
+}
+
+// value return the sorted column of a row, 0 when sorted by name
+func (params listParams) value(row exportRow) int64 {
+	switch params.Sort {
+	case "messages":
+		return row.Messages
+	case "replies":
+		return row.Replies
+	case "files_size":
+		return row.FilesSize
+	}
+	return 0
+}
+
+// less compare two rows by the sorted column then by name, ties are sorted by id so pages are stable
+func (params listParams) less(a exportRow, aName string, b exportRow, bName string) bool {
+	if va, vb := params.value(a), params.value(b); va != vb {
+		return (va < vb) != params.Desc
+	}
+	if aName, bName = strings.ToLower(aName), strings.ToLower(bName); aName != bName {
+		return (aName < bName) != params.Desc
+	}
+	return a.ChannelID+a.UserID < b.ChannelID+b.UserID
+}
+
+// pageBounds return the slice bounds of the page among total rows
+func (params listParams) pageBounds(total int) (int, int) {
+	start := params.Page * params.PerPage
+	if start > total {
+		start = total
+	}
+	end := start + params.PerPage
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+// parseDateRange read from and to query parameters (YYYY-MM-DD, both included), last 30 days by default
+func parseDateRange(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, -29)
+	var err error
+	if value := r.URL.Query().Get("from"); value != "" {
+		if from, err = time.ParseInLocation("2006-01-02", value, now.Location()); err != nil {
+			return from, to, fmt.Errorf("bad from date %s, expected YYYY-MM-DD", value)
+		}
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		if to, err = time.ParseInLocation("2006-01-02", value, now.Location()); err != nil {
+			return from, to, fmt.Errorf("bad to date %s, expected YYYY-MM-DD", value)
+		}
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("to date is before from date")
+	}
+	if to.Sub(from) > maxAPIRangeDays*24*time.Hour {
+		return from, to, fmt.Errorf("date range is longer than %d days", maxAPIRangeDays)
+	}
+	return from, to, nil
+}
+
+// authorizeAPI reply with an error and return false unless the request comes from a logged in system admin
+func (p *Plugin) authorizeAPI(w http.ResponseWriter, r *http.Request) bool {
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if !p.isSystemAdmin(userID) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// handleAnalyticsChannels serve `GET /api/v1/analytics/channels?from=&to=&sort=&order=&q=&page=&per_page=`
+// a page of channels matching the search, sorted by messages by default
+func (p *Plugin) handleAnalyticsChannels(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	from, to, err := parseDateRange(r, p.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	params, err := parseListParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	totals, err := p.sumDailyBuckets(dailyScopeChannel, from, to)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+
+	policy := p.maskingPolicy("api")
+	salt := p.API.GetDiagnosticId()
+	channels := make([]*APIChannel, 0, len(totals))
+	for channelID, counters := range totals {
+		row := exportRow{ChannelID: channelID, Messages: counters.Messages, Replies: counters.Replies}
+		name, displayName, _, err := p.getChannelName(channelID)
+		if err == nil {
+			row.ChannelName = name
+		}
+		row = policy.apply(row, salt)
+		channel := &APIChannel{ID: row.ChannelID, Name: row.ChannelName, Messages: row.Messages, Replies: row.Replies, FilesSize: counters.FilesSize}
+		if row.ChannelName != "" {
+			channel.DisplayName = displayName
+		}
+		// masked names can't be searched
+		if params.matches(channel.Name, channel.DisplayName) {
+			channels = append(channels, channel)
+		}
+	}
+	channelRow := func(channel *APIChannel) (exportRow, string) {
+		name := channel.DisplayName
+		if name == "" {
+			name = channel.Name
+		}
+		return exportRow{ChannelID: channel.ID, Messages: channel.Messages, Replies: channel.Replies, FilesSize: channel.FilesSize}, name
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		a, aName := channelRow(channels[i])
+		b, bName := channelRow(channels[j])
+		return params.less(a, aName, b, bName)
+	})
+	start, end := params.pageBounds(len(channels))
+	response := &APIResponse{
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Channels: channels[start:end],
+		Total:    len(channels),
+		Page:     params.Page,
+		PerPage:  params.PerPage,
+		Degraded: p.storeDegraded(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+// handleAnalyticsUsers serve `GET /api/v1/analytics/users?from=&to=&sort=&order=&q=&page=&per_page=`
+// a page of users matching the search, sorted by messages by default
+func (p *Plugin) handleAnalyticsUsers(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	from, to, err := parseDateRange(r, p.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	params, err := parseListParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	totals, err := p.sumDailyBuckets(dailyScopeUser, from, to)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+
+	policy := p.maskingPolicy("api")
+	salt := p.API.GetDiagnosticId()
+	users := make([]*APIUser, 0, len(totals))
+	for userID, counters := range totals {
+		row := exportRow{UserID: userID, Messages: counters.Messages, Replies: counters.Replies}
+		if username, err := p.getUsername(userID); err == nil {
+			row.Username = username
+		}
+		row = policy.apply(row, salt)
+		if params.matches(row.Username) {
+			users = append(users, &APIUser{ID: row.UserID, Username: row.Username, Messages: row.Messages, Replies: row.Replies, FilesSize: counters.FilesSize})
+		}
+	}
+	userRow := func(user *APIUser) exportRow {
+		return exportRow{UserID: user.ID, Messages: user.Messages, Replies: user.Replies, FilesSize: user.FilesSize}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return params.less(userRow(users[i]), users[i].Username, userRow(users[j]), users[j].Username)
+	})
+	start, end := params.pageBounds(len(users))
+	response := &APIResponse{
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		Users:   users[start:end],
+		Total:   len(users),
+		Page:    params.Page,
+		PerPage: params.PerPage,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+// apiDays return one day by date between from and to included from users buckets, days without activity are zero
+func apiDays(buckets []dailyBucket, from time.Time, to time.Time) []*APIDay {
+	days := make([]*APIDay, 0)
+	byDate := make(map[string]*APIDay)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		apiDay := &APIDay{Date: day.Format("2006-01-02")}
+		days = append(days, apiDay)
+		byDate[apiDay.Date] = apiDay
+	}
+	for _, bucket := range buckets {
+		apiDay, ok := byDate[bucket.Date.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		apiDay.Messages += bucket.Counters.Messages
+		apiDay.Replies += bucket.Counters.Replies
+		if bucket.Counters.Messages > 0 {
+			apiDay.ActiveUsers++
+		}
+	}
+	return days
+}
+
+// handleAnalyticsDays serve `GET /api/v1/analytics/days?from=&to=`, messages and active users of each day
+func (p *Plugin) handleAnalyticsDays(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	from, to, err := parseDateRange(r, p.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	buckets, err := p.dailyBuckets(dailyScopeUser, from, to)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+
+	response := &APIResponse{From: from.Format("2006-01-02"), To: to.Format("2006-01-02"), Days: apiDays(buckets, from, to), Degraded: p.storeDegraded()}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+// seriesMessages return the number of messages of each day between from and to included from channels buckets
+// of channelID, of all channels if channelID is empty
+func seriesMessages(buckets []dailyBucket, channelID string, from time.Time, to time.Time) []int64 {
+	messages := make([]int64, 0)
+	byDate := make(map[string]int)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		byDate[day.Format("2006-01-02")] = len(messages)
+		messages = append(messages, 0)
+	}
+	for _, bucket := range buckets {
+		index, ok := byDate[bucket.Date.Format("2006-01-02")]
+		if !ok || (channelID != "" && bucket.ID != channelID) {
+			continue
+		}
+		messages[index] += bucket.Counters.Messages
+	}
+	return messages
+}
+
+// handleAnalyticsSeries serve `GET /api/v1/analytics/series?from=&to=&channel=&channel=&compare_from=`
+// messages by day of each channel, of all channels without channel, overlaid with the same days of
+// the period starting at compare_from if set
+func (p *Plugin) handleAnalyticsSeries(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	from, to, err := parseDateRange(r, p.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	type period struct {
+		from time.Time
+		to   time.Time
+	}
+	periods := []period{{from: from, to: to}}
+	if value := r.URL.Query().Get("compare_from"); value != "" {
+		compareFrom, err := time.ParseInLocation("2006-01-02", value, from.Location())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("bad compare_from date %s, expected YYYY-MM-DD", value), http.StatusBadRequest)
+			return nil
+		}
+		periods = append(periods, period{from: compareFrom, to: compareFrom.AddDate(0, 0, int(to.Sub(from).Hours()/24+0.5))})
+	}
+	channelsID := r.URL.Query()["channel"]
+	if len(channelsID) == 0 {
+		channelsID = []string{""}
+	}
+	if len(channelsID)*len(periods) > maxAPISeries {
+		http.Error(w, fmt.Sprintf("more than %d series to compare", maxAPISeries), http.StatusBadRequest)
+		return nil
+	}
+
+	policy := p.maskingPolicy("api")
+	salt := p.API.GetDiagnosticId()
+	names := make(map[string]string, len(channelsID))
+	for _, channelID := range channelsID {
+		if channelID == "" {
+			names[channelID] = "All channels"
+			continue
+		}
+		row := exportRow{ChannelID: channelID}
+		name, displayName, _, err := p.getChannelName(channelID)
+		if err != nil {
+			http.Error(w, "unknown channel "+channelID, http.StatusBadRequest)
+			return nil
+		}
+		row.ChannelName = name
+		row = policy.apply(row, salt)
+		names[channelID] = row.ChannelID
+		if row.ChannelName != "" {
+			names[channelID] = displayName
+		}
+	}
+
+	lineage, err := p.channelLineage()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	response := &APIResponse{From: from.Format("2006-01-02"), To: to.Format("2006-01-02"), Series: make([]*APISeries, 0, len(channelsID)*len(periods))}
+	for _, period := range periods {
+		buckets, err := p.dailyBuckets(dailyScopeChannel, period.from, period.to)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return err
+		}
+		// merged channels are charted as part of the channel they continue in
+		buckets = stitchBuckets(buckets, lineage)
+		for _, channelID := range channelsID {
+			name := names[channelID]
+			if len(periods) > 1 {
+				name = fmt.Sprintf("%s (%s)", name, period.from.Format("2006-01-02"))
+			}
+			response.Series = append(response.Series, &APISeries{
+				Name:     name,
+				From:     period.from.Format("2006-01-02"),
+				To:       period.to.Format("2006-01-02"),
+				Messages: seriesMessages(buckets, channelID, period.from, period.to),
+			})
+		}
+	}
+	response.Degraded = p.storeDegraded()
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDateRange(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+
+	from, to, err := parseDateRange(httptest.NewRequest("GET", "/api/v1/analytics/channels", nil), now)
+	assert.Nil(err)
+	assert.Equal(time.Date(2019, time.March, 23, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(time.Date(2019, time.April, 21, 0, 0, 0, 0, time.UTC), to)
+
+	from, to, err = parseDateRange(httptest.NewRequest("GET", "/api/v1/analytics/channels?from=2019-01-01&to=2019-01-31", nil), now)
+	assert.Nil(err)
+	assert.Equal(time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC), from)
+	assert.Equal(time.Date(2019, time.January, 31, 0, 0, 0, 0, time.UTC), to)
+
+	_, _, err = parseDateRange(httptest.NewRequest("GET", "/api/v1/analytics/channels?from=2019-02-01&to=2019-01-31", nil), now)
+	assert.NotNil(err)
+	_, _, err = parseDateRange(httptest.NewRequest("GET", "/api/v1/analytics/channels?from=2017-01-01", nil), now)
+	assert.NotNil(err)
+	_, _, err = parseDateRange(httptest.NewRequest("GET", "/api/v1/analytics/channels?from=yesterday", nil), now)
+	assert.NotNil(err)
+}
+
+func TestAPIDays(t *testing.T) {
+	assert := assert.New(t)
+	from := time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2019, time.April, 3, 0, 0, 0, 0, time.UTC)
+	buckets := []dailyBucket{
+		{Date: from, ID: "user1", Counters: DailyCounters{Messages: 3, Replies: 1}},
+		{Date: from, ID: "user2", Counters: DailyCounters{Messages: 2}},
+		{Date: to, ID: "user1", Counters: DailyCounters{FilesSize: 10}},
+	}
+
+	days := apiDays(buckets, from, to)
+	assert.Len(days, 3)
+	assert.Equal(&APIDay{Date: "2019-04-01", Messages: 5, Replies: 1, ActiveUsers: 2}, days[0])
+	assert.Equal(&APIDay{Date: "2019-04-02"}, days[1])
+	assert.Equal(&APIDay{Date: "2019-04-03"}, days[2])
+}
+
+func TestSeriesMessages(t *testing.T) {
+	assert := assert.New(t)
+	from := time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2019, time.April, 3, 0, 0, 0, 0, time.UTC)
+	buckets := []dailyBucket{
+		{Date: from, ID: "channel1", Counters: DailyCounters{Messages: 3, Replies: 1}},
+		{Date: from, ID: "channel2", Counters: DailyCounters{Messages: 2}},
+		{Date: to, ID: "channel1", Counters: DailyCounters{Messages: 4}},
+		{Date: to.AddDate(0, 0, 1), ID: "channel1", Counters: DailyCounters{Messages: 7}},
+	}
+
+	assert.Equal([]int64{3, 0, 4}, seriesMessages(buckets, "channel1", from, to))
+	assert.Equal([]int64{5, 0, 4}, seriesMessages(buckets, "", from, to))
+	assert.Equal([]int64{0, 0, 0}, seriesMessages(buckets, "channel3", from, to))
+}
+
+func TestParseListParams(t *testing.T) {
+	assert := assert.New(t)
+
+	params, err := parseListParams(httptest.NewRequest("GET", "/api/v1/analytics/users", nil))
+	assert.Nil(err)
+	assert.Equal(listParams{Sort: "messages", Desc: true, PerPage: defaultAPIPerPage}, params)
+
+	params, err = parseListParams(httptest.NewRequest("GET", "/api/v1/analytics/users?sort=name&q=+Bob+&page=2&per_page=10", nil))
+	assert.Nil(err)
+	assert.Equal(listParams{Sort: "name", Search: "bob", Page: 2, PerPage: 10}, params)
+
+	params, err = parseListParams(httptest.NewRequest("GET", "/api/v1/analytics/users?sort=replies&order=asc", nil))
+	assert.Nil(err)
+	assert.Equal(listParams{Sort: "replies", PerPage: defaultAPIPerPage}, params)
+
+	for _, query := range []string{"sort=words", "order=up", "page=-1", "per_page=0", "per_page=1000"} {
+		_, err = parseListParams(httptest.NewRequest("GET", "/api/v1/analytics/users?"+query, nil))
+		assert.NotNil(err, query)
+	}
+}
+
+func TestListParamsSortAndPage(t *testing.T) {
+	assert := assert.New(t)
+	rows := []exportRow{
+		{UserID: "u1", Username: "carol", Messages: 5},
+		{UserID: "u2", Username: "Alice", Messages: 9},
+		{UserID: "u3", Username: "bob", Messages: 5},
+		{UserID: "u4", Username: "bob", Messages: 5},
+	}
+	sorted := func(params listParams) []string {
+		copied := append([]exportRow{}, rows...)
+		sort.Slice(copied, func(i, j int) bool {
+			return params.less(copied[i], copied[i].Username, copied[j], copied[j].Username)
+		})
+		ids := make([]string, 0, len(copied))
+		for _, row := range copied {
+			ids = append(ids, row.UserID)
+		}
+		return ids
+	}
+
+	assert.Equal([]string{"u2", "u1", "u3", "u4"}, sorted(listParams{Sort: "messages", Desc: true}))
+	assert.Equal([]string{"u3", "u4", "u1", "u2"}, sorted(listParams{Sort: "messages"}))
+	assert.Equal([]string{"u2", "u3", "u4", "u1"}, sorted(listParams{Sort: "name"}))
+
+	params := listParams{Search: "bo"}
+	assert.True(params.matches("carol", "Bob"))
+	assert.False(params.matches("carol"))
+
+	start, end := listParams{Page: 1, PerPage: 3}.pageBounds(4)
+	assert.Equal([]int{3, 4}, []int{start, end})
+	start, end = listParams{Page: 5, PerPage: 3}.pageBounds(4)
+	assert.Equal([]int{4, 4}, []int{start, end})
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// archivedKey store the last day whose daily aggregates were archived in the bucket
+	archivedKey = "archived"
+	// archiveSchemaVersion is the version of the format of daily snapshots
+	archiveSchemaVersion = 1
+	// defaultArchiveRegion is the region signing requests when ArchiveRegion is not set
+	defaultArchiveRegion = "us-east-1"
+	// amzDateFormat is the format of dates in signed requests
+	amzDateFormat = "20060102T150405Z"
+)
+
+// DailySnapshot is the archive of all daily buckets of a day
+type DailySnapshot struct {
+	SchemaVersion int    `json:"schema_version"`
+	Date          string `json:"date"`
+	// Buckets are daily counters by scope then by id, e.g. channels under c and users under u
+	Buckets map[string]map[string]DailyCounters `json:"buckets"`
+}
+
+// dailySnapshots group buckets by day, each day of the range has a snapshot even without bucket
+func dailySnapshots(buckets []dailyBucket, from time.Time, to time.Time) []*DailySnapshot {
+	snapshots := make([]*DailySnapshot, 0)
+	byDate := make(map[string]*DailySnapshot)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		snapshot := &DailySnapshot{SchemaVersion: archiveSchemaVersion, Date: day.Format(dailyKeyFormat), Buckets: make(map[string]map[string]DailyCounters)}
+		snapshots = append(snapshots, snapshot)
+		byDate[snapshot.Date] = snapshot
+	}
+	for _, bucket := range buckets {
+		snapshot, ok := byDate[bucket.Date.Format(dailyKeyFormat)]
+		if !ok {
+			continue
+		}
+		if snapshot.Buckets[bucket.Scope] == nil {
+			snapshot.Buckets[bucket.Scope] = make(map[string]DailyCounters)
+		}
+		snapshot.Buckets[bucket.Scope][bucket.ID] = bucket.Counters
+	}
+	return snapshots
+}
+
+// gzipJSON return the gzipped json of value
+func gzipJSON(value interface{}) ([]byte, error) {
+	var content bytes.Buffer
+	writer := gzip.NewWriter(&content)
+	if err := json.NewEncoder(writer).Encode(value); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return content.Bytes(), nil
+}
+
+// archiveObjectKey return the key of the object of a daily snapshot, e.g. analytics/daily/2020-03-16.json.gz
+func archiveObjectKey(prefix string, date string, extension string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + "daily/" + date + extension
+}
+
+// objectStorageClient put objects in a bucket of an s3 compatible storage, with path style urls
+type objectStorageClient struct {
+	endpoint  string
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// newObjectStorageClient return the client of the configured bucket, nil if ArchiveEndpoint is not set
+func (c *configuration) newObjectStorageClient() *objectStorageClient {
+	if c.ArchiveEndpoint == "" {
+		return nil
+	}
+	region := c.ArchiveRegion
+	if region == "" {
+		region = defaultArchiveRegion
+	}
+	return &objectStorageClient{
+		endpoint:  strings.TrimSuffix(c.ArchiveEndpoint, "/"),
+		bucket:    c.ArchiveBucket,
+		prefix:    c.ArchivePrefix,
+		region:    region,
+		accessKey: c.ArchiveAccessKey,
+		secretKey: c.ArchiveSecretKey,
+		client:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// signingKey derive the aws signature version 4 key of a day, region and service
+func signingKey(secretKey string, date string, region string, service string) []byte {
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	return key
+}
+
+// sign add the aws signature version 4 headers to a request with body, sent at now
+func (c *objectStorageClient) sign(request *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256.Sum256(body)
+	amzDate := now.UTC().Format(amzDateFormat)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	signedHeaders := "content-type;host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		"content-type:" + request.Header.Get("Content-Type"),
+		"host:" + request.URL.Host,
+		"x-amz-content-sha256:" + hex.EncodeToString(payloadHash[:]),
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := amzDate[:8] + "/" + c.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+	mac := hmac.New(sha256.New, signingKey(c.secretKey, amzDate[:8], c.region, "s3"))
+	mac.Write([]byte(stringToSign))
+	request.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+c.accessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+// put store body of contentType as the object key, retried with backoff on network and server errors
+func (c *objectStorageClient) put(key string, body []byte, contentType string, now time.Time) error {
+	delay := deliveryRetryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := c.putOnce(key, body, contentType, now)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == maxDeliveryAttempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// putOnce send a put object request, return true with the error if it may succeed later
+func (c *objectStorageClient) putOnce(key string, body []byte, contentType string, now time.Time) (bool, error) {
+	request, err := http.NewRequest(http.MethodPut, c.endpoint+"/"+c.bucket+"/"+key, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "can't create put object request")
+	}
+	request.Header.Set("Content-Type", contentType)
+	c.sign(request, body, now)
+	response, err := c.client.Do(request)
+	if err != nil {
+		return true, errors.Wrap(err, "can't send put object request")
+	}
+	defer response.Body.Close()
+	content, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500 {
+		return true, errors.Errorf("put object failed, status %d", response.StatusCode)
+	}
+	if response.StatusCode >= 300 {
+		return false, errors.Errorf("put object failed, status %d: %s", response.StatusCode, content)
+	}
+	return false, nil
+}
+
+// archiveObject return the extension, the content and its type of the object of a snapshot in the ArchiveFormat
+// parquet objects have the columns of csv exports, without masking
+func (p *Plugin) archiveObject(snapshot *DailySnapshot, location *time.Location) (string, []byte, string, error) {
+	if p.getConfiguration().ArchiveFormat != exportFormatParquet {
+		body, err := gzipJSON(snapshot)
+		return ".json.gz", body, "application/gzip", err
+	}
+	day, err := time.ParseInLocation(dailyKeyFormat, snapshot.Date, location)
+	if err != nil {
+		return "", nil, "", err
+	}
+	rows, err := p.exportRows(day, day, granularityDay, nil)
+	if err != nil {
+		return "", nil, "", err
+	}
+	var content bytes.Buffer
+	if err := writeExportParquet(&content, rows); err != nil {
+		return "", nil, "", err
+	}
+	return ".parquet", content.Bytes(), parquetContentType, nil
+}
+
+// archiveDailyAggregates write a gzipped json snapshot of all daily buckets of each day since the last archived one
+// until yesterday in the bucket, a day is archived again after a failure
+func (p *Plugin) archiveDailyAggregates(now time.Time) error {
+	client := p.getConfiguration().newObjectStorageClient()
+	if client == nil {
+		return nil
+	}
+	yesterday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -1)
+	from := yesterday
+	archived, appErr := p.API.KVGet(archivedKey)
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't get last archived day")
+	}
+	if last, err := time.ParseInLocation(dailyKeyFormat, string(archived), now.Location()); err == nil {
+		from = last.AddDate(0, 0, 1)
+	}
+	if oldest := yesterday.AddDate(0, 0, 1-maxAPIRangeDays); from.Before(oldest) {
+		from = oldest
+	}
+	if from.After(yesterday) {
+		return nil
+	}
+
+	buckets, err := p.listDailyBuckets(allScopes, from, yesterday)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range dailySnapshots(buckets, from, yesterday) {
+		extension, body, contentType, err := p.archiveObject(snapshot, now.Location())
+		if err != nil {
+			return errors.Wrap(err, "can't write snapshot of "+snapshot.Date)
+		}
+		if err := client.put(archiveObjectKey(client.prefix, snapshot.Date, extension), body, contentType, p.now()); err != nil {
+			return errors.Wrap(err, "can't archive snapshot of "+snapshot.Date)
+		}
+		if appErr := p.API.KVSet(archivedKey, []byte(snapshot.Date)); appErr != nil {
+			return errors.Wrap(appErr, "can't save last archived day")
+		}
+		p.API.LogInfo("daily aggregates archived", "date", snapshot.Date, "bytes", strconv.Itoa(len(body)))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigningKey(t *testing.T) {
+	assert := assert.New(t)
+
+	// example of the aws documentation deriving a signing key
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	assert.Equal("f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestDailySnapshots(t *testing.T) {
+	assert := assert.New(t)
+
+	day := time.Date(2020, time.March, 16, 0, 0, 0, 0, time.UTC)
+	snapshots := dailySnapshots([]dailyBucket{
+		{Date: day, Scope: dailyScopeChannel, ID: "channel1", Counters: DailyCounters{Messages: 10}},
+		{Date: day, Scope: dailyScopeUser, ID: "user1", Counters: DailyCounters{Messages: 4}},
+		{Date: day.AddDate(0, 0, 2), Scope: dailyScopeChannel, ID: "channel1", Counters: DailyCounters{Messages: 3}},
+		{Date: day.AddDate(0, 0, 5), Scope: dailyScopeChannel, ID: "channel1", Counters: DailyCounters{Messages: 1}},
+	}, day, day.AddDate(0, 0, 2))
+
+	assert.Len(snapshots, 3)
+	assert.Equal("2020-03-16", snapshots[0].Date)
+	assert.Equal(archiveSchemaVersion, snapshots[0].SchemaVersion)
+	assert.Equal(map[string]map[string]DailyCounters{
+		dailyScopeChannel: {"channel1": {Messages: 10}},
+		dailyScopeUser:    {"user1": {Messages: 4}},
+	}, snapshots[0].Buckets)
+	assert.Empty(snapshots[1].Buckets)
+	assert.Equal(int64(3), snapshots[2].Buckets[dailyScopeChannel]["channel1"].Messages)
+
+	body, err := gzipJSON(snapshots[0])
+	assert.Nil(err)
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	assert.Nil(err)
+	var decoded DailySnapshot
+	assert.Nil(json.NewDecoder(reader).Decode(&decoded))
+	assert.Equal(*snapshots[0], decoded)
+}
+
+func TestArchiveObjectKey(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("daily/2020-03-16.json.gz", archiveObjectKey("", "2020-03-16", ".json.gz"))
+	assert.Equal("mattermost/analytics/daily/2020-03-16.json.gz", archiveObjectKey("mattermost/analytics", "2020-03-16", ".json.gz"))
+	assert.Equal("mattermost/daily/2020-03-16.parquet", archiveObjectKey("mattermost/", "2020-03-16", ".parquet"))
+}
+
+func TestObjectStoragePut(t *testing.T) {
+	assert := assert.New(t)
+
+	deliveryRetryDelay = 0
+	defer func() { deliveryRetryDelay = 2 * time.Second }()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(http.MethodPut, r.Method)
+		assert.Equal("/analytics/backup/daily/2020-03-16.json.gz", r.URL.Path)
+		assert.Equal("20200317T010203Z", r.Header.Get("X-Amz-Date"))
+		assert.True(strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/20200317/eu-west-1/s3/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, Signature="))
+		body, err := ioutil.ReadAll(r.Body)
+		assert.Nil(err)
+		assert.Equal("snapshot", string(body))
+	}))
+	defer server.Close()
+
+	client := (&configuration{ArchiveEndpoint: server.URL + "/", ArchiveBucket: "analytics", ArchivePrefix: "backup", ArchiveRegion: "eu-west-1", ArchiveAccessKey: "AKID", ArchiveSecretKey: "secret"}).newObjectStorageClient()
+	now := time.Date(2020, time.March, 17, 1, 2, 3, 0, time.UTC)
+	assert.Nil(client.put(archiveObjectKey(client.prefix, "2020-03-16", ".json.gz"), []byte("snapshot"), "application/gzip", now))
+	assert.Equal(2, requests)
+	assert.Nil((&configuration{}).newObjectStorageClient())
+	assert.Equal(defaultArchiveRegion, (&configuration{ArchiveEndpoint: server.URL}).newObjectStorageClient().region)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	arrowContentType = "application/vnd.apache.arrow.stream"
+	// arrowBatchRows is the number of rows of each record batch of arrow streams
+	arrowBatchRows = 64 * 1024
+	// arrowContinuation start each message of arrow streams
+	arrowContinuation = 0xffffffff
+)
+
+// arrow metadata enums, see Schema.fbs and Message.fbs of apache/arrow
+const (
+	arrowMetadataV5        = 4
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+	arrowTypeInt           = 2
+	arrowTypeUtf8          = 5
+	arrowTypeDate          = 8
+	arrowDateDay           = 0
+)
+
+// flatBuilder build a flatbuffer back to front like the reference builders, offsets are counted from the end
+type flatBuilder struct {
+	buf      []byte
+	minAlign int
+	// vtable is the position of the fields of the table being built, 0 for unset fields
+	vtable      []int
+	objectStart int
+}
+
+func (b *flatBuilder) offset() int {
+	return len(b.buf)
+}
+
+func (b *flatBuilder) prepend(raw ...byte) {
+	b.buf = append(append(make([]byte, 0, len(b.buf)+len(raw)), raw...), b.buf...)
+}
+
+// prep pad the buffer so size bytes can be prepended after additional bytes with size alignment
+func (b *flatBuilder) prep(size int, additional int) {
+	if size > b.minAlign {
+		b.minAlign = size
+	}
+	for (len(b.buf)+additional)%size != 0 {
+		b.prepend(0)
+	}
+}
+
+func (b *flatBuilder) uint16(v uint16) {
+	b.prep(2, 0)
+	var raw [2]byte
+	binary.LittleEndian.PutUint16(raw[:], v)
+	b.prepend(raw[:]...)
+}
+
+func (b *flatBuilder) uint32(v uint32) {
+	b.prep(4, 0)
+	var raw [4]byte
+	binary.LittleEndian.PutUint32(raw[:], v)
+	b.prepend(raw[:]...)
+}
+
+func (b *flatBuilder) uint64(v uint64) {
+	b.prep(8, 0)
+	var raw [8]byte
+	binary.LittleEndian.PutUint64(raw[:], v)
+	b.prepend(raw[:]...)
+}
+
+// uoffset prepend the offset to the object at off, which must be already built
+func (b *flatBuilder) uoffset(off int) {
+	b.prep(4, 0)
+	b.uint32(uint32(b.offset() - off + 4))
+}
+
+func (b *flatBuilder) createString(s string) int {
+	b.prep(4, len(s)+1)
+	b.prepend(0)
+	b.prepend([]byte(s)...)
+	b.uint32(uint32(len(s)))
+	return b.offset()
+}
+
+func (b *flatBuilder) createOffsets(offs []int) int {
+	b.prep(4, 4*len(offs))
+	for i := len(offs) - 1; i >= 0; i-- {
+		b.uoffset(offs[i])
+	}
+	b.uint32(uint32(len(offs)))
+	return b.offset()
+}
+
+// createPairs build a vector of structs of two longs, e.g. FieldNode or Buffer
+func (b *flatBuilder) createPairs(pairs [][2]int64) int {
+	b.prep(4, 16*len(pairs))
+	b.prep(8, 16*len(pairs))
+	for i := len(pairs) - 1; i >= 0; i-- {
+		b.uint64(uint64(pairs[i][1]))
+		b.uint64(uint64(pairs[i][0]))
+	}
+	b.uint32(uint32(len(pairs)))
+	return b.offset()
+}
+
+func (b *flatBuilder) startTable(fields int) {
+	b.vtable = make([]int, fields)
+	b.objectStart = b.offset()
+}
+
+func (b *flatBuilder) addUint8(field int, v uint8) {
+	b.prepend(v)
+	b.vtable[field] = b.offset()
+}
+
+func (b *flatBuilder) addInt16(field int, v int16) {
+	b.uint16(uint16(v))
+	b.vtable[field] = b.offset()
+}
+
+func (b *flatBuilder) addInt32(field int, v int32) {
+	b.uint32(uint32(v))
+	b.vtable[field] = b.offset()
+}
+
+func (b *flatBuilder) addInt64(field int, v int64) {
+	b.uint64(uint64(v))
+	b.vtable[field] = b.offset()
+}
+
+func (b *flatBuilder) addOffset(field int, off int) {
+	b.uoffset(off)
+	b.vtable[field] = b.offset()
+}
+
+// endTable write the vtable of the table before it and return the offset of the table
+func (b *flatBuilder) endTable() int {
+	b.uint32(0)
+	objectEnd := b.offset()
+	for i := len(b.vtable) - 1; i >= 0; i-- {
+		position := 0
+		if b.vtable[i] != 0 {
+			position = objectEnd - b.vtable[i]
+		}
+		b.uint16(uint16(position))
+	}
+	b.uint16(uint16(objectEnd - b.objectStart))
+	b.uint16(uint16((len(b.vtable) + 2) * 2))
+	// the table start with the signed offset from its vtable, which is just before it
+	binary.LittleEndian.PutUint32(b.buf[len(b.buf)-objectEnd:], uint32(int32(b.offset()-objectEnd)))
+	return objectEnd
+}
+
+// finish prepend the offset of the root table and return the flatbuffer
+func (b *flatBuilder) finish(root int) []byte {
+	b.prep(b.minAlign, 4)
+	b.uoffset(root)
+	return b.buf
+}
+
+// arrowMessage build a message of header, whose type is kind, followed by a body of bodyLength bytes
+func arrowMessage(b *flatBuilder, kind uint8, header int, bodyLength int64) []byte {
+	b.startTable(4)
+	b.addInt64(3, bodyLength)
+	b.addOffset(2, header)
+	b.addUint8(1, kind)
+	b.addInt16(0, arrowMetadataV5)
+	return b.finish(b.endTable())
+}
+
+// arrowColumn is a column of arrow streams of exports, utf8 columns have a text value and others an int one
+type arrowColumn struct {
+	name string
+	kind uint8
+	// bitWidth is the width of int columns
+	bitWidth int32
+	text     func(row exportRow) string
+	int      func(row exportRow) int64
+}
+
+// exportArrowColumns are the columns of csv exports, dates are days since epoch
+var exportArrowColumns = []arrowColumn{
+	{name: exportHeader[0], kind: arrowTypeDate, int: func(row exportRow) int64 { return int64(parquetDays(row.Date)) }},
+	{name: exportHeader[1], kind: arrowTypeUtf8, text: func(row exportRow) string { return row.ChannelID }},
+	{name: exportHeader[2], kind: arrowTypeUtf8, text: func(row exportRow) string { return row.ChannelName }},
+	{name: exportHeader[3], kind: arrowTypeUtf8, text: func(row exportRow) string { return row.UserID }},
+	{name: exportHeader[4], kind: arrowTypeUtf8, text: func(row exportRow) string { return row.Username }},
+	{name: exportHeader[5], kind: arrowTypeInt, bitWidth: 64, int: func(row exportRow) int64 { return row.Messages }},
+	{name: exportHeader[6], kind: arrowTypeInt, bitWidth: 64, int: func(row exportRow) int64 { return row.Replies }},
+	{name: exportHeader[7], kind: arrowTypeInt, bitWidth: 64, int: func(row exportRow) int64 { return row.FilesSize }},
+}
+
+// arrowSchemaMessage return the metadata of the schema message of columns
+func arrowSchemaMessage(columns []arrowColumn) []byte {
+	b := &flatBuilder{minAlign: 1}
+	fields := make([]int, 0, len(columns))
+	for _, column := range columns {
+		name := b.createString(column.name)
+		b.startTable(2)
+		switch column.kind {
+		case arrowTypeInt:
+			b.addUint8(1, 1)
+			b.addInt32(0, column.bitWidth)
+		case arrowTypeDate:
+			b.addInt16(0, arrowDateDay)
+		}
+		columnType := b.endTable()
+		children := b.createOffsets(nil)
+		b.startTable(6)
+		b.addOffset(5, children)
+		b.addOffset(3, columnType)
+		b.addOffset(0, name)
+		b.addUint8(2, column.kind)
+		b.addUint8(1, 0)
+		fields = append(fields, b.endTable())
+	}
+	vector := b.createOffsets(fields)
+	b.startTable(2)
+	b.addOffset(1, vector)
+	b.addInt16(0, 0)
+	return arrowMessage(b, arrowHeaderSchema, b.endTable(), 0)
+}
+
+// arrowBody is the body of a record batch, its buffers are aligned on 8 bytes
+type arrowBody struct {
+	content bytes.Buffer
+	buffers [][2]int64
+}
+
+func (a *arrowBody) add(buffer []byte) {
+	a.buffers = append(a.buffers, [2]int64{int64(a.content.Len()), int64(len(buffer))})
+	a.content.Write(buffer)
+	for a.content.Len()%8 != 0 {
+		a.content.WriteByte(0)
+	}
+}
+
+// arrowRecordBatch return the metadata and the body of the record batch of rows, without nulls
+func arrowRecordBatch(rows []exportRow) ([]byte, []byte) {
+	body := &arrowBody{}
+	nodes := make([][2]int64, 0, len(exportArrowColumns))
+	for _, column := range exportArrowColumns {
+		nodes = append(nodes, [2]int64{int64(len(rows)), 0})
+		// validity bitmaps are omitted, no value is null
+		body.add(nil)
+		var data bytes.Buffer
+		switch column.kind {
+		case arrowTypeDate:
+			for _, row := range rows {
+				binary.Write(&data, binary.LittleEndian, int32(column.int(row)))
+			}
+		case arrowTypeInt:
+			for _, row := range rows {
+				binary.Write(&data, binary.LittleEndian, column.int(row))
+			}
+		case arrowTypeUtf8:
+			var offsets bytes.Buffer
+			binary.Write(&offsets, binary.LittleEndian, int32(0))
+			for _, row := range rows {
+				data.WriteString(column.text(row))
+				binary.Write(&offsets, binary.LittleEndian, int32(data.Len()))
+			}
+			body.add(offsets.Bytes())
+		}
+		body.add(data.Bytes())
+	}
+
+	b := &flatBuilder{minAlign: 1}
+	buffers := b.createPairs(body.buffers)
+	fieldNodes := b.createPairs(nodes)
+	b.startTable(3)
+	b.addInt64(0, int64(len(rows)))
+	b.addOffset(2, buffers)
+	b.addOffset(1, fieldNodes)
+	return arrowMessage(b, arrowHeaderRecordBatch, b.endTable(), int64(body.content.Len())), body.content.Bytes()
+}
+
+// writeArrowMessage write an encapsulated message, its metadata is padded so the body is aligned on 8 bytes
+func writeArrowMessage(w io.Writer, metadata []byte, body []byte) error {
+	padded := (len(metadata) + 7) / 8 * 8
+	var prefix [8]byte
+	binary.LittleEndian.PutUint32(prefix[:4], arrowContinuation)
+	binary.LittleEndian.PutUint32(prefix[4:], uint32(padded))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(append(metadata, make([]byte, padded-len(metadata))...)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// writeExportArrow write rows as an arrow ipc stream with the columns of csv exports, in batches of arrowBatchRows
+func writeExportArrow(w io.Writer, rows []exportRow) error {
+	if err := writeArrowMessage(w, arrowSchemaMessage(exportArrowColumns), nil); err != nil {
+		return err
+	}
+	for start := 0; start < len(rows); start += arrowBatchRows {
+		end := start + arrowBatchRows
+		if end > len(rows) {
+			end = len(rows)
+		}
+		metadata, body := arrowRecordBatch(rows[start:end])
+		if err := writeArrowMessage(w, metadata, body); err != nil {
+			return err
+		}
+	}
+	var end [8]byte
+	binary.LittleEndian.PutUint32(end[:4], arrowContinuation)
+	_, err := w.Write(end[:])
+	return err
+}
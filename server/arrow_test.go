@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlatBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	b := &flatBuilder{minAlign: 1}
+	b.startTable(2)
+	b.addInt32(1, 42)
+	buf := b.finish(b.endTable())
+	assert.Equal(0, len(buf)%4)
+	root := int(binary.LittleEndian.Uint32(buf))
+	vtable := root - int(int32(binary.LittleEndian.Uint32(buf[root:])))
+	assert.Equal(uint16(8), binary.LittleEndian.Uint16(buf[vtable:]))
+	assert.Equal(uint16(0), binary.LittleEndian.Uint16(buf[vtable+4:]))
+	field := int(binary.LittleEndian.Uint16(buf[vtable+6:]))
+	assert.Equal(uint32(42), binary.LittleEndian.Uint32(buf[root+field:]))
+}
+
+func TestWriteExportArrow(t *testing.T) {
+	assert := assert.New(t)
+
+	var content bytes.Buffer
+	rows := []exportRow{
+		{Date: time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local), ChannelID: "channel1", ChannelName: "town-square", Messages: 3, Replies: 1},
+		{Date: time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local), UserID: "user1", Username: "alice", Messages: 3},
+	}
+	assert.Nil(writeExportArrow(&content, rows))
+	stream := content.Bytes()
+
+	// schema, record batch then end of stream, each message prefixed by the continuation and its metadata size
+	messages := 0
+	for position := 0; ; messages++ {
+		assert.Equal(uint32(arrowContinuation), binary.LittleEndian.Uint32(stream[position:]))
+		size := int(binary.LittleEndian.Uint32(stream[position+4:]))
+		position += 8
+		if size == 0 {
+			assert.Equal(len(stream), position)
+			break
+		}
+		assert.Equal(0, size%8)
+		if messages == 0 {
+			assert.True(bytes.Contains(stream[position:position+size], []byte("files_size")))
+			position += size
+			continue
+		}
+		_, body := arrowRecordBatch(rows)
+		position += size
+		assert.Equal(body, stream[position:position+len(body)])
+		assert.True(bytes.Contains(body, []byte("channel1")))
+		assert.Equal(0, len(body)%8)
+		position += len(body)
+	}
+	assert.Equal(2, messages)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	auditKey        = "audit"
+	maxAuditEntries = 1000
+)
+
+// AuditEntry is a trace of an action made by or on behalf of a user on analytics
+type AuditEntry struct {
+	// Date of the action
+	Date time.Time
+	// Action is a short machine readable name (e.g. consent_accepted)
+	Action string
+	// UserID of the user responsible of this action, empty when done by the plugin itself
+	UserID string
+	// Details give the context of this action (e.g. channel id)
+	Details map[string]string
+}
+
+// audit append an entry to the audit log, keeping only the last maxAuditEntries entries
+func (p *Plugin) audit(action string, userID string, details map[string]string) {
+	p.auditLock.Lock()
+	defer p.auditLock.Unlock()
+
+	entries, err := p.auditEntries()
+	if err != nil {
+		p.API.LogWarn("can't get audit log", "err", err.Error())
+	}
+	entries = append(entries, &AuditEntry{
+		Date:    p.now(),
+		Action:  action,
+		UserID:  userID,
+		Details: details,
+	})
+	if len(entries) > maxAuditEntries {
+		entries = entries[len(entries)-maxAuditEntries:]
+	}
+
+	j, err := json.Marshal(entries)
+	if err != nil {
+		p.API.LogError("can't marshal audit log", "err", err.Error())
+		return
+	}
+	if err := p.API.KVSet(auditKey, j); err != nil {
+		p.API.LogError("can't save audit log", "err", err.Error())
+	}
+}
+
+// auditEntries return all entries of the audit log, oldest first
+func (p *Plugin) auditEntries() ([]*AuditEntry, error) {
+	entries := make([]*AuditEntry, 0)
+	j, appErr := p.API.KVGet(auditKey)
+	if appErr != nil {
+		return entries, errors.Wrap(appErr, "can't get audit log from kv")
+	}
+	if j == nil {
+		return entries, nil
+	}
+	if err := json.Unmarshal(j, &entries); err != nil {
+		return make([]*AuditEntry, 0), errors.Wrap(err, "can't unmarshal audit log")
+	}
+	return entries, nil
+}
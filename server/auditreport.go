@@ -0,0 +1,368 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// auditReportSecretKey store the secret signing audit reports
+	auditReportSecretKey = "audit_report_secret"
+	// maxAuditReportSize is the maximum size of audit reports uploaded for verification
+	maxAuditReportSize = 50 * 1000 * 1000
+
+	auditReportActivityFile  = "activity.csv"
+	auditReportTrailFile     = "audit_trail.json"
+	auditReportManifestFile  = "manifest.json"
+	auditReportSignatureFile = "manifest.sig"
+
+	auditScopeAll     = "all"
+	auditScopeTeam    = "team"
+	auditScopeChannel = "channel"
+	auditScopeUser    = "user"
+)
+
+// AuditScope is the scope of an audit report, all analytics or those of a team, a channel or a user
+type AuditScope struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// channelsID are the channels of a team scope
+	channelsID map[string]bool
+}
+
+// includeChannel return true if the counters of channelID are in the scope
+func (s *AuditScope) includeChannel(channelID string) bool {
+	switch s.Kind {
+	case auditScopeAll:
+		return true
+	case auditScopeTeam:
+		return s.channelsID[channelID]
+	case auditScopeChannel:
+		return s.ID == channelID
+	default:
+		return false
+	}
+}
+
+// includeUser return true if the counters of userID are in the scope, channels of a user can't be told apart in daily
+// buckets so only the all and user scopes include users
+func (s *AuditScope) includeUser(userID string) bool {
+	return s.Kind == auditScopeAll || (s.Kind == auditScopeUser && s.ID == userID)
+}
+
+// includeEntry return true if the audit entry is about the scope
+func (s *AuditScope) includeEntry(entry *AuditEntry) bool {
+	if s.Kind == auditScopeAll {
+		return true
+	}
+	if s.includeUser(entry.UserID) || s.includeUser(entry.Details["user_id"]) {
+		return true
+	}
+	return entry.Details["channel_id"] != "" && s.includeChannel(entry.Details["channel_id"])
+}
+
+// AuditReportManifest describe an audit report, its files checksums are signed with the secret of the plugin
+type AuditReportManifest struct {
+	ExportManifest
+	Scope       AuditScope `json:"scope"`
+	Reference   string     `json:"reference"`
+	RequestedBy string     `json:"requested_by"`
+}
+
+// auditReportRows return the daily rows of the buckets in the scope, sorted by date then channels then users
+func auditReportRows(channelBuckets []dailyBucket, userBuckets []dailyBucket, scope *AuditScope) []exportRow {
+	rows := make([]exportRow, 0)
+	for _, bucket := range channelBuckets {
+		if scope.includeChannel(bucket.ID) {
+			rows = append(rows, exportRow{Date: bucket.Date, ChannelID: bucket.ID, Messages: bucket.Counters.Messages, Replies: bucket.Counters.Replies, FilesSize: bucket.Counters.FilesSize})
+		}
+	}
+	for _, bucket := range userBuckets {
+		if scope.includeUser(bucket.ID) {
+			rows = append(rows, exportRow{Date: bucket.Date, UserID: bucket.ID, Messages: bucket.Counters.Messages, Replies: bucket.Counters.Replies, FilesSize: bucket.Counters.FilesSize})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Date.Equal(rows[j].Date) {
+			return rows[i].Date.Before(rows[j].Date)
+		}
+		if rows[i].ChannelID != rows[j].ChannelID {
+			return rows[i].ChannelID > rows[j].ChannelID
+		}
+		return rows[i].UserID < rows[j].UserID
+	})
+	return rows
+}
+
+// auditTrail return the entries of the audit log about the scope between from and to included
+func auditTrail(entries []*AuditEntry, from time.Time, to time.Time, scope *AuditScope) []*AuditEntry {
+	trail := make([]*AuditEntry, 0)
+	end := to.AddDate(0, 0, 1)
+	for _, entry := range entries {
+		if !entry.Date.Before(from) && entry.Date.Before(end) && scope.includeEntry(entry) {
+			trail = append(trail, entry)
+		}
+	}
+	return trail
+}
+
+// auditReportSignature return the signature of the content of a manifest
+func auditReportSignature(secret string, manifestContent []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(manifestContent)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyAuditReport check the signature of the manifest of a zipped audit report and the checksums of its files
+func verifyAuditReport(secret string, content []byte) error {
+	reader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return errors.Wrap(err, "not a zip archive")
+	}
+	files := make(map[string][]byte)
+	for _, file := range reader.File {
+		opened, err := file.Open()
+		if err != nil {
+			return errors.Wrap(err, "can't open "+file.Name)
+		}
+		files[file.Name], err = ioutil.ReadAll(opened)
+		opened.Close()
+		if err != nil {
+			return errors.Wrap(err, "can't read "+file.Name)
+		}
+	}
+	manifestContent, ok := files[auditReportManifestFile]
+	if !ok {
+		return errors.New("manifest is missing")
+	}
+	signature := strings.TrimSpace(string(files[auditReportSignatureFile]))
+	if !hmac.Equal([]byte(auditReportSignature(secret, manifestContent)), []byte(signature)) {
+		return errors.New("manifest signature is invalid")
+	}
+	var reportManifest AuditReportManifest
+	if err := json.Unmarshal(manifestContent, &reportManifest); err != nil {
+		return errors.Wrap(err, "can't unmarshal manifest")
+	}
+	for _, file := range reportManifest.Files {
+		sum := sha256.Sum256(files[file.Name])
+		if hex.EncodeToString(sum[:]) != file.SHA256 {
+			return fmt.Errorf("checksum of %s doesn't match the manifest", file.Name)
+		}
+	}
+	return nil
+}
+
+// parseAuditScope resolve the scope of an audit report: all, a team name, ~channel of the team of teamID or @user
+func (p *Plugin) parseAuditScope(value string, teamID string) (*AuditScope, error) {
+	switch {
+	case value == auditScopeAll:
+		return &AuditScope{Kind: auditScopeAll}, nil
+	case strings.HasPrefix(value, "~"):
+		channel, appErr := p.API.GetChannelByName(teamID, strings.TrimPrefix(value, "~"), true)
+		if appErr != nil {
+			return nil, fmt.Errorf("unknown channel %s", value)
+		}
+		return &AuditScope{Kind: auditScopeChannel, ID: channel.Id, Name: channel.Name}, nil
+	case strings.HasPrefix(value, "@"):
+		user, appErr := p.API.GetUserByUsername(strings.TrimPrefix(value, "@"))
+		if appErr != nil {
+			return nil, fmt.Errorf("unknown user %s", value)
+		}
+		return &AuditScope{Kind: auditScopeUser, ID: user.Id, Name: user.Username}, nil
+	default:
+		team, appErr := p.API.GetTeamByName(value)
+		if appErr != nil {
+			return nil, fmt.Errorf("unknown team %s", value)
+		}
+		return &AuditScope{Kind: auditScopeTeam, ID: team.Id, Name: team.Name, channelsID: make(map[string]bool)}, nil
+	}
+}
+
+// buildAuditReport return the zipped audit report of the scope between from and to included: daily activity as csv,
+// the audit trail, the manifest and its signature
+func (p *Plugin) buildAuditReport(from time.Time, to time.Time, scope *AuditScope, reference string, userID string) ([]byte, error) {
+	channelBuckets, err := p.dailyBuckets(dailyScopeChannel, from, to)
+	if err != nil {
+		return nil, err
+	}
+	userBuckets, err := p.dailyBuckets(dailyScopeUser, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if scope.Kind == auditScopeTeam {
+		// channels of the team, private ones included, are found by their buckets
+		for _, bucket := range channelBuckets {
+			if teamID, err := p.getChannelTeamID(bucket.ID); err == nil && teamID == scope.ID {
+				scope.channelsID[bucket.ID] = true
+			}
+		}
+	}
+	policy := p.maskingPolicy("csv")
+	salt := p.API.GetDiagnosticId()
+	rows := auditReportRows(channelBuckets, userBuckets, scope)
+	for index, row := range rows {
+		if row.ChannelID != "" {
+			row.ChannelName, _, _, _ = p.getChannelName(row.ChannelID)
+		}
+		if row.UserID != "" {
+			row.Username, _ = p.getUsername(row.UserID)
+		}
+		rows[index] = policy.apply(row, salt)
+	}
+	var activity bytes.Buffer
+	if err := writeExportCSV(&activity, rows); err != nil {
+		return nil, errors.Wrap(err, "can't write csv")
+	}
+
+	entries, err := p.auditEntries()
+	if err != nil {
+		return nil, err
+	}
+	trail := auditTrail(entries, from, to, scope)
+	trailContent, err := json.MarshalIndent(trail, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "can't marshal audit trail")
+	}
+
+	reportManifest := &AuditReportManifest{
+		ExportManifest: *newExportManifest(from, to, granularityDay, p.now()),
+		Scope:          *scope,
+		Reference:      reference,
+		RequestedBy:    userID,
+	}
+	reportManifest.addFile(auditReportActivityFile, len(rows), activity.Bytes())
+	reportManifest.addFile(auditReportTrailFile, len(trail), trailContent)
+	manifestContent, err := json.MarshalIndent(reportManifest, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "can't marshal audit report manifest")
+	}
+	secret, err := p.signingSecret(auditReportSecretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var content bytes.Buffer
+	archive := zip.NewWriter(&content)
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{auditReportActivityFile, activity.Bytes()},
+		{auditReportTrailFile, trailContent},
+		{auditReportManifestFile, manifestContent},
+		{auditReportSignatureFile, []byte(auditReportSignature(secret, manifestContent) + "\n")},
+	} {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't add "+file.name+" to audit report")
+		}
+		if _, err := writer.Write(file.content); err != nil {
+			return nil, errors.Wrap(err, "can't write "+file.name+" to audit report")
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, errors.Wrap(err, "can't close audit report")
+	}
+	return content.Bytes(), nil
+}
+
+// executeAuditReportCommand handle `/analytics audit-report <from> <to> <all|team|~channel|@user> <reference>`, the
+// signed report is sent by DM to the admin, the reference of the authorizing case is recorded in the audit log
+func (p *Plugin) executeAuditReportCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can generate audit reports.")
+	}
+	if len(parameters) < 4 {
+		return ephemeralResponse("Usage: /analytics audit-report <from YYYY-MM-DD> <to YYYY-MM-DD> <all|team|~channel|@user> <case reference>")
+	}
+	now := p.now()
+	from, err := time.ParseInLocation("2006-01-02", parameters[0], now.Location())
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("Bad from date %s, expected YYYY-MM-DD.", parameters[0]))
+	}
+	to, err := time.ParseInLocation("2006-01-02", parameters[1], now.Location())
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("Bad to date %s, expected YYYY-MM-DD.", parameters[1]))
+	}
+	if to.Before(from) || to.After(now) {
+		return ephemeralResponse("The to date must be after the from date and not in the future.")
+	}
+	scope, err := p.parseAuditScope(parameters[2], args.TeamId)
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("Bad scope: %s.", err.Error()))
+	}
+	reference := strings.Join(parameters[3:], " ")
+
+	content, err := p.buildAuditReport(from, to, scope, reference, args.UserId)
+	if err != nil {
+		p.API.LogError("can't build audit report", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	channel, appErr := p.API.GetDirectChannel(p.BotUserID, args.UserId)
+	if appErr != nil {
+		p.API.LogError("can't get direct channel", "err", appErr.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	filename := fmt.Sprintf("audit-report-%s-%s-%s.zip", scope.Kind, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	fileInfo, appErr := p.API.UploadFile(content, channel.Id, filename)
+	if appErr != nil {
+		p.API.LogError("can't upload audit report", "err", appErr.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	message := fmt.Sprintf("Audit report of %s from %s to %s for %s. Its manifest is signed, upload the archive to `/plugins/%s/api/v1/audit-report/verify` to check it was not altered.",
+		scope.Kind+" "+scope.Name, from.Format("January 2, 2006"), to.Format("January 2, 2006"), reference, manifest.Id)
+	if _, appErr := p.API.CreatePost(&model.Post{UserId: p.BotUserID, ChannelId: channel.Id, Message: message, FileIds: []string{fileInfo.Id}}); appErr != nil {
+		p.API.LogError("can't post audit report", "err", appErr.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	p.audit("audit_report_generated", args.UserId, map[string]string{
+		"from":      parameters[0],
+		"to":        parameters[1],
+		"scope":     scope.Kind,
+		"scope_id":  scope.ID,
+		"reference": reference,
+	})
+	return ephemeralResponse("The audit report was sent to you by direct message.")
+}
+
+// handleAuditReportVerify serve `POST /api/v1/audit-report/verify` with a zipped audit report as body, it replies
+// whether the report was generated by this server and not altered since
+func (p *Plugin) handleAuditReportVerify(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	content, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxAuditReportSize))
+	if err != nil {
+		http.Error(w, "audit report is too large", http.StatusRequestEntityTooLarge)
+		return nil
+	}
+	secret, err := p.signingSecret(auditReportSecretKey)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	result := map[string]interface{}{"valid": true}
+	if err := verifyAuditReport(secret, content); err != nil {
+		result = map[string]interface{}{"valid": false, "error": err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
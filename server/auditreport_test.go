@@ -0,0 +1,92 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditReportRows(t *testing.T) {
+	assert := assert.New(t)
+
+	day1 := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	channelBuckets := []dailyBucket{
+		{Date: day2, ID: "channel1", Counters: DailyCounters{Messages: 2}},
+		{Date: day1, ID: "channel2", Counters: DailyCounters{Messages: 3}},
+		{Date: day1, ID: "channel1", Counters: DailyCounters{Messages: 1}},
+	}
+	userBuckets := []dailyBucket{
+		{Date: day1, ID: "user1", Counters: DailyCounters{Messages: 4}},
+		{Date: day2, ID: "user2", Counters: DailyCounters{Messages: 2}},
+	}
+
+	rows := auditReportRows(channelBuckets, userBuckets, &AuditScope{Kind: auditScopeAll})
+	assert.Len(rows, 5)
+	assert.Equal("channel2", rows[0].ChannelID)
+	assert.Equal("user1", rows[2].UserID)
+
+	rows = auditReportRows(channelBuckets, userBuckets, &AuditScope{Kind: auditScopeChannel, ID: "channel1"})
+	assert.Equal([]exportRow{
+		{Date: day1, ChannelID: "channel1", Messages: 1},
+		{Date: day2, ChannelID: "channel1", Messages: 2},
+	}, rows)
+
+	rows = auditReportRows(channelBuckets, userBuckets, &AuditScope{Kind: auditScopeUser, ID: "user2"})
+	assert.Equal([]exportRow{{Date: day2, UserID: "user2", Messages: 2}}, rows)
+
+	rows = auditReportRows(channelBuckets, userBuckets, &AuditScope{Kind: auditScopeTeam, channelsID: map[string]bool{"channel2": true}})
+	assert.Equal([]exportRow{{Date: day1, ChannelID: "channel2", Messages: 3}}, rows)
+}
+
+func TestAuditTrail(t *testing.T) {
+	assert := assert.New(t)
+
+	from := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC)
+	before := &AuditEntry{Date: from.Add(-time.Hour), Action: "consent_accepted", UserID: "user1", Details: map[string]string{"channel_id": "channel1"}}
+	consent := &AuditEntry{Date: from.Add(time.Hour), Action: "consent_accepted", UserID: "user1", Details: map[string]string{"channel_id": "channel1"}}
+	forget := &AuditEntry{Date: to.Add(23 * time.Hour), Action: "user_forgotten", UserID: "admin", Details: map[string]string{"user_id": "user2"}}
+	after := &AuditEntry{Date: to.AddDate(0, 0, 1), Action: "csv_exported", UserID: "admin"}
+	entries := []*AuditEntry{before, consent, forget, after}
+
+	assert.Equal([]*AuditEntry{consent, forget}, auditTrail(entries, from, to, &AuditScope{Kind: auditScopeAll}))
+	assert.Equal([]*AuditEntry{consent}, auditTrail(entries, from, to, &AuditScope{Kind: auditScopeChannel, ID: "channel1"}))
+	assert.Equal([]*AuditEntry{forget}, auditTrail(entries, from, to, &AuditScope{Kind: auditScopeUser, ID: "user2"}))
+	assert.Empty(auditTrail(entries, from, to, &AuditScope{Kind: auditScopeTeam, channelsID: map[string]bool{}}))
+}
+
+func TestVerifyAuditReport(t *testing.T) {
+	assert := assert.New(t)
+
+	build := func(activity string, signature string) []byte {
+		reportManifest := &AuditReportManifest{ExportManifest: *newExportManifest(time.Now(), time.Now(), granularityDay, time.Now())}
+		reportManifest.addFile(auditReportActivityFile, 1, []byte("date\n2020-03-01\n"))
+		manifestContent, _ := json.Marshal(reportManifest)
+		if signature == "" {
+			signature = auditReportSignature("secret", manifestContent)
+		}
+		var content bytes.Buffer
+		archive := zip.NewWriter(&content)
+		for name, file := range map[string]string{
+			auditReportActivityFile:  activity,
+			auditReportManifestFile:  string(manifestContent),
+			auditReportSignatureFile: signature + "\n",
+		} {
+			writer, _ := archive.Create(name)
+			writer.Write([]byte(file))
+		}
+		archive.Close()
+		return content.Bytes()
+	}
+
+	assert.NoError(verifyAuditReport("secret", build("date\n2020-03-01\n", "")))
+	assert.Error(verifyAuditReport("other", build("date\n2020-03-01\n", "")))
+	assert.Error(verifyAuditReport("secret", build("date\n2020-03-02\n", "")))
+	assert.Error(verifyAuditReport("secret", build("date\n2020-03-01\n", "forged")))
+	assert.Error(verifyAuditReport("secret", []byte("not a zip")))
+}
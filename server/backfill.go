@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxBackfillDays  = 365
+	backfillPageSize = 200
+	backfillWorkers  = 4
+	// backfillRequestsPerSecond limit the load of all workers on the server
+	backfillRequestsPerSecond = 20
+)
+
+// BackfillProgress is the state of the running backfill
+type BackfillProgress struct {
+	UserID        string
+	Days          int
+	StartedAt     time.Time
+	TotalChannels int
+	DoneChannels  int
+	Posts         int
+}
+
+// percent return the percentage of channels already walked
+func (b BackfillProgress) percent() int {
+	if b.TotalChannels == 0 {
+		return 0
+	}
+	return b.DoneChannels * 100 / b.TotalChannels
+}
+
+// eta return the estimated remaining time from the average time spent by channel
+func (b BackfillProgress) eta(now time.Time) time.Duration {
+	if b.DoneChannels == 0 {
+		return 0
+	}
+	elapsed := now.Sub(b.StartedAt)
+	return (elapsed / time.Duration(b.DoneChannels) * time.Duration(b.TotalChannels-b.DoneChannels)).Round(time.Second)
+}
+
+// describe return a humanized progress at now, e.g. 42% (21/50 channels), about 3m0s left
+func (b BackfillProgress) describe(now time.Time) string {
+	text := fmt.Sprintf("%d%% (%d/%d channels, %d posts)", b.percent(), b.DoneChannels, b.TotalChannels, b.Posts)
+	if b.DoneChannels > 0 {
+		text += fmt.Sprintf(", about %s left", b.eta(now))
+	}
+	return text
+}
+
+// backfillProgress return a copy of the running backfill progress, nil if no backfill is running
+func (p *Plugin) backfillProgress() *BackfillProgress {
+	p.backfillLock.Lock()
+	defer p.backfillLock.Unlock()
+	if p.backfillRunning == nil {
+		return nil
+	}
+	progress := *p.backfillRunning
+	return &progress
+}
+
+// backfillBuckets are daily counters rebuilt from history, by daily key
+type backfillBuckets map[string]*DailyCounters
+
+func (b backfillBuckets) add(date time.Time, scope string, id string, delta DailyCounters) {
+	key := dailyKey(date, scope, id)
+	if _, ok := b[key]; !ok {
+		b[key] = &DailyCounters{}
+	}
+	b[key].add(delta)
+}
+
+// backfillChannelsID return public channels of all teams where analytics are collected with the consent of their
+// admins
+func (p *Plugin) backfillChannelsID() ([]string, error) {
+	channelsID, err := p.collectedPublicChannelsID()
+	if err != nil {
+		return nil, err
+	}
+	consented := make([]string, 0, len(channelsID))
+	for _, channelID := range channelsID {
+		if p.hasRecordedConsent(channelID) {
+			consented = append(consented, channelID)
+		}
+	}
+	return consented, nil
+}
+
+// collectedPublicChannelsID return public channels of all teams where analytics are collected
+func (p *Plugin) collectedPublicChannelsID() ([]string, error) {
+	teams, appErr := p.API.GetTeams()
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "can't get teams")
+	}
+	channelsID := make([]string, 0)
+	for _, team := range teams {
+		for page := 0; ; page++ {
+			channels, appErr := p.API.GetPublicChannelsForTeam(team.Id, page, backfillPageSize)
+			if appErr != nil {
+				return nil, errors.Wrap(appErr, "can't get channels of team "+team.Name)
+			}
+			for _, channel := range channels {
+				if p.isChannelCollected(channel.Id) && p.isChannelEnabled(channel.Id) {
+					channelsID = append(channelsID, channel.Id)
+				}
+			}
+			if len(channels) < backfillPageSize {
+				break
+			}
+		}
+	}
+	return channelsID, nil
+}
+
+// backfillChannel walk history of a channel from newest to oldest post and add posts created in [from, to) to buckets
+func (p *Plugin) backfillChannel(channelID string, from time.Time, to time.Time, buckets backfillBuckets, limiter <-chan time.Time) (int, error) {
+	nbPosts := 0
+	config := p.getConfiguration()
+	anonymous := config.AnonymousMode
+	for page := 0; ; page++ {
+		<-limiter
+		postList, appErr := p.API.GetPostsForChannel(channelID, page, backfillPageSize)
+		if appErr != nil {
+			return nbPosts, errors.Wrap(appErr, "can't get posts of channel "+channelID)
+		}
+		for _, postID := range postList.Order {
+			post := postList.Posts[postID]
+			createdAt := time.Unix(0, post.CreateAt*int64(time.Millisecond)).In(from.Location())
+			if createdAt.Before(from) {
+				return nbPosts, nil
+			}
+			if !createdAt.Before(to) || post.DeleteAt != 0 || automatedOutcome(post, p.isBot, config.IgnoreBots, config.IgnoreWebhooks) != "" {
+				continue
+			}
+			delta := DailyCounters{Messages: 1}
+			if post.RootId != "" {
+				delta.Replies = 1
+			}
+			for _, fileID := range post.FileIds {
+				<-limiter
+				if info, appErr := p.API.GetFileInfo(fileID); appErr == nil {
+					delta.FilesSize += info.Size
+				}
+			}
+			buckets.add(createdAt, dailyScopeChannel, post.ChannelId, delta)
+			if !anonymous {
+				buckets.add(createdAt, dailyScopeUser, post.UserId, delta)
+			}
+			nbPosts++
+		}
+		if len(postList.Order) < backfillPageSize {
+			return nbPosts, nil
+		}
+	}
+}
+
+// backfilledCounters return the counters of a stored bucket rebuilt from walked, the counters of posts found in the
+// history of walked channels, buckets of walked channels take the walked counters, buckets of users keep the highest of
+// both counters as they also count posts of private channels, which are not walked, so running it twice doesn't count
+// posts twice
+func backfilledCounters(scope string, stored DailyCounters, walked DailyCounters) DailyCounters {
+	rebuilt := stored
+	rebuilt.Messages, rebuilt.Replies, rebuilt.FilesSize = walked.Messages, walked.Replies, walked.FilesSize
+	if scope != dailyScopeUser {
+		return rebuilt
+	}
+	if stored.Messages > walked.Messages {
+		rebuilt.Messages = stored.Messages
+	}
+	if stored.Replies > walked.Replies {
+		rebuilt.Replies = stored.Replies
+	}
+	if stored.FilesSize > walked.FilesSize {
+		rebuilt.FilesSize = stored.FilesSize
+	}
+	return rebuilt
+}
+
+// backfill rebuild daily buckets of the last days before today from channels history
+// channels are walked by parallel workers, buckets of walked channels are overwritten and buckets of users merged, see
+// backfilledCounters
+func (p *Plugin) backfill(days int, now time.Time) (int, error) {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, -days)
+
+	channelsID, err := p.backfillChannelsID()
+	if err != nil {
+		return 0, err
+	}
+	p.backfillLock.Lock()
+	p.backfillRunning.TotalChannels = len(channelsID)
+	p.backfillLock.Unlock()
+
+	limiter := time.NewTicker(time.Second / backfillRequestsPerSecond)
+	defer limiter.Stop()
+	channels := make(chan string)
+	errs := make(chan error, backfillWorkers)
+	workersBuckets := make([]backfillBuckets, backfillWorkers)
+	var wg sync.WaitGroup
+	for i := range workersBuckets {
+		buckets := make(backfillBuckets)
+		workersBuckets[i] = buckets
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for channelID := range channels {
+				nb, err := p.backfillChannel(channelID, from, to, buckets, limiter.C)
+				if err != nil {
+					errs <- err
+					return
+				}
+				p.backfillLock.Lock()
+				p.backfillRunning.DoneChannels++
+				p.backfillRunning.Posts += nb
+				p.backfillLock.Unlock()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+send:
+	for _, channelID := range channelsID {
+		select {
+		case channels <- channelID:
+		case err = <-errs:
+			break send
+		}
+	}
+	close(channels)
+	<-done
+	if err == nil && len(errs) > 0 {
+		err = <-errs
+	}
+	progress := p.backfillProgress()
+	if err != nil {
+		return progress.Posts, err
+	}
+
+	for _, buckets := range workersBuckets[1:] {
+		for key, counters := range buckets {
+			if _, ok := workersBuckets[0][key]; !ok {
+				workersBuckets[0][key] = &DailyCounters{}
+			}
+			workersBuckets[0][key].add(*counters)
+		}
+	}
+	// buffered increments are written first so they are not added on top of the rebuilt buckets
+	if err := p.flushWriteBuffer(); err != nil {
+		return progress.Posts, errors.Wrap(err, "can't flush write buffer before writing backfilled buckets")
+	}
+	stamp := changeStamp(p.now())
+	location := p.getConfiguration().getLocation()
+	for key, counters := range workersBuckets[0] {
+		bucket, ok := parseDailyKey(key, location)
+		if !ok {
+			continue
+		}
+		stored, err := p.store().get(bucket.Date, bucket.Scope, bucket.ID)
+		if err != nil {
+			return progress.Posts, err
+		}
+		bucket.Counters = backfilledCounters(bucket.Scope, stored, *counters)
+		bucket.Counters.UpdatedAt = stamp
+		if err := p.store().set(bucket); err != nil {
+			return progress.Posts, err
+		}
+	}
+	for _, channelID := range channelsID {
+		if err := p.markTracked(channelID, from); err != nil {
+			return progress.Posts, err
+		}
+	}
+	return progress.Posts, nil
+}
+
+// executeBackfillCommand handle `/analytics backfill <days>`, rebuilding daily analytics in background
+func (p *Plugin) executeBackfillCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can backfill analytics.")
+	}
+	if len(parameters) != 1 {
+		return ephemeralResponse("Usage: /analytics backfill <days>")
+	}
+	days, err := strconv.Atoi(parameters[0])
+	if err != nil || days < 1 || days > maxBackfillDays {
+		return ephemeralResponse(fmt.Sprintf("Bad number of days %s, expected between 1 and %d.", parameters[0], maxBackfillDays))
+	}
+
+	p.backfillLock.Lock()
+	if p.backfillRunning != nil {
+		progress := *p.backfillRunning
+		p.backfillLock.Unlock()
+		return ephemeralResponse("A backfill is already running: " + progress.describe(p.now()) + ".")
+	}
+	p.backfillRunning = &BackfillProgress{UserID: args.UserId, Days: days, StartedAt: p.now()}
+	p.backfillLock.Unlock()
+
+	p.audit("backfill_started", args.UserId, map[string]string{"days": parameters[0]})
+	go func() {
+		nbPosts, err := p.backfill(days, p.now())
+		p.backfillLock.Lock()
+		p.backfillRunning = nil
+		p.backfillLock.Unlock()
+
+		message := fmt.Sprintf("Backfill of the last %d days is done, %d posts were counted.", days, nbPosts)
+		if err != nil {
+			p.API.LogError("can't backfill analytics", "err", err.Error())
+			message = fmt.Sprintf("Backfill of the last %d days failed after %d posts: %s", days, nbPosts, err.Error())
+		} else {
+			p.API.LogInfo("analytics backfilled", "days", parameters[0], "posts", strconv.Itoa(nbPosts))
+		}
+		if err := p.sendDirectMessage(args.UserId, message, nil); err != nil {
+			p.API.LogWarn("can't notify backfill completion", "user", args.UserId, "err", err.Error())
+		}
+	}()
+	return ephemeralResponse(fmt.Sprintf("Backfill of the last %d days of public channels started, daily analytics are rebuilt in background. Follow the progress with `/analytics status`, you will receive a direct message when it's done.", days))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackfillProgressDescribe(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2020, 3, 16, 10, 0, 0, 0, time.UTC)
+	progress := BackfillProgress{StartedAt: start, TotalChannels: 50, Posts: 0}
+	assert.Equal("0% (0/50 channels, 0 posts)", progress.describe(start.Add(time.Minute)))
+
+	progress.DoneChannels = 21
+	progress.Posts = 420
+	assert.Equal("42% (21/50 channels, 420 posts), about 2m18s left", progress.describe(start.Add(100*time.Second)))
+}
+
+func TestBackfilledCounters(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		name     string
+		scope    string
+		stored   DailyCounters
+		walked   DailyCounters
+		expected DailyCounters
+	}{
+		{"channel", dailyScopeChannel, DailyCounters{Messages: 3, Replies: 1, FilesSize: 10, UpdatedAt: 1}, DailyCounters{Messages: 5, Replies: 2}, DailyCounters{Messages: 5, Replies: 2, UpdatedAt: 1}},
+		{"channel counted twice", dailyScopeChannel, DailyCounters{Messages: 10, Replies: 4}, DailyCounters{Messages: 5, Replies: 2}, DailyCounters{Messages: 5, Replies: 2}},
+		{"user with private posts", dailyScopeUser, DailyCounters{Messages: 8, Replies: 1, FilesSize: 10}, DailyCounters{Messages: 5, Replies: 2}, DailyCounters{Messages: 8, Replies: 2, FilesSize: 10}},
+		{"user not tracked", dailyScopeUser, DailyCounters{}, DailyCounters{Messages: 5, Replies: 2, FilesSize: 3}, DailyCounters{Messages: 5, Replies: 2, FilesSize: 3}},
+	} {
+		assert.Equal(test.expected, backfilledCounters(test.scope, test.stored, test.walked), test.name)
+	}
+}
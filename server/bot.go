@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	botUsername = "analytics"
+
+	// botIconTimeout bound the download of the bot icon, which holds the configuration change
+	botIconTimeout = 10 * time.Second
+	// maxBotIconSize is the maximum size in bytes of the downloaded bot icon
+	maxBotIconSize = 5 * 1000 * 1000
+)
+
+// botProfile is the profile of the bot set from the configuration
+type botProfile struct {
+	DisplayName string
+	IconURL     string
+}
+
+// ensureBot create the bot account of this plugin if needed and return its id
+func (p *Plugin) ensureBot() (string, error) {
+	botID, err := p.Helpers.EnsureBot(&model.Bot{
+		Username:    botUsername,
+		DisplayName: p.getConfiguration().BotUsername,
+		Description: "Created by the Analytics plugin.",
+	})
+	if err != nil {
+		return "", errors.Wrap(err, "can't ensure bot")
+	}
+	return botID, nil
+}
+
+// applyBotProfile set the display name and the icon of the bot from the configuration, each one only when it
+// changed since it was last applied
+func (p *Plugin) applyBotProfile(config *configuration) error {
+	p.botProfileLock.Lock()
+	defer p.botProfileLock.Unlock()
+
+	if config.BotUsername != p.botProfile.DisplayName {
+		displayName := config.BotUsername
+		if _, appErr := p.API.PatchBot(p.BotUserID, &model.BotPatch{DisplayName: &displayName}); appErr != nil {
+			return errors.Wrap(appErr, "can't update bot display name")
+		}
+		p.botProfile.DisplayName = displayName
+	}
+	if config.BotIconURL == "" || config.BotIconURL == p.botProfile.IconURL {
+		return nil
+	}
+
+	icon, err := downloadBotIcon(config.BotIconURL)
+	if err != nil {
+		return err
+	}
+	if appErr := p.API.SetProfileImage(p.BotUserID, icon); appErr != nil {
+		return errors.Wrap(appErr, "can't set bot icon")
+	}
+	p.botProfile.IconURL = config.BotIconURL
+	return nil
+}
+
+// downloadBotIcon return the image at iconURL, the download is bounded by botIconTimeout and maxBotIconSize
+func downloadBotIcon(iconURL string) ([]byte, error) {
+	client := &http.Client{Timeout: botIconTimeout}
+	resp, err := client.Get(iconURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't download bot icon")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("can't download bot icon, status %d", resp.StatusCode)
+	}
+	icon, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxBotIconSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't read bot icon")
+	}
+	if len(icon) > maxBotIconSize {
+		return nil, errors.Errorf("can't use bot icon larger than %d bytes", maxBotIconSize)
+	}
+	return icon, nil
+}
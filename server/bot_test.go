@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadBotIcon(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/icon.png":
+			_, _ = w.Write([]byte("png"))
+		case "/large.png":
+			_, _ = w.Write(bytes.Repeat([]byte("x"), maxBotIconSize+1))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	icon, err := downloadBotIcon(server.URL + "/icon.png")
+	assert.Nil(err)
+	assert.Equal([]byte("png"), icon)
+
+	_, err = downloadBotIcon(server.URL + "/large.png")
+	assert.NotNil(err)
+
+	_, err = downloadBotIcon(server.URL + "/missing.png")
+	assert.NotNil(err)
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	// jpeg logos are decoded by image.Decode
+	_ "image/jpeg"
+
+	"github.com/pkg/errors"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+	xdraw "golang.org/x/image/draw"
+)
+
+const (
+	// brandLogoKey store the png of the logo drawn on charts
+	brandLogoKey = "brand_logo"
+	// brandLogoHeight is the height of the logo on charts, in pixels
+	brandLogoHeight = 32
+	// maxBrandLogoSize is the maximum size of uploaded logos
+	maxBrandLogoSize = 1024 * 1024
+	// chartPadding is the padding of charts around their canvas, the go-chart default
+	chartPadding = 20
+
+	chartThemeLight = "light"
+	chartThemeDark  = "dark"
+)
+
+// brandColorPattern match a hex color, e.g. #1e325c or #f80
+var brandColorPattern = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// chartTheme is the color palette of charts, series use brand colors when set
+type chartTheme struct {
+	background drawing.Color
+	axis       drawing.Color
+	text       drawing.Color
+	series     []drawing.Color
+}
+
+// chartThemes are the themes of the ChartTheme setting
+var chartThemes = map[string]chartTheme{
+	chartThemeLight: {background: chart.ColorWhite, axis: chart.DefaultAxisColor, text: chart.DefaultTextColor, series: chart.DefaultColors},
+	chartThemeDark: {
+		background: drawing.ColorFromHex("1f1f1f"),
+		axis:       drawing.ColorFromHex("9e9e9e"),
+		text:       drawing.ColorFromHex("dddddd"),
+		series:     chart.DefaultAlternateColors,
+	},
+}
+
+func (t chartTheme) BackgroundColor() drawing.Color       { return t.background }
+func (t chartTheme) BackgroundStrokeColor() drawing.Color { return t.background }
+func (t chartTheme) CanvasColor() drawing.Color           { return t.background }
+func (t chartTheme) CanvasStrokeColor() drawing.Color     { return t.background }
+func (t chartTheme) AxisStrokeColor() drawing.Color       { return t.axis }
+func (t chartTheme) TextColor() drawing.Color             { return t.text }
+func (t chartTheme) GetSeriesColor(index int) drawing.Color {
+	return t.series[index%len(t.series)]
+}
+
+// parseBrandColors parse BrandColors, hex colors separated by commas, e.g. #1e325c,#ff8000
+func parseBrandColors(config string) ([]drawing.Color, error) {
+	colors := make([]drawing.Color, 0)
+	for _, value := range strings.Split(config, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !brandColorPattern.MatchString(value) {
+			return nil, fmt.Errorf("Bad formatted BrandColors: %v, expected hex colors like #1e325c", value)
+		}
+		colors = append(colors, drawing.ColorFromHex(strings.TrimPrefix(value, "#")))
+	}
+	return colors, nil
+}
+
+// chartPalette return the palette of the ChartTheme with the BrandColors as series colors, nil for the go-chart
+// defaults of each chart type
+func (c *configuration) chartPalette() chart.ColorPalette {
+	theme, ok := chartThemes[c.ChartTheme]
+	colors, err := parseBrandColors(c.BrandColors)
+	if err != nil {
+		colors = nil
+	}
+	if (!ok || c.ChartTheme == chartThemeLight) && len(colors) == 0 {
+		return nil
+	}
+	if !ok {
+		theme = chartThemes[chartThemeLight]
+	}
+	if len(colors) > 0 {
+		theme.series = colors
+	}
+	return theme
+}
+
+// brandLogo is the uploaded logo, as png and scaled to the height of charts
+type brandLogo struct {
+	content []byte
+	scaled  image.Image
+}
+
+// newBrandLogo decode a png or jpeg logo and scale it to brandLogoHeight
+func newBrandLogo(content []byte) (*brandLogo, error) {
+	source, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode logo")
+	}
+	bounds := source.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return nil, errors.New("logo is empty")
+	}
+	var normalized bytes.Buffer
+	if err := png.Encode(&normalized, source); err != nil {
+		return nil, errors.Wrap(err, "can't encode logo")
+	}
+	width := bounds.Dx() * brandLogoHeight / bounds.Dy()
+	if width == 0 {
+		width = 1
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, width, brandLogoHeight))
+	xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), source, bounds, xdraw.Over, nil)
+	return &brandLogo{content: normalized.Bytes(), scaled: scaled}, nil
+}
+
+// brandLogoID return the id of a logo, its BrandLogo setting
+func brandLogoID(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+// loadBrandLogo return the logo of the BrandLogo setting, nil without logo
+func (p *Plugin) loadBrandLogo(id string) (*brandLogo, error) {
+	if id == "" {
+		return nil, nil
+	}
+	content, appErr := p.API.KVGet(brandLogoKey)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "can't get logo from kv")
+	}
+	if content == nil || brandLogoID(content) != id {
+		return nil, errors.New("BrandLogo " + id + " was not uploaded")
+	}
+	return newBrandLogo(content)
+}
+
+// chartBackground return the style of the background of charts, with room above the canvas for the logo
+func (c *configuration) chartBackground() chart.Style {
+	if c.logo == nil {
+		return chart.Style{}
+	}
+	return chart.Style{Padding: chart.Box{Top: 2*chartPadding + brandLogoHeight, Left: chartPadding, Right: chartPadding, Bottom: chartPadding}}
+}
+
+// logoX return the left of the logo on a chart of width, at the top left or top right for right to left languages
+func logoX(width int, logoWidth int, rtl bool) int {
+	if rtl {
+		return width - chartPadding - logoWidth
+	}
+	return chartPadding
+}
+
+// brandPNG draw the logo above the canvas of a png chart rendered with chartBackground
+func (c *configuration) brandPNG(content []byte, rtl bool) ([]byte, error) {
+	if c.logo == nil {
+		return content, nil
+	}
+	source, err := png.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode chart")
+	}
+	branded := image.NewRGBA(source.Bounds())
+	xdraw.Draw(branded, branded.Bounds(), source, source.Bounds().Min, xdraw.Src)
+	logoBounds := c.logo.scaled.Bounds()
+	at := image.Pt(logoX(branded.Bounds().Dx(), logoBounds.Dx(), rtl), chartPadding)
+	xdraw.Draw(branded, logoBounds.Add(at), c.logo.scaled, logoBounds.Min, xdraw.Over)
+	var result bytes.Buffer
+	if err := png.Encode(&result, branded); err != nil {
+		return nil, errors.Wrap(err, "can't encode chart")
+	}
+	return result.Bytes(), nil
+}
+
+// brandSVG add the logo above the canvas of a svg chart of width rendered with chartBackground
+func (c *configuration) brandSVG(content []byte, width int, rtl bool) []byte {
+	if c.logo == nil {
+		return content
+	}
+	end := bytes.LastIndex(content, []byte("</svg>"))
+	if end < 0 {
+		return content
+	}
+	logoWidth := c.logo.scaled.Bounds().Dx()
+	element := fmt.Sprintf(`<image x="%d" y="%d" width="%d" height="%d" href="data:image/png;base64,%s"/>`,
+		logoX(width, logoWidth, rtl), chartPadding, logoWidth, brandLogoHeight, base64.StdEncoding.EncodeToString(c.logo.content))
+	return append(append(append([]byte{}, content[:end]...), element...), content[end:]...)
+}
+
+// handleBrandLogo serve `GET /api/v1/branding/logo` the uploaded logo, and `POST /api/v1/branding/logo` with a png or
+// jpeg body storing a new logo, it is used once its returned id is saved in the BrandLogo setting
+func (p *Plugin) handleBrandLogo(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	switch r.Method {
+	case http.MethodGet:
+		content, appErr := p.API.KVGet(brandLogoKey)
+		if appErr != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return appErr
+		}
+		if content == nil {
+			http.NotFound(w, r)
+			return nil
+		}
+		w.Header().Set("Content-Type", "image/png")
+		_, err := w.Write(content)
+		return err
+	case http.MethodPost:
+		content, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBrandLogoSize))
+		if err != nil {
+			http.Error(w, "logo must be smaller than 1MB", http.StatusRequestEntityTooLarge)
+			return nil
+		}
+		logo, err := newBrandLogo(content)
+		if err != nil {
+			http.Error(w, "logo must be a png or jpeg image", http.StatusBadRequest)
+			return nil
+		}
+		if appErr := p.API.KVSet(brandLogoKey, logo.content); appErr != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return appErr
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(map[string]string{"id": brandLogoID(logo.content)})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+func TestParseBrandColors(t *testing.T) {
+	assert := assert.New(t)
+
+	colors, err := parseBrandColors("#1e325c, #F80,")
+	assert.Nil(err)
+	assert.Equal([]drawing.Color{{R: 0x1e, G: 0x32, B: 0x5c, A: 255}, {R: 0xff, G: 0x88, B: 0x00, A: 255}}, colors)
+
+	colors, err = parseBrandColors("")
+	assert.Nil(err)
+	assert.Empty(colors)
+
+	_, err = parseBrandColors("1e325c")
+	assert.NotNil(err)
+	_, err = parseBrandColors("#1e325")
+	assert.NotNil(err)
+}
+
+func TestChartPalette(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil((&configuration{}).chartPalette())
+	assert.Nil((&configuration{ChartTheme: chartThemeLight}).chartPalette())
+
+	dark := (&configuration{ChartTheme: chartThemeDark}).chartPalette()
+	assert.Equal(chartThemes[chartThemeDark].background, dark.BackgroundColor())
+
+	branded := (&configuration{BrandColors: "#1e325c,#ff8000"}).chartPalette()
+	assert.Equal(chartThemes[chartThemeLight].background, branded.BackgroundColor())
+	assert.Equal(drawing.ColorFromHex("ff8000"), branded.GetSeriesColor(1))
+	assert.Equal(drawing.ColorFromHex("1e325c"), branded.GetSeriesColor(2))
+}
+
+func TestBrandCharts(t *testing.T) {
+	assert := assert.New(t)
+	source := image.NewRGBA(image.Rect(0, 0, 128, 64))
+	for x := 0; x < 128; x++ {
+		for y := 0; y < 64; y++ {
+			source.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	var content bytes.Buffer
+	assert.Nil(png.Encode(&content, source))
+	logo, err := newBrandLogo(content.Bytes())
+	assert.Nil(err)
+	assert.Equal(image.Rect(0, 0, 64, brandLogoHeight), logo.scaled.Bounds())
+	_, err = newBrandLogo([]byte("not an image"))
+	assert.NotNil(err)
+
+	config := &configuration{logo: logo}
+	assert.Equal(2*chartPadding+brandLogoHeight, config.chartBackground().Padding.Top)
+
+	chartImage := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	var chartContent bytes.Buffer
+	assert.Nil(png.Encode(&chartContent, chartImage))
+	branded, err := config.brandPNG(chartContent.Bytes(), false)
+	assert.Nil(err)
+	decoded, err := png.Decode(bytes.NewReader(branded))
+	assert.Nil(err)
+	r, _, _, _ := decoded.At(chartPadding+1, chartPadding+1).RGBA()
+	assert.Equal(uint32(0xffff), r)
+	r, _, _, _ = decoded.At(chartPadding-1, chartPadding+1).RGBA()
+	assert.Equal(uint32(0), r)
+
+	svg := string(config.brandSVG([]byte("<svg></svg>"), 200, true))
+	assert.Contains(svg, `<image x="116" y="20" width="64" height="32" href="data:image/png;base64,`)
+	assert.Equal("<svg></svg>", string((&configuration{}).brandSVG([]byte("<svg></svg>"), 200, true)))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// writeBufferTick is the period the write buffer checks whether WriteBufferSeconds elapsed since its last flush
+const writeBufferTick = time.Second
+
+// bufferDaily add delta to the pending increment of the bucket of id in scope for the day of date, the increment is
+// journaled so it is replayed if the plugin stops before the flush
+// return the number of increments buffered since the last flush
+func (p *Plugin) bufferDaily(date time.Time, scope string, id string, delta DailyCounters) int {
+	key := dailyKey(date, scope, id)
+	p.writeBufferLock.Lock()
+	defer p.writeBufferLock.Unlock()
+	if err := p.journal.Append(JournalEvent{Kind: journalDaily, Date: date, Bucket: &dailyBucket{Date: date, Scope: scope, ID: id, Counters: delta}}); err != nil {
+		p.API.LogError("can't append event to journal", "err", err.Error())
+	}
+	if p.writeBuffer == nil {
+		p.writeBuffer = make(map[string]*dailyBucket)
+	}
+	pending, ok := p.writeBuffer[key]
+	if !ok {
+		pending = &dailyBucket{Date: date, Scope: scope, ID: id}
+		p.writeBuffer[key] = pending
+	}
+	pending.Counters.add(delta)
+	p.writeBufferEvents++
+	return p.writeBufferEvents
+}
+
+// flushWriteBuffer write pending increments to the daily store, increments which can't be written are kept
+// for the next flush
+// the flush is journaled under the lock of the buffer so it covers exactly the increments journaled before, and is
+// ended once failed increments are buffered and journaled again
+func (p *Plugin) flushWriteBuffer() error {
+	p.writeBufferLock.Lock()
+	pending := p.writeBuffer
+	events := p.writeBufferEvents
+	p.writeBuffer = nil
+	p.writeBufferEvents = 0
+	p.writeBufferFlushed = p.now()
+	var flush int64
+	if len(pending) > 0 {
+		var err error
+		if flush, err = p.journal.BeginFlush(); err != nil {
+			p.API.LogError("can't append event to journal", "err", err.Error())
+		}
+	}
+	p.writeBufferLock.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	defer func() {
+		if err := p.journal.EndFlush(flush); err != nil {
+			p.API.LogError("can't append event to journal", "err", err.Error())
+		}
+	}()
+
+	stamp := changeStamp(p.now())
+	var err error
+	failed := 0
+	for _, bucket := range pending {
+		if err == nil {
+			err = p.store().increment(bucket.Date, bucket.Scope, bucket.ID, bucket.Counters, stamp)
+			if err == nil {
+				continue
+			}
+		}
+		p.bufferDaily(bucket.Date, bucket.Scope, bucket.ID, bucket.Counters)
+		failed++
+	}
+	if err != nil {
+		return errors.Wrap(err, "can't flush "+strconv.Itoa(failed)+" daily buckets of "+strconv.Itoa(events)+" increments")
+	}
+	return nil
+}
+
+// runWriteBuffer flush the write buffer every WriteBufferSeconds until stop is closed
+func (p *Plugin) runWriteBuffer(stop <-chan struct{}) {
+	ticker := time.NewTicker(writeBufferTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			p.writeBufferLock.Lock()
+			due := p.now().Sub(p.writeBufferFlushed) >= time.Duration(p.getConfiguration().WriteBufferSeconds)*time.Second
+			p.writeBufferLock.Unlock()
+			if !due {
+				continue
+			}
+			if err := p.flushWriteBuffer(); err != nil {
+				p.API.LogError("can't flush daily analytics", "err", err.Error())
+			}
+		}
+	}
+}
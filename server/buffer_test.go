@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// memoryDailyStore record increments in memory, failing them while err is set
+type memoryDailyStore struct {
+	dailyStore
+	increments map[string]DailyCounters
+	err        error
+}
+
+func (s *memoryDailyStore) increment(date time.Time, scope string, id string, delta DailyCounters, stamp int64) error {
+	if s.err != nil {
+		return s.err
+	}
+	counters := s.increments[dailyKey(date, scope, id)]
+	counters.add(delta)
+	counters.UpdatedAt = stamp
+	s.increments[dailyKey(date, scope, id)] = counters
+	return nil
+}
+
+func (s *memoryDailyStore) get(date time.Time, scope string, id string) (DailyCounters, error) {
+	if s.err != nil {
+		return DailyCounters{}, s.err
+	}
+	return s.increments[dailyKey(date, scope, id)], nil
+}
+
+func (s *memoryDailyStore) set(bucket dailyBucket) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.increments[dailyKey(bucket.Date, bucket.Scope, bucket.ID)] = bucket.Counters
+	return nil
+}
+
+func TestWriteBuffer(t *testing.T) {
+	assert := assert.New(t)
+
+	store := &memoryDailyStore{increments: make(map[string]DailyCounters), err: errors.New("database is down")}
+	p := &Plugin{dailyStore: store}
+	p.setConfiguration(&configuration{WriteBufferSeconds: 5})
+	day := time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local)
+
+	assert.Nil(p.incrementDaily(day, dailyScopeChannel, "channel1", DailyCounters{Messages: 1}))
+	assert.Nil(p.incrementDaily(day, dailyScopeChannel, "channel1", DailyCounters{Messages: 1, Replies: 1}))
+	assert.Nil(p.incrementDaily(day, dailyScopeUser, "user1", DailyCounters{Messages: 1}))
+	assert.Equal(3, p.writeBufferEvents)
+	assert.Len(p.writeBuffer, 2)
+
+	// failed increments are kept for the next flush
+	assert.NotNil(p.flushWriteBuffer())
+	assert.Empty(store.increments)
+	assert.Len(p.writeBuffer, 2)
+
+	store.err = nil
+	assert.Nil(p.flushWriteBuffer())
+	assert.Empty(p.writeBuffer)
+	assert.Equal(int64(2), store.increments["analytics:2020-03-16:c:channel1"].Messages)
+	assert.Equal(int64(1), store.increments["analytics:2020-03-16:c:channel1"].Replies)
+	assert.Equal(int64(1), store.increments["analytics:2020-03-16:u:user1"].Messages)
+
+	p.setConfiguration(&configuration{})
+	assert.Nil(p.incrementDaily(day, dailyScopeUser, "user1", DailyCounters{Messages: 1}))
+	assert.Empty(p.writeBuffer)
+	assert.Equal(int64(2), store.increments["analytics:2020-03-16:u:user1"].Messages)
+}
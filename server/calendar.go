@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// calendar align weekly and quarterly aggregates on the reporting calendar of the organization
+type calendar struct {
+	// weekStart is the first day of the week
+	weekStart time.Weekday
+	// fiscalYearStart is the first month of the fiscal year
+	fiscalYearStart time.Month
+}
+
+// calendar return the reporting calendar from the configuration
+func (c *configuration) calendar() calendar {
+	cal := calendar{weekStart: time.Sunday, fiscalYearStart: time.January}
+	if c.WeekStart == "monday" {
+		cal.weekStart = time.Monday
+	}
+	if c.FiscalYearStartMonth >= 1 && c.FiscalYearStartMonth <= 12 {
+		cal.fiscalYearStart = time.Month(c.FiscalYearStartMonth)
+	}
+	return cal
+}
+
+// startOfWeek return the first day of the week of date at midnight
+func (c calendar) startOfWeek(date time.Time) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	offset := (int(day.Weekday()) - int(c.weekStart) + 7) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// weekPeriod return an id for the week of date, e.g. W2019-04-21
+func (c calendar) weekPeriod(date time.Time) string {
+	return "W" + c.startOfWeek(date).Format("2006-01-02")
+}
+
+// fiscalQuarter return the fiscal year (named after the calendar year it ends in) and quarter (1 to 4) of date
+func (c calendar) fiscalQuarter(date time.Time) (int, int) {
+	monthsIntoYear := (int(date.Month()) - int(c.fiscalYearStart) + 12) % 12
+	year := date.Year()
+	if c.fiscalYearStart != time.January && date.Month() >= c.fiscalYearStart {
+		year++
+	}
+	return year, monthsIntoYear/3 + 1
+}
+
+// startOfQuarter return the first day of the fiscal quarter of date at midnight
+func (c calendar) startOfQuarter(date time.Time) time.Time {
+	monthsIntoQuarter := ((int(date.Month()) - int(c.fiscalYearStart) + 12) % 12) % 3
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location()).AddDate(0, -monthsIntoQuarter, 0)
+}
+
+// quarterPeriod return an id for the fiscal quarter of date, e.g. FY2019-Q2
+func (c calendar) quarterPeriod(date time.Time) string {
+	year, quarter := c.fiscalQuarter(date)
+	return fmt.Sprintf("FY%d-Q%d", year, quarter)
+}
+
+// weeklyCronSpec return the standard cron spec firing at midnight at the start of each week
+func (c calendar) weeklyCronSpec() string {
+	return fmt.Sprintf("0 0 * * %d", int(c.weekStart))
+}
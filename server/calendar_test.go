@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarStartOfWeek(t *testing.T) {
+	assert := assert.New(t)
+	wednesday := time.Date(2019, time.April, 24, 15, 4, 0, 0, time.UTC)
+
+	sunday := calendar{weekStart: time.Sunday, fiscalYearStart: time.January}
+	assert.Equal(time.Date(2019, time.April, 21, 0, 0, 0, 0, time.UTC), sunday.startOfWeek(wednesday))
+	assert.Equal("W2019-04-21", sunday.weekPeriod(wednesday))
+
+	monday := calendar{weekStart: time.Monday, fiscalYearStart: time.January}
+	assert.Equal(time.Date(2019, time.April, 22, 0, 0, 0, 0, time.UTC), monday.startOfWeek(wednesday))
+	assert.Equal(time.Date(2019, time.April, 15, 0, 0, 0, 0, time.UTC), monday.startOfWeek(time.Date(2019, time.April, 21, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestCalendarFiscalQuarter(t *testing.T) {
+	assert := assert.New(t)
+
+	civil := calendar{weekStart: time.Sunday, fiscalYearStart: time.January}
+	assert.Equal("FY2019-Q2", civil.quarterPeriod(time.Date(2019, time.April, 24, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC), civil.startOfQuarter(time.Date(2019, time.June, 30, 0, 0, 0, 0, time.UTC)))
+
+	october := calendar{weekStart: time.Sunday, fiscalYearStart: time.October}
+	assert.Equal("FY2020-Q1", october.quarterPeriod(time.Date(2019, time.October, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal("FY2019-Q4", october.quarterPeriod(time.Date(2019, time.September, 30, 0, 0, 0, 0, time.UTC)))
+	assert.Equal("FY2020-Q2", october.quarterPeriod(time.Date(2020, time.February, 12, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), october.startOfQuarter(time.Date(2020, time.February, 12, 0, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const hourlyKeyFormat = "2006-01-02T15"
+
+// capacityWeek is the activity of one session, used as a point of the capacity projection
+type capacityWeek struct {
+	start     time.Time
+	days      float64
+	messages  int64
+	peakHour  int64
+	filesNb   int64
+	filesSize int64
+}
+
+// capacityWeeks return activity of all archived sessions and the current one, oldest first
+func (p *Plugin) capacityWeeks() ([]capacityWeek, error) {
+	sessions, err := p.allSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	p.currentAnalytic.RLock()
+	current := &Analytic{
+		Start:     p.currentAnalytic.Start,
+		End:       p.now(),
+		Channels:  p.currentAnalytic.Channels,
+		Hourly:    p.currentAnalytic.Hourly,
+		FilesNb:   p.currentAnalytic.FilesNb,
+		FilesSize: p.currentAnalytic.FilesSize,
+	}
+	weeks := make([]capacityWeek, 0, len(sessions)+1)
+	for _, session := range append(sessions, current) {
+		week := capacityWeek{
+			start:     session.Start,
+			days:      session.End.Sub(session.Start).Hours() / 24,
+			filesNb:   session.FilesNb,
+			filesSize: session.FilesSize,
+		}
+		for _, nb := range session.Channels {
+			week.messages += nb
+		}
+		for _, nb := range session.Hourly {
+			if nb > week.peakHour {
+				week.peakHour = nb
+			}
+		}
+		if week.days > 0 {
+			weeks = append(weeks, week)
+		}
+	}
+	p.currentAnalytic.RUnlock()
+	return weeks, nil
+}
+
+// linearProjection fit a line on values (one by week) and return the projected value in nbWeeks after the last one
+func linearProjection(values []float64, nbWeeks int) float64 {
+	n := float64(len(values))
+	if n == 0 {
+		return 0
+	}
+	if n == 1 {
+		return values[0]
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i, y := range values {
+		x := float64(i)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	slope := (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX)
+	intercept := (sumY - slope*sumX) / n
+	projection := intercept + slope*(n-1+float64(nbWeeks))
+	if projection < 0 {
+		return 0
+	}
+	return projection
+}
+
+// executeCapacityCommand handle `/analytics capacity`
+func (p *Plugin) executeCapacityCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can see the capacity report.")
+	}
+	T := p.channelTranslate(args.ChannelId)
+	weeks, err := p.capacityWeeks()
+	if err != nil {
+		p.API.LogError("can't get capacity data", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	if len(weeks) == 0 {
+		return ephemeralResponse(T("report.capacity.no_data"))
+	}
+
+	postsPerDay := make([]float64, 0, len(weeks))
+	filesSizePerDay := make([]float64, 0, len(weeks))
+	totalFilesSize := int64(0)
+	peakHour := int64(0)
+	text := T("report.capacity.title") + "\n" + T("report.capacity.columns") + "\n|:--|--:|--:|--:|--:|\n"
+	for _, week := range weeks {
+		postsPerDay = append(postsPerDay, float64(week.messages)/week.days)
+		filesSizePerDay = append(filesSizePerDay, float64(week.filesSize)/week.days)
+		totalFilesSize += week.filesSize
+		if week.peakHour > peakHour {
+			peakHour = week.peakHour
+		}
+		text += fmt.Sprintf("| %s | %.0f | %d | %d | %s |\n", week.start.Format(T("report.date_layout")), float64(week.messages)/week.days, week.peakHour, week.filesNb, byteCountDecimal(week.filesSize))
+	}
+
+	text += "\n" + T("report.capacity.summary", map[string]interface{}{"Peak": peakHour, "Storage": byteCountDecimal(totalFilesSize)}) + "\n"
+	text += T("report.capacity.projection_columns") + "\n|:--|--:|--:|\n"
+	for _, months := range []int{3, 6, 12} {
+		nbWeeks := months * 52 / 12
+		storage := int64(0)
+		for week := 1; week <= nbWeeks; week++ {
+			storage += int64(linearProjection(filesSizePerDay, week) * 7)
+		}
+		text += fmt.Sprintf("| %s | %.0f | %s |\n", T("report.capacity.in_months", map[string]interface{}{"Months": months}), linearProjection(postsPerDay, nbWeeks), byteCountDecimal(storage))
+	}
+	return ephemeralResponse(text)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+const (
+	privacyLevelAggregate = "aggregate"
+	privacyLevelPersonal  = "personal"
+
+	retentionSession = "current session, archived at each scheduled report"
+	// retentionDaily is replaced by the retention of daily buckets of the configuration, see dailyRetention
+	retentionDaily = "daily buckets"
+	retentionNone  = "computed for each report, not stored"
+)
+
+// retentionSurveys is the retention of answers to pulse surveys
+var retentionSurveys = fmt.Sprintf("pulse surveys, deleted %d days after being posted", int(surveysRetention.Hours()/24))
+
+// dailyRetention return the retention of daily buckets, pruned after RetentionDays if set
+func (c *configuration) dailyRetention() string {
+	if c.RetentionDays == 0 {
+		return retentionDaily + ", kept forever"
+	}
+	return fmt.Sprintf("%s, deleted after %d days", retentionDaily, c.RetentionDays)
+}
+
+// withRetention return a copy of definitions with the retention of daily buckets of config
+func withRetention(definitions []MetricDefinition, config *configuration) []MetricDefinition {
+	resolved := make([]MetricDefinition, 0, len(definitions))
+	for _, definition := range definitions {
+		if definition.Retention == retentionDaily {
+			definition.Retention = config.dailyRetention()
+		}
+		resolved = append(resolved, definition)
+	}
+	return resolved
+}
+
+// MetricDefinition describe a metric stored by this plugin, used by data governance tooling
+type MetricDefinition struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Unit        string   `json:"unit"`
+	Dimensions  []string `json:"dimensions"`
+	Retention   string   `json:"retention"`
+	Privacy     string   `json:"privacy_level"`
+	// Section is the translation id of the title of the report section showing the metric, if any
+	Section string `json:"report_section,omitempty"`
+}
+
+// metricsCatalog is the data dictionary of every stored metric
+var metricsCatalog = []MetricDefinition{
+	{
+		Name:        "channel_messages",
+		Description: "Number of messages posted in a channel.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "channel_replies",
+		Description: "Number of messages posted in a channel as a reply to another message.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "user_messages",
+		Description: "Number of messages posted by a user.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "user_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelPersonal,
+	},
+	{
+		Name:        "user_replies",
+		Description: "Number of messages posted by a user as a reply to another message.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "user_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelPersonal,
+	},
+	{
+		Name:        "files",
+		Description: "Number of files uploaded.",
+		Unit:        "files",
+		Dimensions:  []string{"session"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "files_size",
+		Description: "Total size of files uploaded.",
+		Unit:        "bytes",
+		Dimensions:  []string{"session"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "hourly_messages",
+		Description: "Number of messages posted during an hour.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "hour"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "channel_files_size",
+		Description: "Total size of files posted in a channel.",
+		Unit:        "bytes",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "thread_replies",
+		Description: "Number of replies posted in a thread.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "root_post_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "channel_words",
+		Description: "Number of words posted in a channel, each chinese or japanese character and each emoji counts as a word.",
+		Unit:        "words",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "script_messages",
+		Description: "Number of messages by writing system used by most of their letters (e.g. Latin, Japanese).",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "script"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "channel_user_messages",
+		Description: "Number of messages posted by a user in a channel, used to count posters of channels.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "channel_id", "user_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelPersonal,
+	},
+	{
+		Name:        "channel_user_replies",
+		Description: "Number of replies posted by a user in a channel, used to classify users as thread starters or repliers.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "channel_id", "user_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelPersonal,
+	},
+	{
+		Name:        "daily_channel_counters",
+		Description: "Number of messages, replies and size of files posted in a channel during a day.",
+		Unit:        "messages, bytes",
+		Dimensions:  []string{"day", "channel_id"},
+		Retention:   retentionDaily,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "daily_user_counters",
+		Description: "Number of messages, replies and size of files posted by a user during a day.",
+		Unit:        "messages, bytes",
+		Dimensions:  []string{"day", "user_id"},
+		Retention:   retentionDaily,
+		Privacy:     privacyLevelPersonal,
+	},
+	{
+		Name:        "daily_channel_membership",
+		Description: "Number of users who joined and left a channel during a day.",
+		Unit:        "users",
+		Dimensions:  []string{"day", "channel_id"},
+		Retention:   retentionDaily,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "daily_team_membership",
+		Description: "Number of users who joined and left a team during a day.",
+		Unit:        "users",
+		Dimensions:  []string{"day", "team_id"},
+		Retention:   retentionDaily,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "daily_first_response_times",
+		Description: "Delays before the first reply of someone else to each root post of a channel, by day of the reply.",
+		Unit:        "seconds",
+		Dimensions:  []string{"day", "channel_id"},
+		Retention:   retentionDaily,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "user_mentions",
+		Description: "Number of messages mentioning a user, counted once per message.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "username"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelPersonal,
+	},
+	{
+		Name:        "channel_broadcast_mentions",
+		Description: "Number of messages of a channel using @channel, @here or @all.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "channel_id", "mention"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "message_lengths",
+		Description: "Number of messages by length class: emoji only, short, medium, long or with a code block.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "length_class"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.lengths.title",
+	},
+	{
+		Name:        "message_edits",
+		Description: "Number of edits of messages of a channel, an edit changes the message or its files.",
+		Unit:        "edits",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "message_deletions",
+		Description: "Number of deleted messages of a channel, messages deleted during the session they were posted in are also removed from the other metrics.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionSession,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "direct_messages",
+		Description: "Number of direct and group messages, only counted when TrackDirectMessages is on, without channel nor participants.",
+		Unit:        "messages",
+		Dimensions:  []string{"session", "day"},
+		Retention:   retentionDaily,
+		Privacy:     privacyLevelAggregate,
+	},
+	{
+		Name:        "first_response_time",
+		Description: "Delay before the first reply of someone else to a root post, the median is the typical delay and the p90 the delay 90% of threads were answered within.",
+		Unit:        "minutes",
+		Dimensions:  []string{"day", "channel_id"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.response_times.title",
+	},
+	{
+		Name:        "notable_change",
+		Description: "Messages a day of a channel compared to its previous sessions, in standard deviations from their mean: 2 or more is unusual.",
+		Unit:        "standard deviations",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.highlights.title",
+	},
+	{
+		Name:        "health_score",
+		Description: "Activity of a channel between 0 and 100, weighting its posting frequency, number of posters, share of replies and delay before the first reply.",
+		Unit:        "score",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.health.title",
+	},
+	{
+		Name:        "lurkers",
+		Description: "Share of the members who viewed a channel during the session without posting in it, estimated from their last view.",
+		Unit:        "percent",
+		Dimensions:  []string{"session", "channel_id"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.readership.title",
+	},
+	{
+		Name:        "conversation_roles",
+		Description: "Users doing more than half of their activity by starting threads, replying or reacting, mixed otherwise.",
+		Unit:        "users",
+		Dimensions:  []string{"session", "team_id", "role"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.roles.title",
+	},
+	{
+		Name:        "emoji_reactions",
+		Description: "Number of reactions with an emoji to posts of the session in public channels, skin tone variants are merged unless KeepEmojiVariants is on.",
+		Unit:        "reactions",
+		Dimensions:  []string{"session", "emoji"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.reactions.title",
+	},
+	{
+		Name:        "post_reactions",
+		Description: "Number of reactions to a post of the session, used to list the most reacted posts of public channels.",
+		Unit:        "reactions",
+		Dimensions:  []string{"session", "channel_id", "post_id"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.reactions.posts_title",
+	},
+	{
+		Name:        "user_reactions",
+		Description: "Number of reactions added by a user to posts of the session, in a channel, used by leaderboards and conversation roles.",
+		Unit:        "reactions",
+		Dimensions:  []string{"session", "channel_id", "user_id"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelPersonal,
+	},
+	{
+		Name:        "survey_scores",
+		Description: "Score between 1 and 5 given by a user to a pulse survey of a channel, the user id is hashed in anonymous mode, reports show the average score and the response rate.",
+		Unit:        "score",
+		Dimensions:  []string{"survey", "channel_id", "user_id"},
+		Retention:   retentionSurveys,
+		Privacy:     privacyLevelPersonal,
+		Section:     "report.surveys.title",
+	},
+	{
+		Name:        "oncall_first_responses",
+		Description: "Delay before the first reply of a member of the on-call rotation of a channel to a root post of someone else, by responder, omitted in anonymous mode.",
+		Unit:        "minutes",
+		Dimensions:  []string{"session", "channel_id", "user_id"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelPersonal,
+	},
+	{
+		Name:        "net_membership_changes",
+		Description: "Users who joined minus users who left a team or a channel during the period, summed from daily membership buckets.",
+		Unit:        "users",
+		Dimensions:  []string{"period", "team_id", "channel_id"},
+		Retention:   retentionNone,
+		Privacy:     privacyLevelAggregate,
+		Section:     "report.membership.title",
+	},
+}
+
+// handleCatalog serve the data dictionary as json, followed by collectors registered by other plugins
+func (p *Plugin) handleCatalog(w http.ResponseWriter, r *http.Request) error {
+	if r.Header.Get("Mattermost-User-Id") == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	collectors, err := p.getExternalCollectors()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	external := make([]MetricDefinition, 0, len(collectors))
+	for _, collector := range collectors {
+		external = append(external, collector.definition())
+	}
+	sort.Slice(external, func(i, j int) bool {
+		return external[i].Name < external[j].Name
+	})
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(withRetention(append(append([]MetricDefinition{}, metricsCatalog...), external...), p.getConfiguration()))
+}
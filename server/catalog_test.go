@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDailyRetention(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("daily buckets, kept forever", (&configuration{}).dailyRetention())
+	assert.Equal("daily buckets, deleted after 90 days", (&configuration{RetentionDays: 90}).dailyRetention())
+
+	definitions := withRetention(metricsCatalog, &configuration{RetentionDays: 30})
+	assert.Len(definitions, len(metricsCatalog))
+	names := make(map[string]bool)
+	for index, definition := range definitions {
+		assert.NotEqual(retentionDaily, definition.Retention, definition.Name)
+		if metricsCatalog[index].Retention == retentionDaily {
+			assert.Equal("daily buckets, deleted after 30 days", definition.Retention, definition.Name)
+		}
+		assert.False(names[definition.Name], definition.Name)
+		names[definition.Name] = true
+	}
+}
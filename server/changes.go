@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// deletedBucketsKey store daily buckets deleted by purges and forgotten users, for delta syncs
+	deletedBucketsKey = "deleted_buckets"
+	// maxDeletedBuckets is the number of deletions kept, older ones are dropped
+	maxDeletedBuckets = 10000
+	// maxChangesPerPage is the number of changes returned by a request of the changes api
+	maxChangesPerPage = 1000
+	// changesSettleDelay hide changes younger than this delay, so writes in progress are not skipped by a cursor
+	changesSettleDelay = 5 * time.Second
+)
+
+// changeStamp return the stamp of a change made at t, in milliseconds since epoch
+func changeStamp(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// DeletedBucket is a daily bucket deleted by a purge or a forgotten user
+type DeletedBucket struct {
+	Key       string
+	DeletedAt int64
+}
+
+// recordDeletedBuckets remember keys deleted at now, keeping the last maxDeletedBuckets deletions
+func (p *Plugin) recordDeletedBuckets(keys []string, now time.Time) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	p.deletedBucketsLock.Lock()
+	defer p.deletedBucketsLock.Unlock()
+	deleted := make([]DeletedBucket, 0)
+	if err := p.kvGetJSON(deletedBucketsKey, &deleted); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if strings.HasPrefix(key, dailyKeyPrefix) {
+			deleted = append(deleted, DeletedBucket{Key: key, DeletedAt: changeStamp(now)})
+		}
+	}
+	if len(deleted) > maxDeletedBuckets {
+		deleted = deleted[len(deleted)-maxDeletedBuckets:]
+	}
+	return p.kvSetJSON(deletedBucketsKey, deleted)
+}
+
+// changesCursor is the position of a delta sync, after the change of key at stamp
+type changesCursor struct {
+	Stamp int64
+	Key   string
+}
+
+// parseChangesCursor parse a cursor in form stamp:key, an empty cursor is before all changes, unstamped ones included
+func parseChangesCursor(value string) (changesCursor, error) {
+	if value == "" {
+		return changesCursor{}, nil
+	}
+	v := strings.SplitN(value, ":", 2)
+	stamp, err := strconv.ParseInt(v[0], 10, 64)
+	if err != nil || stamp < 0 {
+		return changesCursor{}, fmt.Errorf("bad cursor %s", value)
+	}
+	cursor := changesCursor{Stamp: stamp}
+	if len(v) == 2 {
+		cursor.Key = v[1]
+	}
+	return cursor, nil
+}
+
+func (c changesCursor) String() string {
+	return strconv.FormatInt(c.Stamp, 10) + ":" + c.Key
+}
+
+// before return true if the cursor is before the change of key at stamp
+func (c changesCursor) before(stamp int64, key string) bool {
+	return stamp > c.Stamp || (stamp == c.Stamp && key > c.Key)
+}
+
+// BucketChange is a daily bucket written or deleted since a cursor
+type BucketChange struct {
+	Date      string         `json:"date"`
+	Scope     string         `json:"scope"`
+	ID        string         `json:"id"`
+	Counters  *DailyCounters `json:"counters,omitempty"`
+	Deleted   bool           `json:"deleted,omitempty"`
+	UpdatedAt int64          `json:"updated_at"`
+
+	// key orders changes of the same stamp, it is a hash of the daily key so cursors don't carry user ids
+	key string
+}
+
+// ChangesResponse is the json response of the changes api
+type ChangesResponse struct {
+	// Cursor is the since parameter of the next request
+	Cursor  string          `json:"cursor"`
+	HasMore bool            `json:"has_more"`
+	Changes []*BucketChange `json:"changes"`
+	// Degraded is true when the database fails and changes come from the kv store, they may be behind
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// newBucketChange return the change of a daily key, nil if key is not a daily key
+func newBucketChange(key string, stamp int64) *BucketChange {
+	parts := strings.SplitN(strings.TrimPrefix(key, dailyKeyPrefix), ":", 3)
+	if !strings.HasPrefix(key, dailyKeyPrefix) || len(parts) != 3 {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(key))
+	return &BucketChange{Date: parts[0], Scope: parts[1], ID: parts[2], UpdatedAt: stamp, key: hex.EncodeToString(sum[:8])}
+}
+
+// pageChanges sort changes after cursor and until the settled stamp, return at most limit of them and the next cursor
+func pageChanges(changes []*BucketChange, cursor changesCursor, settled int64, limit int) *ChangesResponse {
+	selected := make([]*BucketChange, 0)
+	for _, change := range changes {
+		if cursor.before(change.UpdatedAt, change.key) && change.UpdatedAt <= settled {
+			selected = append(selected, change)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].UpdatedAt != selected[j].UpdatedAt {
+			return selected[i].UpdatedAt < selected[j].UpdatedAt
+		}
+		return selected[i].key < selected[j].key
+	})
+	response := &ChangesResponse{Cursor: cursor.String(), Changes: selected}
+	if len(selected) > limit {
+		response.Changes = selected[:limit]
+		response.HasMore = true
+	}
+	if len(response.Changes) > 0 {
+		last := response.Changes[len(response.Changes)-1]
+		response.Cursor = changesCursor{Stamp: last.UpdatedAt, Key: last.key}.String()
+	}
+	return response
+}
+
+// collectChanges return changes of all daily buckets and deletions, user ids are hashed if the warehouse policy
+// hash usernames
+func (p *Plugin) collectChanges() ([]*BucketChange, error) {
+	changes := make([]*BucketChange, 0)
+	buckets, err := p.listAllDailyBuckets(allScopes)
+	if err != nil {
+		return nil, err
+	}
+	for index := range buckets {
+		bucket := &buckets[index]
+		change := newBucketChange(dailyKey(bucket.Date, bucket.Scope, bucket.ID), bucket.Counters.UpdatedAt)
+		change.Counters = &bucket.Counters
+		changes = append(changes, change)
+	}
+	deleted := make([]DeletedBucket, 0)
+	if err := p.kvGetJSON(deletedBucketsKey, &deleted); err != nil {
+		return nil, err
+	}
+	for _, bucket := range deleted {
+		if change := newBucketChange(bucket.Key, bucket.DeletedAt); change != nil {
+			change.Deleted = true
+			changes = append(changes, change)
+		}
+	}
+
+	if policy := p.maskingPolicy("warehouse"); policy != nil && policy.HashUsernames {
+		salt := p.API.GetDiagnosticId()
+		for _, change := range changes {
+			if change.Scope == dailyScopeUser {
+				change.ID = hashIdentifier(change.ID, salt)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// handleChanges serve `GET /api/v1/changes?since=<cursor>`, daily buckets written or deleted since the cursor
+// ordered by change, buckets written before this api existed have no stamp and are returned by the first sync
+func (p *Plugin) handleChanges(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	cursor, err := parseChangesCursor(r.URL.Query().Get("since"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	changes, err := p.collectChanges()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	response := pageChanges(changes, cursor, changeStamp(p.now().Add(-changesSettleDelay)), maxChangesPerPage)
+	response.Degraded = p.storeDegraded()
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
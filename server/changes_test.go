@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChangesCursor(t *testing.T) {
+	assert := assert.New(t)
+
+	cursor, err := parseChangesCursor("")
+	assert.Nil(err)
+	assert.Equal(changesCursor{}, cursor)
+
+	cursor, err = parseChangesCursor("1584316800000:0a1b2c")
+	assert.Nil(err)
+	assert.Equal(changesCursor{Stamp: 1584316800000, Key: "0a1b2c"}, cursor)
+	assert.Equal("1584316800000:0a1b2c", cursor.String())
+
+	for _, bad := range []string{"abc", "-1:0a1b2c", ":0a1b2c"} {
+		_, err = parseChangesCursor(bad)
+		assert.NotNil(err, bad)
+	}
+}
+
+func TestNewBucketChange(t *testing.T) {
+	assert := assert.New(t)
+
+	change := newBucketChange("analytics:2020-03-16:u:user1", 42)
+	assert.Equal("2020-03-16", change.Date)
+	assert.Equal(dailyScopeUser, change.Scope)
+	assert.Equal("user1", change.ID)
+	assert.Equal(int64(42), change.UpdatedAt)
+	assert.NotContains(change.key, "user1")
+	assert.Nil(newBucketChange("allAnalytics", 42))
+	assert.Nil(newBucketChange("analytics:2020-03-16", 42))
+}
+
+func TestPageChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	unstamped := newBucketChange("analytics:2020-03-14:c:channel1", 0)
+	first := newBucketChange("analytics:2020-03-15:c:channel1", 100)
+	second := newBucketChange("analytics:2020-03-16:c:channel1", 200)
+	third := newBucketChange("analytics:2020-03-16:u:user1", 200)
+	third.Deleted = true
+	unsettled := newBucketChange("analytics:2020-03-17:c:channel1", 900)
+	changes := []*BucketChange{unsettled, third, second, first, unstamped}
+	sameStamp := []*BucketChange{second, third}
+	if third.key < second.key {
+		sameStamp = []*BucketChange{third, second}
+	}
+
+	page := pageChanges(changes, changesCursor{}, 500, 2)
+	assert.Equal([]*BucketChange{unstamped, first}, page.Changes)
+	assert.True(page.HasMore)
+
+	cursor, err := parseChangesCursor(page.Cursor)
+	assert.Nil(err)
+	page = pageChanges(changes, cursor, 500, 2)
+	assert.Equal(sameStamp, page.Changes)
+	assert.False(page.HasMore)
+
+	cursor, err = parseChangesCursor(page.Cursor)
+	assert.Nil(err)
+	page = pageChanges(changes, cursor, 500, 2)
+	assert.Empty(page.Changes)
+	assert.Equal(cursor.String(), page.Cursor)
+
+	page = pageChanges(changes, cursor, 1000, 2)
+	assert.Equal([]*BucketChange{unsettled}, page.Changes)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// costCenterUsage is the usage of the teams of a cost center during the current session
+type costCenterUsage struct {
+	name        string
+	teams       []string
+	activeUsers int64
+	messages    int64
+	filesSize   int64
+}
+
+// parseCostCenters parse cost centers in form CostCenter:team1,team2;CostCenter2:team3
+// and return team names by cost center
+func parseCostCenters(config string) (map[string][]string, error) {
+	costCenters := make(map[string][]string)
+	if strings.TrimSpace(config) == "" {
+		return costCenters, nil
+	}
+	for _, costCenter := range strings.Split(config, ";") {
+		v := strings.SplitN(costCenter, ":", 2)
+		if len(v) != 2 || strings.TrimSpace(v[0]) == "" || strings.TrimSpace(v[1]) == "" {
+			return nil, fmt.Errorf("Bad formatted cost center: %v", costCenter)
+		}
+		for _, team := range strings.Split(v[1], ",") {
+			costCenters[strings.TrimSpace(v[0])] = append(costCenters[strings.TrimSpace(v[0])], strings.TrimSpace(team))
+		}
+	}
+	return costCenters, nil
+}
+
+// getTeamMembersID return the set of ids of all members of a team
+func (p *Plugin) getTeamMembersID(teamID string) (map[string]bool, error) {
+	members := make(map[string]bool)
+	perPage := 200
+	for page := 0; ; page++ {
+		teamMembers, appErr := p.API.GetTeamMembers(teamID, page, perPage)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't get team members")
+		}
+		for _, member := range teamMembers {
+			members[member.UserId] = true
+		}
+		if len(teamMembers) < perPage {
+			return members, nil
+		}
+	}
+}
+
+// costCentersUsage compute the usage of each configured cost center during the current session
+func (p *Plugin) costCentersUsage() ([]*costCenterUsage, error) {
+	costCenters, err := parseCostCenters(p.getConfiguration().CostCenters)
+	if err != nil {
+		return nil, err
+	}
+
+	p.currentAnalytic.RLock()
+	defer p.currentAnalytic.RUnlock()
+
+	usages := make([]*costCenterUsage, 0, len(costCenters))
+	for name, teamNames := range costCenters {
+		usage := &costCenterUsage{name: name, teams: teamNames}
+		activeUsers := make(map[string]bool)
+		for _, teamName := range teamNames {
+			team, appErr := p.API.GetTeamByName(teamName)
+			if appErr != nil {
+				return nil, fmt.Errorf("Unable to find team with configured cost center team: %v", teamName)
+			}
+			members, err := p.getTeamMembersID(team.Id)
+			if err != nil {
+				return nil, err
+			}
+			for userID := range p.currentAnalytic.Users {
+				if members[userID] {
+					activeUsers[userID] = true
+				}
+			}
+			for channelID, nb := range p.currentAnalytic.Channels {
+				teamID, err := p.getChannelTeamID(channelID)
+				if err != nil {
+					return nil, err
+				}
+				if teamID == team.Id {
+					usage.messages += nb
+					usage.filesSize += p.currentAnalytic.ChannelsFilesSize[channelID]
+				}
+			}
+		}
+		usage.activeUsers = int64(len(activeUsers))
+		usages = append(usages, usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].name < usages[j].name
+	})
+	return usages, nil
+}
+
+// executeChargebackCommand handle `/analytics chargeback`
+func (p *Plugin) executeChargebackCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can see the chargeback report.")
+	}
+	T := p.channelTranslate(args.ChannelId)
+	usages, err := p.costCentersUsage()
+	if err != nil {
+		p.API.LogError("can't compute cost centers usage", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	if len(usages) == 0 {
+		return ephemeralResponse(T("report.chargeback.no_cost_center"))
+	}
+
+	p.currentAnalytic.RLock()
+	text := T("report.chargeback.title", map[string]interface{}{"Date": p.currentAnalytic.Start.Format(T("report.date_layout"))}) + "\n"
+	p.currentAnalytic.RUnlock()
+	text += T("report.chargeback.columns") + "\n|:--|:--|--:|--:|--:|\n"
+	for _, usage := range usages {
+		text += fmt.Sprintf("| %s | %s | %d | %d | %s |\n", usage.name, strings.Join(usage.teams, ", "), usage.activeUsers, usage.messages, byteCountDecimal(usage.filesSize))
+	}
+	return ephemeralResponse(text)
+}
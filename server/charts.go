@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// chartImage is a png chart attached to a report post
+type chartImage struct {
+	Name    string
+	Content []byte
+	// Usernames are the users drawn in the chart
+	Usernames []string
+}
+
+// dailyVolume sum hourly messages by day, sorted by day
+func dailyVolume(hourly map[string]int64) ([]time.Time, []int64) {
+	byDay := make(map[time.Time]int64)
+	for key, nb := range hourly {
+		hour, err := time.Parse(hourlyKeyFormat, key)
+		if err != nil {
+			continue
+		}
+		byDay[time.Date(hour.Year(), hour.Month(), hour.Day(), 0, 0, 0, 0, time.UTC)] += nb
+	}
+	days := make([]time.Time, 0, len(byDay))
+	for day := range byDay {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Before(days[j])
+	})
+	volumes := make([]int64, 0, len(days))
+	for _, day := range days {
+		volumes = append(volumes, byDay[day])
+	}
+	return days, volumes
+}
+
+// buildReportCharts render the message volume by day and the top users of the analytic as png images
+// a chart without enough data is skipped
+func (p *Plugin) buildReportCharts(analytic *Analytic, rtl bool) ([]*chartImage, error) {
+	analytic.RLock()
+	days, volumes := dailyVolume(analytic.Hourly)
+	users := topCounters(analytic.Users, maxUsersToDisplay)
+	analytic.RUnlock()
+
+	config := p.getConfiguration()
+	images := make([]*chartImage, 0, 2)
+	if len(days) > 1 {
+		query := url.Values{}
+		for index, day := range days {
+			query.Add("date", fmt.Sprintf("%d", day.Unix()))
+			query.Add("messages", fmt.Sprintf("%d", volumes[index]))
+		}
+		addRTLChartParameter(query, rtl)
+		graph, err := p.lineChart(query)
+		if err != nil {
+			return nil, err
+		}
+		var content bytes.Buffer
+		if err := graph.Render(chart.PNG, &content); err != nil {
+			return nil, err
+		}
+		branded, err := config.brandPNG(content.Bytes(), rtl)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, &chartImage{Name: "message-volume.png", Content: branded})
+	}
+
+	if len(users) > 0 {
+		query := url.Values{}
+		usernames := make([]string, 0, len(users))
+		for _, user := range users {
+			username, err := p.getUsername(user.key)
+			if err != nil {
+				continue
+			}
+			query.Add("@"+username, fmt.Sprintf("%d", user.nb))
+			usernames = append(usernames, username)
+		}
+		addRTLChartParameter(query, rtl)
+		var content bytes.Buffer
+		if err := barChart(query, config).Render(chart.PNG, &content); err != nil {
+			return nil, err
+		}
+		branded, err := config.brandPNG(content.Bytes(), rtl)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, &chartImage{Name: "top-users.png", Content: branded, Usernames: usernames})
+	}
+	return images, nil
+}
+
+// uploadReportCharts upload charts in channelID and return their file ids, a chart which can't be uploaded is skipped
+func (p *Plugin) uploadReportCharts(channelID string, images []*chartImage) []string {
+	fileIDs := make([]string, 0, len(images))
+	for _, image := range images {
+		fileInfo, appErr := p.API.UploadFile(image.Content, channelID, image.Name)
+		if appErr != nil {
+			p.API.LogWarn("can't upload chart", "channel", channelID, "chart", image.Name, "err", appErr.Error())
+			continue
+		}
+		fileIDs = append(fileIDs, fileInfo.Id)
+	}
+	return fileIDs
+}
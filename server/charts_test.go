@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDailyVolume(t *testing.T) {
+	assert := assert.New(t)
+	hourly := map[string]int64{
+		"2019-04-16T09": 3,
+		"2019-04-15T23": 1,
+		"2019-04-16T18": 2,
+		"bad":           5,
+	}
+
+	days, volumes := dailyVolume(hourly)
+	assert.Equal([]time.Time{
+		time.Date(2019, time.April, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2019, time.April, 16, 0, 0, 0, 0, time.UTC),
+	}, days)
+	assert.Equal([]int64{1, 5}, volumes)
+}
+
+func TestBarChart(t *testing.T) {
+	assert := assert.New(t)
+
+	labels := func(query url.Values) []string {
+		bars := make([]string, 0)
+		for _, bar := range barChart(query, &configuration{}).Bars {
+			bars = append(bars, bar.Label)
+		}
+		return bars
+	}
+	query := url.Values{}
+	for _, label := range []string{"Wed", "Mon", "Tue", "Fri", "Thu"} {
+		query.Set(label, "1")
+	}
+	for attempt := 0; attempt < 10; attempt++ {
+		assert.Equal([]string{"Fri", "Mon", "Thu", "Tue", "Wed"}, labels(query))
+	}
+
+	query.Set(rtlChartParameter, "1")
+	for attempt := 0; attempt < 10; attempt++ {
+		assert.Equal([]string{"Wed", "Tue", "Thu", "Mon", "Fri"}, labels(query))
+	}
+}
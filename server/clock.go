@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// Clock give the current time, replaced in tests to travel in time
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is the wall clock
+type realClock struct{}
+
+// Now return the current local time
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// fixedClock always return the same time
+type fixedClock time.Time
+
+// Now return the fixed time
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+// clockFunc is a function used as a clock, e.g. the clock of the plugin given to the journal
+type clockFunc func() time.Time
+
+// Now return the time given by the function
+func (f clockFunc) Now() time.Time {
+	return f()
+}
+
+// now return the current time of the plugin clock, the wall clock by default, in the configured timezone
+func (p *Plugin) now() time.Time {
+	var clock Clock = realClock{}
+	if p.clock != nil {
+		clock = p.clock
+	}
+	return clock.Now().In(p.getConfiguration().getLocation())
+}
+
+// atClock return a view of the plugin reading the time from clock, to build reports as they were built at another
+// time without moving the clock of the running plugin, the view shares the API, the configuration, the resolved
+// report channels, the daily store and the current session of the plugin
+func (p *Plugin) atClock(clock Clock) *Plugin {
+	p.dailyStoreLock.RLock()
+	store := p.dailyStore
+	p.dailyStoreLock.RUnlock()
+	return &Plugin{
+		MattermostPlugin:   p.MattermostPlugin,
+		configuration:      p.getConfiguration(),
+		currentAnalytic:    p.currentAnalytic,
+		clock:              clock,
+		translations:       p.translations,
+		BotUserID:          p.BotUserID,
+		ChannelsID:         p.ChannelsID,
+		ReportRoutes:       p.ReportRoutes,
+		RolloutTeamsID:     p.RolloutTeamsID,
+		ExcludedChannelsID: p.ExcludedChannelsID,
+		IncludedChannelsID: p.IncludedChannelsID,
+		CriticalChannelsID: p.CriticalChannelsID,
+		ChannelLanguages:   p.ChannelLanguages,
+		GlossaryChannelsID: p.GlossaryChannelsID,
+		dailyStore:         store,
+	}
+}
+
+// sessionAsOf return the archived session recording on date, or the current one if date is after its start
+func (p *Plugin) sessionAsOf(date time.Time) (*Analytic, error) {
+	sessions, err := p.allSessions()
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		if !date.Before(session.Start) && date.Before(session.End) {
+			return session, nil
+		}
+	}
+	p.currentAnalytic.RLock()
+	defer p.currentAnalytic.RUnlock()
+	if !date.Before(p.currentAnalytic.Start) {
+		return p.currentAnalytic, nil
+	}
+	return nil, nil
+}
+
+// executeSimulateCommand handle `/analytics simulate <YYYY-MM-DD>`, sending by direct message
+// the weekly report as it was built on this date, to validate template and threshold changes against history
+// the report is built by a view of the plugin whose clock is fixed on the date
+func (p *Plugin) executeSimulateCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can simulate reports.")
+	}
+	if len(parameters) != 1 {
+		return ephemeralResponse("Usage: /analytics simulate YYYY-MM-DD")
+	}
+	date, err := time.ParseInLocation("2006-01-02", parameters[0], p.getConfiguration().getLocation())
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("Bad date %s, expected YYYY-MM-DD.", parameters[0]))
+	}
+	simulation := p.atClock(fixedClock(date))
+	session, err := simulation.sessionAsOf(date)
+	if err != nil {
+		p.API.LogError("can't get sessions", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	if session == nil {
+		return ephemeralResponse(fmt.Sprintf("No analytics were recorded on %s.", date.Format("January 2, 2006")))
+	}
+	attachments, err := simulation.buildAnalyticAttachments(session, simulation.shouldShrinkDigest())
+	if err != nil {
+		p.API.LogError("can't build simulated report", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	message := fmt.Sprintf("#### Simulation of the weekly report as of %s\nThis report was not posted in report channels.", date.Format("January 2, 2006"))
+	if err := p.sendDirectMessage(args.UserId, message, attachments); err != nil {
+		p.API.LogError("can't send simulated report", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return ephemeralResponse("Simulated report sent by direct message.")
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginClock(t *testing.T) {
+	assert := assert.New(t)
+
+	location, err := loadLocation("America/New_York")
+	assert.Nil(err)
+	p := &Plugin{}
+	p.setConfiguration(&configuration{location: location})
+	before := time.Now()
+	assert.False(p.now().Before(before))
+	assert.Equal("America/New_York", p.now().Location().String())
+
+	date := time.Date(2019, time.April, 22, 3, 30, 0, 0, time.UTC)
+	p.clock = fixedClock(date)
+	assert.True(date.Equal(p.now()))
+	assert.Equal("2019-04-21", p.now().Format(dailyKeyFormat))
+
+	// a view at another time doesn't move the clock of the plugin
+	later := date.AddDate(0, 0, 7)
+	simulation := p.atClock(fixedClock(later))
+	assert.True(later.Equal(simulation.now()))
+	assert.True(date.Equal(p.now()))
+	assert.Equal(p.getConfiguration(), simulation.getConfiguration())
+}
+
+func TestAnalyticDates(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2019, time.April, 21, 0, 0, 0, 0, time.UTC)
+	analytic := NewAnalytic(start)
+	assert.Equal(start, analytic.Start)
+	analytic.Channels["channel1"] = 1
+
+	end := start.AddDate(0, 0, 7)
+	assert.Equal(end, analytic.Close(end).End)
+	analytic.Init(end)
+	assert.Equal(end, analytic.Start)
+	assert.True(analytic.End.IsZero())
+	assert.Empty(analytic.Channels)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// clusterLeaderKey store the lease of the node running reports and daily jobs in high availability mode
+	clusterLeaderKey = "cluster_leader"
+	// leaderLeaseDuration is the time a leader keeps its lease without renewing it, renewed every minute
+	leaderLeaseDuration = 3 * time.Minute
+)
+
+// LeaderLease is the lease of the leader node, its expiry is stored in the value as expired keys may not be
+// cleaned up yet
+type LeaderLease struct {
+	NodeID    string    `json:"node_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// canAcquire return true if nodeID may take the lease at now, when it is free, expired or its own
+func (l *LeaderLease) canAcquire(nodeID string, now time.Time) bool {
+	return l == nil || l.NodeID == nodeID || !now.Before(l.ExpiresAt)
+}
+
+// clusterNodeID return the id of this node in the cluster, its hostname or a random id
+func clusterNodeID() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return model.NewId()
+}
+
+// clusterEnabled return true if mattermost runs in high availability mode, each node running the plugin
+func (p *Plugin) clusterEnabled() bool {
+	enable := p.API.GetConfig().ClusterSettings.Enable
+	return enable != nil && *enable
+}
+
+// isLeader return true if this node should run reports and daily jobs, always true outside of a cluster
+func (p *Plugin) isLeader() bool {
+	if !p.clusterEnabled() {
+		return true
+	}
+	p.leaderLock.Lock()
+	defer p.leaderLock.Unlock()
+	return p.leader
+}
+
+// refreshLeadership take or renew the leader lease if it is free, expired or held by this node
+func (p *Plugin) refreshLeadership() error {
+	if !p.clusterEnabled() {
+		return nil
+	}
+	now := p.now()
+	old, appErr := p.API.KVGet(clusterLeaderKey)
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't get "+clusterLeaderKey+" from kv")
+	}
+	var lease *LeaderLease
+	if old != nil {
+		lease = &LeaderLease{}
+		if err := json.Unmarshal(old, lease); err != nil {
+			return errors.Wrap(err, "can't unmarshal "+clusterLeaderKey)
+		}
+	}
+
+	leader := false
+	if lease.canAcquire(p.nodeID, now) {
+		j, err := json.Marshal(&LeaderLease{NodeID: p.nodeID, ExpiresAt: now.Add(leaderLeaseDuration)})
+		if err != nil {
+			return errors.Wrap(err, "can't marshal "+clusterLeaderKey)
+		}
+		// KVSetWithOptions would need mattermost 5.20, compare and set is available since 5.12
+		leader, appErr = p.API.KVCompareAndSet(clusterLeaderKey, old, j)
+		if appErr != nil {
+			return errors.Wrap(appErr, "can't save "+clusterLeaderKey)
+		}
+	}
+
+	p.leaderLock.Lock()
+	defer p.leaderLock.Unlock()
+	if leader != p.leader {
+		p.API.LogInfo("analytics leadership changed", "node", p.nodeID, "leader", strconv.FormatBool(leader))
+	}
+	p.leader = leader
+	return nil
+}
+
+// mergeClusterSession add events counted by this node since the last merge to the session shared by all nodes,
+// the merged session becomes the current one, update is applied to it before it is saved, with rotate a new session
+// is shared instead and the merged one is left to be archived, caller must hold the write lock of the current session
+func (p *Plugin) mergeClusterSession(update func(merged *Analytic), rotate bool) error {
+	for attempt := 0; attempt < maxIncrementAttempts; attempt++ {
+		old, appErr := p.API.KVGet("analytics")
+		if appErr != nil {
+			return errors.Wrap(appErr, "can't get analytics from kv")
+		}
+		shared := NewAnalytic(p.currentAnalytic.Start)
+		if old != nil {
+			if err := json.Unmarshal(old, shared); err != nil {
+				return errors.Wrap(err, "can't unmarshal shared analytics")
+			}
+		}
+		merged := mergeAnalytics([]*Analytic{shared, p.clusterDelta})
+		merged.Start = shared.Start
+		if update != nil {
+			update(merged)
+		}
+		next := merged
+		if rotate {
+			next = NewAnalytic(p.now())
+		}
+		j, err := json.Marshal(next)
+		if err != nil {
+			return errors.Wrap(err, "can't marshal shared analytics")
+		}
+		saved, appErr := p.API.KVCompareAndSet("analytics", old, j)
+		if appErr != nil {
+			return errors.Wrap(appErr, "can't save shared analytics")
+		}
+		if !saved {
+			continue
+		}
+
+		if j, err = json.Marshal(merged); err != nil {
+			return errors.Wrap(err, "can't marshal merged analytics")
+		}
+		p.currentAnalytic.Init(p.now())
+		if err := json.Unmarshal(j, p.currentAnalytic); err != nil {
+			return errors.Wrap(err, "can't load merged analytics")
+		}
+		p.clusterDelta.Init(p.now())
+		return nil
+	}
+	return errors.New("too many concurrent updates of shared analytics")
+}
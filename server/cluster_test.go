@@ -0,0 +1,21 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderLeaseCanAcquire(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2020, 3, 16, 12, 0, 0, 0, time.UTC)
+
+	var free *LeaderLease
+	assert.True(free.canAcquire("node1", now))
+
+	lease := &LeaderLease{NodeID: "node1", ExpiresAt: now.Add(time.Minute)}
+	assert.True(lease.canAcquire("node1", now))
+	assert.False(lease.canAcquire("node2", now))
+	assert.True(lease.canAcquire("node2", now.Add(time.Minute)))
+}
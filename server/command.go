@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+func ephemeralResponse(text string) *model.CommandResponse {
+	return &model.CommandResponse{
+		ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
+		Text:         text,
+	}
+}
+
+// isSystemAdmin return true if the user can manage the system
+func (p *Plugin) isSystemAdmin(userID string) bool {
+	return p.API.HasPermissionTo(userID, model.PERMISSION_MANAGE_SYSTEM)
+}
+
+// executePauseCommand handle `/analytics pause <YYYY-MM-DD>`
+func (p *Plugin) executePauseCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can pause analytics posting.")
+	}
+	if len(parameters) != 1 {
+		return ephemeralResponse("Usage: /analytics pause YYYY-MM-DD")
+	}
+	until, err := time.ParseInLocation("2006-01-02", parameters[0], p.getConfiguration().getLocation())
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("Bad date %s, expected YYYY-MM-DD.", parameters[0]))
+	}
+	if err := p.pausePosting(args.UserId, until); err != nil {
+		p.API.LogError("can't pause posting", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return ephemeralResponse(fmt.Sprintf("Analytics posting is paused until %s, collection continues.", until.Format("January 2, 2006")))
+}
+
+// executeResumeCommand handle `/analytics resume`
+func (p *Plugin) executeResumeCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can resume analytics posting.")
+	}
+	if err := p.resumePosting(args.UserId); err != nil {
+		p.API.LogError("can't resume posting", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return ephemeralResponse("Analytics posting is resumed.")
+}
+
+// executeStatusCommand handle `/analytics status`
+func (p *Plugin) executeStatusCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can see analytics status.")
+	}
+	config := p.getConfiguration()
+
+	p.currentAnalytic.RLock()
+	text := fmt.Sprintf("#### Analytics status\n* Current session started on %s.\n", p.currentAnalytic.Start.Format("January 2, 2006 15:04"))
+	p.currentAnalytic.RUnlock()
+	if p.isPostingPaused() {
+		text += fmt.Sprintf("* Posting is paused until %s.\n", p.pausedUntil().Format("January 2, 2006"))
+	}
+	if config.CanaryMode {
+		text += "* Canary mode is enabled, digests are posted only in the canary channel.\n"
+	}
+	if config.rolloutActive() && config.rolloutPercentage() >= 100 {
+		text += fmt.Sprintf("* Progressive rollout: only %d teams allowed.\n", len(p.RolloutTeamsID))
+	} else if config.rolloutActive() {
+		text += fmt.Sprintf("* Progressive rollout: %d teams allowed and %d%% of other teams.\n", len(p.RolloutTeamsID), config.rolloutPercentage())
+	}
+	if progress := p.backfillProgress(); progress != nil {
+		text += fmt.Sprintf("* Backfill of the last %d days: %s.\n", progress.Days, progress.describe(p.now()))
+	}
+	if failures, err := p.deliveryFailures(); err == nil && failures.Total > 0 {
+		text += fmt.Sprintf("* %d reports not delivered, last one at %s.\n", failures.Total, failures.LastFailureAt.Format("January 2, 2006 15:04"))
+	}
+	conflicts, err := p.conflictingPlugins()
+	if err != nil {
+		p.API.LogWarn("can't detect conflicting plugins", "err", err.Error())
+	} else if len(conflicts) > 0 {
+		text += "* :warning: " + conflictsWarning(conflicts) + "\n"
+	}
+	return ephemeralResponse(text)
+}
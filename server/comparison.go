@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// periodSlack is the tolerance on the start of sessions of the previous period, scheduled reports don't run at the same second
+const periodSlack = 24 * time.Hour
+
+// periodTotals are the headline metrics of a period compared with the previous period
+type periodTotals struct {
+	Messages  int64
+	Users     int64
+	Channels  int64
+	Files     int64
+	FilesSize int64
+}
+
+// analyticTotals return the headline metrics of the analytic, users and channels with replies only are counted
+func analyticTotals(analytic *Analytic) periodTotals {
+	analytic.RLock()
+	defer analytic.RUnlock()
+	totals := periodTotals{Messages: analytic.DirectMessages, Files: analytic.FilesNb, FilesSize: analytic.FilesSize}
+	for _, nb := range analytic.Channels {
+		totals.Messages += nb
+	}
+	totals.Users = int64(len(unionKeys(analytic.Users, analytic.UsersReply)))
+	totals.Channels = int64(len(unionKeys(analytic.Channels, analytic.ChannelsReply)))
+	return totals
+}
+
+func unionKeys(counters ...map[string]int64) map[string]bool {
+	keys := make(map[string]bool)
+	for _, counter := range counters {
+		for key := range counter {
+			keys[key] = true
+		}
+	}
+	return keys
+}
+
+// previousPeriod return closed sessions started in the period of the same length just before [start, end), sorted by start
+// a period shorter than a session only matches the previous session when it is nearly as long
+func previousPeriod(sessions []*Analytic, start time.Time, end time.Time) []*Analytic {
+	from := start.Add(-end.Sub(start) - periodSlack)
+	previous := make([]*Analytic, 0)
+	for _, session := range sessions {
+		if !session.End.IsZero() && session.Start.Before(start) && !session.Start.Before(from) {
+			previous = append(previous, session)
+		}
+	}
+	sort.Slice(previous, func(i, j int) bool {
+		return previous[i].Start.Before(previous[j].Start)
+	})
+	return previous
+}
+
+// collectPreviousTotals return the headline metrics of the period before the analytic ending at end, nil without session
+// in that period, sessions are filtered by include if not nil
+func (p *Plugin) collectPreviousTotals(analytic *Analytic, end time.Time, include func(channelID string) bool) (*periodTotals, error) {
+	sessions, err := p.allSessions()
+	if err != nil {
+		return nil, err
+	}
+	analytic.RLock()
+	start := analytic.Start
+	analytic.RUnlock()
+
+	previous := previousPeriod(sessions, start, end)
+	if len(previous) == 0 {
+		return nil, nil
+	}
+	merged := mergeAnalytics(previous)
+	if include != nil {
+		merged = filterAnalytic(merged, include)
+	}
+	totals := analyticTotals(merged)
+	return &totals, nil
+}
+
+// changeNote return the evolution of a metric since the previous period, e.g. *(▲ 12% vs previous period)*
+// empty without previous period or when the metric was 0
+func changeNote(current periodTotals, previous *periodTotals, metric func(periodTotals) int64, threshold deltaThreshold, T translateFunc) string {
+	if previous == nil || metric(*previous) == 0 {
+		return ""
+	}
+	return T("report.vs_previous", map[string]interface{}{"Change": percentChange(metric(current), metric(*previous), threshold)})
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyticTotals(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	analytic.Channels = map[string]int64{"channel1": 10, "channel2": 5}
+	analytic.ChannelsReply = map[string]int64{"channel3": 2}
+	analytic.Users = map[string]int64{"user1": 12, "user2": 3}
+	analytic.UsersReply = map[string]int64{"user2": 1, "user3": 1}
+	analytic.FilesNb = 4
+	analytic.FilesSize = 2048
+	analytic.DirectMessages = 5
+
+	assert.Equal(periodTotals{Messages: 20, Users: 3, Channels: 3, Files: 4, FilesSize: 2048}, analyticTotals(analytic))
+	assert.Equal(periodTotals{}, analyticTotals(NewAnalytic(time.Now())))
+}
+
+func TestPreviousPeriod(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Date(2020, time.March, 16, 9, 0, 0, 0, time.UTC)
+	session := func(start time.Time, days int) *Analytic {
+		analytic := NewAnalytic(time.Now())
+		analytic.Start = start
+		analytic.End = start.AddDate(0, 0, days)
+		return analytic
+	}
+	weekBefore := session(start.AddDate(0, 0, -7), 7)
+	twoWeeksBefore := session(start.AddDate(0, 0, -14), 7)
+	current := session(start, 0)
+	current.End = time.Time{}
+	sessions := []*Analytic{twoWeeksBefore, weekBefore, current}
+
+	assert.Equal([]*Analytic{weekBefore}, previousPeriod(sessions, start, start.AddDate(0, 0, 7)))
+	assert.Equal([]*Analytic{weekBefore}, previousPeriod(sessions, start, start.AddDate(0, 0, 6)))
+	assert.Equal([]*Analytic{twoWeeksBefore, weekBefore}, previousPeriod(sessions, start, start.AddDate(0, 0, 14)))
+	assert.Empty(previousPeriod(sessions, start, start.AddDate(0, 0, 3)))
+	assert.Empty(previousPeriod(nil, start, start.AddDate(0, 0, 7)))
+}
+
+func TestChangeNote(t *testing.T) {
+	assert := assert.New(t)
+
+	T := testTranslate(t, "en")
+	threshold := deltaThreshold{Percent: 10, Absolute: 5}
+	messages := func(t periodTotals) int64 { return t.Messages }
+	current := periodTotals{Messages: 112}
+
+	assert.Equal(" *(▲ 12% vs previous period)*", changeNote(current, &periodTotals{Messages: 100}, messages, threshold, T))
+	assert.Equal(" *(+1% vs previous period)*", changeNote(current, &periodTotals{Messages: 110}, messages, threshold, T))
+	assert.Equal(" *(▼ 44% vs previous period)*", changeNote(current, &periodTotals{Messages: 200}, messages, threshold, T))
+	assert.Equal("", changeNote(current, &periodTotals{}, messages, threshold, T))
+	assert.Equal("", changeNote(current, nil, messages, threshold, T))
+	assert.Equal(" *(▲ 12% par rapport à la période précédente)*", changeNote(current, &periodTotals{Messages: 100}, messages, threshold, testTranslate(t, "fr")))
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -20,22 +22,114 @@ import (
 // If you add non-reference types to your configuration struct, be sure to rewrite Clone as a deep
 // copy appropriate for your types.
 type configuration struct {
-	Username      string
-	TeamsChannels string
-	BotUsername   string
-	BotIconURL    string
+	// ReportChannels are picked in the admin console, TeamsChannels is the legacy setting used when none is picked
+	ReportChannels         []ReportChannel
+	TeamsChannels          string
+	BotUsername            string
+	BotIconURL             string
+	CanaryMode             bool
+	CanaryChannel          string
+	RolloutTeams           string
+	RolloutPercentage      *int
+	ConsentMode            string
+	ExcludedChannels       string
+	IncludedChannels       string
+	CriticalChannels       string
+	SilenceHours           int
+	AnomalyStdDevs         int
+	AnomalyAlertChannel    string
+	RetentionDays          int
+	PurgeGraceDays         int
+	AccumulatorMemoryLimit int
+	EnableProfiling        bool
+	TransparencyDM         bool
+	AnonymousMode          bool
+	IgnoreBots             bool
+	IgnoreWebhooks         bool
+	TrackDirectMessages    bool
+	ThreadedDigests        bool
+
+	ShrinkUnusefulDigests bool
+	KeepEmojiVariants     bool
+	AttachChartImages     bool
+	// DigestDelivery is where scheduled digests are delivered: central, in-channel, dm or all
+	DigestDelivery  string
+	LeaderboardSize int
+
+	WeekStart               string
+	FiscalYearStartMonth    int
+	DeltaMinPercent         int
+	DeltaMinAbsolute        int
+	ExecutiveUsernames      string
+	CostCenters             string
+	OnCallRotations         string
+	HealthWeights           string
+	MessageLengthThresholds string
+
+	ReportSchedule   string
+	DeliveryWindows  string
+	Timezone         string
+	Language         string
+	ChannelLanguages string
+	GlossaryChannels string
+
+	SurveyChannels string
+	SurveyQuestion string
+	SurveySchedule string
+
+	JournalDirectory string
+
+	ExportMaskingPolicies string
+
+	MetricsToken string
+
+	ElasticsearchURL      string
+	ElasticsearchIndex    string
+	ElasticsearchUsername string
+	ElasticsearchPassword string
+	ElasticsearchEvents   bool
+
+	OutgoingWebhookURL    string
+	OutgoingWebhookSecret string
+
+	ArchiveEndpoint  string
+	ArchiveBucket    string
+	ArchivePrefix    string
+	ArchiveRegion    string
+	ArchiveAccessKey string
+	ArchiveSecretKey string
+	ArchiveFormat    string
+
+	StorageBackend     string
+	WriteBufferSeconds int
+	WriteBufferEvents  int
+
+	ChartTheme  string
+	BrandColors string
+	// BrandLogo is the id of the logo uploaded in the admin console, empty without logo
+	BrandLogo string
+
+	// logo is the loaded BrandLogo
+	logo *brandLogo
+	// location is the parsed Timezone
+	location *time.Location
 }
 
 // IsValid validates if all the required fields are set.
 func (c *configuration) IsValid() error {
-	if c.Username == "" {
-		return errors.New("Need a Username to make posts as")
+	if !isDigestDelivery(c.DigestDelivery) {
+		return errors.New("DigestDelivery must be central, in-channel, dm or all")
 	}
-	if c.TeamsChannels == "" {
-		return errors.New("Need TeamsChannels to post in")
+	if len(c.ReportChannels) == 0 && c.TeamsChannels == "" && c.deliversCentral() {
+		return errors.New("Need ReportChannels to post in")
 	}
-	if strings.Count(c.TeamsChannels, ",")+1 != strings.Count(c.TeamsChannels, "/") {
-		return errors.New("TeamsChannels must be in ofrm TeamName/ChannelName")
+	if err := validateReportChannels(c.ReportChannels); err != nil {
+		return err
+	}
+	if len(c.ReportChannels) == 0 {
+		if _, _, err := parseReportRoutes(c.TeamsChannels); err != nil {
+			return err
+		}
 	}
 	if c.BotUsername == "" {
 		return errors.New("Need BotUsername")
@@ -43,14 +137,164 @@ func (c *configuration) IsValid() error {
 	if c.BotIconURL == "" {
 		return errors.New("Need BotIconURL")
 	}
+	if c.ExcludedChannels != "" && strings.Count(c.ExcludedChannels, ",")+1 != strings.Count(c.ExcludedChannels, "/") {
+		return errors.New("ExcludedChannels must be in form TeamName/ChannelName")
+	}
+	if c.IncludedChannels != "" && strings.Count(c.IncludedChannels, ",")+1 != strings.Count(c.IncludedChannels, "/") {
+		return errors.New("IncludedChannels must be in form TeamName/ChannelName")
+	}
+	if c.CriticalChannels != "" && strings.Count(c.CriticalChannels, ",")+1 != strings.Count(c.CriticalChannels, "/") {
+		return errors.New("CriticalChannels must be in form TeamName/ChannelName")
+	}
+	if c.SilenceHours < 0 {
+		return errors.New("SilenceHours must be positive")
+	}
+	if c.AnomalyStdDevs < 0 {
+		return errors.New("AnomalyStdDevs must be positive")
+	}
+	if c.AnomalyAlertChannel != "" && strings.Count(c.AnomalyAlertChannel, "/") != 1 {
+		return errors.New("AnomalyAlertChannel must be in form TeamName/ChannelName")
+	}
+	if c.RetentionDays < 0 {
+		return errors.New("RetentionDays must be positive")
+	}
+	if c.PurgeGraceDays < 0 {
+		return errors.New("PurgeGraceDays must be positive")
+	}
+	if c.AccumulatorMemoryLimit < 0 {
+		return errors.New("AccumulatorMemoryLimit must be positive")
+	}
+	if c.LeaderboardSize < 0 {
+		return errors.New("LeaderboardSize must be positive")
+	}
+	if c.CanaryMode && strings.Count(c.CanaryChannel, "/") != 1 {
+		return errors.New("CanaryChannel must be in form TeamName/ChannelName")
+	}
+	if percentage := c.rolloutPercentage(); percentage < 0 || percentage > 100 {
+		return errors.New("RolloutPercentage must be between 0 and 100")
+	}
+	if c.ConsentMode != "" && c.ConsentMode != consentModeOff && c.ConsentMode != consentModeNotify && c.ConsentMode != consentModeStrict {
+		return errors.New("ConsentMode must be off, notify or strict")
+	}
+	if c.WriteBufferSeconds < 0 {
+		return errors.New("WriteBufferSeconds must be positive")
+	}
+	if c.WriteBufferEvents < 0 {
+		return errors.New("WriteBufferEvents must be positive")
+	}
+	if c.ArchiveFormat != "" && c.ArchiveFormat != "json" && c.ArchiveFormat != exportFormatParquet {
+		return errors.New("ArchiveFormat must be json or parquet")
+	}
+	if _, ok := chartThemes[c.ChartTheme]; c.ChartTheme != "" && !ok {
+		return errors.New("ChartTheme must be light or dark")
+	}
+	if _, err := parseBrandColors(c.BrandColors); err != nil {
+		return err
+	}
+	if c.StorageBackend != "" && c.StorageBackend != storageBackendKV && c.StorageBackend != storageBackendSQL {
+		return errors.New("StorageBackend must be kv or sql")
+	}
+	if c.WeekStart != "" && c.WeekStart != "sunday" && c.WeekStart != "monday" {
+		return errors.New("WeekStart must be sunday or monday")
+	}
+	if c.FiscalYearStartMonth < 0 || c.FiscalYearStartMonth > 12 {
+		return errors.New("FiscalYearStartMonth must be between 1 and 12")
+	}
+	if c.DeltaMinPercent < 0 || c.DeltaMinAbsolute < 0 {
+		return errors.New("DeltaMinPercent and DeltaMinAbsolute must be positive")
+	}
+	if _, err := parseMaskingPolicies(c.ExportMaskingPolicies); err != nil {
+		return err
+	}
+	if c.ElasticsearchURL != "" {
+		if u, err := url.Parse(c.ElasticsearchURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Bad ElasticsearchURL %v, expected http(s)://host:port", c.ElasticsearchURL)
+		}
+	}
+	if c.OutgoingWebhookURL != "" {
+		if u, err := url.Parse(c.OutgoingWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Bad OutgoingWebhookURL %v, expected http(s)://host/path", c.OutgoingWebhookURL)
+		}
+		if c.OutgoingWebhookSecret == "" {
+			return errors.New("OutgoingWebhookSecret must be set to sign reports pushed to OutgoingWebhookURL")
+		}
+	}
+	if c.ArchiveEndpoint != "" {
+		if u, err := url.Parse(c.ArchiveEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Bad ArchiveEndpoint %v, expected http(s)://host:port", c.ArchiveEndpoint)
+		}
+		if c.ArchiveBucket == "" || strings.Contains(c.ArchiveBucket, "/") {
+			return errors.New("ArchiveBucket must be the name of a bucket when ArchiveEndpoint is set")
+		}
+	}
+	if c.ElasticsearchIndex != "" && (strings.ToLower(c.ElasticsearchIndex) != c.ElasticsearchIndex || strings.ContainsAny(c.ElasticsearchIndex, " ,/*?\"<>|#:")) {
+		return fmt.Errorf("Bad ElasticsearchIndex %v, expected a lowercase name without special characters", c.ElasticsearchIndex)
+	}
+	if _, err := parseCostCenters(c.CostCenters); err != nil {
+		return err
+	}
+	if _, err := parseOnCallRotations(c.OnCallRotations); err != nil {
+		return err
+	}
+	if _, err := parseHealthWeights(c.HealthWeights); err != nil {
+		return err
+	}
+	if _, err := parseLengthThresholds(c.MessageLengthThresholds); err != nil {
+		return err
+	}
+	if c.Language != "" && !isSupportedLanguage(c.Language) {
+		return fmt.Errorf("Unsupported language %v, expected one of %v", c.Language, strings.Join(supportedLanguages, ", "))
+	}
+	if _, err := parseReportSchedule(c.ReportSchedule); err != nil {
+		return err
+	}
+	if c.SurveyChannels != "" && strings.Count(c.SurveyChannels, ",")+1 != strings.Count(c.SurveyChannels, "/") {
+		return errors.New("SurveyChannels must be in form TeamName/ChannelName")
+	}
+	if _, err := parseReportSchedule(c.SurveySchedule); err != nil {
+		return err
+	}
+	if _, err := parseDeliveryWindows(c.DeliveryWindows, time.UTC); err != nil {
+		return err
+	}
+	if _, err := parseChannelLanguages(c.ChannelLanguages); err != nil {
+		return err
+	}
+	if c.GlossaryChannels != "" && strings.Count(c.GlossaryChannels, ",")+1 != strings.Count(c.GlossaryChannels, "/") {
+		return errors.New("GlossaryChannels must be in form TeamName/ChannelName")
+	}
+	if _, err := loadLocation(c.Timezone); err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// loadLocation return the location of an IANA timezone name (e.g. Europe/Paris), the server one when empty
+func loadLocation(timezone string) (*time.Location, error) {
+	if strings.TrimSpace(timezone) == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(strings.TrimSpace(timezone))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Timezone %s is unknown", timezone)
+	}
+	return location, nil
+}
+
+// getLocation return the timezone used to bucket days and fire scheduled reports
+func (c *configuration) getLocation() *time.Location {
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
+}
+
 // Clone shallow copies the configuration. Your implementation may require a deep copy if
 // your configuration has reference types.
 func (c *configuration) Clone() *configuration {
 	var clone = *c
+	clone.ReportChannels = append([]ReportChannel(nil), c.ReportChannels...)
 	return &clone
 }
 
@@ -103,31 +347,164 @@ func (p *Plugin) OnConfigurationChange() error {
 	if err := p.API.LoadPluginConfiguration(configuration); err != nil {
 		return errors.Wrap(err, "failed to load plugin configuration")
 	}
+	if location, err := loadLocation(configuration.Timezone); err == nil {
+		configuration.location = location
+	}
+	logo, err := p.loadBrandLogo(configuration.BrandLogo)
+	if err != nil {
+		p.API.LogWarn("can't load brand logo, charts are drawn without it", "err", err.Error())
+	}
+	configuration.logo = logo
 
+	previous := p.getConfiguration()
 	p.setConfiguration(configuration)
 
 	if err := configuration.IsValid(); err != nil {
 		return err
 	}
 
-	user, apErr := p.API.GetUserByUsername(configuration.Username)
-	if apErr != nil {
-		return fmt.Errorf("Unable to find user with configured username: %v", configuration.Username)
+	// buffered increments are written to the store they were counted for
+	if err := p.flushWriteBuffer(); err != nil {
+		p.API.LogError("can't flush daily analytics", "err", err.Error())
+	}
+	if err := p.configureDailyStore(configuration); err != nil {
+		return errors.Wrap(err, "can't configure storage backend")
 	}
-	p.BotUserID = user.Id
 
-	channelsID, err := p.parseChannelsFromConfig(configuration)
+	if p.BotUserID != "" {
+		if err := p.applyBotProfile(configuration); err != nil {
+			p.API.LogWarn("can't apply bot profile", "err", err.Error())
+		}
+	}
+
+	if p.scheduler != nil {
+		if err := p.scheduler.Replace(configuration); err != nil {
+			return err
+		}
+	}
+
+	// journal is opened in OnActivate after replaying pending events
+	if p.currentAnalytic != nil {
+		if err := p.journal.Open(configuration.JournalDirectory); err != nil {
+			return err
+		}
+	}
+
+	channelsID, reportRoutes, err := p.resolveConfiguredReportChannels(configuration)
 	if err != nil {
 		return err
 	}
 	p.ChannelsID = channelsID
+	p.ReportRoutes = reportRoutes
+	windows, err := parseDeliveryWindows(configuration.DeliveryWindows, configuration.getLocation())
+	if err != nil {
+		return err
+	}
+	deliveryWindows, err := p.resolveDeliveryWindows(windows)
+	if err != nil {
+		return err
+	}
+	p.DeliveryWindows = deliveryWindows
+	languages, err := parseChannelLanguages(configuration.ChannelLanguages)
+	if err != nil {
+		return err
+	}
+	channelLanguages := make(map[string]string, len(languages))
+	for channel, language := range languages {
+		channelsID, err := p.parseChannelsFromConfig(channel)
+		if err != nil {
+			return err
+		}
+		channelLanguages[channelsID[0]] = language
+	}
+	p.ChannelLanguages = channelLanguages
+	glossaryChannelsID, err := p.parseChannelsSet(configuration.GlossaryChannels)
+	if err != nil {
+		return err
+	}
+	p.GlossaryChannelsID = glossaryChannelsID
+
+	p.CanaryChannelID = ""
+	if configuration.CanaryMode {
+		canaryChannelsID, err := p.parseChannelsFromConfig(configuration.CanaryChannel)
+		if err != nil {
+			return err
+		}
+		p.CanaryChannelID = canaryChannelsID[0]
+	}
+
+	excludedChannelsID, err := p.parseChannelsSet(configuration.ExcludedChannels)
+	if err != nil {
+		return err
+	}
+	p.ExcludedChannelsID = excludedChannelsID
+	includedChannelsID, err := p.parseChannelsSet(configuration.IncludedChannels)
+	if err != nil {
+		return err
+	}
+	p.IncludedChannelsID = includedChannelsID
+	criticalChannelsID, err := p.parseChannelsSet(configuration.CriticalChannels)
+	if err != nil {
+		return err
+	}
+	p.CriticalChannelsID = criticalChannelsID
+	p.AnomalyChannelID = ""
+	if configuration.AnomalyAlertChannel != "" {
+		anomalyChannelsID, err := p.parseChannelsFromConfig(configuration.AnomalyAlertChannel)
+		if err != nil {
+			return err
+		}
+		p.AnomalyChannelID = anomalyChannelsID[0]
+	}
+	surveyChannelsID := make([]string, 0)
+	if configuration.SurveyChannels != "" {
+		if surveyChannelsID, err = p.parseChannelsFromConfig(configuration.SurveyChannels); err != nil {
+			return err
+		}
+	}
+	p.SurveyChannelsID = surveyChannelsID
+
+	rolloutTeamsID, err := p.parseRolloutTeams(configuration.RolloutTeams)
+	if err != nil {
+		return err
+	}
+	p.RolloutTeamsID = rolloutTeamsID
+
+	// admins of channels whose tracking is enabled are notified, without a bot they are on activation
+	if p.BotUserID != "" && trackingChanged(previous, configuration) {
+		go p.requestConsents()
+	}
 
 	return nil
 }
 
-func (p *Plugin) parseChannelsFromConfig(configuration *configuration) ([]string, error) {
+// resolveConfiguredReportChannels return channels receiving all analytics and routed report channels, from the
+// channels picked in the admin console or else from the legacy TeamsChannels
+func (p *Plugin) resolveConfiguredReportChannels(configuration *configuration) ([]string, map[string]*reportRoute, error) {
+	if len(configuration.ReportChannels) > 0 {
+		return p.resolveReportChannels(configuration.ReportChannels)
+	}
+	global, routes, err := parseReportRoutes(configuration.TeamsChannels)
+	if err != nil {
+		return nil, nil, err
+	}
+	channelsID := make([]string, 0)
+	if len(global) > 0 {
+		if channelsID, err = p.parseChannelsFromConfig(strings.Join(global, ",")); err != nil {
+			return nil, nil, err
+		}
+	}
+	reportRoutes, err := p.resolveReportRoutes(routes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return channelsID, reportRoutes, nil
+}
+
+// parseChannelsFromConfig take a list of TeamName/ChannelName separated by comma and return channels id
+func (p *Plugin) parseChannelsFromConfig(config string) ([]string, error) {
 	channelsID := make([]string, 0)
-	for _, teamsChannels := range strings.Split(configuration.TeamsChannels, ",") {
+	for _, teamsChannels := range strings.Split(config, ",") {
 		v := strings.Split(teamsChannels, "/")
 		if len(v) != 2 {
 			return channelsID, fmt.Errorf("Bad formatted TeamsChannels: %v", teamsChannels)
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// knownAnalyticsPlugins are ids of plugins collecting the same analytics as this one
+var knownAnalyticsPlugins = []string{
+	"com.github.manland.mattermost-plugin-analytics",
+	"com.mattermost.plugin-insights",
+	"com.mattermost.plugin-channel-analytics",
+	"mattermost-plugin-analytics",
+	"mattermost-plugin-stats",
+}
+
+// isAnalyticsPlugin return true if a plugin is known to collect analytics or looks like it does
+func isAnalyticsPlugin(id string) bool {
+	for _, known := range knownAnalyticsPlugins {
+		if id == known {
+			return true
+		}
+	}
+	lower := strings.ToLower(id)
+	return strings.Contains(lower, "analytics") || strings.Contains(lower, "insights")
+}
+
+// conflictingPlugins return manifests of other enabled plugins collecting analytics
+func (p *Plugin) conflictingPlugins() ([]*model.Manifest, error) {
+	manifests, appErr := p.API.GetPlugins()
+	if appErr != nil {
+		return nil, appErr
+	}
+	conflicts := make([]*model.Manifest, 0)
+	for _, m := range manifests {
+		if m.Id == manifest.Id || !isAnalyticsPlugin(m.Id) {
+			continue
+		}
+		status, appErr := p.API.GetPluginStatus(m.Id)
+		if appErr != nil || status.State != model.PluginStateRunning {
+			continue
+		}
+		conflicts = append(conflicts, m)
+	}
+	return conflicts, nil
+}
+
+func conflictsWarning(conflicts []*model.Manifest) string {
+	names := make([]string, 0, len(conflicts))
+	for _, m := range conflicts {
+		names = append(names, fmt.Sprintf("%s (`%s`)", m.Name, m.Id))
+	}
+	return fmt.Sprintf("Other analytics plugins are enabled: %s. Messages are collected twice, which adds overhead, and users may receive conflicting digests.", strings.Join(names, ", "))
+}
+
+// warnAboutConflicts DM system admins if other analytics plugins are enabled
+func (p *Plugin) warnAboutConflicts() {
+	conflicts, err := p.conflictingPlugins()
+	if err != nil {
+		p.API.LogWarn("can't detect conflicting plugins", "err", err.Error())
+		return
+	}
+	if len(conflicts) == 0 {
+		return
+	}
+	message := conflictsWarning(conflicts)
+	p.API.LogWarn(message)
+	admins, err := p.getSystemAdmins()
+	if err != nil {
+		p.API.LogWarn("can't warn system admins about conflicting plugins", "err", err.Error())
+		return
+	}
+	for _, adminID := range admins {
+		if err := p.sendDirectMessage(adminID, ":warning: "+message, nil); err != nil {
+			p.API.LogWarn("can't warn system admin about conflicting plugins", "user", adminID, "err", err.Error())
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	consentModeOff    = "off"
+	consentModeNotify = "notify"
+	consentModeStrict = "strict"
+
+	consentKeyPrefix = "consent_"
+)
+
+// ChannelConsent store the state of notification and acknowledgment of channel admins for a tracked channel
+type ChannelConsent struct {
+	ChannelID string
+	// NotifiedAt is the date where channel admins were informed of the tracking
+	NotifiedAt time.Time
+	// Accepted is true once a channel admin acknowledged the tracking
+	Accepted bool
+	// AcceptedBy is the id of the channel admin who acknowledged the tracking
+	AcceptedBy string
+	AcceptedAt time.Time
+}
+
+// hasConsent return true if analytics can be collected in this channel
+// in notify mode a channel whose admins were not notified yet is collected, they are notified when its tracking
+// starts, see requestConsent
+func (p *Plugin) hasConsent(channelID string) bool {
+	return p.checkConsent(channelID, true)
+}
+
+// hasRecordedConsent return true if analytics can be collected in this channel, like hasConsent except a channel
+// whose admins were never notified has no consent yet
+func (p *Plugin) hasRecordedConsent(channelID string) bool {
+	return p.checkConsent(channelID, false)
+}
+
+// consentChannel return the consent mode and false if channelID needs no consent, consent is off or it's a DM or
+// group channel, which has no channel admin to ask
+func (p *Plugin) consentChannel(channelID string) (string, bool) {
+	mode := p.getConfiguration().ConsentMode
+	if mode == "" || mode == consentModeOff {
+		return mode, false
+	}
+	teamID, err := p.getChannelTeamID(channelID)
+	if err != nil {
+		p.API.LogWarn("can't find team of channel", "channel", channelID, "err", err.Error())
+		return mode, true
+	}
+	return mode, teamID != ""
+}
+
+// checkConsent return true if analytics can be collected in this channel, a channel whose admins were never
+// notified is collected in notify mode only if unrecorded is set
+func (p *Plugin) checkConsent(channelID string, unrecorded bool) bool {
+	mode, needed := p.consentChannel(channelID)
+	if !needed {
+		return true
+	}
+
+	p.consentsLock.Lock()
+	defer p.consentsLock.Unlock()
+
+	consent, err := p.getChannelConsent(channelID)
+	if err != nil {
+		p.API.LogError("can't get channel consent", "channel", channelID, "err", err.Error())
+		return false
+	}
+	if consent == nil {
+		return unrecorded && mode == consentModeNotify
+	}
+	return mode == consentModeNotify || consent.Accepted
+}
+
+// requestConsent notify admins of channelID that its tracking starts, once, in strict mode their acknowledgment is
+// requested before analytics are collected
+func (p *Plugin) requestConsent(channelID string) error {
+	mode, needed := p.consentChannel(channelID)
+	if !needed {
+		return nil
+	}
+
+	p.consentsLock.Lock()
+	defer p.consentsLock.Unlock()
+
+	consent, err := p.getChannelConsent(channelID)
+	if err != nil || consent != nil {
+		return err
+	}
+	consent = &ChannelConsent{ChannelID: channelID, NotifiedAt: p.now()}
+	if err := p.saveChannelConsent(consent); err != nil {
+		return err
+	}
+	p.audit("consent_notified", "", map[string]string{"channel_id": channelID, "mode": mode})
+	go p.notifyChannelAdmins(channelID, mode == consentModeStrict)
+	return nil
+}
+
+// trackingChanged return true if channels collected with next may not be collected with previous, or need a consent
+// they didn't need
+func trackingChanged(previous *configuration, next *configuration) bool {
+	return previous.ConsentMode != next.ConsentMode ||
+		previous.IncludedChannels != next.IncludedChannels ||
+		previous.ExcludedChannels != next.ExcludedChannels ||
+		previous.RolloutTeams != next.RolloutTeams ||
+		previous.rolloutPercentage() != next.rolloutPercentage()
+}
+
+// requestConsents request the consent of admins of the channels whose tracking is enabled by the configuration, the
+// included channels or else the collected public channels, channels created later are requested on creation
+func (p *Plugin) requestConsents() {
+	if mode := p.getConfiguration().ConsentMode; mode == "" || mode == consentModeOff {
+		return
+	}
+	channelsID := make([]string, 0, len(p.IncludedChannelsID))
+	for channelID := range p.IncludedChannelsID {
+		channelsID = append(channelsID, channelID)
+	}
+	if len(channelsID) == 0 {
+		var err error
+		if channelsID, err = p.collectedPublicChannelsID(); err != nil {
+			p.API.LogError("can't list collected channels to request consent", "err", err.Error())
+			return
+		}
+	}
+	for _, channelID := range channelsID {
+		if !p.isChannelCollected(channelID) || !p.isChannelEnabled(channelID) {
+			continue
+		}
+		if err := p.requestConsent(channelID); err != nil {
+			p.API.LogError("can't request channel consent", "channel", channelID, "err", err.Error())
+		}
+	}
+}
+
+// ChannelHasBeenCreated is called by mattermost when a channel has been created, its tracking starts with it
+func (p *Plugin) ChannelHasBeenCreated(c *plugin.Context, channel *model.Channel) {
+	if !p.isChannelCollected(channel.Id) || !p.isChannelEnabled(channel.Id) {
+		return
+	}
+	if err := p.requestConsent(channel.Id); err != nil {
+		p.API.LogError("can't request channel consent", "channel", channel.Id, "err", err.Error())
+	}
+}
+
+// getChannelConsent return consent of a channel from cache or kv, nil if channel admins were never notified
+func (p *Plugin) getChannelConsent(channelID string) (*ChannelConsent, error) {
+	if consent, ok := p.consents[channelID]; ok {
+		return consent, nil
+	}
+	j, appErr := p.API.KVGet(consentKeyPrefix + channelID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "can't get consent from kv")
+	}
+	if j == nil {
+		return nil, nil
+	}
+	var consent ChannelConsent
+	if err := json.Unmarshal(j, &consent); err != nil {
+		return nil, errors.Wrap(err, "can't unmarshal consent")
+	}
+	if p.consents == nil {
+		p.consents = make(map[string]*ChannelConsent)
+	}
+	p.consents[channelID] = &consent
+	return &consent, nil
+}
+
+func (p *Plugin) saveChannelConsent(consent *ChannelConsent) error {
+	j, err := json.Marshal(consent)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal consent")
+	}
+	if appErr := p.API.KVSet(consentKeyPrefix+consent.ChannelID, j); appErr != nil {
+		return errors.Wrap(appErr, "can't save consent")
+	}
+	if p.consents == nil {
+		p.consents = make(map[string]*ChannelConsent)
+	}
+	p.consents[consent.ChannelID] = consent
+	return nil
+}
+
+// getChannelAdmins return ids of all channel admins of a channel
+func (p *Plugin) getChannelAdmins(channelID string) ([]string, error) {
+	admins := make([]string, 0)
+	perPage := 200
+	for page := 0; ; page++ {
+		members, err := p.API.GetChannelMembers(channelID, page, perPage)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't get channel members")
+		}
+		for _, member := range *members {
+			if member.SchemeAdmin {
+				admins = append(admins, member.UserId)
+			}
+		}
+		if len(*members) < perPage {
+			return admins, nil
+		}
+	}
+}
+
+// notifyChannelAdmins send a DM to all channel admins to inform them that analytics are collected in their channel
+// in strict mode the DM contains a button to acknowledge the tracking
+func (p *Plugin) notifyChannelAdmins(channelID string, strict bool) {
+	channelName, channelDisplayName, link, err := p.getChannelName(channelID)
+	if err != nil {
+		p.API.LogError("can't get channel name", "channel", channelID, "err", err.Error())
+		return
+	}
+	admins, err := p.getChannelAdmins(channelID)
+	if err != nil {
+		p.API.LogError("can't get channel admins", "channel", channelID, "err", err.Error())
+		return
+	}
+
+	attachment := &model.SlackAttachment{
+		Color: "#FF8000",
+		Text:  fmt.Sprintf("Analytics (number of messages, replies and files by channel and user) are now collected in [~%s](%s).", channelDisplayName, link),
+	}
+	if strict {
+		attachment.Text = fmt.Sprintf("Analytics (number of messages, replies and files by channel and user) are waiting your acknowledgment to be collected in [~%s](%s).", channelDisplayName, link)
+		attachment.Actions = []*model.PostAction{{
+			Name: "Accept",
+			Integration: &model.PostActionIntegration{
+				URL:     *p.API.GetConfig().ServiceSettings.SiteURL + "/plugins/" + manifest.Id + "/consent",
+				Context: map[string]interface{}{"channel_id": channelID},
+			},
+		}}
+	}
+
+	for _, adminID := range admins {
+		if err := p.sendDirectMessage(adminID, "", []*model.SlackAttachment{attachment}); err != nil {
+			p.API.LogError("can't notify channel admin", "channel", channelName, "user", adminID, "err", err.Error())
+		}
+	}
+}
+
+// sendDirectMessage post a message as the bot in the DM channel between the bot and a user
+func (p *Plugin) sendDirectMessage(userID string, message string, attachments []*model.SlackAttachment) error {
+	channel, appErr := p.API.GetDirectChannel(p.BotUserID, userID)
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't get direct channel")
+	}
+	post := &model.Post{
+		UserId:    p.BotUserID,
+		ChannelId: channel.Id,
+		Message:   message,
+		Props: map[string]interface{}{
+			"attachments": attachments,
+		},
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		return errors.Wrap(appErr, "can't post direct message")
+	}
+	return nil
+}
+
+// handleConsent is called when a channel admin click on the accept button of a consent DM
+func (p *Plugin) handleConsent(w http.ResponseWriter, r *http.Request) error {
+	request := model.PostActionIntegrationRequestFromJson(r.Body)
+	if request == nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return errors.New("can't decode consent request")
+	}
+	userID := r.Header.Get("Mattermost-User-Id")
+	channelID, _ := request.Context["channel_id"].(string)
+	if userID == "" || channelID == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return errors.New("missing user or channel in consent request")
+	}
+
+	member, appErr := p.API.GetChannelMember(channelID, userID)
+	if appErr != nil || !member.SchemeAdmin {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return fmt.Errorf("user %s is not admin of channel %s", userID, channelID)
+	}
+
+	p.consentsLock.Lock()
+	consent, err := p.getChannelConsent(channelID)
+	if err == nil {
+		if consent == nil {
+			consent = &ChannelConsent{ChannelID: channelID, NotifiedAt: p.now()}
+		}
+		consent.Accepted = true
+		consent.AcceptedBy = userID
+		consent.AcceptedAt = p.now()
+		err = p.saveChannelConsent(consent)
+	}
+	p.consentsLock.Unlock()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	p.audit("consent_accepted", userID, map[string]string{"channel_id": channelID})
+
+	response := &model.PostActionIntegrationResponse{
+		EphemeralText: "Thanks, analytics will now be collected in this channel.",
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(response.ToJson())
+	return err
+}
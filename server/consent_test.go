@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackingChanged(t *testing.T) {
+	assert := assert.New(t)
+
+	half, full := 50, 100
+	previous := &configuration{ConsentMode: consentModeNotify, IncludedChannels: "team/town-square", RolloutPercentage: &full}
+
+	for _, test := range []struct {
+		name    string
+		next    *configuration
+		changed bool
+	}{
+		{name: "same", next: &configuration{ConsentMode: consentModeNotify, IncludedChannels: "team/town-square", RolloutPercentage: &full}},
+		{name: "unset percentage", next: &configuration{ConsentMode: consentModeNotify, IncludedChannels: "team/town-square"}},
+		{name: "other fields", next: &configuration{ConsentMode: consentModeNotify, IncludedChannels: "team/town-square", TransparencyDM: true}},
+		{name: "consent mode", next: &configuration{ConsentMode: consentModeStrict, IncludedChannels: "team/town-square"}, changed: true},
+		{name: "included channels", next: &configuration{ConsentMode: consentModeNotify, IncludedChannels: "team/off-topic"}, changed: true},
+		{name: "excluded channels", next: &configuration{ConsentMode: consentModeNotify, IncludedChannels: "team/town-square", ExcludedChannels: "team/off-topic"}, changed: true},
+		{name: "rollout teams", next: &configuration{ConsentMode: consentModeNotify, IncludedChannels: "team/town-square", RolloutTeams: "team"}, changed: true},
+		{name: "rollout percentage", next: &configuration{ConsentMode: consentModeNotify, IncludedChannels: "team/town-square", RolloutPercentage: &half}, changed: true},
+	} {
+		assert.Equal(test.changed, trackingChanged(previous, test.next), test.name)
+	}
+}
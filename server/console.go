@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// maxSettingsChannels is the number of channels listed by the channel picker of the admin console
+const maxSettingsChannels = 50
+
+// ReportChannel is a report channel picked in the admin console with the teams and channels whose analytics are posted
+// there, it receives all analytics when it has no source
+type ReportChannel struct {
+	TeamID           string   `json:"team_id"`
+	ChannelID        string   `json:"channel_id"`
+	SourceTeamsID    []string `json:"source_teams_id,omitempty"`
+	SourceChannelsID []string `json:"source_channels_id,omitempty"`
+}
+
+// validateReportChannels check ids of the report channels picked in the admin console, their existence is checked
+// when the configuration is applied
+func validateReportChannels(reportChannels []ReportChannel) error {
+	seen := make(map[string]bool)
+	for _, reportChannel := range reportChannels {
+		if !model.IsValidId(reportChannel.TeamID) || !model.IsValidId(reportChannel.ChannelID) {
+			return fmt.Errorf("Bad ReportChannels: invalid team or channel id %q/%q", reportChannel.TeamID, reportChannel.ChannelID)
+		}
+		if seen[reportChannel.ChannelID] {
+			return fmt.Errorf("Bad ReportChannels: channel %s is selected twice", reportChannel.ChannelID)
+		}
+		seen[reportChannel.ChannelID] = true
+		for _, id := range append(append([]string{}, reportChannel.SourceTeamsID...), reportChannel.SourceChannelsID...) {
+			if !model.IsValidId(id) {
+				return fmt.Errorf("Bad ReportChannels: invalid source id %q of channel %s", id, reportChannel.ChannelID)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveReportChannels check the report channels picked in the admin console still exist and return channels
+// receiving all analytics and the route of each routed report channel id
+func (p *Plugin) resolveReportChannels(reportChannels []ReportChannel) ([]string, map[string]*reportRoute, error) {
+	global := make([]string, 0)
+	routes := make(map[string]*reportRoute)
+	for _, reportChannel := range reportChannels {
+		channel, appErr := p.API.GetChannel(reportChannel.ChannelID)
+		if appErr != nil {
+			return nil, nil, fmt.Errorf("Unable to find report channel: %v", reportChannel.ChannelID)
+		}
+		if channel.TeamId != reportChannel.TeamID {
+			return nil, nil, fmt.Errorf("Report channel %v is not in team %v", channel.Name, reportChannel.TeamID)
+		}
+		if len(reportChannel.SourceTeamsID) == 0 && len(reportChannel.SourceChannelsID) == 0 {
+			global = append(global, channel.Id)
+			continue
+		}
+		route := &reportRoute{TeamsID: make(map[string]bool), ChannelsID: make(map[string]bool)}
+		for _, teamID := range reportChannel.SourceTeamsID {
+			if _, appErr := p.API.GetTeam(teamID); appErr != nil {
+				return nil, nil, fmt.Errorf("Unable to find source team of %v: %v", channel.Name, teamID)
+			}
+			route.TeamsID[teamID] = true
+		}
+		for _, channelID := range reportChannel.SourceChannelsID {
+			if _, appErr := p.API.GetChannel(channelID); appErr != nil {
+				return nil, nil, fmt.Errorf("Unable to find source channel of %v: %v", channel.Name, channelID)
+			}
+			route.ChannelsID[channelID] = true
+		}
+		routes[channel.Id] = route
+	}
+	return global, routes, nil
+}
+
+// SettingsTeam is a team offered by the pickers of the admin console
+type SettingsTeam struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// SettingsChannel is a channel offered by the pickers of the admin console
+type SettingsChannel struct {
+	ID          string `json:"id"`
+	TeamID      string `json:"team_id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+// handleSettingsTeams serve `GET /api/v1/settings/teams`, all teams sorted by display name
+func (p *Plugin) handleSettingsTeams(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	teams, appErr := p.API.GetTeams()
+	if appErr != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return appErr
+	}
+	result := make([]SettingsTeam, 0, len(teams))
+	for _, team := range teams {
+		result = append(result, SettingsTeam{ID: team.Id, Name: team.Name, DisplayName: team.DisplayName})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].DisplayName) < strings.ToLower(result[j].DisplayName)
+	})
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// handleSettingsChannels serve `GET /api/v1/settings/channels?team_id=&term=` the channels of a team matching the
+// term, or `GET /api/v1/settings/channels?id=&id=` the channels of ids, to show selections
+func (p *Plugin) handleSettingsChannels(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	query := r.URL.Query()
+	channels := make([]*model.Channel, 0)
+	if ids := query["id"]; len(ids) > 0 {
+		for _, id := range ids {
+			// deleted channels are left out, the picker shows their id
+			if channel, appErr := p.API.GetChannel(id); appErr == nil {
+				channels = append(channels, channel)
+			}
+		}
+	} else {
+		teamID := query.Get("team_id")
+		if !model.IsValidId(teamID) {
+			http.Error(w, "team_id is required", http.StatusBadRequest)
+			return nil
+		}
+		var appErr *model.AppError
+		if term := strings.TrimSpace(query.Get("term")); term != "" {
+			channels, appErr = p.API.SearchChannels(teamID, term)
+		} else {
+			channels, appErr = p.API.GetPublicChannelsForTeam(teamID, 0, maxSettingsChannels)
+		}
+		if appErr != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return appErr
+		}
+	}
+
+	result := make([]SettingsChannel, 0, len(channels))
+	for _, channel := range channels {
+		if channel.DeleteAt != 0 || channel.TeamId == "" {
+			continue
+		}
+		result = append(result, SettingsChannel{ID: channel.Id, TeamID: channel.TeamId, Name: channel.Name, DisplayName: channel.DisplayName})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].DisplayName) < strings.ToLower(result[j].DisplayName)
+	})
+	if len(result) > maxSettingsChannels {
+		result = result[:maxSettingsChannels]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReportChannels(t *testing.T) {
+	assert := assert.New(t)
+	teamID, channelID, sourceID := model.NewId(), model.NewId(), model.NewId()
+
+	assert.Nil(validateReportChannels(nil))
+	assert.Nil(validateReportChannels([]ReportChannel{
+		{TeamID: teamID, ChannelID: channelID},
+		{TeamID: teamID, ChannelID: sourceID, SourceTeamsID: []string{teamID}, SourceChannelsID: []string{channelID}},
+	}))
+
+	assert.NotNil(validateReportChannels([]ReportChannel{{TeamID: "TeamA", ChannelID: channelID}}))
+	assert.NotNil(validateReportChannels([]ReportChannel{{TeamID: teamID}}))
+	assert.NotNil(validateReportChannels([]ReportChannel{{TeamID: teamID, ChannelID: channelID}, {TeamID: teamID, ChannelID: channelID}}))
+	assert.NotNil(validateReportChannels([]ReportChannel{{TeamID: teamID, ChannelID: channelID, SourceChannelsID: []string{"town-square"}}}))
+}
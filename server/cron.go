@@ -1,6 +1,8 @@
 package main
 
 import (
+	"strconv"
+
 	"github.com/robfig/cron"
 )
 
@@ -13,21 +15,64 @@ type Cron struct {
 
 // NewCron return a cron
 func NewCron(p *Plugin) (*Cron, error) {
-	c := cron.New()
+	c := cron.NewWithLocation(p.getConfiguration().getLocation())
 
 	if err := c.AddFunc("@every 1m", func() { // Run once a week, midnight between Sat/Sun
 		if err := p.saveCurrentAnalytic(); err != nil {
 			p.API.LogError("can't save current analytic", "err", err.Error())
 		}
+		if err := p.flushElasticsearchEvents(); err != nil {
+			p.API.LogError("can't flush events to elasticsearch", "err", err.Error())
+		}
+		if err := p.refreshLeadership(); err != nil {
+			p.API.LogError("can't refresh cluster leadership", "err", err.Error())
+		}
+		if !p.isLeader() {
+			return
+		}
+		if err := p.deliverPendingReports(); err != nil {
+			p.API.LogError("can't deliver pending reports", "err", err.Error())
+		}
 	}); err != nil {
 		return nil, err
 	}
 
-	if err := c.AddFunc("@weekly", func() { // Run once a week, midnight between Sat/Sun
-		if err := p.sendAnalytics(p.ChannelsID); err != nil {
-			p.API.LogError("can't send post", "err", err.Error())
+	// in a cluster daily and hourly jobs only run on the leader node
+	if err := c.AddFunc("@daily", func() {
+		if !p.isLeader() {
+			return
+		}
+		if err := p.sendQuarterlyReportIfNeeded(p.now()); err != nil {
+			p.API.LogError("can't send quarterly report", "err", err.Error())
+		}
+		if err := p.recordProvisionedUsers(p.now()); err != nil {
+			p.API.LogError("can't record provisioned users", "err", err.Error())
+		}
+		if err := p.shipDailyToElasticsearch(p.now()); err != nil {
+			p.API.LogError("can't ship daily analytics to elasticsearch", "err", err.Error())
+		}
+		if err := p.archiveDailyAggregates(p.now()); err != nil {
+			p.API.LogError("can't archive daily aggregates", "err", err.Error())
+		}
+		if err := p.checkAnomalies(p.now()); err != nil {
+			p.API.LogError("can't check anomalies", "err", err.Error())
+		}
+		if pruned, err := p.pruneDailyBuckets(p.now()); err != nil {
+			p.API.LogError("can't prune daily analytics", "keys", strconv.Itoa(pruned), "err", err.Error())
+		} else {
+			p.API.LogInfo("daily analytics pruned", "keys", strconv.Itoa(pruned))
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := c.AddFunc("@hourly", func() {
+		if !p.isLeader() {
+			return
+		}
+		if err := p.checkSilentChannels(); err != nil {
+			p.API.LogError("can't check silent channels", "err", err.Error())
 		}
-		p.newSession()
 	}); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	collectorPosts = "posts"
+	collectorFiles = "files"
+
+	// maxSampledEvents is the number of last events kept by each collector
+	maxSampledEvents = 20
+
+	outcomeCounted   = "counted"
+	outcomeExcluded  = "excluded channel"
+	outcomeRollout   = "team not in rollout"
+	outcomeNoConsent = "no consent"
+	outcomeBot       = "bot account"
+	outcomeWebhook   = "incoming webhook"
+	outcomeDirect    = "direct message"
+)
+
+// sampledEvent is a redacted event seen by a collector, without content and with a hashed user id
+type sampledEvent struct {
+	Date      time.Time
+	ChannelID string
+	User      string
+	Outcome   string
+}
+
+// collectorStats keep last events of a collector, its drop counters and the number of events not saved in kv yet
+type collectorStats struct {
+	lock      sync.Mutex
+	samples   []sampledEvent
+	next      int
+	processed int64
+	dropped   map[string]int64
+	pending   int64
+}
+
+// collectorNames are collectors of this plugin, one by hook
+var collectorNames = []string{collectorPosts, collectorFiles}
+
+// collector return the stats of a collector, created on first use
+func (p *Plugin) collector(name string) *collectorStats {
+	p.collectorsLock.Lock()
+	defer p.collectorsLock.Unlock()
+	if p.collectors == nil {
+		p.collectors = make(map[string]*collectorStats)
+	}
+	if _, ok := p.collectors[name]; !ok {
+		p.collectors[name] = &collectorStats{dropped: make(map[string]int64)}
+	}
+	return p.collectors[name]
+}
+
+// sample record an event seen by the collector
+func (p *Plugin) sample(name string, channelID string, userID string, outcome string) {
+	event := sampledEvent{
+		Date:      p.now(),
+		ChannelID: channelID,
+		User:      hashIdentifier(userID, p.API.GetDiagnosticId()),
+		Outcome:   outcome,
+	}
+	stats := p.collector(name)
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	if len(stats.samples) < maxSampledEvents {
+		stats.samples = append(stats.samples, event)
+	} else {
+		stats.samples[stats.next] = event
+	}
+	stats.next = (stats.next + 1) % maxSampledEvents
+	stats.processed++
+	if outcome == outcomeCounted {
+		stats.pending++
+	} else {
+		stats.dropped[outcome]++
+	}
+}
+
+// resetPending is called once the current session is saved in kv
+func (p *Plugin) resetPending() {
+	for _, name := range collectorNames {
+		stats := p.collector(name)
+		stats.lock.Lock()
+		stats.pending = 0
+		stats.lock.Unlock()
+	}
+}
+
+// lastSamples return sampled events, newest first
+func (s *collectorStats) lastSamples() []sampledEvent {
+	samples := make([]sampledEvent, len(s.samples))
+	copy(samples, s.samples)
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].Date.After(samples[j].Date)
+	})
+	return samples
+}
+
+// executeDebugCommand handle `/analytics debug sample <collector>`
+func (p *Plugin) executeDebugCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can debug collectors.")
+	}
+	if len(parameters) != 2 || parameters[0] != "sample" {
+		return ephemeralResponse(fmt.Sprintf("Usage: /analytics debug sample <collector>, collectors are %s", strings.Join(collectorNames, ", ")))
+	}
+	name := parameters[1]
+	known := false
+	for _, collectorName := range collectorNames {
+		known = known || collectorName == name
+	}
+	if !known {
+		return ephemeralResponse(fmt.Sprintf("Unknown collector %s, collectors are %s", name, strings.Join(collectorNames, ", ")))
+	}
+
+	stats := p.collector(name)
+	stats.lock.Lock()
+	defer stats.lock.Unlock()
+	text := fmt.Sprintf("#### Collector %s\n* %d events processed since activation.\n* %d counted events not saved in kv yet.\n", name, stats.processed, stats.pending)
+	reasons := make([]string, 0, len(stats.dropped))
+	for reason, nb := range stats.dropped {
+		reasons = append(reasons, fmt.Sprintf("%s: %d", reason, nb))
+	}
+	sort.Strings(reasons)
+	if len(reasons) > 0 {
+		text += fmt.Sprintf("* Dropped events: %s.\n", strings.Join(reasons, ", "))
+	}
+	samples := stats.lastSamples()
+	if len(samples) == 0 {
+		return ephemeralResponse(text + "\nNo event seen yet.")
+	}
+	text += fmt.Sprintf("\n##### Last %d events\n| Date | Channel | User (hashed) | Outcome |\n|:--|:--|:--|:--|\n", len(samples))
+	for _, event := range samples {
+		text += fmt.Sprintf("| %s | %s | %s | %s |\n", event.Date.Format("2006-01-02 15:04:05"), event.ChannelID, event.User, event.Outcome)
+	}
+	return ephemeralResponse(text)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	deliveryFailuresKey = "delivery_failures"
+	maxDeliveryAttempts = 3
+)
+
+// deliveryRetryDelay is the delay before the first retry, doubled on each attempt
+var deliveryRetryDelay = 2 * time.Second
+
+// DeliveryFailures store metrics on reports which can't be posted
+type DeliveryFailures struct {
+	// Total number of reports not delivered
+	Total int64
+	// ByChannel number of reports not delivered by channel id
+	ByChannel map[string]int64
+	// LastError is the error of the last failure
+	LastError     string
+	LastFailureAt time.Time
+}
+
+// deliverAnalytics post a report with its chart images in a channel, retrying with backoff
+// if it still fails, the failure is recorded and the report is sent to system admins
+func (p *Plugin) deliverAnalytics(channelID string, attachments []*model.SlackAttachment, images []*chartImage) (*model.Post, error) {
+	rootID := ""
+	if p.getConfiguration().ThreadedDigests {
+		anchorID, err := p.monthlyAnchor(channelID, p.now())
+		if err != nil {
+			p.API.LogWarn("can't get monthly anchor post, post digest at root", "channel", channelID, "err", err.Error())
+		}
+		rootID = anchorID
+	}
+
+	fileIDs := p.uploadReportCharts(channelID, images)
+	delay := deliveryRetryDelay
+	var err error
+	for attempt := 1; attempt <= maxDeliveryAttempts; attempt++ {
+		var post *model.Post
+		if post, err = p.postAnalytics(channelID, rootID, attachments, fileIDs); err == nil {
+			return post, nil
+		}
+		p.API.LogWarn("can't deliver report", "channel", channelID, "attempt", attempt, "err", err.Error())
+		if attempt < maxDeliveryAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	if errRecord := p.recordDeliveryFailure(channelID, err); errRecord != nil {
+		p.API.LogError("can't record delivery failure", "err", errRecord.Error())
+	}
+	p.sendReportToAdmins(channelID, err, attachments)
+	return nil, err
+}
+
+func (p *Plugin) deliveryFailures() (*DeliveryFailures, error) {
+	failures, _, err := p.storedDeliveryFailures()
+	return failures, err
+}
+
+// storedDeliveryFailures return the delivery failures and their raw value in kv, nil if none were recorded
+func (p *Plugin) storedDeliveryFailures() (*DeliveryFailures, []byte, error) {
+	failures := &DeliveryFailures{ByChannel: make(map[string]int64)}
+	j, appErr := p.API.KVGet(deliveryFailuresKey)
+	if appErr != nil {
+		return failures, nil, errors.Wrap(appErr, "can't get delivery failures from kv")
+	}
+	if j == nil {
+		return failures, nil, nil
+	}
+	if err := json.Unmarshal(j, failures); err != nil {
+		return failures, j, errors.Wrap(err, "can't unmarshal delivery failures")
+	}
+	return failures, j, nil
+}
+
+// recordDeliveryFailure count a report not delivered in channelID, reports are delivered by concurrent workers so
+// failures are saved by compare and set
+func (p *Plugin) recordDeliveryFailure(channelID string, deliveryErr error) error {
+	for attempt := 0; attempt < maxIncrementAttempts; attempt++ {
+		failures, old, err := p.storedDeliveryFailures()
+		if err != nil {
+			return err
+		}
+		if failures.ByChannel == nil {
+			failures.ByChannel = make(map[string]int64)
+		}
+		failures.Total++
+		failures.ByChannel[channelID]++
+		failures.LastError = deliveryErr.Error()
+		failures.LastFailureAt = p.now()
+
+		j, err := json.Marshal(failures)
+		if err != nil {
+			return errors.Wrap(err, "can't marshal delivery failures")
+		}
+		saved, appErr := p.API.KVCompareAndSet(deliveryFailuresKey, old, j)
+		if appErr != nil {
+			return errors.Wrap(appErr, "can't save delivery failures")
+		}
+		if saved {
+			return nil
+		}
+	}
+	return errors.New("too many concurrent updates of delivery failures")
+}
+
+// getSystemAdmins return ids of all system admins
+func (p *Plugin) getSystemAdmins() ([]string, error) {
+	admins := make([]string, 0)
+	perPage := 100
+	for page := 0; ; page++ {
+		users, appErr := p.API.GetUsers(&model.UserGetOptions{Role: model.SYSTEM_ADMIN_ROLE_ID, Page: page, PerPage: perPage})
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't get system admins")
+		}
+		for _, user := range users {
+			admins = append(admins, user.Id)
+		}
+		if len(users) < perPage {
+			return admins, nil
+		}
+	}
+}
+
+// sendReportToAdmins DM all system admins with a report which can't be delivered in its channel
+func (p *Plugin) sendReportToAdmins(channelID string, deliveryErr error, attachments []*model.SlackAttachment) {
+	admins, err := p.getSystemAdmins()
+	if err != nil {
+		p.API.LogError("can't send undelivered report to admins", "err", err.Error())
+		return
+	}
+	message := fmt.Sprintf("The analytics report can't be posted in channel `%s` after %d attempts: %s\nHere is the report:", channelID, maxDeliveryAttempts, deliveryErr.Error())
+	for _, adminID := range admins {
+		if err := p.sendDirectMessage(adminID, message, attachments); err != nil {
+			p.API.LogError("can't send undelivered report to admin", "user", adminID, "err", err.Error())
+		}
+	}
+}
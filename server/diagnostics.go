@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// quarantineKeyPrefix prefix copies of the keys deleted by a repair
+	quarantineKeyPrefix = "quarantine_"
+
+	// keys are repaired by class, the repair of each class is confirmed by the admin
+	kvClassSessions = "sessions"
+	kvClassDaily    = "daily"
+	kvClassConsents = "consents"
+	kvClassOther    = "other"
+)
+
+// kvClasses are the classes of keys in the order they should be repaired, sessions are rebuilt from daily analytics
+// so daily keys are repaired first
+var kvClasses = []string{kvClassDaily, kvClassSessions, kvClassConsents, kvClassOther}
+
+// kvIssue is an orphaned or corrupted key found in the kv store of this plugin
+type kvIssue struct {
+	Key     string
+	Problem string
+}
+
+// QuarantinedKey is the raw value of a key deleted by a repair, kept to be restored by hand
+type QuarantinedKey struct {
+	Key           string
+	Problem       string
+	Value         []byte
+	QuarantinedAt time.Time
+}
+
+// kvKeyClass return the class of a key
+func kvKeyClass(key string) string {
+	switch {
+	case key == "analytics", key == "allAnalytics":
+		return kvClassSessions
+	case strings.HasPrefix(key, dailyKeyPrefix), strings.HasPrefix(key, tombstoneKeyPrefix+dailyKeyPrefix):
+		return kvClassDaily
+	case strings.HasPrefix(key, consentKeyPrefix):
+		return kvClassConsents
+	default:
+		return kvClassOther
+	}
+}
+
+// isKVClass return true if class is a class of keys
+func isKVClass(class string) bool {
+	for _, known := range kvClasses {
+		if class == known {
+			return true
+		}
+	}
+	return false
+}
+
+// quarantineKey return the key keeping the copy of a deleted key, hashed to fit the length of kv keys
+func quarantineKey(key string) string {
+	return quarantineKeyPrefix + fmt.Sprintf("%x", sha256.Sum256([]byte(key)))[:32]
+}
+
+// sessionFromDaily return a session summing the messages, replies and files of buckets of channels and users, the
+// other counters of sessions have no daily analytics and are left empty
+func sessionFromDaily(buckets []dailyBucket) *Analytic {
+	session := NewAnalytic(time.Time{})
+	for _, bucket := range buckets {
+		if session.Start.IsZero() || bucket.Date.Before(session.Start) {
+			session.Start = bucket.Date
+		}
+		if end := bucket.Date.AddDate(0, 0, 1); end.After(session.End) {
+			session.End = end
+		}
+		switch bucket.Scope {
+		case dailyScopeChannel:
+			session.Channels[bucket.ID] += bucket.Counters.Messages
+			session.ChannelsReply[bucket.ID] += bucket.Counters.Replies
+			session.ChannelsFilesSize[bucket.ID] += bucket.Counters.FilesSize
+			session.FilesSize += bucket.Counters.FilesSize
+		case dailyScopeUser:
+			session.Users[bucket.ID] += bucket.Counters.Messages
+			session.UsersReply[bucket.ID] += bucket.Counters.Replies
+		}
+	}
+	return session
+}
+
+// scanKV iterate over all keys of this plugin and return the number of keys and their issues
+func (p *Plugin) scanKV() (int, []kvIssue, error) {
+	issues := make([]kvIssue, 0)
+	nbKeys := 0
+	perPage := 100
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			return nbKeys, issues, errors.Wrap(appErr, "can't list kv keys")
+		}
+		for _, key := range keys {
+			nbKeys++
+			value, appErr := p.API.KVGet(key)
+			if appErr != nil {
+				issues = append(issues, kvIssue{Key: key, Problem: "unreadable: " + appErr.Error()})
+				continue
+			}
+			if problem := p.checkKV(key, value); problem != "" {
+				issues = append(issues, kvIssue{Key: key, Problem: problem})
+			}
+		}
+		if len(keys) < perPage {
+			return nbKeys, issues, nil
+		}
+	}
+}
+
+// checkKV return a description of the problem of a key, empty if the key is healthy
+func (p *Plugin) checkKV(key string, value []byte) string {
+	var err error
+	switch {
+	case key == "analytics":
+		err = json.Unmarshal(value, NewAnalytic(time.Time{}))
+	case key == "allAnalytics":
+		allAnalytics := make([]*Analytic, 0)
+		err = json.Unmarshal(value, &allAnalytics)
+	case key == auditKey:
+		entries := make([]*AuditEntry, 0)
+		err = json.Unmarshal(value, &entries)
+	case key == pausedUntilKey:
+		var until time.Time
+		err = until.UnmarshalText(value)
+	case key == channelLineageKey:
+		err = json.Unmarshal(value, &map[string]string{})
+	case key == sharedReportsKey:
+		err = json.Unmarshal(value, &[]*SharedReport{})
+	case key == shareSecretKey, key == auditReportSecretKey:
+		if len(value) == 0 {
+			err = errors.New("empty secret")
+		}
+	case strings.HasPrefix(key, sharedReportKeyPrefix):
+		if !bytes.Contains(value, []byte("<html")) {
+			err = errors.New("not an html report")
+		}
+	case key == brandLogoKey:
+		_, err = newBrandLogo(value)
+	case key == clusterLeaderKey:
+		err = json.Unmarshal(value, &LeaderLease{})
+	case key == dailyStoreBackendKey:
+		var backend string
+		err = json.Unmarshal(value, &backend)
+	case key == dailyStoreSyncKey:
+		err = json.Unmarshal(value, &DailyStoreSync{})
+	case key == deliveryFailuresKey:
+		err = json.Unmarshal(value, &DeliveryFailures{})
+	case key == digestPostsKey:
+		err = json.Unmarshal(value, &[]*DigestPost{})
+	case key == feedbackKey:
+		err = json.Unmarshal(value, &[]*DigestFeedback{})
+	case key == surveysKey:
+		err = json.Unmarshal(value, &[]*Survey{})
+	case key == purgesKey:
+		err = json.Unmarshal(value, &[]*Purge{})
+	case key == pendingReportsKey:
+		err = json.Unmarshal(value, &[]*PendingReport{})
+	case key == deletedBucketsKey:
+		err = json.Unmarshal(value, &[]DeletedBucket{})
+	case key == externalCollectorsKey:
+		err = json.Unmarshal(value, &map[string]*ExternalCollector{})
+	case key == plugin.BOT_USER_KEY:
+		if _, appErr := p.API.GetUser(string(value)); appErr != nil {
+			return "orphaned: bot user not found"
+		}
+	case key == trackedSinceKey:
+		err = json.Unmarshal(value, &map[string]time.Time{})
+	case key == seatsKey, key == silenceAlertsKey:
+		err = json.Unmarshal(value, &map[string]int64{})
+	case strings.HasPrefix(key, quarantineKeyPrefix):
+		err = json.Unmarshal(value, &QuarantinedKey{})
+	case key == spilledCountersKey:
+		err = json.Unmarshal(value, &map[string]map[string]int64{})
+	case key == anomalyCheckedKey, key == elasticsearchShippedKey, key == archivedKey:
+		_, err = time.Parse(dailyKeyFormat, string(value))
+	case strings.HasPrefix(key, openThreadKeyPrefix):
+		if string(value) != answeredThread {
+			err = json.Unmarshal(value, &openThread{})
+		}
+	case strings.HasPrefix(key, anchorKeyPrefix):
+		if _, appErr := p.API.GetPost(string(value)); appErr != nil {
+			return "orphaned: anchor post not found"
+		}
+	case strings.HasPrefix(key, idempotencyKeyPrefix):
+		_, err = hex.DecodeString(string(value))
+	case strings.HasPrefix(key, reportKeyPrefix):
+		_, err = time.Parse(time.RFC3339, string(value))
+	case strings.HasPrefix(key, dailyKeyPrefix), strings.HasPrefix(key, tombstoneKeyPrefix+dailyKeyPrefix):
+		err = json.Unmarshal(value, &DailyCounters{})
+	case strings.HasPrefix(key, consentKeyPrefix):
+		var consent ChannelConsent
+		if err = json.Unmarshal(value, &consent); err == nil {
+			if _, appErr := p.API.GetChannel(strings.TrimPrefix(key, consentKeyPrefix)); appErr != nil {
+				return "orphaned: channel not found"
+			}
+		}
+	default:
+		return "orphaned: unknown key"
+	}
+	if err != nil {
+		return "corrupted: " + err.Error()
+	}
+	return ""
+}
+
+// quarantineKV copy the raw value of the key of issue under its quarantine key before it's deleted
+func (p *Plugin) quarantineKV(issue kvIssue) error {
+	value, appErr := p.API.KVGet(issue.Key)
+	if appErr != nil {
+		p.API.LogWarn("can't read key to quarantine, only its problem is kept", "key", issue.Key, "err", appErr.Error())
+	}
+	return p.kvSetJSON(quarantineKey(issue.Key), &QuarantinedKey{Key: issue.Key, Problem: issue.Problem, Value: value, QuarantinedAt: p.now()})
+}
+
+// rebuildSessions replace archived sessions by a single one rebuilt from daily analytics of days before the current
+// session
+func (p *Plugin) rebuildSessions() error {
+	p.currentAnalytic.RLock()
+	start := p.currentAnalytic.Start
+	p.currentAnalytic.RUnlock()
+	buckets, err := p.listDailyBuckets(func(scope string) bool {
+		return scope == dailyScopeChannel || scope == dailyScopeUser
+	}, time.Time{}, start.AddDate(0, 0, -1))
+	if err != nil {
+		return errors.Wrap(err, "can't list daily analytics, repair daily keys first")
+	}
+	sessions := make([]*Analytic, 0, 1)
+	if len(buckets) > 0 {
+		session := sessionFromDaily(buckets)
+		if session.End.After(start) {
+			session.End = start
+		}
+		sessions = append(sessions, session)
+	}
+	return p.kvSetJSON("allAnalytics", sessions)
+}
+
+// repairKV quarantine and delete keys of class with issues and return their number, archived sessions are rebuilt
+// from daily analytics and the current session from memory on next save
+func (p *Plugin) repairKV(userID string, class string, issues []kvIssue) (int, error) {
+	repaired := 0
+	rebuild := false
+	for _, issue := range issues {
+		if kvKeyClass(issue.Key) != class {
+			continue
+		}
+		if err := p.quarantineKV(issue); err != nil {
+			return repaired, errors.Wrap(err, "can't quarantine key "+issue.Key)
+		}
+		if err := p.API.KVDelete(issue.Key); err != nil {
+			return repaired, errors.Wrap(err, "can't delete key "+issue.Key)
+		}
+		p.consentsLock.Lock()
+		delete(p.consents, strings.TrimPrefix(issue.Key, consentKeyPrefix))
+		p.consentsLock.Unlock()
+		p.audit("kv_repaired", userID, map[string]string{"key": issue.Key, "problem": issue.Problem, "class": class, "quarantine": quarantineKey(issue.Key)})
+		rebuild = rebuild || issue.Key == "allAnalytics"
+		repaired++
+	}
+	if rebuild {
+		if err := p.rebuildSessions(); err != nil {
+			return repaired, err
+		}
+	}
+	return repaired, p.saveCurrentAnalytic()
+}
+
+// repairGuide return how to repair issues, one confirmation by class of keys
+func repairGuide(issues []kvIssue) string {
+	counts := make(map[string]int)
+	for _, issue := range issues {
+		counts[kvKeyClass(issue.Key)]++
+	}
+	text := "Keys are copied under a `" + quarantineKeyPrefix + "` key before being deleted. Confirm the repair of each class of keys:\n"
+	for _, class := range kvClasses {
+		if counts[class] == 0 {
+			continue
+		}
+		text += fmt.Sprintf("* `/analytics diagnostics repair %s` for %d keys", class, counts[class])
+		if class == kvClassSessions {
+			text += ", archived sessions are rebuilt from daily analytics"
+		}
+		text += "\n"
+	}
+	return text
+}
+
+// executeDiagnosticsCommand handle `/analytics diagnostics [repair]`
+func (p *Plugin) executeDiagnosticsCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can run diagnostics.")
+	}
+	nbKeys, issues, err := p.scanKV()
+	if err != nil {
+		p.API.LogError("can't scan kv", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+
+	if len(parameters) > 0 && parameters[0] == "repair" {
+		if len(issues) == 0 {
+			return ephemeralResponse("No key to repair.")
+		}
+		if len(parameters) != 2 || !isKVClass(parameters[1]) {
+			return ephemeralResponse(repairGuide(issues))
+		}
+		repaired, err := p.repairKV(args.UserId, parameters[1], issues)
+		if err != nil {
+			p.API.LogError("can't repair kv", "class", parameters[1], "err", err.Error())
+			return ephemeralResponse(fmt.Sprintf("%d keys repaired before an error: %s", repaired, err.Error()))
+		}
+		return ephemeralResponse(fmt.Sprintf("%d %s keys repaired.", repaired, parameters[1]))
+	}
+
+	text := fmt.Sprintf("#### %d keys scanned, %d issues found.\n", nbKeys, len(issues))
+	if failures, err := p.deliveryFailures(); err == nil && failures.Total > 0 {
+		text += fmt.Sprintf("#### %d reports not delivered, last one at %s: %s\n", failures.Total, failures.LastFailureAt.Format("January 2, 2006 15:04"), failures.LastError)
+	}
+	if len(issues) > 0 {
+		text += "| Key | Class | Problem |\n|:--|:--|:--|\n"
+		for _, issue := range issues {
+			text += fmt.Sprintf("| `%s` | %s | %s |\n", issue.Key, kvKeyClass(issue.Key), issue.Problem)
+		}
+		text += "\n" + repairGuide(issues)
+	}
+	return ephemeralResponse(text)
+}
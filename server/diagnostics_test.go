@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVKeyClass(t *testing.T) {
+	assert := assert.New(t)
+
+	day := time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local)
+	for _, test := range []struct {
+		key   string
+		class string
+	}{
+		{"analytics", kvClassSessions},
+		{"allAnalytics", kvClassSessions},
+		{dailyKey(day, dailyScopeChannel, "channel1"), kvClassDaily},
+		{tombstoneKeyPrefix + dailyKey(day, dailyScopeUser, "user1"), kvClassDaily},
+		{consentKeyPrefix + "channel1", kvClassConsents},
+		{auditKey, kvClassOther},
+	} {
+		assert.Equal(test.class, kvKeyClass(test.key), test.key)
+	}
+
+	key := quarantineKey(tombstoneKeyPrefix + dailyKey(day, dailyScopeUser, model.NewId()))
+	assert.True(len(key) <= model.KEY_VALUE_KEY_MAX_RUNES)
+	assert.NotEqual(key, quarantineKey("analytics"))
+}
+
+func TestSessionFromDaily(t *testing.T) {
+	assert := assert.New(t)
+
+	day := time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local)
+	session := sessionFromDaily([]dailyBucket{
+		{Date: day.AddDate(0, 0, 1), Scope: dailyScopeChannel, ID: "channel1", Counters: DailyCounters{Messages: 3, Replies: 1, FilesSize: 10}},
+		{Date: day, Scope: dailyScopeChannel, ID: "channel1", Counters: DailyCounters{Messages: 2}},
+		{Date: day, Scope: dailyScopeUser, ID: "user1", Counters: DailyCounters{Messages: 5, Replies: 1}},
+		{Date: day, Scope: dailyScopeChannelMembers, ID: "channel1", Counters: DailyCounters{Joins: 4}},
+	})
+	assert.Equal(day, session.Start)
+	assert.Equal(day.AddDate(0, 0, 2), session.End)
+	assert.Equal(map[string]int64{"channel1": 5}, session.Channels)
+	assert.Equal(map[string]int64{"channel1": 1}, session.ChannelsReply)
+	assert.Equal(map[string]int64{"user1": 5}, session.Users)
+	assert.Equal(int64(10), session.FilesSize)
+}
+
+func TestRepairGuide(t *testing.T) {
+	assert := assert.New(t)
+
+	day := time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local)
+	guide := repairGuide([]kvIssue{
+		{Key: "allAnalytics", Problem: "corrupted"},
+		{Key: dailyKey(day, dailyScopeChannel, "channel1"), Problem: "corrupted"},
+		{Key: dailyKey(day, dailyScopeUser, "user1"), Problem: "corrupted"},
+	})
+	assert.Contains(guide, "`/analytics diagnostics repair daily` for 2 keys\n* `/analytics diagnostics repair sessions` for 1 keys, archived sessions are rebuilt")
+	assert.NotContains(guide, kvClassConsents)
+	assert.True(isKVClass(kvClassOther))
+	assert.False(isKVClass("all"))
+}
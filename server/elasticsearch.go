@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// elasticsearchShippedKey store the last day whose daily analytics were shipped to elasticsearch
+	elasticsearchShippedKey = "elasticsearch_shipped"
+	// defaultElasticsearchIndex is the index of documents when ElasticsearchIndex is not set
+	defaultElasticsearchIndex = "mattermost-analytics"
+	// elasticsearchBatchSize is the number of documents sent by bulk request
+	elasticsearchBatchSize = 500
+	// maxElasticsearchEvents is the number of events kept in memory between two flushes, older ones are dropped
+	maxElasticsearchEvents = 10000
+)
+
+// elasticsearchDocument is a document indexed with its id, an existing document with the same id is replaced
+type elasticsearchDocument struct {
+	ID     string
+	Source map[string]interface{}
+}
+
+// bulkBody return the ndjson body of a bulk request indexing documents in index
+func bulkBody(index string, documents []elasticsearchDocument) ([]byte, error) {
+	var body bytes.Buffer
+	for _, document := range documents {
+		action := map[string]interface{}{"index": map[string]string{"_index": index, "_id": document.ID}}
+		for _, line := range []interface{}{action, document.Source} {
+			j, err := json.Marshal(line)
+			if err != nil {
+				return nil, err
+			}
+			body.Write(j)
+			body.WriteByte('\n')
+		}
+	}
+	return body.Bytes(), nil
+}
+
+// bulkResponse is the part of the response of a bulk request telling which documents failed
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int `json:"status"`
+		Error  struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// firstError return the first error of the response, nil if all documents were indexed
+func (r *bulkResponse) firstError() error {
+	if !r.Errors {
+		return nil
+	}
+	for _, item := range r.Items {
+		for _, result := range item {
+			if result.Status >= 300 {
+				return fmt.Errorf("document not indexed, status %d: %s %s", result.Status, result.Error.Type, result.Error.Reason)
+			}
+		}
+	}
+	return errors.New("document not indexed")
+}
+
+// elasticsearchClient send documents to an elasticsearch or opensearch index
+type elasticsearchClient struct {
+	url      string
+	index    string
+	username string
+	password string
+	client   *http.Client
+}
+
+// newElasticsearchClient return the client of the configured index, nil if ElasticsearchURL is not set
+func (c *configuration) newElasticsearchClient() *elasticsearchClient {
+	if c.ElasticsearchURL == "" {
+		return nil
+	}
+	index := c.ElasticsearchIndex
+	if index == "" {
+		index = defaultElasticsearchIndex
+	}
+	return &elasticsearchClient{
+		url:      strings.TrimSuffix(c.ElasticsearchURL, "/"),
+		index:    index,
+		username: c.ElasticsearchUsername,
+		password: c.ElasticsearchPassword,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// send index documents by batches of elasticsearchBatchSize, each batch is retried with backoff on network errors,
+// throttling and server errors, documents rejected by elasticsearch are not retried
+func (c *elasticsearchClient) send(documents []elasticsearchDocument) error {
+	for start := 0; start < len(documents); start += elasticsearchBatchSize {
+		end := start + elasticsearchBatchSize
+		if end > len(documents) {
+			end = len(documents)
+		}
+		body, err := bulkBody(c.index, documents[start:end])
+		if err != nil {
+			return errors.Wrap(err, "can't marshal documents")
+		}
+		delay := deliveryRetryDelay
+		for attempt := 1; ; attempt++ {
+			retry, err := c.bulk(body)
+			if err == nil {
+				break
+			}
+			if !retry || attempt == maxDeliveryAttempts {
+				return err
+			}
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+	return nil
+}
+
+// bulk send a bulk request, return true with the error if it may succeed later
+func (c *elasticsearchClient) bulk(body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, c.url+"/_bulk", bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "can't create bulk request")
+	}
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	if c.username != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return true, errors.Wrap(err, "can't send bulk request")
+	}
+	defer response.Body.Close()
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return true, errors.Wrap(err, "can't read bulk response")
+	}
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500 {
+		return true, errors.Errorf("bulk request failed, status %d", response.StatusCode)
+	}
+	if response.StatusCode >= 300 {
+		return false, errors.Errorf("bulk request failed, status %d: %s", response.StatusCode, content)
+	}
+	var result bulkResponse
+	if err := json.Unmarshal(content, &result); err != nil {
+		return false, errors.Wrap(err, "can't unmarshal bulk response")
+	}
+	return false, result.firstError()
+}
+
+// rowDocument return the document of a daily row of a channel or a user
+func rowDocument(row exportRow) elasticsearchDocument {
+	source := map[string]interface{}{
+		"@timestamp": row.Date.Format(time.RFC3339),
+		"messages":   row.Messages,
+		"replies":    row.Replies,
+		"files_size": row.FilesSize,
+	}
+	id := row.Date.Format(dailyKeyFormat)
+	if row.UserID != "" {
+		source["type"] = "user"
+		source["user_id"] = row.UserID
+		source["username"] = row.Username
+		id += ":" + dailyScopeUser + ":" + row.UserID
+	} else {
+		source["type"] = "channel"
+		source["channel_id"] = row.ChannelID
+		source["channel_name"] = row.ChannelName
+		id += ":" + dailyScopeChannel + ":" + row.ChannelID
+	}
+	return elasticsearchDocument{ID: id, Source: source}
+}
+
+// eventDocument return the document of a raw event, identified by its position in its batch
+func eventDocument(event JournalEvent, batch time.Time, position int) elasticsearchDocument {
+	source := map[string]interface{}{
+		"@timestamp": event.Date.Format(time.RFC3339Nano),
+		"type":       "event",
+		"kind":       event.Kind,
+	}
+	if event.ChannelID != "" {
+		source["channel_id"] = event.ChannelID
+	}
+	if event.UserID != "" {
+		source["user_id"] = event.UserID
+	}
+	if event.Kind == journalPost {
+		source["reply"] = event.Reply
+		source["words"] = event.Words
+		source["length"] = event.Length
+		source["script"] = event.Script
+	}
+	if event.FilesSize > 0 {
+		source["files_size"] = event.FilesSize
+	}
+	return elasticsearchDocument{ID: "event:" + strconv.FormatInt(batch.UnixNano(), 10) + ":" + strconv.Itoa(position), Source: source}
+}
+
+// shipDailyToElasticsearch index daily analytics of the days since the last shipped one until yesterday
+// masked by the elasticsearch policy, a day is shipped again after a failure
+func (p *Plugin) shipDailyToElasticsearch(now time.Time) error {
+	client := p.getConfiguration().newElasticsearchClient()
+	if client == nil {
+		return nil
+	}
+	yesterday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -1)
+	from := yesterday
+	shipped, appErr := p.API.KVGet(elasticsearchShippedKey)
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't get last day shipped to elasticsearch")
+	}
+	if last, err := time.ParseInLocation(dailyKeyFormat, string(shipped), now.Location()); err == nil {
+		from = last.AddDate(0, 0, 1)
+	}
+	if oldest := yesterday.AddDate(0, 0, 1-maxAPIRangeDays); from.Before(oldest) {
+		from = oldest
+	}
+	if from.After(yesterday) {
+		return nil
+	}
+
+	rows, err := p.exportRows(from, yesterday, granularityDay, p.maskingPolicy("elasticsearch"))
+	if err != nil {
+		return err
+	}
+	documents := make([]elasticsearchDocument, 0, len(rows))
+	for _, row := range rows {
+		documents = append(documents, rowDocument(row))
+	}
+	if err := client.send(documents); err != nil {
+		return errors.Wrap(err, "can't index daily analytics")
+	}
+	if appErr := p.API.KVSet(elasticsearchShippedKey, []byte(yesterday.Format(dailyKeyFormat))); appErr != nil {
+		return errors.Wrap(appErr, "can't save last day shipped to elasticsearch")
+	}
+	p.API.LogInfo("daily analytics shipped to elasticsearch", "documents", strconv.Itoa(len(documents)))
+	return nil
+}
+
+// bufferElasticsearchEvent keep an event until the next flush if ElasticsearchEvents is on
+func (p *Plugin) bufferElasticsearchEvent(event JournalEvent) {
+	config := p.getConfiguration()
+	if config.ElasticsearchURL == "" || !config.ElasticsearchEvents {
+		return
+	}
+	p.elasticsearchEventsLock.Lock()
+	defer p.elasticsearchEventsLock.Unlock()
+	if len(p.elasticsearchEvents) == maxElasticsearchEvents {
+		p.elasticsearchEvents = p.elasticsearchEvents[1:]
+		p.elasticsearchDropped++
+	}
+	p.elasticsearchEvents = append(p.elasticsearchEvents, event)
+}
+
+// flushElasticsearchEvents index buffered events, user ids are hashed if the elasticsearch policy hash usernames
+// events are dropped if they can't be indexed after retries
+func (p *Plugin) flushElasticsearchEvents() error {
+	client := p.getConfiguration().newElasticsearchClient()
+	p.elasticsearchEventsLock.Lock()
+	events := p.elasticsearchEvents
+	dropped := p.elasticsearchDropped
+	p.elasticsearchEvents = nil
+	p.elasticsearchDropped = 0
+	p.elasticsearchEventsLock.Unlock()
+	if dropped > 0 {
+		p.API.LogWarn("too many events buffered for elasticsearch, oldest ones dropped", "dropped", strconv.Itoa(dropped))
+	}
+	if client == nil || len(events) == 0 {
+		return nil
+	}
+
+	policy := p.maskingPolicy("elasticsearch")
+	salt := p.API.GetDiagnosticId()
+	batch := p.now()
+	documents := make([]elasticsearchDocument, 0, len(events))
+	for position, event := range events {
+		if policy != nil && policy.HashUsernames {
+			event.UserID = hashIdentifier(event.UserID, salt)
+		}
+		documents = append(documents, eventDocument(event, batch, position))
+	}
+	if err := client.send(documents); err != nil {
+		return errors.Wrap(err, "can't index events, "+strconv.Itoa(len(events))+" events lost")
+	}
+	return nil
+}
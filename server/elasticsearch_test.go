@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkBody(t *testing.T) {
+	assert := assert.New(t)
+
+	body, err := bulkBody("analytics", []elasticsearchDocument{
+		{ID: "doc1", Source: map[string]interface{}{"messages": 3}},
+		{ID: "doc2", Source: map[string]interface{}{"messages": 5}},
+	})
+	assert.Nil(err)
+	assert.Equal(`{"index":{"_id":"doc1","_index":"analytics"}}
+{"messages":3}
+{"index":{"_id":"doc2","_index":"analytics"}}
+{"messages":5}
+`, string(body))
+}
+
+func TestRowDocument(t *testing.T) {
+	assert := assert.New(t)
+
+	date := time.Date(2020, time.March, 16, 0, 0, 0, 0, time.UTC)
+	channel := rowDocument(exportRow{Date: date, ChannelID: "channel1", ChannelName: "town-square", Messages: 10, Replies: 2})
+	assert.Equal("2020-03-16:c:channel1", channel.ID)
+	assert.Equal("channel", channel.Source["type"])
+	assert.Equal("town-square", channel.Source["channel_name"])
+	assert.Equal(int64(10), channel.Source["messages"])
+	assert.Equal("2020-03-16T00:00:00Z", channel.Source["@timestamp"])
+
+	user := rowDocument(exportRow{Date: date, UserID: "user1", Username: "alice", Messages: 4})
+	assert.Equal("2020-03-16:u:user1", user.ID)
+	assert.Equal("user", user.Source["type"])
+	assert.Nil(user.Source["channel_id"])
+}
+
+func TestElasticsearchClientSend(t *testing.T) {
+	assert := assert.New(t)
+
+	deliveryRetryDelay = 0
+	defer func() { deliveryRetryDelay = 2 * time.Second }()
+
+	statuses := []int{}
+	documents := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/_bulk", r.URL.Path)
+		if len(statuses) == 0 {
+			statuses = append(statuses, http.StatusServiceUnavailable)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		statuses = append(statuses, http.StatusOK)
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			if bytes.HasPrefix(scanner.Bytes(), []byte(`{"index"`)) {
+				documents++
+			}
+		}
+		_, _ = w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer server.Close()
+
+	client := (&configuration{ElasticsearchURL: server.URL + "/"}).newElasticsearchClient()
+	assert.Equal(defaultElasticsearchIndex, client.index)
+	batch := make([]elasticsearchDocument, elasticsearchBatchSize+1)
+	for i := range batch {
+		batch[i] = elasticsearchDocument{ID: "doc", Source: map[string]interface{}{}}
+	}
+	assert.Nil(client.send(batch))
+	assert.Equal([]int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK}, statuses)
+	assert.Equal(elasticsearchBatchSize+1, documents)
+}
+
+func TestElasticsearchClientRejected(t *testing.T) {
+	assert := assert.New(t)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(`{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}]}`))
+	}))
+	defer server.Close()
+
+	client := (&configuration{ElasticsearchURL: server.URL, ElasticsearchIndex: "analytics"}).newElasticsearchClient()
+	err := client.send([]elasticsearchDocument{{ID: "doc", Source: map[string]interface{}{}}})
+	assert.EqualError(err, "document not indexed, status 400: mapper_parsing_exception failed to parse")
+	assert.Equal(1, requests)
+	assert.Nil((&configuration{}).newElasticsearchClient())
+}
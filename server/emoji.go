@@ -0,0 +1,40 @@
+package main
+
+import "strings"
+
+// emojiSkinToneSuffixes are suffixes of skin tone variants of an emoji name, from the unicode names
+// (thumbsup_medium_skin_tone) and from older emoji sets (thumbsup_skin_tone_3, thumbsup_tone3)
+var emojiSkinToneSuffixes = []string{
+	"_medium_light_skin_tone", "_medium_dark_skin_tone", "_light_skin_tone", "_medium_skin_tone", "_dark_skin_tone",
+	"_skin_tone_2", "_skin_tone_3", "_skin_tone_4", "_skin_tone_5", "_skin_tone_6",
+	"_tone1", "_tone2", "_tone3", "_tone4", "_tone5",
+}
+
+// emojiAliases are alternative names of an emoji with its canonical mattermost name
+var emojiAliases = map[string]string{
+	"thumbsup":   "+1",
+	"thumbsdown": "-1",
+	"satisfied":  "laughing",
+	"hooray":     "tada",
+	"poop":       "hankey",
+	"shit":       "hankey",
+	"punch":      "facepunch",
+	"hand":       "raised_hand",
+	"shipit":     "squirrel",
+	"runner":     "running",
+	"collision":  "boom",
+}
+
+// normalizeEmoji return the canonical name of an emoji, without skin tone and with aliases resolved
+func normalizeEmoji(name string) string {
+	for _, suffix := range emojiSkinToneSuffixes {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			name = strings.TrimSuffix(name, suffix)
+			break
+		}
+	}
+	if canonical, ok := emojiAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeEmoji(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("+1", normalizeEmoji("+1"))
+	assert.Equal("+1", normalizeEmoji("thumbsup"))
+	assert.Equal("+1", normalizeEmoji("thumbsup_tone3"))
+	assert.Equal("+1", normalizeEmoji("+1_medium_dark_skin_tone"))
+	assert.Equal("wave", normalizeEmoji("wave_skin_tone_2"))
+	assert.Equal("hankey", normalizeEmoji("poop"))
+	assert.Equal("smile", normalizeEmoji("smile"))
+	assert.Equal("_tone1", normalizeEmoji("_tone1"))
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	granularityDay   = "day"
+	granularityWeek  = "week"
+	granularityMonth = "month"
+
+	defaultExportDays = 30
+
+	exportFormatCSV     = "csv"
+	exportFormatParquet = "parquet"
+	exportFormatArrow   = "arrow"
+)
+
+// exportHeader is the first line of csv exports
+var exportHeader = []string{"date", "channel_id", "channel_name", "user_id", "username", "messages", "replies", "files_size"}
+
+// parseGranularity return the granularity of an export, day by default
+func parseGranularity(value string) (string, error) {
+	switch value {
+	case "":
+		return granularityDay, nil
+	case granularityDay, granularityWeek, granularityMonth:
+		return value, nil
+	default:
+		return "", fmt.Errorf("bad granularity %s, expected %s, %s or %s", value, granularityDay, granularityWeek, granularityMonth)
+	}
+}
+
+// periodStart return the first day of the period of date, weeks start on monday
+func periodStart(date time.Time, granularity string) time.Time {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	switch granularity {
+	case granularityWeek:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case granularityMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// exportPeriod identify the counters of a channel or a user during a period
+type exportPeriod struct {
+	date time.Time
+	id   string
+}
+
+// groupBuckets sum daily buckets by period of granularity
+func groupBuckets(buckets []dailyBucket, granularity string) map[exportPeriod]DailyCounters {
+	periods := make(map[exportPeriod]DailyCounters)
+	for _, bucket := range buckets {
+		key := exportPeriod{date: periodStart(bucket.Date, granularity), id: bucket.ID}
+		counters := periods[key]
+		counters.add(bucket.Counters)
+		periods[key] = counters
+	}
+	return periods
+}
+
+// exportRows return masked rows of channels then users counters between from and to included, sorted by date
+func (p *Plugin) exportRows(from time.Time, to time.Time, granularity string, policy *MaskingPolicy) ([]exportRow, error) {
+	salt := p.API.GetDiagnosticId()
+	rows := make([]exportRow, 0)
+
+	channelBuckets, err := p.dailyBuckets(dailyScopeChannel, from, to)
+	if err != nil {
+		return nil, err
+	}
+	channelsName := make(map[string]string)
+	for period, counters := range groupBuckets(channelBuckets, granularity) {
+		name, ok := channelsName[period.id]
+		if !ok {
+			name, _, _, _ = p.getChannelName(period.id)
+			channelsName[period.id] = name
+		}
+		row := exportRow{Date: period.date, ChannelID: period.id, ChannelName: name, Messages: counters.Messages, Replies: counters.Replies, FilesSize: counters.FilesSize}
+		rows = append(rows, policy.apply(row, salt))
+	}
+
+	userBuckets, err := p.dailyBuckets(dailyScopeUser, from, to)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make(map[string]string)
+	for period, counters := range groupBuckets(userBuckets, granularity) {
+		username, ok := usernames[period.id]
+		if !ok {
+			username, _ = p.getUsername(period.id)
+			usernames[period.id] = username
+		}
+		row := exportRow{Date: period.date, UserID: period.id, Username: username, Messages: counters.Messages, Replies: counters.Replies, FilesSize: counters.FilesSize}
+		rows = append(rows, policy.apply(row, salt))
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].Date.Equal(rows[j].Date) {
+			return rows[i].Date.Before(rows[j].Date)
+		}
+		if rows[i].ChannelID != rows[j].ChannelID {
+			return rows[i].ChannelID > rows[j].ChannelID
+		}
+		return rows[i].UserID < rows[j].UserID
+	})
+	return rows, nil
+}
+
+// channelRows return rows of daily buckets of channelID named name, sorted by date
+func channelRows(buckets []dailyBucket, channelID string, name string) []exportRow {
+	rows := make([]exportRow, 0)
+	for _, bucket := range buckets {
+		if bucket.ID != channelID {
+			continue
+		}
+		rows = append(rows, exportRow{Date: bucket.Date, ChannelID: channelID, ChannelName: name, Messages: bucket.Counters.Messages, Replies: bucket.Counters.Replies, FilesSize: bucket.Counters.FilesSize})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Date.Before(rows[j].Date)
+	})
+	return rows
+}
+
+// writeExportCSV write rows as csv with a header line
+func writeExportCSV(w io.Writer, rows []exportRow) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportHeader); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{
+			row.Date.Format("2006-01-02"),
+			row.ChannelID,
+			row.ChannelName,
+			row.UserID,
+			row.Username,
+			strconv.FormatInt(row.Messages, 10),
+			strconv.FormatInt(row.Replies, 10),
+			strconv.FormatInt(row.FilesSize, 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportFilename return the name of the export file of a date range in format, csv, parquet or arrow
+func exportFilename(from time.Time, to time.Time, format string) string {
+	return fmt.Sprintf("analytics-%s-%s.%s", from.Format("2006-01-02"), to.Format("2006-01-02"), format)
+}
+
+// handleExport serve `GET /api/v1/export.csv?from=&to=&granularity=day` and the same columns as a parquet file
+// at `GET /api/v1/export.parquet` or an arrow ipc stream at `GET /api/v1/export.arrow`, channels and users counters
+// masked by the csv policy
+func (p *Plugin) handleExport(w http.ResponseWriter, r *http.Request, format string) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	from, to, err := parseDateRange(r, p.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	granularity, err := parseGranularity(r.URL.Query().Get("granularity"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	rows, err := p.exportRows(from, to, granularity, p.maskingPolicy("csv"))
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+
+	var content bytes.Buffer
+	write, contentType := writeExportCSV, "text/csv"
+	switch format {
+	case exportFormatParquet:
+		write, contentType = writeExportParquet, parquetContentType
+	case exportFormatArrow:
+		write, contentType = writeExportArrow, arrowContentType
+	}
+	if err := write(&content, rows); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+
+	// the manifest of the export is sent in headers, rows exclude the header line
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+exportFilename(from, to, format)+"\"")
+	w.Header().Set("Digest", digestHeader(content.Bytes()))
+	w.Header().Set("X-Export-Rows", strconv.Itoa(len(rows)))
+	w.Header().Set("X-Export-Schema-Version", strconv.Itoa(exportSchemaVersion))
+	_, err = w.Write(content.Bytes())
+	return err
+}
+
+// executeExportCommand handle `/analytics export [days]`, upload the csv of the last days in the current channel
+func (p *Plugin) executeExportCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can export analytics.")
+	}
+	days := defaultExportDays
+	if len(parameters) > 1 {
+		return ephemeralResponse("Usage: /analytics export [days]")
+	}
+	if len(parameters) == 1 {
+		var err error
+		days, err = strconv.Atoi(parameters[0])
+		if err != nil || days < 1 || days > maxAPIRangeDays {
+			return ephemeralResponse(fmt.Sprintf("Bad number of days %s, expected between 1 and %d.", parameters[0], maxAPIRangeDays))
+		}
+	}
+
+	now := p.now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, 1-days)
+	if err := p.uploadExport(args.ChannelId, from, to); err != nil {
+		p.API.LogError("can't export analytics", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	p.audit("csv_exported", args.UserId, map[string]string{"channel_id": args.ChannelId, "days": strconv.Itoa(days)})
+	return &model.CommandResponse{}
+}
+
+// uploadExport post the daily csv between from and to as a file of the bot in channelID
+func (p *Plugin) uploadExport(channelID string, from time.Time, to time.Time) error {
+	rows, err := p.exportRows(from, to, granularityDay, p.maskingPolicy("csv"))
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Analytics by day from %s to %s.", from.Format("January 2, 2006"), to.Format("January 2, 2006"))
+	return p.uploadCSV(channelID, exportFilename(from, to, exportFormatCSV), message, rows, from, to)
+}
+
+// uploadCSV post daily rows between from and to as a csv file of the bot in channelID, with its manifest
+func (p *Plugin) uploadCSV(channelID string, filename string, message string, rows []exportRow, from time.Time, to time.Time) error {
+	var content bytes.Buffer
+	if err := writeExportCSV(&content, rows); err != nil {
+		return errors.Wrap(err, "can't write csv")
+	}
+	exportManifest := newExportManifest(from, to, granularityDay, p.now())
+	exportManifest.addFile(filename, len(rows), content.Bytes())
+	manifestContent, err := marshalManifest(exportManifest)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal export manifest")
+	}
+
+	fileInfo, appErr := p.API.UploadFile(content.Bytes(), channelID, filename)
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't upload csv")
+	}
+	manifestInfo, appErr := p.API.UploadFile(manifestContent, channelID, manifestFilename(filename))
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't upload export manifest")
+	}
+	post := &model.Post{
+		UserId:    p.BotUserID,
+		ChannelId: channelID,
+		Message:   message,
+		FileIds:   []string{fileInfo.Id, manifestInfo.Id},
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		return errors.Wrap(appErr, "can't post csv")
+	}
+	return nil
+}
+
+// canExportChannel return true if userID is a system admin or an admin of channelID
+func (p *Plugin) canExportChannel(userID string, channelID string) bool {
+	if p.isSystemAdmin(userID) {
+		return true
+	}
+	member, appErr := p.API.GetChannelMember(channelID, userID)
+	return appErr == nil && member.SchemeAdmin
+}
+
+// handleChannelExport serve `POST /api/v1/export/channel?channel_id=&days=30`, send by DM to the requester
+// the daily csv of a channel, masked by the csv policy, only system admins and channel admins are allowed
+func (p *Plugin) handleChannelExport(w http.ResponseWriter, r *http.Request) error {
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	channelID := r.URL.Query().Get("channel_id")
+	if !model.IsValidId(channelID) {
+		http.Error(w, "bad channel_id", http.StatusBadRequest)
+		return nil
+	}
+	days := defaultExportDays
+	if value := r.URL.Query().Get("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 1 || days > maxAPIRangeDays {
+			http.Error(w, fmt.Sprintf("bad days %s, expected between 1 and %d", value, maxAPIRangeDays), http.StatusBadRequest)
+			return nil
+		}
+	}
+	if !p.canExportChannel(userID, channelID) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil
+	}
+
+	now := p.now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	from := to.AddDate(0, 0, 1-days)
+	buckets, err := p.dailyBuckets(dailyScopeChannel, from, to)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	name, displayName, _, err := p.getChannelName(channelID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	policy := p.maskingPolicy("csv")
+	salt := p.API.GetDiagnosticId()
+	rows := channelRows(buckets, channelID, name)
+	for index := range rows {
+		rows[index] = policy.apply(rows[index], salt)
+	}
+
+	direct, appErr := p.API.GetDirectChannel(p.BotUserID, userID)
+	if appErr != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return errors.Wrap(appErr, "can't get direct channel")
+	}
+	message := fmt.Sprintf("Analytics of ~%s by day from %s to %s.", displayName, from.Format("January 2, 2006"), to.Format("January 2, 2006"))
+	if err := p.uploadCSV(direct.Id, fmt.Sprintf("analytics-%s-%s-%s.csv", name, from.Format("2006-01-02"), to.Format("2006-01-02")), message, rows, from, to); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	p.audit("channel_csv_exported", userID, map[string]string{"channel_id": channelID, "days": strconv.Itoa(days)})
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeriodStart(t *testing.T) {
+	assert := assert.New(t)
+	date := time.Date(2019, time.April, 18, 15, 4, 0, 0, time.UTC)
+
+	assert.Equal(time.Date(2019, time.April, 18, 0, 0, 0, 0, time.UTC), periodStart(date, granularityDay))
+	assert.Equal(time.Date(2019, time.April, 15, 0, 0, 0, 0, time.UTC), periodStart(date, granularityWeek))
+	assert.Equal(time.Date(2019, time.April, 15, 0, 0, 0, 0, time.UTC), periodStart(time.Date(2019, time.April, 21, 0, 0, 0, 0, time.UTC), granularityWeek))
+	assert.Equal(time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC), periodStart(date, granularityMonth))
+
+	_, err := parseGranularity("year")
+	assert.NotNil(err)
+	granularity, err := parseGranularity("")
+	assert.Nil(err)
+	assert.Equal(granularityDay, granularity)
+}
+
+func TestWriteExportCSV(t *testing.T) {
+	assert := assert.New(t)
+	date := time.Date(2019, time.April, 18, 0, 0, 0, 0, time.UTC)
+	rows := []exportRow{
+		{Date: date, ChannelID: "channel1", ChannelName: "town, square", Messages: 3, Replies: 1, FilesSize: 10},
+		{Date: date, UserID: "user1", Username: "murat", Messages: 2},
+	}
+
+	var content bytes.Buffer
+	assert.Nil(writeExportCSV(&content, rows))
+	assert.Equal("date,channel_id,channel_name,user_id,username,messages,replies,files_size\n"+
+		"2019-04-18,channel1,\"town, square\",,,3,1,10\n"+
+		"2019-04-18,,,user1,murat,2,0,0\n", content.String())
+}
+
+func TestChannelRows(t *testing.T) {
+	assert := assert.New(t)
+	day1 := time.Date(2019, time.April, 18, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+	buckets := []dailyBucket{
+		{Date: day2, ID: "channel1", Counters: DailyCounters{Messages: 4, Replies: 2}},
+		{Date: day1, ID: "channel2", Counters: DailyCounters{Messages: 7}},
+		{Date: day1, ID: "channel1", Counters: DailyCounters{Messages: 3, FilesSize: 10}},
+	}
+
+	assert.Equal([]exportRow{
+		{Date: day1, ChannelID: "channel1", ChannelName: "town-square", Messages: 3, FilesSize: 10},
+		{Date: day2, ChannelID: "channel1", ChannelName: "town-square", Messages: 4, Replies: 2},
+	}, channelRows(buckets, "channel1", "town-square"))
+	assert.Empty(channelRows(buckets, "channel3", "off-topic"))
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/plugin"
+	"github.com/pkg/errors"
+)
+
+const (
+	// externalCollectorsKey store collectors registered by other plugins by their key
+	externalCollectorsKey = "external_collectors"
+	// maxExternalCollectors is the number of collectors other plugins can register
+	maxExternalCollectors = 100
+)
+
+// errTooManyExternalCollectors is returned when registering a collector beyond maxExternalCollectors
+var errTooManyExternalCollectors = fmt.Errorf("too many collectors, at most %d can be registered", maxExternalCollectors)
+
+// externalCollectorNameRegexp is the form of the name of an external collector, unique by plugin
+var externalCollectorNameRegexp = regexp.MustCompile(`^[a-z0-9_]{1,64}$`)
+
+// ExternalCollector is a metric registered by another plugin, its values are summed by session and by day
+type ExternalCollector struct {
+	PluginID    string    `json:"plugin_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Unit        string    `json:"unit"`
+	Registered  time.Time `json:"registered"`
+}
+
+// externalCollectorKey return the key identifying the collector name of pluginID, e.g. com.example.jira.issues_created
+func externalCollectorKey(pluginID string, name string) string {
+	return pluginID + "." + name
+}
+
+// validate return an error if the collector can't be registered
+func (c *ExternalCollector) validate() error {
+	if !externalCollectorNameRegexp.MatchString(c.Name) {
+		return fmt.Errorf("bad collector name %s, expected 1 to 64 lowercase letters, digits or underscores", c.Name)
+	}
+	if c.Description == "" || len(c.Description) > 280 {
+		return errors.New("collector description must have between 1 and 280 characters")
+	}
+	if c.Unit == "" || len(c.Unit) > 32 {
+		return errors.New("collector unit must have between 1 and 32 characters")
+	}
+	return nil
+}
+
+// definition return the entry of the collector in the data dictionary
+func (c *ExternalCollector) definition() MetricDefinition {
+	return MetricDefinition{
+		Name:        externalCollectorKey(c.PluginID, c.Name),
+		Description: c.Description,
+		Unit:        c.Unit,
+		Dimensions:  []string{"session", "day"},
+		Retention:   retentionDaily,
+		Privacy:     privacyLevelAggregate,
+	}
+}
+
+// getExternalCollectors return collectors registered by other plugins by their key
+func (p *Plugin) getExternalCollectors() (map[string]*ExternalCollector, error) {
+	collectors := make(map[string]*ExternalCollector)
+	if err := p.kvGetJSON(externalCollectorsKey, &collectors); err != nil {
+		return nil, err
+	}
+	return collectors, nil
+}
+
+// registerExternalCollector register or update a collector, its values are kept when it is registered again
+func (p *Plugin) registerExternalCollector(collector *ExternalCollector) error {
+	p.externalCollectorsLock.Lock()
+	defer p.externalCollectorsLock.Unlock()
+	collectors, err := p.getExternalCollectors()
+	if err != nil {
+		return err
+	}
+	key := externalCollectorKey(collector.PluginID, collector.Name)
+	if existing, ok := collectors[key]; ok {
+		collector.Registered = existing.Registered
+	} else if len(collectors) >= maxExternalCollectors {
+		return errTooManyExternalCollectors
+	}
+	collectors[key] = collector
+	return p.kvSetJSON(externalCollectorsKey, collectors)
+}
+
+// recordExternalValue add value to the collector in the current session and in its daily bucket
+func (p *Plugin) recordExternalValue(key string, value int64) {
+	now := p.now()
+	p.currentAnalytic.WLock()
+	p.appendAndApply(JournalEvent{Kind: journalExternal, Date: now, Collector: key, Value: value})
+	p.currentAnalytic.WUnlock()
+	if err := p.incrementDaily(now, dailyScopeExternal, key, DailyCounters{Value: value}); err != nil {
+		p.API.LogError("can't store daily external analytics", "collector", key, "err", err.Error())
+	}
+}
+
+// getExternalDescription render the values of external collectors sorted by collector, unregistered ones are skipped
+func getExternalDescription(values map[string]int64, collectors map[string]*ExternalCollector, T translateFunc) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if _, ok := collectors[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	m := sectionTitle(T, "report.external.title")
+	for _, key := range keys {
+		m += T("report.external.line", map[string]interface{}{
+			"Description": collectors[key].Description,
+			"Value":       values[key],
+			"Unit":        collectors[key].Unit,
+		}) + "\n"
+	}
+	return m
+}
+
+// handleExternalCollectors serve `POST /api/v1/collectors` for other plugins registering a collector
+// with {"name": "issues_created", "description": "Issues created in Jira", "unit": "issues"}
+// and `GET /api/v1/collectors` listing collectors to system admins and plugins
+func (p *Plugin) handleExternalCollectors(c *plugin.Context, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet:
+		if c.SourcePluginId == "" && !p.authorizeAPI(w, r) {
+			return nil
+		}
+		collectors, err := p.getExternalCollectors()
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return err
+		}
+		list := make([]*ExternalCollector, 0, len(collectors))
+		for _, collector := range collectors {
+			list = append(list, collector)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return externalCollectorKey(list[i].PluginID, list[i].Name) < externalCollectorKey(list[j].PluginID, list[j].Name)
+		})
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		if c.SourcePluginId == "" {
+			http.Error(w, "only plugins can register collectors", http.StatusForbidden)
+			return nil
+		}
+		var collector ExternalCollector
+		if err := json.NewDecoder(r.Body).Decode(&collector); err != nil {
+			http.Error(w, "bad collector: "+err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		collector.PluginID = c.SourcePluginId
+		collector.Registered = p.now()
+		if err := collector.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil
+		}
+		if err := p.registerExternalCollector(&collector); err == errTooManyExternalCollectors {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return nil
+		} else if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return err
+		}
+		p.API.LogInfo("external collector registered", "collector", externalCollectorKey(collector.PluginID, collector.Name))
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+}
+
+// externalValue is a value submitted by a plugin for one of its collectors
+type externalValue struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+}
+
+// handleExternalValues serve `POST /api/v1/collectors/values` for other plugins submitting values of their collectors
+// with [{"name": "issues_created", "value": 3}], values are added to the current session and day
+// a submission retried with the same Idempotency-Key header within idempotencyWindow is counted once
+func (p *Plugin) handleExternalValues(c *plugin.Context, w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	if c.SourcePluginId == "" {
+		http.Error(w, "only plugins can submit collector values", http.StatusForbidden)
+		return nil
+	}
+	idempotencyKey := r.Header.Get(idempotencyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		http.Error(w, fmt.Sprintf("idempotency key longer than %d characters", maxIdempotencyKeyLength), http.StatusBadRequest)
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "can't read values", http.StatusBadRequest)
+		return nil
+	}
+	values := make([]externalValue, 0)
+	if err := json.Unmarshal(body, &values); err != nil {
+		http.Error(w, "bad values: "+err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	collectors, err := p.getExternalCollectors()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	for _, value := range values {
+		if _, ok := collectors[externalCollectorKey(c.SourcePluginId, value.Name)]; !ok {
+			http.Error(w, "collector "+value.Name+" is not registered", http.StatusNotFound)
+			return nil
+		}
+	}
+	if idempotencyKey != "" {
+		claimed, err := p.claimIdempotencyKey(c.SourcePluginId, idempotencyKey, body)
+		if err == errIdempotencyKeyReused {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return nil
+		} else if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return err
+		}
+		if !claimed {
+			p.API.LogDebug("duplicate submission of collector values ignored", "plugin_id", c.SourcePluginId)
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+	}
+	for _, value := range values {
+		p.recordExternalValue(externalCollectorKey(c.SourcePluginId, value.Name), value.Value)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// externalExportHeader is the first line of csv exports of external collectors
+var externalExportHeader = []string{"date", "collector", "value"}
+
+// writeExternalExportCSV write the values of external collectors by period as csv with a header line, sorted by date
+func writeExternalExportCSV(w io.Writer, periods map[exportPeriod]DailyCounters) error {
+	keys := make([]exportPeriod, 0, len(periods))
+	for period := range periods {
+		keys = append(keys, period)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].date.Equal(keys[j].date) {
+			return keys[i].date.Before(keys[j].date)
+		}
+		return keys[i].id < keys[j].id
+	})
+	writer := csv.NewWriter(w)
+	if err := writer.Write(externalExportHeader); err != nil {
+		return err
+	}
+	for _, period := range keys {
+		if err := writer.Write([]string{period.date.Format("2006-01-02"), period.id, strconv.FormatInt(periods[period].Value, 10)}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// handleExternalExportCSV serve `GET /api/v1/collectors/export.csv?from=&to=&granularity=day`, values of external collectors
+func (p *Plugin) handleExternalExportCSV(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	from, to, err := parseDateRange(r, p.now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	granularity, err := parseGranularity(r.URL.Query().Get("granularity"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	}
+	buckets, err := p.dailyBuckets(dailyScopeExternal, from, to)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"collectors-"+from.Format("2006-01-02")+"-"+to.Format("2006-01-02")+".csv\"")
+	return writeExternalExportCSV(w, groupBuckets(buckets, granularity))
+}
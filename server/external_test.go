@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalCollectorValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	collector := &ExternalCollector{PluginID: "com.example.jira", Name: "issues_created", Description: "Issues created in Jira", Unit: "issues"}
+	assert.Nil(collector.validate())
+	assert.Equal("com.example.jira.issues_created", collector.definition().Name)
+
+	for _, bad := range []ExternalCollector{
+		{Name: "Issues", Description: "Issues created in Jira", Unit: "issues"},
+		{Name: "", Description: "Issues created in Jira", Unit: "issues"},
+		{Name: "issues_created", Unit: "issues"},
+		{Name: "issues_created", Description: "Issues created in Jira"},
+	} {
+		assert.NotNil(bad.validate(), bad.Name)
+	}
+}
+
+func TestApplyExternalEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	date := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+	analytic.apply(JournalEvent{Kind: journalExternal, Date: date, Collector: "com.example.jira.issues_created", Value: 3})
+	analytic.apply(JournalEvent{Kind: journalExternal, Date: date, Collector: "com.example.jira.issues_created", Value: 2})
+
+	assert.Equal(map[string]int64{"com.example.jira.issues_created": 5}, analytic.External)
+	assert.Empty(analytic.Channels)
+	assert.Equal(int64(10), mergeAnalytics([]*Analytic{analytic, analytic}).External["com.example.jira.issues_created"])
+}
+
+func TestGetExternalDescription(t *testing.T) {
+	assert := assert.New(t)
+
+	T := testTranslate(t, "en")
+	collectors := map[string]*ExternalCollector{
+		"com.example.jira.issues_created": {PluginID: "com.example.jira", Name: "issues_created", Description: "Issues created in Jira", Unit: "issues"},
+		"com.example.ci.builds":           {PluginID: "com.example.ci", Name: "builds", Description: "Builds", Unit: "builds"},
+	}
+	values := map[string]int64{"com.example.jira.issues_created": 5, "com.example.ci.builds": 12, "com.example.gone.metric": 1}
+
+	assert.Equal("### External Metrics\n* Builds: **12** builds\n* Issues created in Jira: **5** issues\n", getExternalDescription(values, collectors, T))
+	assert.Equal("", getExternalDescription(map[string]int64{}, collectors, T))
+}
+
+func TestWriteExternalExportCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	day := time.Date(2020, time.March, 16, 0, 0, 0, 0, time.UTC)
+	var content bytes.Buffer
+	assert.Nil(writeExternalExportCSV(&content, map[exportPeriod]DailyCounters{
+		{date: day.AddDate(0, 0, 1), id: "com.example.ci.builds"}: {Value: 4},
+		{date: day, id: "com.example.jira.issues_created"}:        {Value: 5},
+		{date: day, id: "com.example.ci.builds"}:                  {Value: 12},
+	}))
+	assert.Equal("date,collector,value\n2020-03-16,com.example.ci.builds,12\n2020-03-16,com.example.jira.issues_created,5\n2020-03-17,com.example.ci.builds,4\n", content.String())
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// sqlRetryInterval is the delay between attempts to replay queued writes while the database fails
+	sqlRetryInterval = 30 * time.Second
+	// maxPendingWrites bound the writes queued while the database fails, later writes only reach the kv store
+	maxPendingWrites = 100000
+)
+
+// fallbackDailyStore serve daily buckets from the database, and from the kv store while the database fails
+// writes failing in the database are applied to the kv store and queued, the queue is replayed in order once the
+// database answers again
+type fallbackDailyStore struct {
+	primary   dailyStore
+	secondary dailyStore
+	now       func() time.Time
+	log       func(msg string, keyValuePairs ...interface{})
+
+	lock     sync.Mutex
+	failing  bool
+	retryAt  time.Time
+	pending  []func(dailyStore) error
+	overflow bool
+}
+
+// degraded return true while the database fails and buckets are served from the kv store
+func (s *fallbackDailyStore) degraded() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.failing
+}
+
+// available return true if the database can be used, replaying queued writes first when it is time to retry
+func (s *fallbackDailyStore) available() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.failing {
+		return true
+	}
+	if s.now().Before(s.retryAt) {
+		return false
+	}
+	for len(s.pending) > 0 {
+		if err := s.pending[0](s.primary); err != nil {
+			s.retryAt = s.now().Add(sqlRetryInterval)
+			return false
+		}
+		s.pending = s.pending[1:]
+	}
+	s.failing = false
+	s.overflow = false
+	s.log("database is available again, daily buckets are served from it")
+	return true
+}
+
+// fail switch reads and writes to the kv store after an error of the database
+func (s *fallbackDailyStore) fail(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.failing {
+		s.log("database failed, daily buckets are served from the kv store", "err", err.Error())
+	}
+	s.failing = true
+	s.retryAt = s.now().Add(sqlRetryInterval)
+}
+
+// write apply write to the database, or to the kv store and queue it for the database when it fails
+func (s *fallbackDailyStore) write(write func(dailyStore) error) error {
+	if s.available() {
+		err := write(s.primary)
+		if err == nil {
+			return nil
+		}
+		s.fail(err)
+	}
+	if err := write(s.secondary); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.pending) >= maxPendingWrites {
+		if !s.overflow {
+			s.log("too many writes queued for the database, next ones are only kept in the kv store")
+			s.overflow = true
+		}
+		return nil
+	}
+	s.pending = append(s.pending, write)
+	return nil
+}
+
+func (s *fallbackDailyStore) increment(date time.Time, scope string, id string, delta DailyCounters, stamp int64) error {
+	return s.write(func(store dailyStore) error {
+		return store.increment(date, scope, id, delta, stamp)
+	})
+}
+
+func (s *fallbackDailyStore) get(date time.Time, scope string, id string) (DailyCounters, error) {
+	if s.available() {
+		counters, err := s.primary.get(date, scope, id)
+		if err == nil {
+			return counters, nil
+		}
+		s.fail(err)
+	}
+	return s.secondary.get(date, scope, id)
+}
+
+func (s *fallbackDailyStore) set(bucket dailyBucket) error {
+	return s.write(func(store dailyStore) error {
+		return store.set(bucket)
+	})
+}
+
+func (s *fallbackDailyStore) restore(bucket dailyBucket) (bool, error) {
+	restored := false
+	err := s.write(func(store dailyStore) error {
+		created, err := store.restore(bucket)
+		restored = created
+		return err
+	})
+	return restored, err
+}
+
+func (s *fallbackDailyStore) list(include func(scope string) bool, from time.Time, to time.Time) ([]dailyBucket, error) {
+	if s.available() {
+		buckets, err := s.primary.list(include, from, to)
+		if err == nil {
+			return buckets, nil
+		}
+		s.fail(err)
+	}
+	return s.secondary.list(include, from, to)
+}
+
+func (s *fallbackDailyStore) delete(buckets []dailyBucket) error {
+	return s.write(func(store dailyStore) error {
+		return store.delete(buckets)
+	})
+}
+
+func (s *fallbackDailyStore) deleteBefore(cutoff string) (int, error) {
+	deleted := 0
+	err := s.write(func(store dailyStore) error {
+		n, err := store.deleteBefore(cutoff)
+		deleted = n
+		return err
+	})
+	return deleted, err
+}
+
+// storeDegraded return true if daily buckets are served from the kv store because the database fails
+func (p *Plugin) storeDegraded() bool {
+	s, ok := p.store().(*fallbackDailyStore)
+	return ok && s.degraded()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFallbackDailyStore(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2020, 3, 16, 10, 0, 0, 0, time.Local)
+	database := &memoryDailyStore{increments: make(map[string]DailyCounters)}
+	kv := &memoryDailyStore{increments: make(map[string]DailyCounters)}
+	s := &fallbackDailyStore{primary: database, secondary: kv, now: func() time.Time { return now }, log: func(string, ...interface{}) {}}
+	day := time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local)
+
+	assert.Nil(s.increment(day, dailyScopeChannel, "channel1", DailyCounters{Messages: 1}, 1))
+	assert.False(s.degraded())
+	assert.Equal(int64(1), database.increments["analytics:2020-03-16:c:channel1"].Messages)
+	assert.Empty(kv.increments)
+
+	// reads and writes go to the kv store while the database fails, writes are queued
+	database.err = errors.New("database is down")
+	kv.increments["analytics:2020-03-16:c:channel1"] = DailyCounters{Messages: 5}
+	counters, err := s.get(day, dailyScopeChannel, "channel1")
+	assert.Nil(err)
+	assert.Equal(int64(5), counters.Messages)
+	assert.True(s.degraded())
+	assert.Nil(s.increment(day, dailyScopeChannel, "channel1", DailyCounters{Messages: 2}, 2))
+	assert.Equal(int64(7), kv.increments["analytics:2020-03-16:c:channel1"].Messages)
+	assert.Len(s.pending, 1)
+
+	// the database is not retried before the retry interval
+	database.err = nil
+	counters, err = s.get(day, dailyScopeChannel, "channel1")
+	assert.Nil(err)
+	assert.Equal(int64(7), counters.Messages)
+	assert.True(s.degraded())
+
+	// queued writes are replayed once the database answers again
+	now = now.Add(sqlRetryInterval)
+	counters, err = s.get(day, dailyScopeChannel, "channel1")
+	assert.Nil(err)
+	assert.Equal(int64(3), counters.Messages)
+	assert.False(s.degraded())
+	assert.Empty(s.pending)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const (
+	digestPostsKey = "digest_posts"
+	feedbackKey    = "feedback"
+
+	// nbPeriodsToShrink is the number of consecutive periods with more 👎 than 👍 before shrinking digests
+	nbPeriodsToShrink = 3
+)
+
+// DigestPost is a scheduled digest waiting for its reactions to be collected
+type DigestPost struct {
+	PostID    string
+	ChannelID string
+	Period    string
+	CreatedAt time.Time
+}
+
+// DigestFeedback aggregate reactions left on all digests of a period
+type DigestFeedback struct {
+	Period string
+	Up     int64
+	Down   int64
+}
+
+// Usefulness return the percentage of 👍 among 👍 and 👎, -1 if there is no feedback
+func (f *DigestFeedback) Usefulness() int64 {
+	if f.Up+f.Down == 0 {
+		return -1
+	}
+	return (f.Up * 100) / (f.Up + f.Down)
+}
+
+// recordDigestPost remember a digest to collect its reactions once its period is over
+func (p *Plugin) recordDigestPost(post *model.Post, period string) error {
+	p.feedbackLock.Lock()
+	defer p.feedbackLock.Unlock()
+
+	digests := make([]*DigestPost, 0)
+	if err := p.kvGetJSON(digestPostsKey, &digests); err != nil {
+		return err
+	}
+	digests = append(digests, &DigestPost{PostID: post.Id, ChannelID: post.ChannelId, Period: period, CreatedAt: p.now()})
+	return p.kvSetJSON(digestPostsKey, digests)
+}
+
+// collectFeedback count 👍 and 👎 left on digests of previous periods and aggregate them by period
+func (p *Plugin) collectFeedback(currentPeriod string) error {
+	p.feedbackLock.Lock()
+	defer p.feedbackLock.Unlock()
+
+	digests := make([]*DigestPost, 0)
+	if err := p.kvGetJSON(digestPostsKey, &digests); err != nil {
+		return err
+	}
+	feedbacks := make([]*DigestFeedback, 0)
+	if err := p.kvGetJSON(feedbackKey, &feedbacks); err != nil {
+		return err
+	}
+
+	pending := make([]*DigestPost, 0)
+	for _, digest := range digests {
+		if digest.Period == currentPeriod {
+			pending = append(pending, digest)
+			continue
+		}
+		reactions, appErr := p.API.GetReactions(digest.PostID)
+		if appErr != nil {
+			p.API.LogWarn("can't get reactions of digest, skip it", "post", digest.PostID, "err", appErr.Error())
+			continue
+		}
+		feedback := findOrAppendFeedback(&feedbacks, digest.Period)
+		for _, reaction := range reactions {
+			switch normalizeEmoji(reaction.EmojiName) {
+			case "+1":
+				feedback.Up++
+			case "-1":
+				feedback.Down++
+			}
+		}
+	}
+
+	sort.Slice(feedbacks, func(i, j int) bool {
+		return feedbacks[i].Period < feedbacks[j].Period
+	})
+	if err := p.kvSetJSON(feedbackKey, feedbacks); err != nil {
+		return err
+	}
+	return p.kvSetJSON(digestPostsKey, pending)
+}
+
+func findOrAppendFeedback(feedbacks *[]*DigestFeedback, period string) *DigestFeedback {
+	for _, feedback := range *feedbacks {
+		if feedback.Period == period {
+			return feedback
+		}
+	}
+	feedback := &DigestFeedback{Period: period}
+	*feedbacks = append(*feedbacks, feedback)
+	return feedback
+}
+
+// shouldShrinkDigest return true if the last periods consistently got more 👎 than 👍
+func (p *Plugin) shouldShrinkDigest() bool {
+	if !p.getConfiguration().ShrinkUnusefulDigests {
+		return false
+	}
+	feedbacks := make([]*DigestFeedback, 0)
+	if err := p.kvGetJSON(feedbackKey, &feedbacks); err != nil {
+		p.API.LogWarn("can't get digest feedback", "err", err.Error())
+		return false
+	}
+	if len(feedbacks) < nbPeriodsToShrink {
+		return false
+	}
+	for _, feedback := range feedbacks[len(feedbacks)-nbPeriodsToShrink:] {
+		if feedback.Down <= feedback.Up {
+			return false
+		}
+	}
+	return true
+}
+
+// executeFeedbackCommand handle `/analytics feedback`
+func (p *Plugin) executeFeedbackCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can see digest feedback.")
+	}
+	feedbacks := make([]*DigestFeedback, 0)
+	if err := p.kvGetJSON(feedbackKey, &feedbacks); err != nil {
+		p.API.LogError("can't get digest feedback", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	T := p.channelTranslate(args.ChannelId)
+	if len(feedbacks) == 0 {
+		return ephemeralResponse(T("report.feedback.none"))
+	}
+	text := T("report.feedback.title") + "\n" + T("report.feedback.columns") + "\n|:--|--:|--:|--:|\n"
+	for _, feedback := range feedbacks {
+		usefulness := "-"
+		if feedback.Usefulness() >= 0 {
+			usefulness = fmt.Sprintf("%d%%", feedback.Usefulness())
+		}
+		text += fmt.Sprintf("| %s | %d | %d | %s |\n", feedback.Period, feedback.Up, feedback.Down, usefulness)
+	}
+	return ephemeralResponse(text)
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// ForgetResult count per user data removed or anonymized for a user
+type ForgetResult struct {
+	UserID string `json:"user_id"`
+	// Counters is the number of per user counters removed from sessions
+	Counters int `json:"counters"`
+	// Buckets is the number of daily buckets of the user deleted
+	Buckets int `json:"buckets"`
+	// SurveyAnswers is the number of survey answers anonymized, their scores are kept
+	SurveyAnswers int `json:"survey_answers"`
+}
+
+// scrubUser remove counters of userID and mentions of username from the analytic and return the number of removed
+// counters, channel totals are untouched, caller must hold the write lock of the analytic
+func scrubUser(analytic *Analytic, userID string, username string) int {
+	removed := 0
+	if _, ok := analytic.Mentions[username]; ok && username != "" {
+		delete(analytic.Mentions, username)
+		removed++
+	}
+	for _, counters := range []map[string]int64{analytic.Users, analytic.UsersReply} {
+		if _, ok := counters[userID]; ok {
+			delete(counters, userID)
+			removed++
+		}
+	}
+	for _, counters := range []map[string]int64{analytic.ChannelsUsers, analytic.ChannelsUsersReply} {
+		for key := range counters {
+			if strings.HasSuffix(key, ":"+userID) {
+				delete(counters, key)
+				removed++
+			}
+		}
+	}
+	return removed
+}
+
+// isUserDailyKey return true if key is a daily bucket of userID
+func isUserDailyKey(key string, userID string) bool {
+	parts := strings.SplitN(strings.TrimPrefix(key, dailyKeyPrefix), ":", 3)
+	return strings.HasPrefix(key, dailyKeyPrefix) && len(parts) == 3 && parts[1] == dailyScopeUser && parts[2] == userID
+}
+
+// forgetUser remove per user analytics of userID from the current session, archived sessions and daily buckets,
+// and anonymize its survey answers, aggregated channel totals are preserved
+func (p *Plugin) forgetUser(actorID string, userID string) (*ForgetResult, error) {
+	result := &ForgetResult{UserID: userID}
+	// mentions are keyed by username, a deleted user only has its id left
+	username := ""
+	if user, appErr := p.API.GetUser(userID); appErr == nil {
+		username = strings.ToLower(user.Username)
+	}
+
+	// sessions are rewritten under the lock of the current analytic so a new session can't be archived meanwhile
+	p.currentAnalytic.WLock()
+	if p.clusterDelta != nil {
+		// the shared session is scrubbed too, it would bring counters of the user back at the next merge
+		scrubbed := 0
+		if err := p.mergeClusterSession(func(merged *Analytic) { scrubbed = scrubUser(merged, userID, username) }, false); err != nil {
+			p.currentAnalytic.WUnlock()
+			return nil, err
+		}
+		result.Counters += scrubbed
+	}
+	result.Counters += scrubUser(p.currentAnalytic, userID, username)
+	sessions, err := p.allSessions()
+	if err != nil {
+		p.currentAnalytic.WUnlock()
+		return nil, err
+	}
+	for _, session := range sessions {
+		result.Counters += scrubUser(session, userID, username)
+	}
+	j, err := json.Marshal(sessions)
+	if err != nil {
+		p.currentAnalytic.WUnlock()
+		return nil, errors.Wrap(err, "can't marshal sessions")
+	}
+	if appErr := p.API.KVSet("allAnalytics", j); appErr != nil {
+		p.currentAnalytic.WUnlock()
+		return nil, errors.Wrap(appErr, "can't save sessions")
+	}
+	p.currentAnalytic.WUnlock()
+	// counters spilled to kv would bring the user back once restored
+	scrubbed, err := p.scrubSpilled(userID, username)
+	if err != nil {
+		return nil, err
+	}
+	result.Counters += scrubbed
+	// saving the current session also checkpoints the journal, dropping raw events of the user
+	if err := p.saveCurrentAnalytic(); err != nil {
+		return nil, err
+	}
+
+	// increments still buffered would write buckets of the user again
+	if err := p.flushWriteBuffer(); err != nil {
+		return nil, err
+	}
+	buckets, err := p.listAllDailyBuckets(func(scope string) bool { return scope == dailyScopeUser })
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	for _, bucket := range buckets {
+		if bucket.ID != userID {
+			continue
+		}
+		if err := p.store().delete([]dailyBucket{bucket}); err != nil {
+			return nil, err
+		}
+		keys = append(keys, dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+		result.Buckets++
+	}
+	// buckets of purges in their grace period and kv copies left by a switch to the sql backend are forgotten too
+	leftovers := make([]string, 0)
+	perPage := 100
+	for page := 0; ; page++ {
+		list, appErr := p.API.KVList(page, perPage)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't list kv keys")
+		}
+		for _, key := range list {
+			if isUserDailyKey(strings.TrimPrefix(key, tombstoneKeyPrefix), userID) {
+				leftovers = append(leftovers, key)
+			}
+		}
+		if len(list) < perPage {
+			break
+		}
+	}
+	for _, key := range leftovers {
+		if appErr := p.API.KVDelete(key); appErr != nil {
+			return nil, errors.Wrap(appErr, "can't delete key "+key)
+		}
+		result.Buckets++
+	}
+	if err := p.recordDeletedBuckets(keys, p.now()); err != nil {
+		p.API.LogWarn("can't record deleted buckets for delta syncs", "err", err.Error())
+	}
+
+	if err := p.forgetPurgedKeys(userID); err != nil {
+		return nil, err
+	}
+
+	p.surveysLock.Lock()
+	surveys := make([]*Survey, 0)
+	err = p.kvGetJSON(surveysKey, &surveys)
+	if err == nil {
+		for _, survey := range surveys {
+			if score, ok := survey.Answers[userID]; ok {
+				delete(survey.Answers, userID)
+				survey.Answers[model.NewId()] = score
+				result.SurveyAnswers++
+			}
+		}
+		if result.SurveyAnswers > 0 {
+			err = p.kvSetJSON(surveysKey, surveys)
+		}
+	}
+	p.surveysLock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	p.audit("user_forgotten", actorID, map[string]string{
+		"counters":       strconv.Itoa(result.Counters),
+		"buckets":        strconv.Itoa(result.Buckets),
+		"survey_answers": strconv.Itoa(result.SurveyAnswers),
+	})
+	return result, nil
+}
+
+// handleForgetUser serve `POST /api/v1/users/forget?user_id=` removing per user analytics of a user
+func (p *Plugin) handleForgetUser(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeAPI(w, r) {
+		return nil
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	userID := r.URL.Query().Get("user_id")
+	if !model.IsValidId(userID) {
+		http.Error(w, "bad user_id", http.StatusBadRequest)
+		return nil
+	}
+	result, err := p.forgetUser(r.Header.Get("Mattermost-User-Id"), userID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
+
+// executeForgetCommand handle `/analytics forget @username`, a user id is accepted for deleted users
+func (p *Plugin) executeForgetCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can forget users.")
+	}
+	if len(parameters) != 1 {
+		return ephemeralResponse("Usage: /analytics forget @username")
+	}
+	userID := parameters[0]
+	if user, appErr := p.API.GetUserByUsername(strings.TrimPrefix(parameters[0], "@")); appErr == nil {
+		userID = user.Id
+	} else if !model.IsValidId(userID) {
+		return ephemeralResponse(fmt.Sprintf("Unknown user %s.", parameters[0]))
+	}
+	result, err := p.forgetUser(args.UserId, userID)
+	if err != nil {
+		p.API.LogError("can't forget user", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return ephemeralResponse(fmt.Sprintf("Analytics of %s forgotten: %d counters and %d daily buckets removed, %d survey answers anonymized. Channel totals are kept.", parameters[0], result.Counters, result.Buckets, result.SurveyAnswers))
+}
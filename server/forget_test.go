@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScrubUser(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	analytic.Channels = map[string]int64{"channel1": 5, "channel2": 2}
+	analytic.ChannelsReply = map[string]int64{"channel1": 2}
+	analytic.Users = map[string]int64{"user1": 4, "user2": 3}
+	analytic.UsersReply = map[string]int64{"user1": 2}
+	analytic.ChannelsUsers = map[string]int64{"channel1:user1": 3, "channel2:user1": 1, "channel1:user2": 2, "channel2:user12": 1}
+	analytic.ChannelsUsersReply = map[string]int64{"channel1:user1": 2}
+	analytic.Mentions = map[string]int64{"alice": 2, "bob": 1}
+
+	assert.Equal(6, scrubUser(analytic, "user1", "alice"))
+	assert.Equal(map[string]int64{"bob": 1}, analytic.Mentions)
+	assert.Equal(map[string]int64{"user2": 3}, analytic.Users)
+	assert.Empty(analytic.UsersReply)
+	assert.Equal(map[string]int64{"channel1:user2": 2, "channel2:user12": 1}, analytic.ChannelsUsers)
+	assert.Empty(analytic.ChannelsUsersReply)
+	assert.Equal(map[string]int64{"channel1": 5, "channel2": 2}, analytic.Channels)
+	assert.Equal(map[string]int64{"channel1": 2}, analytic.ChannelsReply)
+
+	assert.Equal(0, scrubUser(analytic, "user1", "alice"))
+	assert.Equal(0, scrubUser(analytic, "user3", ""))
+	assert.Equal(map[string]int64{"bob": 1}, analytic.Mentions)
+}
+
+func TestIsUserDailyKey(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isUserDailyKey("analytics:2019-05-01:u:user1", "user1"))
+	assert.False(isUserDailyKey("analytics:2019-05-01:u:user12", "user1"))
+	assert.False(isUserDailyKey("analytics:2019-05-01:c:user1", "user1"))
+	assert.False(isUserDailyKey("analytics", "user1"))
+	assert.False(isUserDailyKey("report:2019-05-01:u:user1", "user1"))
+}
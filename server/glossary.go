@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// glossaryDescription return the glossary of metrics of the data dictionary whose section appears in fields
+// each metric is listed once, in the order of the catalog, empty if no section needs explanation
+func glossaryDescription(fields []*model.SlackAttachmentField, T translateFunc) string {
+	var values strings.Builder
+	for _, field := range fields {
+		if value, ok := field.Value.(string); ok {
+			values.WriteString(strings.Replace(value, rtlMark, "", -1))
+			values.WriteByte('\n')
+		}
+	}
+	text := values.String()
+	m := ""
+	for _, definition := range metricsCatalog {
+		if definition.Section == "" || !strings.Contains(text, sectionTitle(T, definition.Section)) {
+			continue
+		}
+		m += "* **" + strings.Replace(definition.Name, "_", " ", -1) + "** (" + definition.Unit + "): " + definition.Description + "\n"
+	}
+	if m == "" {
+		return ""
+	}
+	return sectionTitle(T, "report.glossary.title") + m
+}
+
+// withGlossary return a copy of attachments whose first attachment ends with the glossary of its metrics
+// attachments are returned as is when no metric needs explanation
+func withGlossary(attachments []*model.SlackAttachment, rtl bool, T translateFunc) []*model.SlackAttachment {
+	if len(attachments) == 0 {
+		return attachments
+	}
+	glossary := glossaryDescription(attachments[0].Fields, T)
+	if glossary == "" {
+		return attachments
+	}
+	if rtl {
+		glossary = rtlMarkdown(glossary)
+	}
+	first := *attachments[0]
+	first.Fields = append(append([]*model.SlackAttachmentField{}, first.Fields...), &model.SlackAttachmentField{Short: false, Value: glossary})
+	return append([]*model.SlackAttachment{&first}, attachments[1:]...)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlossaryDescription(t *testing.T) {
+	assert := assert.New(t)
+
+	T := testTranslate(t, "en")
+	fields := []*model.SlackAttachmentField{
+		{Value: sectionTitle(T, "report.health.title") + "* town-square: 80\n"},
+		{Value: rtlMarkdown(sectionTitle(T, "report.response_times.title") + "* median: 5 minutes\n")},
+		{Value: sectionTitle(T, "report.top_users.title")},
+	}
+	glossary := glossaryDescription(fields, T)
+	assert.Contains(glossary, "### Glossary\n")
+	assert.Contains(glossary, "* **health score** (score): ")
+	assert.Contains(glossary, "* **first response time** (minutes): ")
+	assert.NotContains(glossary, "lurkers")
+	assert.True(strings.Index(glossary, "first response time") < strings.Index(glossary, "health score"))
+
+	assert.Equal("", glossaryDescription(fields[2:], T))
+	fr := testTranslate(t, "fr")
+	assert.Contains(glossaryDescription([]*model.SlackAttachmentField{{Value: sectionTitle(fr, "report.health.title")}}, fr), "### Glossaire\n")
+}
+
+func TestWithGlossary(t *testing.T) {
+	assert := assert.New(t)
+
+	T := testTranslate(t, "en")
+	attachments := []*model.SlackAttachment{{Fields: []*model.SlackAttachmentField{{Value: sectionTitle(T, "report.roles.title")}}}}
+	glossary := withGlossary(attachments, false, T)
+	assert.Len(glossary[0].Fields, 2)
+	assert.Len(attachments[0].Fields, 1)
+
+	plain := []*model.SlackAttachment{{Fields: []*model.SlackAttachmentField{{Value: "nothing to explain"}}}}
+	assert.Equal(plain, withGlossary(plain, false, T))
+	assert.Empty(withGlossary(nil, false, T))
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	healthFrequency    = "frequency"
+	healthParticipants = "participants"
+	healthReplies      = "replies"
+	healthLatency      = "latency"
+
+	// healthyMessagesPerDay is the posting frequency of a channel with a full frequency score
+	healthyMessagesPerDay = 20
+	// healthyParticipants is the number of posters of a channel with a full participants score
+	healthyParticipants = 10
+	// healthDropToFlag is the drop of health score since the previous session flagging a channel as trending toward inactivity
+	healthDropToFlag = 15
+)
+
+// healthComponents are components of the health score in display order
+var healthComponents = []string{healthFrequency, healthParticipants, healthReplies, healthLatency}
+
+// defaultHealthWeights is the weight of each component when HealthWeights is not set
+var defaultHealthWeights = map[string]float64{
+	healthFrequency:    40,
+	healthParticipants: 30,
+	healthReplies:      15,
+	healthLatency:      15,
+}
+
+// parseHealthWeights parse weights in form frequency:40,participants:30,replies:15,latency:15
+// missing components weigh 0, an empty config return default weights
+func parseHealthWeights(config string) (map[string]float64, error) {
+	if strings.TrimSpace(config) == "" {
+		return defaultHealthWeights, nil
+	}
+	weights := make(map[string]float64)
+	total := 0.0
+	for _, entry := range strings.Split(config, ",") {
+		v := strings.SplitN(entry, ":", 2)
+		if len(v) != 2 {
+			return nil, fmt.Errorf("Bad formatted health weight: %v, expected component:weight", entry)
+		}
+		component := strings.ToLower(strings.TrimSpace(v[0]))
+		if _, ok := defaultHealthWeights[component]; !ok {
+			return nil, fmt.Errorf("Unknown health component %v, expected one of %v", v[0], strings.Join(healthComponents, ", "))
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(v[1]), 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("Bad health weight %v, expected a positive number", v[1])
+		}
+		weights[component] = weight
+		total += weight
+	}
+	if total == 0 {
+		return nil, errors.New("Health weights can't all be 0")
+	}
+	return weights, nil
+}
+
+// channelActivity is the activity of a channel during a session used to compute its health
+type channelActivity struct {
+	Days         float64
+	Messages     int64
+	Replies      int64
+	Participants int64
+	// Delays are delays before the first reply of someone else to root posts, sorted ascending
+	Delays []time.Duration
+}
+
+// healthScore return the health of a channel between 0 and 100, the weighted mean of component scores between 0 and 1
+func healthScore(activity *channelActivity, weights map[string]float64) int64 {
+	scores := make(map[string]float64, len(healthComponents))
+	if activity.Days > 0 {
+		scores[healthFrequency] = minFloat(float64(activity.Messages)/activity.Days/healthyMessagesPerDay, 1)
+	}
+	scores[healthParticipants] = minFloat(float64(activity.Participants)/healthyParticipants, 1)
+	if activity.Messages > 0 {
+		scores[healthReplies] = float64(activity.Replies) / float64(activity.Messages)
+	}
+	if len(activity.Delays) > 0 {
+		// an immediate answer scores 1, an answer after an hour 0.5, after a day 0.04
+		scores[healthLatency] = 1 / (1 + medianDelay(activity.Delays).Hours())
+	}
+
+	sum, total := 0.0, 0.0
+	for component, weight := range weights {
+		sum += scores[component] * weight
+		total += weight
+	}
+	if total == 0 {
+		return 0
+	}
+	return int64(sum * 100 / total)
+}
+
+func minFloat(a float64, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// firstReplyDelays return sorted delays before the first reply of someone else to root posts created in [from, to)
+func firstReplyDelays(posts *model.PostList, from time.Time, to time.Time) []time.Duration {
+	firsts := make(map[string]int64)
+	for _, post := range posts.Posts {
+		root, ok := posts.Posts[post.RootId]
+		if !ok || post.UserId == root.UserId {
+			continue
+		}
+		if first, ok := firsts[post.RootId]; !ok || post.CreateAt < first {
+			firsts[post.RootId] = post.CreateAt
+		}
+	}
+	delays := make([]time.Duration, 0, len(firsts))
+	for rootID, replyAt := range firsts {
+		root := posts.Posts[rootID]
+		createdAt := time.Unix(0, root.CreateAt*int64(time.Millisecond))
+		if root.Type != "" || createdAt.Before(from) || !createdAt.Before(to) {
+			continue
+		}
+		delays = append(delays, time.Duration(replyAt-root.CreateAt)*time.Millisecond)
+	}
+	sort.Slice(delays, func(i, j int) bool {
+		return delays[i] < delays[j]
+	})
+	return delays
+}
+
+// channelsActivity return the activity of each channel of the analytic ending at to
+func channelsActivity(analytic *Analytic, to time.Time) map[string]*channelActivity {
+	analytic.RLock()
+	defer analytic.RUnlock()
+	if !analytic.End.IsZero() {
+		to = analytic.End
+	}
+	days := to.Sub(analytic.Start).Hours() / 24
+	posters := channelsPosters(analytic.ChannelsUsers)
+	activities := make(map[string]*channelActivity, len(analytic.Channels))
+	for channelID, nb := range analytic.Channels {
+		activities[channelID] = &channelActivity{
+			Days:         days,
+			Messages:     nb,
+			Replies:      analytic.ChannelsReply[channelID],
+			Participants: posters[channelID],
+		}
+	}
+	return activities
+}
+
+// decliningChannels return channels whose health dropped by healthDropToFlag since the previous session, sorted by drop
+// channels silent in the current session have a score of 0
+func decliningChannels(previous map[string]int64, current map[string]int64) []string {
+	declining := make([]string, 0)
+	for channelID, score := range previous {
+		if score-current[channelID] >= healthDropToFlag {
+			declining = append(declining, channelID)
+		}
+	}
+	sort.Slice(declining, func(i, j int) bool {
+		dropI := previous[declining[i]] - current[declining[i]]
+		dropJ := previous[declining[j]] - current[declining[j]]
+		if dropI == dropJ {
+			return declining[i] < declining[j]
+		}
+		return dropI > dropJ
+	})
+	return declining
+}
+
+// collectChannelsHealth return health scores of channels during the analytic and the session before it
+// channels of the previous session are filtered by include if not nil
+func (p *Plugin) collectChannelsHealth(analytic *Analytic, include func(channelID string) bool) (map[string]int64, map[string]int64, error) {
+	weights, err := parseHealthWeights(p.getConfiguration().HealthWeights)
+	if err != nil {
+		return nil, nil, err
+	}
+	analytic.RLock()
+	start := analytic.Start
+	analytic.RUnlock()
+	now := p.now()
+
+	current := channelsActivity(analytic, now)
+	previous := make(map[string]*channelActivity)
+	previousStart := start
+	sessions, err := p.allSessions()
+	if err != nil {
+		return nil, nil, err
+	}
+	var last *Analytic
+	for _, session := range sessions {
+		if session.Start.Before(start) && (last == nil || session.Start.After(last.Start)) {
+			last = session
+		}
+	}
+	if last != nil {
+		if include != nil {
+			last = filterAnalytic(last, include)
+		}
+		previous = channelsActivity(last, start)
+		previousStart = last.Start
+	}
+
+	channelsID := make(map[string]bool)
+	for channelID := range current {
+		channelsID[channelID] = true
+	}
+	for channelID := range previous {
+		channelsID[channelID] = true
+	}
+	for channelID := range channelsID {
+		// direct and group messages have no health
+		if teamID, err := p.getChannelTeamID(channelID); err != nil || teamID == "" {
+			delete(current, channelID)
+			delete(previous, channelID)
+			continue
+		}
+		posts, appErr := p.API.GetPostsSince(channelID, previousStart.UnixNano()/int64(time.Millisecond))
+		if appErr != nil {
+			return nil, nil, errors.Wrap(appErr, "can't get posts of channel "+channelID)
+		}
+		if activity, ok := current[channelID]; ok {
+			activity.Delays = firstReplyDelays(posts, start, now)
+		}
+		if activity, ok := previous[channelID]; ok {
+			activity.Delays = firstReplyDelays(posts, previousStart, start)
+		}
+	}
+
+	currentScores := make(map[string]int64, len(current))
+	for channelID, activity := range current {
+		currentScores[channelID] = healthScore(activity, weights)
+	}
+	previousScores := make(map[string]int64, len(previous))
+	for channelID, activity := range previous {
+		previousScores[channelID] = healthScore(activity, weights)
+	}
+	return currentScores, previousScores, nil
+}
+
+// getHealthDescription render channels trending toward inactivity with their health score
+func (p *Plugin) getHealthDescription(current map[string]int64, previous map[string]int64, T translateFunc) string {
+	declining := decliningChannels(previous, current)
+	if len(declining) == 0 {
+		return ""
+	}
+	m := sectionTitle(T, "report.health.title")
+	for index, channelID := range declining {
+		if index == maxChannelsToDisplay {
+			break
+		}
+		_, displayName, link, err := p.getChannelName(channelID)
+		if err != nil {
+			continue
+		}
+		m += fmt.Sprintf("* [~%s](%s): health **%d**/100, was %d\n", displayName, link, current[channelID], previous[channelID])
+	}
+	return m
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHealthWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	weights, err := parseHealthWeights("")
+	assert.Nil(err)
+	assert.Equal(defaultHealthWeights, weights)
+
+	weights, err = parseHealthWeights("Frequency:1, latency:0.5")
+	assert.Nil(err)
+	assert.Equal(map[string]float64{healthFrequency: 1, healthLatency: 0.5}, weights)
+
+	for _, config := range []string{"frequency", "mood:10", "replies:-1", "replies:x", "replies:0,latency:0"} {
+		_, err = parseHealthWeights(config)
+		assert.NotNil(err, config)
+	}
+}
+
+func TestHealthScore(t *testing.T) {
+	assert := assert.New(t)
+
+	healthy := &channelActivity{Days: 7, Messages: 200, Replies: 200, Participants: 12, Delays: []time.Duration{0}}
+	assert.Equal(int64(100), healthScore(healthy, defaultHealthWeights))
+	assert.Equal(int64(0), healthScore(&channelActivity{Days: 7}, defaultHealthWeights))
+
+	activity := &channelActivity{Days: 7, Messages: 70, Replies: 35, Participants: 4, Delays: []time.Duration{time.Hour}}
+	assert.Equal(int64(50), healthScore(activity, map[string]float64{healthFrequency: 1}))
+	assert.Equal(int64(50), healthScore(activity, map[string]float64{healthLatency: 1, healthReplies: 1}))
+	assert.Equal(int64(47), healthScore(activity, defaultHealthWeights))
+}
+
+func TestFirstReplyDelays(t *testing.T) {
+	assert := assert.New(t)
+	from := time.Date(2019, time.April, 14, 0, 0, 0, 0, time.UTC)
+	millis := func(d time.Duration) int64 {
+		return from.Add(d).UnixNano() / int64(time.Millisecond)
+	}
+	posts := &model.PostList{Posts: map[string]*model.Post{
+		"root1":  {Id: "root1", UserId: "alice", CreateAt: millis(time.Hour)},
+		"self":   {Id: "self", UserId: "alice", RootId: "root1", CreateAt: millis(time.Hour + time.Minute)},
+		"reply1": {Id: "reply1", UserId: "bob", RootId: "root1", CreateAt: millis(time.Hour + 30*time.Minute)},
+		"reply2": {Id: "reply2", UserId: "carol", RootId: "root1", CreateAt: millis(3 * time.Hour)},
+		"root2":  {Id: "root2", UserId: "bob", CreateAt: millis(2 * time.Hour)},
+		"reply3": {Id: "reply3", UserId: "alice", RootId: "root2", CreateAt: millis(2*time.Hour + 10*time.Minute)},
+		"old":    {Id: "old", UserId: "bob", CreateAt: millis(-time.Hour)},
+		"reply4": {Id: "reply4", UserId: "alice", RootId: "old", CreateAt: millis(time.Minute)},
+	}}
+
+	assert.Equal([]time.Duration{10 * time.Minute, 30 * time.Minute}, firstReplyDelays(posts, from, from.AddDate(0, 0, 1)))
+}
+
+func TestDecliningChannels(t *testing.T) {
+	assert := assert.New(t)
+
+	previous := map[string]int64{"dev": 60, "ops": 40, "hr": 30, "sales": 50}
+	current := map[string]int64{"dev": 50, "ops": 20, "sales": 30, "new": 10}
+	assert.Equal([]string{"hr", "ops", "sales"}, decliningChannels(previous, current))
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// heatmapShades are shades of heatmap cells from the least to the most active
+var heatmapShades = []string{"░", "▒", "▓", "█"}
+
+// hourlyHeatmap sum messages by day of week and hour of day, from messages by hour (formatted as 2006-01-02T15)
+func hourlyHeatmap(hourly map[string]int64) [7][24]int64 {
+	var heatmap [7][24]int64
+	for key, nb := range hourly {
+		hour, err := time.Parse(hourlyKeyFormat, key)
+		if err != nil {
+			continue
+		}
+		heatmap[hour.Weekday()][hour.Hour()] += nb
+	}
+	return heatmap
+}
+
+// heatmapShade return the shade of a cell relatively to the most active cell, empty without message
+func heatmapShade(nb int64, max int64) string {
+	if nb == 0 || max == 0 {
+		return ""
+	}
+	index := int((nb*int64(len(heatmapShades)) - 1) / max)
+	if index >= len(heatmapShades) {
+		index = len(heatmapShades) - 1
+	}
+	return heatmapShades[index]
+}
+
+// formatHeatmap render the heatmap as a markdown table, a line by day starting at weekStart and a column by hour
+// with the most active slot, empty if there is no message
+func formatHeatmap(heatmap [7][24]int64, weekStart time.Weekday, T translateFunc) string {
+	max := int64(0)
+	maxDay, maxHour := time.Sunday, 0
+	for day := range heatmap {
+		for hour, nb := range heatmap[day] {
+			if nb > max {
+				max = nb
+				maxDay, maxHour = time.Weekday(day), hour
+			}
+		}
+	}
+	if max == 0 {
+		return ""
+	}
+
+	m := sectionTitle(T, "report.heatmap.title")
+	m += T("report.heatmap.most_active", map[string]interface{}{
+		"Day":      weekdayName(maxDay, T),
+		"From":     fmt.Sprintf("%02d:00", maxHour),
+		"To":       fmt.Sprintf("%02d:00", (maxHour+1)%24),
+		"Messages": max,
+	}) + "\n\n"
+	m += "| |"
+	for hour := 0; hour < 24; hour++ {
+		m += fmt.Sprintf(" %d |", hour)
+	}
+	m += "\n|:--|" + strings.Repeat(":-:|", 24) + "\n"
+	for i := 0; i < 7; i++ {
+		day := time.Weekday((int(weekStart) + i) % 7)
+		m += fmt.Sprintf("| %s |", string([]rune(weekdayName(day, T))[:3]))
+		for hour := 0; hour < 24; hour++ {
+			m += fmt.Sprintf(" %s |", heatmapShade(heatmap[day][hour], max))
+		}
+		m += "\n"
+	}
+	return m
+}
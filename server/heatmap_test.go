@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHourlyHeatmap(t *testing.T) {
+	assert := assert.New(t)
+
+	heatmap := hourlyHeatmap(map[string]int64{
+		"2019-04-16T10": 3,
+		"2019-04-23T10": 2,
+		"2019-04-21T23": 1,
+		"bad key":       5,
+	})
+	assert.Equal(int64(5), heatmap[time.Tuesday][10])
+	assert.Equal(int64(1), heatmap[time.Sunday][23])
+	assert.Equal(int64(0), heatmap[time.Monday][10])
+}
+
+func TestHeatmapShade(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", heatmapShade(0, 8))
+	assert.Equal("░", heatmapShade(1, 8))
+	assert.Equal("░", heatmapShade(2, 8))
+	assert.Equal("▒", heatmapShade(3, 8))
+	assert.Equal("▓", heatmapShade(6, 8))
+	assert.Equal("█", heatmapShade(7, 8))
+	assert.Equal("█", heatmapShade(8, 8))
+}
+
+func TestFormatHeatmap(t *testing.T) {
+	assert := assert.New(t)
+
+	var heatmap [7][24]int64
+	assert.Equal("", formatHeatmap(heatmap, time.Sunday, testTranslate(t, "en")))
+
+	heatmap[time.Tuesday][10] = 4
+	heatmap[time.Sunday][23] = 1
+	m := formatHeatmap(heatmap, time.Monday, testTranslate(t, "en"))
+	assert.Contains(m, "Most active on **Tuesday between 10:00 and 11:00** with **4** messages.")
+	lines := strings.Split(strings.TrimSpace(m), "\n")
+	assert.True(strings.HasPrefix(lines[len(lines)-7], "| Mon |"))
+	assert.True(strings.HasPrefix(lines[len(lines)-1], "| Sun |"))
+	assert.True(strings.HasSuffix(lines[len(lines)-1], " ░ |"))
+
+	m = formatHeatmap(heatmap, time.Monday, testTranslate(t, "fr"))
+	assert.Contains(m, "Le plus actif le **mardi entre 10:00 et 11:00** avec **4** messages.")
+	lines = strings.Split(strings.TrimSpace(m), "\n")
+	assert.True(strings.HasPrefix(lines[len(lines)-7], "| lun |"))
+}
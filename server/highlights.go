@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// maxHighlights is the maximum number of notable changes in reports
+	maxHighlights = 5
+	// minHighlightZScore is the minimum z-score of a notable change
+	minHighlightZScore = 2
+	// minHighlightSessions is the number of previous sessions needed to compare a channel to its history
+	minHighlightSessions = 3
+	// maxHighlightSessions is the number of previous sessions used as history
+	maxHighlightSessions = 12
+)
+
+// highlight is an unusual number of messages by day in a channel compared to previous sessions
+type highlight struct {
+	channelID string
+	current   float64
+	mean      float64
+	zScore    float64
+}
+
+// zScore return the number of standard deviations between value and the mean of history
+// the standard deviation is at least 1 so a flat history doesn't turn every small change into a highlight
+func zScore(value float64, history []float64) (float64, float64) {
+	mean := 0.0
+	for _, v := range history {
+		mean += v
+	}
+	mean /= float64(len(history))
+	variance := 0.0
+	for _, v := range history {
+		variance += (v - mean) * (v - mean)
+	}
+	std := math.Max(math.Sqrt(variance/float64(len(history))), 1)
+	return (value - mean) / std, mean
+}
+
+// messagesByDay return the number of messages by day of each channel of the session ending at to
+// caller must hold the read lock of the session
+func messagesByDay(session *Analytic, to time.Time) map[string]float64 {
+	if !session.End.IsZero() {
+		to = session.End
+	}
+	days := math.Max(to.Sub(session.Start).Hours()/24, 1)
+	rates := make(map[string]float64, len(session.Channels))
+	for channelID, nb := range session.Channels {
+		rates[channelID] = float64(nb) / days
+	}
+	return rates
+}
+
+// notableChanges return up to max channels whose messages by day are the most unusual compared to history
+// sorted by absolute z-score, channels with less than minHighlightSessions sessions of history are ignored
+// and the change must reach threshold to be notable
+func notableChanges(current map[string]float64, history []map[string]float64, max int, threshold deltaThreshold) []highlight {
+	if len(history) < minHighlightSessions {
+		return nil
+	}
+	channelsID := make(map[string]bool)
+	for channelID := range current {
+		channelsID[channelID] = true
+	}
+	for _, session := range history {
+		for channelID := range session {
+			channelsID[channelID] = true
+		}
+	}
+
+	highlights := make([]highlight, 0)
+	for channelID := range channelsID {
+		values := make([]float64, 0, len(history))
+		for _, session := range history {
+			values = append(values, session[channelID])
+		}
+		z, mean := zScore(current[channelID], values)
+		change := math.Abs(current[channelID] - mean)
+		if math.Abs(z) < minHighlightZScore || change < float64(threshold.Absolute) || (mean > 0 && change*100/mean < float64(threshold.Percent)) {
+			continue
+		}
+		highlights = append(highlights, highlight{channelID: channelID, current: current[channelID], mean: mean, zScore: z})
+	}
+	sort.Slice(highlights, func(i, j int) bool {
+		if math.Abs(highlights[i].zScore) == math.Abs(highlights[j].zScore) {
+			return highlights[i].channelID < highlights[j].channelID
+		}
+		return math.Abs(highlights[i].zScore) > math.Abs(highlights[j].zScore)
+	})
+	if len(highlights) > max {
+		highlights = highlights[:max]
+	}
+	return highlights
+}
+
+// getHighlightsDescription render the most unusual changes of the analytic compared to previous sessions
+// sessions are filtered by include if not nil
+func (p *Plugin) getHighlightsDescription(analytic *Analytic, include func(channelID string) bool, T translateFunc) (string, error) {
+	sessions, err := p.allSessions()
+	if err != nil {
+		return "", err
+	}
+	analytic.RLock()
+	start := analytic.Start
+	current := messagesByDay(analytic, p.now())
+	analytic.RUnlock()
+
+	previous := make([]*Analytic, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Start.Before(start) {
+			previous = append(previous, session)
+		}
+	}
+	sort.Slice(previous, func(i, j int) bool {
+		return previous[i].Start.After(previous[j].Start)
+	})
+	if len(previous) > maxHighlightSessions {
+		previous = previous[:maxHighlightSessions]
+	}
+	history := make([]map[string]float64, 0, len(previous))
+	for _, session := range previous {
+		if include != nil {
+			session = filterAnalytic(session, include)
+		}
+		session.RLock()
+		history = append(history, messagesByDay(session, session.End))
+		session.RUnlock()
+	}
+
+	highlights := notableChanges(current, history, maxHighlights, p.getConfiguration().deltaThreshold())
+	if len(highlights) == 0 {
+		return "", nil
+	}
+	m := sectionTitle(T, "report.highlights.title")
+	for _, h := range highlights {
+		_, displayName, link, err := p.getChannelName(h.channelID)
+		if err != nil {
+			continue
+		}
+		arrow := "▲"
+		if h.zScore < 0 {
+			arrow = "▼"
+		}
+		m += fmt.Sprintf("* %s [~%s](%s): **%.0f** messages a day, usually %.0f *(%.1f standard deviations)*\n", arrow, displayName, link, h.current, h.mean, math.Abs(h.zScore))
+	}
+	return m, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZScore(t *testing.T) {
+	assert := assert.New(t)
+
+	z, mean := zScore(20, []float64{8, 12, 8, 12})
+	assert.Equal(10.0, mean)
+	assert.Equal(5.0, z)
+
+	z, mean = zScore(3, []float64{5, 5, 5})
+	assert.Equal(5.0, mean)
+	assert.Equal(-2.0, z, "standard deviation is at least 1")
+}
+
+func TestNotableChanges(t *testing.T) {
+	assert := assert.New(t)
+	history := []map[string]float64{
+		{"dev": 10, "ops": 40, "hr": 2, "sales": 30},
+		{"dev": 12, "ops": 42, "hr": 2, "sales": 30},
+		{"dev": 8, "ops": 38, "hr": 2, "sales": 30},
+	}
+	current := map[string]float64{"dev": 30, "ops": 10, "hr": 5, "sales": 31, "new": 25}
+	threshold := deltaThreshold{Percent: 10, Absolute: 5}
+
+	highlights := notableChanges(current, history, 5, threshold)
+	assert.Len(highlights, 3)
+	assert.Equal("new", highlights[0].channelID)
+	assert.Equal("ops", highlights[1].channelID)
+	assert.True(highlights[1].zScore < 0)
+	assert.Equal("dev", highlights[2].channelID)
+
+	assert.Len(notableChanges(current, history, 2, threshold), 2)
+	assert.Empty(notableChanges(current, history[:2], 5, threshold), "not enough history")
+}
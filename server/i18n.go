@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/go-i18n/i18n/bundle"
+	"github.com/pkg/errors"
+)
+
+const defaultLanguage = "en"
+
+// translationsDirectory is the directory of translation files inside the plugin bundle
+var translationsDirectory = filepath.Join("assets", "i18n")
+
+// supportedLanguages are languages of bundled translations
+var supportedLanguages = []string{"en", "de", "fr", "es"}
+
+// translateFunc return the translation of id, rendered with the template values of args if any
+type translateFunc func(id string, args ...interface{}) string
+
+// isSupportedLanguage return true if reports can be translated in language
+func isSupportedLanguage(language string) bool {
+	for _, supported := range supportedLanguages {
+		if language == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTranslations load translation files of supported languages from dir
+func loadTranslations(dir string) (*bundle.Bundle, error) {
+	translations := bundle.New()
+	for _, language := range supportedLanguages {
+		if err := translations.LoadTranslationFile(filepath.Join(dir, language+".json")); err != nil {
+			return nil, errors.Wrap(err, "can't load translations of "+language)
+		}
+	}
+	return translations, nil
+}
+
+// newTranslateFunc return a translateFunc of language, ids missing in language are translated in english
+// ids are returned untranslated if translations are not loaded
+func newTranslateFunc(translations *bundle.Bundle, language string) translateFunc {
+	if translations == nil {
+		return func(id string, args ...interface{}) string { return id }
+	}
+	english := translations.MustTfunc(defaultLanguage)
+	if !isSupportedLanguage(language) || language == defaultLanguage {
+		return translateFunc(english)
+	}
+	translate := translations.MustTfunc(language)
+	return func(id string, args ...interface{}) string {
+		if translated := translate(id, args...); translated != id {
+			return translated
+		}
+		return english(id, args...)
+	}
+}
+
+// translate return the translateFunc of the report language
+func (p *Plugin) translate() translateFunc {
+	return newTranslateFunc(p.translations, p.getConfiguration().Language)
+}
+
+// channelTranslate return the translateFunc of the language of a report channel, the report language by default
+func (p *Plugin) channelTranslate(channelID string) translateFunc {
+	if language, ok := p.ChannelLanguages[channelID]; ok {
+		return newTranslateFunc(p.translations, language)
+	}
+	return p.translate()
+}
+
+// parseChannelLanguages parse languages in form TeamName/ChannelName=fr separated by semicolons
+// and return languages by TeamName/ChannelName
+func parseChannelLanguages(config string) (map[string]string, error) {
+	languages := make(map[string]string)
+	for _, entry := range strings.Split(config, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		v := strings.SplitN(entry, "=", 2)
+		if len(v) != 2 || strings.Count(v[0], "/") != 1 {
+			return nil, fmt.Errorf("Bad formatted channel language: %v, expected TeamName/ChannelName=fr", entry)
+		}
+		language := strings.TrimSpace(v[1])
+		if !isSupportedLanguage(language) {
+			return nil, fmt.Errorf("Unsupported language %v, expected one of %v", language, strings.Join(supportedLanguages, ", "))
+		}
+		languages[strings.TrimSpace(v[0])] = language
+	}
+	return languages, nil
+}
+
+// sectionTitle return the markdown title of a report section
+func sectionTitle(T translateFunc, id string) string {
+	return "### " + T(id) + "\n"
+}
+
+// weekdayName return the translated name of day
+func weekdayName(day time.Weekday, T translateFunc) string {
+	return T("report.weekday." + strconv.Itoa(int(day)))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testTranslate return the translateFunc of language from the translations of the plugin bundle
+func testTranslate(t *testing.T, language string) translateFunc {
+	translations, err := loadTranslations(filepath.Join("..", translationsDirectory))
+	require.Nil(t, err)
+	return newTranslateFunc(translations, language)
+}
+
+func TestTranslationsComplete(t *testing.T) {
+	assert := assert.New(t)
+
+	translations, err := loadTranslations(filepath.Join("..", translationsDirectory))
+	assert.Nil(err)
+	english := translations.LanguageTranslationIDs(defaultLanguage)
+	for _, language := range supportedLanguages {
+		assert.ElementsMatch(english, translations.LanguageTranslationIDs(language), language)
+	}
+}
+
+func TestNewTranslateFunc(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Top Users", testTranslate(t, "en")("report.top_users.title"))
+	assert.Equal("Top Users", testTranslate(t, "")("report.top_users.title"))
+	assert.Equal("Top Users", testTranslate(t, "ja")("report.top_users.title"))
+	assert.Equal("Aktivste Benutzer", testTranslate(t, "de")("report.top_users.title"))
+	assert.Equal("jusqu'à 5 mots", testTranslate(t, "fr")("report.lengths.up_to", map[string]interface{}{"Words": 5}))
+	assert.Equal("report.unknown", testTranslate(t, "es")("report.unknown"))
+	assert.Equal("report.top_users.title", newTranslateFunc(nil, "fr")("report.top_users.title"))
+
+	assert.Equal("### Mentions\n", sectionTitle(testTranslate(t, "en"), "report.mentions.title"))
+	assert.Equal("sábado", weekdayName(time.Saturday, testTranslate(t, "es")))
+	assert.Equal("## Statistiques de 03/2020\nTous les rapports du mois sont publiés dans ce fil.",
+		testTranslate(t, "fr")("report.anchor", map[string]interface{}{"Month": time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC).Format(testTranslate(t, "fr")("report.month_layout"))}))
+	assert.Equal("**3** of 10 active users *(30%)* posted in several teams.",
+		testTranslate(t, "en")("report.overlap.summary", map[string]interface{}{"CrossTeam": 3, "Users": 10, "Percent": 30}))
+}
+
+func TestParseChannelLanguages(t *testing.T) {
+	assert := assert.New(t)
+
+	languages, err := parseChannelLanguages("TeamA/rapports=fr; TeamB/berichte = de")
+	assert.Nil(err)
+	assert.Equal(map[string]string{"TeamA/rapports": "fr", "TeamB/berichte": "de"}, languages)
+
+	languages, err = parseChannelLanguages("")
+	assert.Nil(err)
+	assert.Empty(languages)
+
+	for _, config := range []string{"TeamA/rapports", "rapports=fr", "TeamA/rapports=ja", "TeamA/rapports="} {
+		_, err = parseChannelLanguages(config)
+		assert.NotNil(err, config)
+	}
+}
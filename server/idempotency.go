@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// idempotencyKeyPrefix prefix kv keys of idempotency keys of submissions, followed by a hash of the key
+	idempotencyKeyPrefix = "idempotency_"
+	// idempotencyHeader is the header of submissions carrying a key chosen by their producer
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyWindow is the duration a key is remembered, a retry after it is counted again
+	idempotencyWindow = 24 * time.Hour
+	// maxIdempotencyKeyLength is the maximum length of an idempotency key
+	maxIdempotencyKeyLength = 255
+)
+
+// errIdempotencyKeyReused is returned when an idempotency key is reused for a different submission
+var errIdempotencyKeyReused = errors.New("idempotency key already used by a different submission")
+
+// idempotencyKVKey return the kv key of an idempotency key of a producer, hashed to fit kv keys length
+func idempotencyKVKey(producer string, key string) string {
+	sum := sha256.Sum256([]byte(producer + ":" + key))
+	return idempotencyKeyPrefix + hex.EncodeToString(sum[:16])
+}
+
+// claimIdempotencyKey remember key of producer for the digest of a submission during idempotencyWindow
+// return false if the key was already used by the same submission, and an error if it was used by another one
+func (p *Plugin) claimIdempotencyKey(producer string, key string, body []byte) (bool, error) {
+	kvKey := idempotencyKVKey(producer, key)
+	sum := sha256.Sum256(body)
+	digest := []byte(hex.EncodeToString(sum[:]))
+	claimed, appErr := p.API.KVCompareAndSet(kvKey, nil, digest)
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "can't claim idempotency key")
+	}
+	if claimed {
+		// compare and set can't expire, the key is set again with its expiry
+		if appErr := p.API.KVSetWithExpiry(kvKey, digest, int64(idempotencyWindow/time.Second)); appErr != nil {
+			p.API.LogWarn("can't set expiry of idempotency key", "err", appErr.Error())
+		}
+		return true, nil
+	}
+	previous, appErr := p.API.KVGet(kvKey)
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "can't get idempotency key")
+	}
+	if previous != nil && !bytes.Equal(previous, digest) {
+		return false, errIdempotencyKeyReused
+	}
+	return false, nil
+}
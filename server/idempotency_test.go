@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKVKey(t *testing.T) {
+	assert := assert.New(t)
+
+	key := idempotencyKVKey("com.example.jira", strings.Repeat("k", maxIdempotencyKeyLength))
+	assert.True(strings.HasPrefix(key, idempotencyKeyPrefix))
+	assert.True(len(key) <= 50)
+	assert.Equal(key, idempotencyKVKey("com.example.jira", strings.Repeat("k", maxIdempotencyKeyLength)))
+	assert.NotEqual(idempotencyKVKey("com.example.jira", "batch-1"), idempotencyKVKey("com.example.ci", "batch-1"))
+	assert.NotEqual(idempotencyKVKey("com.example.jira", "batch-1"), idempotencyKVKey("com.example.jira", "batch-2"))
+}
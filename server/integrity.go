@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// exportSchemaVersion is the version of the columns of csv exports, to increase when exportHeader changes
+const exportSchemaVersion = 1
+
+// ExportManifest describe the files of an export so downstream pipelines can validate its completeness and integrity
+type ExportManifest struct {
+	SchemaVersion int                  `json:"schema_version"`
+	PluginVersion string               `json:"plugin_version"`
+	GeneratedAt   time.Time            `json:"generated_at"`
+	From          string               `json:"from"`
+	To            string               `json:"to"`
+	Granularity   string               `json:"granularity"`
+	Files         []ExportManifestFile `json:"files"`
+}
+
+// ExportManifestFile is a file of an export with its number of rows, header excluded, and its checksum
+type ExportManifestFile struct {
+	Name   string `json:"name"`
+	Rows   int    `json:"rows"`
+	Bytes  int    `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// newExportManifest return the manifest of an export between from and to included without file
+func newExportManifest(from time.Time, to time.Time, granularity string, now time.Time) *ExportManifest {
+	return &ExportManifest{
+		SchemaVersion: exportSchemaVersion,
+		PluginVersion: manifest.Version,
+		GeneratedAt:   now,
+		From:          from.Format("2006-01-02"),
+		To:            to.Format("2006-01-02"),
+		Granularity:   granularity,
+		Files:         make([]ExportManifestFile, 0, 1),
+	}
+}
+
+// addFile add the checksum of the content of a file with rows to the manifest
+func (m *ExportManifest) addFile(name string, rows int, content []byte) {
+	sum := sha256.Sum256(content)
+	m.Files = append(m.Files, ExportManifestFile{Name: name, Rows: rows, Bytes: len(content), SHA256: hex.EncodeToString(sum[:])})
+}
+
+// manifestFilename return the name of the manifest uploaded next to the export filename
+func manifestFilename(filename string) string {
+	return strings.TrimSuffix(filename, ".csv") + ".manifest.json"
+}
+
+// digestHeader return the value of the Digest header of content, the base64 sha-256 of the content
+func digestHeader(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "SHA-256=" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// marshalManifest return the indented json of the manifest
+func marshalManifest(m *ExportManifest) ([]byte, error) {
+	return json.MarshalIndent(m, "", "  ")
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	from := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, time.March, 30, 0, 0, 0, 0, time.UTC)
+	now := time.Date(2020, time.March, 31, 8, 0, 0, 0, time.UTC)
+	m := newExportManifest(from, to, granularityDay, now)
+	m.addFile("analytics-2020-03-01-2020-03-30.csv", 2, []byte("abc"))
+
+	assert.Equal(exportSchemaVersion, m.SchemaVersion)
+	assert.Equal("2020-03-01", m.From)
+	assert.Equal("2020-03-30", m.To)
+	assert.Equal([]ExportManifestFile{{
+		Name:   "analytics-2020-03-01-2020-03-30.csv",
+		Rows:   2,
+		Bytes:  3,
+		SHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+	}}, m.Files)
+
+	content, err := marshalManifest(m)
+	assert.Nil(err)
+	decoded := make(map[string]interface{})
+	assert.Nil(json.Unmarshal(content, &decoded))
+	assert.Equal(float64(exportSchemaVersion), decoded["schema_version"])
+	assert.Equal("day", decoded["granularity"])
+	assert.Equal("2020-03-31T08:00:00Z", decoded["generated_at"])
+}
+
+func TestManifestFilename(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("analytics-2020-03-01-2020-03-30.manifest.json", manifestFilename("analytics-2020-03-01-2020-03-30.csv"))
+	assert.Equal("export.manifest.json", manifestFilename("export"))
+}
+
+func TestDigestHeader(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("SHA-256=ungWv48Bz+pBQUDeXa4iI7ADYaOWF3qctBD/YfIAFa0=", digestHeader([]byte("abc")))
+}
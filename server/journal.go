@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	journalFileName   = "journal.log"
+	maxJournalSize    = 10 * 1000 * 1000
+	maxJournalFiles   = 10
+	journalPost       = "post"
+	journalFile       = "file"
+	journalDirect     = "direct"
+	journalExternal   = "external"
+	journalEdit       = "edit"
+	journalDelete     = "delete"
+	journalCheckpoint = "checkpoint"
+	// journalDaily is an increment of a daily bucket held by the write buffer, increments journaled before a
+	// journalFlushing event belong to its flush and are written once the journalFlushed event of the same flush follows
+	journalDaily    = "daily"
+	journalFlushing = "flushing"
+	journalFlushed  = "flushed"
+	// allFlushes is the flush of a journalFlushed event ending every flush, written once pending increments were
+	// buffered again after a replay
+	allFlushes = 0
+)
+
+// JournalEvent is a raw event appended to the journal before being aggregated in the current session
+type JournalEvent struct {
+	Kind      string
+	Date      time.Time
+	ChannelID string `json:",omitempty"`
+	UserID    string `json:",omitempty"`
+	Reply     bool   `json:",omitempty"`
+	RootID    string `json:",omitempty"`
+	Words     int64  `json:",omitempty"`
+	Script    string `json:",omitempty"`
+	FilesSize int64  `json:",omitempty"`
+	// Mentions are usernames mentioned by a post, Broadcasts its channel wide mentions (all, channel or here)
+	Mentions   []string `json:",omitempty"`
+	Broadcasts []string `json:",omitempty"`
+	// Length is the length class of a post (e.g. emoji, short, code)
+	Length string `json:",omitempty"`
+	// Collector is the key of the external collector of Value
+	Collector string `json:",omitempty"`
+	Value     int64  `json:",omitempty"`
+	// Retract is set on the deletion of a post counted in the current session, PostedAt is its creation date
+	Retract  bool      `json:",omitempty"`
+	PostedAt time.Time `json:",omitempty"`
+	// Bucket is the increment of a daily bucket, Flush the flush of a journalFlushing or journalFlushed event
+	Bucket *dailyBucket `json:",omitempty"`
+	Flush  int64        `json:",omitempty"`
+}
+
+// apply aggregate the event in the analytic, caller must hold the write lock
+func (a *Analytic) apply(event JournalEvent) {
+	switch event.Kind {
+	case journalPost:
+		// events of anonymous mode have no user
+		anonymous := event.UserID == ""
+		if !anonymous {
+			a.Users[event.UserID]++
+		}
+		a.Channels[event.ChannelID]++
+		a.Hourly[event.Date.Format(hourlyKeyFormat)]++
+		if event.Reply {
+			a.ChannelsReply[event.ChannelID]++
+			if !anonymous {
+				a.UsersReply[event.UserID]++
+				a.ChannelsUsersReply[channelUserKey(event.ChannelID, event.UserID)]++
+			}
+		}
+		if event.RootID != "" {
+			a.Threads[event.RootID]++
+		}
+		a.ChannelsWords[event.ChannelID] += event.Words
+		if event.Script != "" {
+			a.Scripts[event.Script]++
+		}
+		if event.Length != "" {
+			a.Lengths[event.Length]++
+		}
+		a.ChannelsFilesSize[event.ChannelID] += event.FilesSize
+		if !anonymous {
+			a.ChannelsUsers[channelUserKey(event.ChannelID, event.UserID)]++
+		}
+		for _, username := range event.Mentions {
+			a.Mentions[username]++
+		}
+		for _, mention := range event.Broadcasts {
+			a.ChannelsBroadcasts[event.ChannelID+":"+mention]++
+		}
+	case journalEdit:
+		a.ChannelsEdits[event.ChannelID]++
+	case journalDelete:
+		a.ChannelsDeletions[event.ChannelID]++
+		if event.Retract {
+			a.retract(event)
+		}
+	case journalFile:
+		a.FilesNb++
+		a.FilesSize += event.FilesSize
+	case journalDirect:
+		a.DirectMessages++
+	case journalExternal:
+		a.External[event.Collector] += event.Value
+	}
+}
+
+// retract remove from the analytic the counters of a deleted post, counters reaching zero are removed so deleted
+// posts don't leave users or channels without messages, caller must hold the write lock
+func (a *Analytic) retract(event JournalEvent) {
+	anonymous := event.UserID == ""
+	if !anonymous {
+		decrementCounter(a.Users, event.UserID, 1)
+		decrementCounter(a.ChannelsUsers, channelUserKey(event.ChannelID, event.UserID), 1)
+	}
+	decrementCounter(a.Channels, event.ChannelID, 1)
+	decrementCounter(a.Hourly, event.PostedAt.Format(hourlyKeyFormat), 1)
+	if event.Reply {
+		decrementCounter(a.ChannelsReply, event.ChannelID, 1)
+		if !anonymous {
+			decrementCounter(a.UsersReply, event.UserID, 1)
+			decrementCounter(a.ChannelsUsersReply, channelUserKey(event.ChannelID, event.UserID), 1)
+		}
+	}
+	if _, ok := a.Threads[event.RootID]; ok {
+		decrementCounter(a.Threads, event.RootID, 1)
+	} else if event.RootID != "" {
+		// the thread was spilled, the reply is retracted when it's restored
+		if a.RetractedThreads == nil {
+			a.RetractedThreads = make(map[string]int64)
+		}
+		a.RetractedThreads[event.RootID]++
+	}
+	decrementCounter(a.ChannelsWords, event.ChannelID, event.Words)
+	if event.Script != "" {
+		decrementCounter(a.Scripts, event.Script, 1)
+	}
+	if event.Length != "" {
+		decrementCounter(a.Lengths, event.Length, 1)
+	}
+	decrementCounter(a.ChannelsFilesSize, event.ChannelID, event.FilesSize)
+	for _, username := range event.Mentions {
+		decrementCounter(a.Mentions, username, 1)
+	}
+	for _, mention := range event.Broadcasts {
+		decrementCounter(a.ChannelsBroadcasts, event.ChannelID+":"+mention, 1)
+	}
+}
+
+// decrementCounter subtract nb from the counter of key, removing it when it reaches zero
+func decrementCounter(counters map[string]int64, key string, nb int64) {
+	if nb == 0 {
+		return
+	}
+	if counters[key] <= nb {
+		delete(counters, key)
+		return
+	}
+	counters[key] -= nb
+}
+
+// Journal is an optional append-only log of raw events on the local disk
+// events after the last checkpoint are not saved in kv yet and are replayed after a crash
+// increments of daily buckets are kept across checkpoints until the write buffer flushed them
+type Journal struct {
+	lock    sync.Mutex
+	dir     string
+	file    *os.File
+	size    int64
+	flushes int64
+	// clock date events marking flushes and checkpoints, the wall clock when nil
+	clock Clock
+}
+
+// now return the current time of the journal clock, caller must hold the lock
+func (j *Journal) now() time.Time {
+	if j.clock == nil {
+		return realClock{}.Now()
+	}
+	return j.clock.Now()
+}
+
+// Open start appending events in dir, an empty dir disable the journal
+func (j *Journal) Open(dir string) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if dir == j.dir {
+		return nil
+	}
+	if err := j.close(); err != nil {
+		return err
+	}
+	j.dir = dir
+	if dir == "" {
+		return nil
+	}
+	return j.open()
+}
+
+func (j *Journal) open() error {
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return errors.Wrap(err, "can't create journal directory")
+	}
+	file, err := os.OpenFile(filepath.Join(j.dir, journalFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "can't open journal")
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrap(err, "can't stat journal")
+	}
+	j.file = file
+	j.size = info.Size()
+	return nil
+}
+
+// Close stop appending events
+func (j *Journal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.dir = ""
+	return j.close()
+}
+
+func (j *Journal) close() error {
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return errors.Wrap(err, "can't close journal")
+}
+
+// Append write the event and flush it to the disk
+func (j *Journal) Append(event JournalEvent) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.append(event)
+}
+
+func (j *Journal) append(event JournalEvent) error {
+	if j.file == nil {
+		return nil
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal journal event")
+	}
+	n, err := j.file.Write(append(line, '\n'))
+	j.size += int64(n)
+	if err != nil {
+		return errors.Wrap(err, "can't write journal event")
+	}
+	return errors.Wrap(j.file.Sync(), "can't sync journal")
+}
+
+// BeginFlush mark increments of daily buckets appended so far, and not part of another flush, as being written by
+// a new flush of the write buffer and return this flush
+func (j *Journal) BeginFlush() (int64, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.flushes++
+	return j.flushes, j.append(JournalEvent{Kind: journalFlushing, Date: j.now(), Flush: j.flushes})
+}
+
+// EndFlush mark increments of daily buckets of flush as written, allFlushes mark every increment appended so far
+func (j *Journal) EndFlush(flush int64) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.append(JournalEvent{Kind: journalFlushed, Date: j.now(), Flush: flush})
+}
+
+// Checkpoint mark all previous events as saved in kv, rotating the journal when it's too big
+func (j *Journal) Checkpoint() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if err := j.append(JournalEvent{Kind: journalCheckpoint, Date: j.now()}); err != nil {
+		return err
+	}
+	if j.file == nil || j.size < maxJournalSize {
+		return nil
+	}
+	return j.rotate()
+}
+
+// rotate rename the current journal with a timestamp and delete the oldest ones, increments of daily buckets not
+// written yet are carried to the new journal
+func (j *Journal) rotate() error {
+	_, daily, err := pendingEvents(j.dir)
+	if err != nil {
+		return err
+	}
+	if err := j.close(); err != nil {
+		return err
+	}
+	current := filepath.Join(j.dir, journalFileName)
+	if err := os.Rename(current, fmt.Sprintf("%s.%s", current, j.now().Format("20060102T150405"))); err != nil {
+		return errors.Wrap(err, "can't rotate journal")
+	}
+	rotated, err := filepath.Glob(current + ".*")
+	if err != nil {
+		return errors.Wrap(err, "can't list rotated journals")
+	}
+	sort.Strings(rotated)
+	for len(rotated) > maxJournalFiles {
+		if err := os.Remove(rotated[0]); err != nil {
+			return errors.Wrap(err, "can't delete old journal")
+		}
+		rotated = rotated[1:]
+	}
+	if err := j.open(); err != nil {
+		return err
+	}
+	for _, event := range daily {
+		if err := j.append(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushedDaily remove from daily the increments of flush and its journalFlushing event
+func flushedDaily(daily []JournalEvent, flush int64) []JournalEvent {
+	if flush == allFlushes {
+		return daily[:0]
+	}
+	start := 0
+	for index, event := range daily {
+		if event.Kind != journalFlushing {
+			continue
+		}
+		if event.Flush == flush {
+			return append(daily[:start], daily[index+1:]...)
+		}
+		start = index + 1
+	}
+	return daily
+}
+
+// pendingEvents return events of the journal in dir written after the last checkpoint, and increments of daily
+// buckets not written yet with the journalFlushing events of their flushes
+func pendingEvents(dir string) ([]JournalEvent, []JournalEvent, error) {
+	events := make([]JournalEvent, 0)
+	daily := make([]JournalEvent, 0)
+	file, err := os.Open(filepath.Join(dir, journalFileName))
+	if os.IsNotExist(err) {
+		return events, daily, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "can't open journal")
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event JournalEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			// last line can be truncated by a crash
+			continue
+		}
+		switch event.Kind {
+		case journalCheckpoint:
+			events = events[:0]
+		case journalDaily, journalFlushing:
+			daily = append(daily, event)
+		case journalFlushed:
+			daily = flushedDaily(daily, event.Flush)
+		default:
+			events = append(events, event)
+		}
+	}
+	return events, daily, errors.Wrap(scanner.Err(), "can't read journal")
+}
+
+// replayJournal aggregate in the current session events which were not saved before a crash, return increments of
+// daily buckets which were not written, see replayDaily
+func (p *Plugin) replayJournal(dir string) ([]JournalEvent, error) {
+	events, daily, err := pendingEvents(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return daily, nil
+	}
+	p.currentAnalytic.WLock()
+	for _, event := range events {
+		p.currentAnalytic.apply(event)
+		if p.clusterDelta != nil {
+			p.clusterDelta.apply(event)
+		}
+	}
+	p.currentAnalytic.WUnlock()
+	p.API.LogInfo("replayed analytics journal", "events", fmt.Sprintf("%d", len(events)))
+	return daily, nil
+}
+
+// replayDaily buffer again increments of daily buckets which were not written before a crash, once the journal is
+// open they are journaled again after the end of their former flushes
+func (p *Plugin) replayDaily(daily []JournalEvent) {
+	if len(daily) == 0 {
+		return
+	}
+	if err := p.journal.EndFlush(allFlushes); err != nil {
+		p.API.LogError("can't append event to journal", "err", err.Error())
+	}
+	replayed := 0
+	for _, event := range daily {
+		if event.Kind == journalDaily && event.Bucket != nil {
+			p.bufferDaily(event.Bucket.Date, event.Bucket.Scope, event.Bucket.ID, event.Bucket.Counters)
+			replayed++
+		}
+	}
+	if err := p.flushWriteBuffer(); err != nil {
+		p.API.LogError("can't flush daily analytics", "err", err.Error())
+	}
+	p.API.LogInfo("replayed daily increments of the journal", "increments", fmt.Sprintf("%d", replayed))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournalPendingEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	events, daily, err := pendingEvents(dir)
+	assert.Nil(err)
+	assert.Empty(events)
+	assert.Empty(daily)
+
+	var j Journal
+	assert.Nil(j.Open(dir))
+	date := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+	assert.Nil(j.Append(JournalEvent{Kind: journalPost, Date: date, ChannelID: "channel1", UserID: "user1"}))
+	assert.Nil(j.Checkpoint())
+	assert.Nil(j.Append(JournalEvent{Kind: journalPost, Date: date, ChannelID: "channel2", UserID: "user1", Reply: true}))
+	assert.Nil(j.Append(JournalEvent{Kind: journalFile, Date: date, FilesSize: 42}))
+	assert.Nil(j.Close())
+
+	events, daily, err = pendingEvents(dir)
+	assert.Nil(err)
+	assert.Len(events, 2)
+	assert.Empty(daily)
+
+	analytic := NewAnalytic(time.Now())
+	for _, event := range events {
+		analytic.apply(event)
+	}
+	assert.Equal(int64(0), analytic.Channels["channel1"])
+	assert.Equal(int64(1), analytic.ChannelsReply["channel2"])
+	assert.Equal(int64(1), analytic.Users["user1"])
+	assert.Equal(int64(1), analytic.Hourly["2019-04-21T12"])
+	assert.Equal(int64(1), analytic.FilesNb)
+	assert.Equal(int64(42), analytic.FilesSize)
+}
+
+func TestJournalClock(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	date := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+	j := Journal{clock: fixedClock(date)}
+	assert.Nil(j.Open(dir))
+	flush, err := j.BeginFlush()
+	assert.Nil(err)
+	assert.Nil(j.EndFlush(flush))
+	assert.Nil(j.Checkpoint())
+	assert.Nil(j.Close())
+
+	file, err := os.Open(filepath.Join(dir, journalFileName))
+	assert.Nil(err)
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	for decoder.More() {
+		var event JournalEvent
+		assert.Nil(decoder.Decode(&event))
+		assert.True(date.Equal(event.Date), event.Kind)
+	}
+}
+
+func TestJournalPendingDaily(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "journal")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	store := &memoryDailyStore{increments: make(map[string]DailyCounters), err: errors.New("database is down")}
+	p := &Plugin{dailyStore: store}
+	p.setConfiguration(&configuration{WriteBufferSeconds: 5})
+	assert.Nil(p.journal.Open(dir))
+	day := time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local)
+
+	// increments are kept across checkpoints until they are written
+	assert.Nil(p.incrementDaily(day, dailyScopeChannel, "channel1", DailyCounters{Messages: 1}))
+	assert.Nil(p.journal.Checkpoint())
+	assert.Nil(p.incrementDaily(day, dailyScopeUser, "user1", DailyCounters{Messages: 1}))
+	events, daily, err := pendingEvents(dir)
+	assert.Nil(err)
+	assert.Empty(events)
+	assert.Len(daily, 2)
+
+	// failed increments are journaled again before the end of their flush
+	assert.NotNil(p.flushWriteBuffer())
+	_, daily, err = pendingEvents(dir)
+	assert.Nil(err)
+	assert.Len(daily, 2)
+	for _, event := range daily {
+		assert.Equal(journalDaily, event.Kind)
+	}
+
+	store.err = nil
+	assert.Nil(p.flushWriteBuffer())
+	_, daily, err = pendingEvents(dir)
+	assert.Nil(err)
+	assert.Empty(daily)
+
+	// increments buffered during a flush are left for the next one
+	assert.Nil(p.incrementDaily(day, dailyScopeChannel, "channel1", DailyCounters{Messages: 1}))
+	flush, err := p.journal.BeginFlush()
+	assert.Nil(err)
+	assert.Nil(p.incrementDaily(day, dailyScopeChannel, "channel2", DailyCounters{Messages: 1}))
+	assert.Nil(p.journal.EndFlush(flush))
+	assert.Nil(p.journal.Close())
+	_, daily, err = pendingEvents(dir)
+	assert.Nil(err)
+	if assert.Len(daily, 1) {
+		assert.Equal("channel2", daily[0].Bucket.ID)
+		assert.Equal(int64(1), daily[0].Bucket.Counters.Messages)
+	}
+}
+
+func TestFlushedDaily(t *testing.T) {
+	assert := assert.New(t)
+
+	daily := []JournalEvent{
+		{Kind: journalDaily, ChannelID: "channel1"},
+		{Kind: journalFlushing, Flush: 1},
+		{Kind: journalDaily, ChannelID: "channel2"},
+		{Kind: journalFlushing, Flush: 2},
+		{Kind: journalDaily, ChannelID: "channel3"},
+	}
+	remaining := flushedDaily(append([]JournalEvent{}, daily...), 2)
+	assert.Equal([]JournalEvent{daily[0], daily[1], daily[4]}, remaining)
+	assert.Equal([]JournalEvent{daily[4]}, flushedDaily(remaining, 1))
+	assert.Equal(daily, flushedDaily(append([]JournalEvent{}, daily...), 3))
+	assert.Empty(flushedDaily(append([]JournalEvent{}, daily...), allFlushes))
+}
+
+func TestApplyAnonymousEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	date := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+	analytic.apply(JournalEvent{Kind: journalPost, Date: date, ChannelID: "channel1", Reply: true, RootID: "root1", Words: 3})
+	analytic.apply(JournalEvent{Kind: journalPost, Date: date, ChannelID: "channel1"})
+
+	assert.Equal(map[string]int64{"channel1": 2}, analytic.Channels)
+	assert.Equal(map[string]int64{"channel1": 1}, analytic.ChannelsReply)
+	assert.Equal(map[string]int64{"channel1": 3}, analytic.ChannelsWords)
+	assert.Empty(analytic.Users)
+	assert.Empty(analytic.UsersReply)
+	assert.Empty(analytic.ChannelsUsers)
+	assert.Empty(analytic.ChannelsUsersReply)
+}
+
+func TestApplyDirectEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	date := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+	analytic.apply(JournalEvent{Kind: journalDirect, Date: date})
+	analytic.apply(JournalEvent{Kind: journalDirect, Date: date})
+
+	assert.Equal(int64(2), analytic.DirectMessages)
+	assert.Empty(analytic.Channels)
+	assert.Empty(analytic.Users)
+	assert.Empty(analytic.Hourly)
+	assert.Equal(int64(2), mergeAnalytics([]*Analytic{analytic, NewAnalytic(time.Now())}).DirectMessages)
+	assert.Equal(int64(0), filterAnalytic(analytic, func(channelID string) bool { return true }).DirectMessages)
+}
+
+func TestApplyEditAndDeleteEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	posted := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+	post := JournalEvent{Kind: journalPost, Date: posted, ChannelID: "channel1", UserID: "user1", Reply: true, RootID: "root1", Words: 3, Length: "short", Mentions: []string{"bob"}}
+	analytic.apply(post)
+	analytic.apply(JournalEvent{Kind: journalPost, Date: posted, ChannelID: "channel1", UserID: "user2", Words: 2})
+	analytic.apply(JournalEvent{Kind: journalEdit, Date: posted.Add(time.Hour), ChannelID: "channel1", UserID: "user1"})
+
+	deletion := post
+	deletion.Kind = journalDelete
+	deletion.Date = posted.Add(2 * time.Hour)
+	deletion.Retract = true
+	deletion.PostedAt = posted
+	analytic.apply(deletion)
+	analytic.apply(JournalEvent{Kind: journalDelete, Date: posted, ChannelID: "channel2", UserID: "user3"})
+
+	assert.Equal(map[string]int64{"channel1": 1}, analytic.ChannelsEdits)
+	assert.Equal(map[string]int64{"channel1": 1, "channel2": 1}, analytic.ChannelsDeletions)
+	assert.Equal(map[string]int64{"channel1": 1}, analytic.Channels)
+	assert.Equal(map[string]int64{"user2": 1}, analytic.Users)
+	assert.Equal(map[string]int64{"channel1": 2}, analytic.ChannelsWords)
+	assert.Equal(map[string]int64{"2019-04-21T12": 1}, analytic.Hourly)
+	assert.Empty(analytic.ChannelsReply)
+	assert.Empty(analytic.UsersReply)
+	assert.Empty(analytic.Threads)
+	assert.Empty(analytic.Mentions)
+	assert.Empty(analytic.Lengths)
+	assert.Equal(int64(1), mergeAnalytics([]*Analytic{analytic, NewAnalytic(time.Now())}).ChannelsEdits["channel1"])
+	assert.Empty(filterAnalytic(analytic, func(channelID string) bool { return channelID == "channel1" }).ChannelsDeletions["channel2"])
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultLeaderboardSize is the size of leaderboards of the command when LeaderboardSize is not set
+	defaultLeaderboardSize = 5
+
+	boardPosters   = "posters"
+	boardReactors  = "reactors"
+	boardMentioned = "mentioned"
+	boardRepliedTo = "replied"
+)
+
+// boards are leaderboards in display order
+var boards = []string{boardPosters, boardReactors, boardMentioned, boardRepliedTo}
+
+var boardsTitle = map[string]string{
+	boardPosters:   "Top Posters",
+	boardReactors:  "Top Reactors",
+	boardMentioned: "Most Mentioned",
+	boardRepliedTo: "Most Replied To",
+}
+
+var boardsUnit = map[string]string{
+	boardPosters:   "messages",
+	boardReactors:  "reactions",
+	boardMentioned: "mentions",
+	boardRepliedTo: "replies",
+}
+
+// rankedCounter is a counter with its rank, tied counters share the same rank
+type rankedCounter struct {
+	rank int
+	counter
+}
+
+// rankCounters rank counts by descending count with standard competition ranking (1, 2, 2, 4), tied keys
+// are sorted alphabetically, keeps the first size ranks and all keys tied with the last one
+func rankCounters(counts map[string]int64, size int) []rankedCounter {
+	ranked := make([]rankedCounter, 0, size)
+	for index, c := range topCounters(counts, len(counts)) {
+		rank := index + 1
+		if index > 0 && ranked[index-1].nb == c.nb {
+			rank = ranked[index-1].rank
+		}
+		if rank > size {
+			break
+		}
+		ranked = append(ranked, rankedCounter{rank: rank, counter: c})
+	}
+	return ranked
+}
+
+// InteractionStats store replies received by users during an analytic
+type InteractionStats struct {
+	// RepliedTo store number of replies by author id of the root post, self replies excluded
+	RepliedTo map[string]int64
+}
+
+// collectInteractions count replies received by users in posts created during the analytic in its channels
+func (p *Plugin) collectInteractions(analytic *Analytic) (*InteractionStats, error) {
+	analytic.RLock()
+	from := analytic.Start
+	to := analytic.End
+	channelsID := make([]string, 0, len(analytic.Channels))
+	for channelID := range analytic.Channels {
+		channelsID = append(channelsID, channelID)
+	}
+	analytic.RUnlock()
+	if to.IsZero() {
+		to = p.now()
+	}
+
+	stats := &InteractionStats{
+		RepliedTo: make(map[string]int64),
+	}
+	rootsAuthor := make(map[string]string)
+	for _, channelID := range channelsID {
+		postList, appErr := p.API.GetPostsSince(channelID, from.UnixNano()/int64(time.Millisecond))
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't get posts of channel "+channelID)
+		}
+		for _, post := range postList.Posts {
+			createdAt := time.Unix(0, post.CreateAt*int64(time.Millisecond))
+			if post.Type != "" || createdAt.Before(from) || !createdAt.Before(to) {
+				continue
+			}
+			if post.RootId == "" {
+				continue
+			}
+			if _, ok := rootsAuthor[post.RootId]; !ok {
+				if root, ok := postList.Posts[post.RootId]; ok {
+					rootsAuthor[post.RootId] = root.UserId
+				} else if root, appErr := p.API.GetPost(post.RootId); appErr == nil {
+					rootsAuthor[post.RootId] = root.UserId
+				} else {
+					p.API.LogWarn("can't get root post, skip it", "post", post.RootId, "err", appErr.Error())
+					rootsAuthor[post.RootId] = ""
+				}
+			}
+			if author := rootsAuthor[post.RootId]; author != "" && author != post.UserId {
+				stats.RepliedTo[author]++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// boardsCounts return counts by user of each leaderboard, mentioned users are keyed by username, others by user id
+// caller must hold the read lock of the analytic
+func boardsCounts(analytic *Analytic, reactions *ReactionStats, interactions *InteractionStats) map[string]map[string]int64 {
+	counts := map[string]map[string]int64{boardPosters: analytic.Users, boardMentioned: analytic.Mentions}
+	if reactions != nil {
+		counts[boardReactors] = reactions.Users
+	}
+	if interactions != nil {
+		counts[boardRepliedTo] = interactions.RepliedTo
+	}
+	return counts
+}
+
+// getLeaderboardDescription render the first size ranks of the selected leaderboards
+func (p *Plugin) getLeaderboardDescription(counts map[string]map[string]int64, selected []string, size int, T translateFunc) string {
+	m := ""
+	for _, board := range selected {
+		ranked := rankCounters(counts[board], size)
+		if len(ranked) == 0 {
+			continue
+		}
+		m += fmt.Sprintf("#### %s\n", boardsTitle[board])
+		for _, entry := range ranked {
+			username := entry.key
+			if board != boardMentioned {
+				var err error
+				if username, err = p.getUsername(entry.key); err != nil {
+					continue
+				}
+			} else if _, appErr := p.API.GetUserByUsername(username); appErr != nil {
+				continue
+			}
+			m += fmt.Sprintf("%d. @%s: **%d** %s\n", entry.rank, username, entry.nb, boardsUnit[board])
+		}
+	}
+	if m == "" {
+		return ""
+	}
+	return sectionTitle(T, "report.leaderboard.title") + m
+}
+
+// executeLeaderboardCommand handle `/analytics leaderboard [posters|reactors|mentioned|replied]`
+// posting leaderboards of the current session in the current channel
+func (p *Plugin) executeLeaderboardCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if p.isPostingPaused() {
+		return ephemeralResponse(fmt.Sprintf("Analytics posting is paused until %s.", p.pausedUntil().Format("January 2, 2006")))
+	}
+	if p.getConfiguration().AnonymousMode {
+		return ephemeralResponse("Leaderboards are not available in anonymous mode.")
+	}
+	selected := boards
+	if len(parameters) > 0 {
+		if _, ok := boardsTitle[parameters[0]]; !ok {
+			return ephemeralResponse("Usage: /analytics leaderboard [posters|reactors|mentioned|replied]")
+		}
+		selected = []string{parameters[0]}
+	}
+	size := p.getConfiguration().LeaderboardSize
+	if size <= 0 {
+		size = defaultLeaderboardSize
+	}
+
+	reactions, err := p.collectReactions(p.currentAnalytic)
+	if err != nil {
+		p.API.LogError("can't collect reactions", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	interactions, err := p.collectInteractions(p.currentAnalytic)
+	if err != nil {
+		p.API.LogError("can't collect interactions", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	p.currentAnalytic.RLock()
+	since := p.currentAnalytic.Start
+	T := p.translate()
+	text := p.getLeaderboardDescription(boardsCounts(p.currentAnalytic, reactions, interactions), selected, size, T)
+	p.currentAnalytic.RUnlock()
+	if text == "" {
+		return ephemeralResponse("No activity in this session yet.")
+	}
+
+	attachments := []*model.SlackAttachment{{
+		Title: fmt.Sprintf("Leaderboard since %s", since.Format("January 2, 2006")),
+		Color: "#FF8000",
+		Text:  strings.TrimPrefix(text, sectionTitle(T, "report.leaderboard.title")),
+	}}
+	if _, err := p.postAnalytics(args.ChannelId, "", attachments, nil); err != nil {
+		p.API.LogError("can't send leaderboard", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return &model.CommandResponse{}
+}
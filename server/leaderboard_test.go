@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRankCounters(t *testing.T) {
+	assert := assert.New(t)
+
+	counts := map[string]int64{"bob": 5, "alice": 8, "eve": 5, "carol": 2, "dave": 5}
+	assert.Equal([]rankedCounter{
+		{rank: 1, counter: counter{key: "alice", nb: 8}},
+		{rank: 2, counter: counter{key: "bob", nb: 5}},
+		{rank: 2, counter: counter{key: "dave", nb: 5}},
+		{rank: 2, counter: counter{key: "eve", nb: 5}},
+	}, rankCounters(counts, 2), "keys tied with the last rank are kept")
+	assert.Equal([]rankedCounter{
+		{rank: 1, counter: counter{key: "alice", nb: 8}},
+		{rank: 2, counter: counter{key: "bob", nb: 5}},
+		{rank: 2, counter: counter{key: "dave", nb: 5}},
+		{rank: 2, counter: counter{key: "eve", nb: 5}},
+		{rank: 5, counter: counter{key: "carol", nb: 2}},
+	}, rankCounters(counts, 5), "ranks after a tie are skipped")
+	assert.Len(rankCounters(counts, 1), 1)
+	assert.Empty(rankCounters(counts, 0))
+	assert.Empty(rankCounters(map[string]int64{}, 3))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	lengthEmoji  = "emoji"
+	lengthShort  = "short"
+	lengthMedium = "medium"
+	lengthLong   = "long"
+	lengthCode   = "code"
+)
+
+// lengthClasses are classes of messages in display order
+var lengthClasses = []string{lengthEmoji, lengthShort, lengthMedium, lengthLong, lengthCode}
+
+// lengthThresholds are the maximum number of words of short and medium messages, longer messages are long
+type lengthThresholds struct {
+	Short  int
+	Medium int
+}
+
+// defaultLengthThresholds are used when MessageLengthThresholds is not set
+var defaultLengthThresholds = lengthThresholds{Short: 5, Medium: 30}
+
+// parseLengthThresholds parse thresholds in form short:5,medium:30, a missing threshold keeps its default value
+// default thresholds are returned with an error
+func parseLengthThresholds(config string) (lengthThresholds, error) {
+	thresholds := defaultLengthThresholds
+	if strings.TrimSpace(config) == "" {
+		return thresholds, nil
+	}
+	for _, entry := range strings.Split(config, ",") {
+		v := strings.SplitN(entry, ":", 2)
+		if len(v) != 2 {
+			return defaultLengthThresholds, fmt.Errorf("Bad formatted message length threshold: %v, expected short:words or medium:words", entry)
+		}
+		words, err := strconv.Atoi(strings.TrimSpace(v[1]))
+		if err != nil || words < 1 {
+			return defaultLengthThresholds, fmt.Errorf("Bad message length threshold %v, expected a number of words", v[1])
+		}
+		switch strings.ToLower(strings.TrimSpace(v[0])) {
+		case lengthShort:
+			thresholds.Short = words
+		case lengthMedium:
+			thresholds.Medium = words
+		default:
+			return defaultLengthThresholds, fmt.Errorf("Unknown message length threshold %v, expected short or medium", v[0])
+		}
+	}
+	if thresholds.Medium <= thresholds.Short {
+		return defaultLengthThresholds, errors.New("Medium message length threshold must be greater than the short one")
+	}
+	return thresholds, nil
+}
+
+// isEmojiToken return true for an :emoji: shortcode or a pictographic token
+func isEmojiToken(token string) bool {
+	if emojiShortcodeRegexp.FindString(token) == token {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(token)
+	return isEmoji(r)
+}
+
+// classifyMessage return the length class of a message from its words, empty for a message without words
+// (e.g. a file without text), messages with a code block are counted as code whatever their length
+func classifyMessage(message string, words []string, thresholds lengthThresholds) string {
+	if strings.Contains(message, "```") {
+		return lengthCode
+	}
+	if len(words) == 0 {
+		return ""
+	}
+	emojiOnly := true
+	for _, word := range words {
+		if !isEmojiToken(word) {
+			emojiOnly = false
+			break
+		}
+	}
+	switch {
+	case emojiOnly:
+		return lengthEmoji
+	case len(words) <= thresholds.Short:
+		return lengthShort
+	case len(words) <= thresholds.Medium:
+		return lengthMedium
+	default:
+		return lengthLong
+	}
+}
+
+// getLengthsDescription render the share of messages of each length class
+func getLengthsDescription(lengths map[string]int64, thresholds lengthThresholds, T translateFunc) string {
+	total := int64(0)
+	for _, nb := range lengths {
+		total += nb
+	}
+	if total == 0 {
+		return ""
+	}
+	hints := map[string]string{
+		lengthShort:  T("report.lengths.up_to", map[string]interface{}{"Words": thresholds.Short}),
+		lengthMedium: T("report.lengths.up_to", map[string]interface{}{"Words": thresholds.Medium}),
+		lengthLong:   T("report.lengths.more_than", map[string]interface{}{"Words": thresholds.Medium}),
+	}
+	m := sectionTitle(T, "report.lengths.title")
+	for _, class := range lengthClasses {
+		label := T("report.lengths." + class)
+		if hint, ok := hints[class]; ok {
+			label += fmt.Sprintf(" *(%s)*", hint)
+		}
+		m += fmt.Sprintf("* %s: **%d** *(%d%%)*\n", label, lengths[class], lengths[class]*100/total)
+	}
+	return m
+}
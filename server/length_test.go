@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLengthThresholds(t *testing.T) {
+	assert := assert.New(t)
+
+	thresholds, err := parseLengthThresholds("")
+	assert.Nil(err)
+	assert.Equal(defaultLengthThresholds, thresholds)
+
+	thresholds, err = parseLengthThresholds("short: 3, medium:50")
+	assert.Nil(err)
+	assert.Equal(lengthThresholds{Short: 3, Medium: 50}, thresholds)
+
+	thresholds, err = parseLengthThresholds("medium:10")
+	assert.Nil(err)
+	assert.Equal(lengthThresholds{Short: 5, Medium: 10}, thresholds)
+
+	_, err = parseLengthThresholds("short")
+	assert.NotNil(err)
+	_, err = parseLengthThresholds("tiny:2")
+	assert.NotNil(err)
+	_, err = parseLengthThresholds("short:0")
+	assert.NotNil(err)
+	_, err = parseLengthThresholds("short:10,medium:10")
+	assert.NotNil(err)
+}
+
+func TestClassifyMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	thresholds := lengthThresholds{Short: 2, Medium: 4}
+	classify := func(message string) string {
+		return classifyMessage(message, defaultTokenizer.Tokenize(message), thresholds)
+	}
+	assert.Equal(lengthEmoji, classify(":+1: :tada:"))
+	assert.Equal(lengthEmoji, classify("👍🏽"))
+	assert.Equal(lengthShort, classify("thanks :tada:"))
+	assert.Equal(lengthMedium, classify("see you all tomorrow"))
+	assert.Equal(lengthLong, classify("see you all tomorrow morning"))
+	assert.Equal(lengthCode, classify("```\nok\n```"))
+	assert.Equal("", classify(""))
+}
+
+func TestGetLengthsDescription(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("", getLengthsDescription(map[string]int64{}, defaultLengthThresholds, testTranslate(t, "en")))
+	assert.Equal("### Message Lengths\n"+
+		"* Emoji only: **1** *(10%)*\n"+
+		"* Short *(up to 5 words)*: **6** *(60%)*\n"+
+		"* Medium *(up to 30 words)*: **2** *(20%)*\n"+
+		"* Long *(more than 30 words)*: **0** *(0%)*\n"+
+		"* Code blocks: **1** *(10%)*\n",
+		getLengthsDescription(map[string]int64{lengthEmoji: 1, lengthShort: 6, lengthMedium: 2, lengthCode: 1}, defaultLengthThresholds, testTranslate(t, "en")))
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// channelLineageKey store the successor of merged channels by channel id, e.g. an archived channel continued in another
+const channelLineageKey = "channel_lineage"
+
+// lineageSuccessor return the channel continuing the history of channelID, following merges of merged channels,
+// channelID itself if it was not merged
+func lineageSuccessor(lineage map[string]string, channelID string) string {
+	// a cycle can't be recorded, the depth is bounded anyway
+	for i := 0; i < len(lineage); i++ {
+		successor, ok := lineage[channelID]
+		if !ok {
+			break
+		}
+		channelID = successor
+	}
+	return channelID
+}
+
+// validateLineage check fromID can be merged in toID, a channel can't be merged twice nor in one of its predecessors
+func validateLineage(lineage map[string]string, fromID string, toID string) error {
+	if fromID == toID {
+		return fmt.Errorf("a channel can't be merged in itself")
+	}
+	if _, ok := lineage[fromID]; ok {
+		return fmt.Errorf("the channel is already merged, remove its lineage first")
+	}
+	if lineageSuccessor(lineage, toID) == fromID {
+		return fmt.Errorf("the channel was merged in the other one")
+	}
+	return nil
+}
+
+// stitchBuckets return buckets of merged channels as buckets of their successor, other buckets are unchanged
+func stitchBuckets(buckets []dailyBucket, lineage map[string]string) []dailyBucket {
+	if len(lineage) == 0 {
+		return buckets
+	}
+	stitched := make([]dailyBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		bucket.ID = lineageSuccessor(lineage, bucket.ID)
+		stitched = append(stitched, bucket)
+	}
+	return stitched
+}
+
+// stitchCounters return counters by channel id with counters of merged channels added to their successor
+func stitchCounters(counters map[string]int64, lineage map[string]string) map[string]int64 {
+	if len(lineage) == 0 {
+		return counters
+	}
+	stitched := make(map[string]int64, len(counters))
+	for channelID, nb := range counters {
+		stitched[lineageSuccessor(lineage, channelID)] += nb
+	}
+	return stitched
+}
+
+// channelLineage return the successor of merged channels by channel id
+func (p *Plugin) channelLineage() (map[string]string, error) {
+	lineage := make(map[string]string)
+	err := p.kvGetJSON(channelLineageKey, &lineage)
+	return lineage, err
+}
+
+// lineageChannel return the channel of a ~name of the team of teamID, archived channels included
+func (p *Plugin) lineageChannel(name string, teamID string) (*model.Channel, error) {
+	channel, appErr := p.API.GetChannelByName(teamID, strings.TrimPrefix(name, "~"), true)
+	if appErr != nil {
+		return nil, fmt.Errorf("unknown channel %s", name)
+	}
+	return channel, nil
+}
+
+// executeLineageCommand handle `/analytics lineage` listing merged channels, `/analytics lineage ~from ~to` recording
+// that ~from continues in ~to and `/analytics lineage remove ~from`
+func (p *Plugin) executeLineageCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can record channel lineage.")
+	}
+	const usage = "Usage: /analytics lineage, /analytics lineage ~from ~to or /analytics lineage remove ~from"
+	p.lineageLock.Lock()
+	defer p.lineageLock.Unlock()
+	lineage, err := p.channelLineage()
+	if err != nil {
+		p.API.LogError("can't get channel lineage", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+
+	switch {
+	case len(parameters) == 0:
+		if len(lineage) == 0 {
+			return ephemeralResponse("No channel was merged.")
+		}
+		lines := make([]string, 0, len(lineage))
+		for fromID, toID := range lineage {
+			fromName, _, _, _ := p.getChannelName(fromID)
+			toName, _, _, _ := p.getChannelName(toID)
+			lines = append(lines, fmt.Sprintf("* ~%s continues in ~%s", fromName, toName))
+		}
+		sort.Strings(lines)
+		return ephemeralResponse("#### Merged channels\n" + strings.Join(lines, "\n"))
+	case len(parameters) == 2 && parameters[0] == "remove":
+		from, err := p.lineageChannel(parameters[1], args.TeamId)
+		if err != nil {
+			return ephemeralResponse(err.Error() + ".")
+		}
+		if _, ok := lineage[from.Id]; !ok {
+			return ephemeralResponse(fmt.Sprintf("~%s was not merged.", from.Name))
+		}
+		delete(lineage, from.Id)
+		if err := p.kvSetJSON(channelLineageKey, lineage); err != nil {
+			p.API.LogError("can't save channel lineage", "err", err.Error())
+			return ephemeralResponse("An error occured!")
+		}
+		p.audit("lineage_removed", args.UserId, map[string]string{"channel_id": from.Id})
+		return ephemeralResponse(fmt.Sprintf("History of ~%s is no longer stitched to another channel.", from.Name))
+	case len(parameters) == 2:
+		from, err := p.lineageChannel(parameters[0], args.TeamId)
+		if err != nil {
+			return ephemeralResponse(err.Error() + ".")
+		}
+		to, err := p.lineageChannel(parameters[1], args.TeamId)
+		if err != nil {
+			return ephemeralResponse(err.Error() + ".")
+		}
+		if err := validateLineage(lineage, from.Id, to.Id); err != nil {
+			return ephemeralResponse(fmt.Sprintf("Can't merge ~%s in ~%s: %s.", from.Name, to.Name, err.Error()))
+		}
+		lineage[from.Id] = to.Id
+		if err := p.kvSetJSON(channelLineageKey, lineage); err != nil {
+			p.API.LogError("can't save channel lineage", "err", err.Error())
+			return ephemeralResponse("An error occured!")
+		}
+		p.audit("lineage_recorded", args.UserId, map[string]string{"channel_id": from.Id, "successor_id": to.Id})
+		return ephemeralResponse(fmt.Sprintf("History of ~%s is now charted as part of ~%s.", from.Name, to.Name))
+	default:
+		return ephemeralResponse(usage)
+	}
+}
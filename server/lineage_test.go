@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineageSuccessor(t *testing.T) {
+	assert := assert.New(t)
+
+	lineage := map[string]string{"a": "b", "b": "c"}
+	assert.Equal("c", lineageSuccessor(lineage, "a"))
+	assert.Equal("c", lineageSuccessor(lineage, "b"))
+	assert.Equal("c", lineageSuccessor(lineage, "c"))
+	assert.Equal("d", lineageSuccessor(lineage, "d"))
+	assert.Equal("a", lineageSuccessor(map[string]string{}, "a"))
+}
+
+func TestValidateLineage(t *testing.T) {
+	assert := assert.New(t)
+
+	lineage := map[string]string{"a": "b", "b": "c"}
+	assert.NoError(validateLineage(lineage, "d", "a"))
+	assert.Error(validateLineage(lineage, "d", "d"))
+	assert.Error(validateLineage(lineage, "a", "d"))
+	assert.Error(validateLineage(lineage, "c", "a"))
+}
+
+func TestStitch(t *testing.T) {
+	assert := assert.New(t)
+
+	lineage := map[string]string{"old": "new"}
+	day := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	buckets := stitchBuckets([]dailyBucket{
+		{Date: day, ID: "old", Counters: DailyCounters{Messages: 3}},
+		{Date: day.AddDate(0, 0, 2), ID: "new", Counters: DailyCounters{Messages: 4}},
+		{Date: day, ID: "other", Counters: DailyCounters{Messages: 1}},
+	}, lineage)
+	assert.Equal([]int64{3, 0, 4}, seriesMessages(buckets, "new", day, day.AddDate(0, 0, 2)))
+	assert.Equal([]int64{0, 0, 0}, seriesMessages(buckets, "old", day, day.AddDate(0, 0, 2)))
+
+	assert.Equal(map[string]int64{"new": 5, "other": 1}, stitchCounters(map[string]int64{"old": 2, "new": 3, "other": 1}, lineage))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maskHashUsernames    = "hash_usernames"
+	maskDropChannelNames = "drop_channel_names"
+	maskBucketCounts     = "bucket_counts"
+)
+
+// exportRow is a line of analytics sent to an export destination
+type exportRow struct {
+	Date        time.Time
+	ChannelID   string
+	ChannelName string
+	UserID      string
+	Username    string
+	Messages    int64
+	Replies     int64
+	FilesSize   int64
+}
+
+// MaskingPolicy describe how rows are masked before being sent to an export destination
+type MaskingPolicy struct {
+	// HashUsernames replace user ids and usernames by a salted hash
+	HashUsernames bool
+	// DropChannelNames remove channel names, keeping only channel ids
+	DropChannelNames bool
+	// BucketCounts round down counts to a multiple of this value (0 to keep exact counts)
+	BucketCounts int64
+}
+
+// parseMaskingPolicies parse policies in form destination:rule,rule;destination:rule
+// e.g. warehouse:hash_usernames,drop_channel_names,bucket_counts=10;csv:hash_usernames
+func parseMaskingPolicies(config string) (map[string]*MaskingPolicy, error) {
+	policies := make(map[string]*MaskingPolicy)
+	if strings.TrimSpace(config) == "" {
+		return policies, nil
+	}
+	for _, destinationPolicy := range strings.Split(config, ";") {
+		v := strings.SplitN(destinationPolicy, ":", 2)
+		if len(v) != 2 || strings.TrimSpace(v[0]) == "" {
+			return nil, fmt.Errorf("Bad formatted masking policy: %v", destinationPolicy)
+		}
+		policy := &MaskingPolicy{}
+		for _, rule := range strings.Split(v[1], ",") {
+			rule = strings.TrimSpace(rule)
+			switch {
+			case rule == maskHashUsernames:
+				policy.HashUsernames = true
+			case rule == maskDropChannelNames:
+				policy.DropChannelNames = true
+			case strings.HasPrefix(rule, maskBucketCounts+"="):
+				bucket, err := strconv.ParseInt(strings.TrimPrefix(rule, maskBucketCounts+"="), 10, 64)
+				if err != nil || bucket < 1 {
+					return nil, fmt.Errorf("Bad bucket size in masking policy: %v", rule)
+				}
+				policy.BucketCounts = bucket
+			case rule == "":
+			default:
+				return nil, fmt.Errorf("Unknown masking rule: %v", rule)
+			}
+		}
+		policies[strings.TrimSpace(v[0])] = policy
+	}
+	return policies, nil
+}
+
+// apply return a masked copy of row, salt is used to hash usernames
+func (m *MaskingPolicy) apply(row exportRow, salt string) exportRow {
+	if m == nil {
+		return row
+	}
+	if m.HashUsernames {
+		row.UserID = hashIdentifier(row.UserID, salt)
+		row.Username = hashIdentifier(row.Username, salt)
+	}
+	if m.DropChannelNames {
+		row.ChannelName = ""
+	}
+	if m.BucketCounts > 0 {
+		row.Messages = (row.Messages / m.BucketCounts) * m.BucketCounts
+		row.Replies = (row.Replies / m.BucketCounts) * m.BucketCounts
+	}
+	return row
+}
+
+// maskingPolicy return the masking policy configured for an export destination, nil if none
+func (p *Plugin) maskingPolicy(destination string) *MaskingPolicy {
+	policies, err := parseMaskingPolicies(p.getConfiguration().ExportMaskingPolicies)
+	if err != nil {
+		p.API.LogError("can't parse masking policies", "err", err.Error())
+		return nil
+	}
+	return policies[destination]
+}
+
+func hashIdentifier(value string, salt string) string {
+	if value == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(salt + value))
+	return hex.EncodeToString(sum[:8])
+}
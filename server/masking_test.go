@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMaskingPolicies(t *testing.T) {
+	assert := assert.New(t)
+
+	policies, err := parseMaskingPolicies("warehouse:hash_usernames,drop_channel_names,bucket_counts=10;csv:hash_usernames")
+	assert.Nil(err)
+	assert.Equal(&MaskingPolicy{HashUsernames: true, DropChannelNames: true, BucketCounts: 10}, policies["warehouse"])
+	assert.Equal(&MaskingPolicy{HashUsernames: true}, policies["csv"])
+
+	policies, err = parseMaskingPolicies("")
+	assert.Nil(err)
+	assert.Empty(policies)
+
+	_, err = parseMaskingPolicies("warehouse:unknown")
+	assert.NotNil(err)
+	_, err = parseMaskingPolicies("warehouse:bucket_counts=0")
+	assert.NotNil(err)
+	_, err = parseMaskingPolicies("hash_usernames")
+	assert.NotNil(err)
+}
+
+func TestMaskingPolicyApply(t *testing.T) {
+	assert := assert.New(t)
+	row := exportRow{ChannelID: "c1", ChannelName: "town-square", UserID: "u1", Username: "john", Messages: 27, Replies: 4}
+
+	masked := (&MaskingPolicy{HashUsernames: true, DropChannelNames: true, BucketCounts: 10}).apply(row, "salt")
+	assert.Equal("c1", masked.ChannelID)
+	assert.Equal("", masked.ChannelName)
+	assert.NotEqual("u1", masked.UserID)
+	assert.NotEqual("john", masked.Username)
+	assert.Equal(hashIdentifier("john", "salt"), masked.Username)
+	assert.Equal(int64(20), masked.Messages)
+	assert.Equal(int64(0), masked.Replies)
+
+	var noPolicy *MaskingPolicy
+	assert.Equal(row, noPolicy.apply(row, "salt"))
+}
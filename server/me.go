@@ -0,0 +1,190 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxPersonalChannels is the number of most active channels of personal stats
+	maxPersonalChannels = 5
+	// maxPersonalHours is the number of busiest hours of personal stats
+	maxPersonalHours = 3
+)
+
+// PersonalStats are the statistics of a user during a period, only sent to this user
+type PersonalStats struct {
+	Messages int64
+	Replies  int64
+	// Channels store number of messages of the user by channel id
+	Channels map[string]int64
+	// Hours store number of messages of the user by hour of the day in their timezone (formatted as 15)
+	Hours             map[string]int64
+	ReactionsGiven    int64
+	ReactionsReceived int64
+}
+
+// personalCounters return messages, replies and messages by channel of userID in the analytic
+// caller must hold the read lock of the analytic
+func personalCounters(analytic *Analytic, userID string) (int64, int64, map[string]int64) {
+	channels := make(map[string]int64)
+	for key, nb := range analytic.ChannelsUsers {
+		if v := strings.SplitN(key, ":", 2); len(v) == 2 && v[1] == userID {
+			channels[v[0]] += nb
+		}
+	}
+	return analytic.Users[userID], analytic.UsersReply[userID], channels
+}
+
+// collectPersonalStats return the statistics of userID during the analytic, hours are in location
+// reactions and hours are collected from posts of the channels of the analytic, like reactions of reports
+func (p *Plugin) collectPersonalStats(analytic *Analytic, userID string, location *time.Location) (*PersonalStats, error) {
+	analytic.RLock()
+	from := analytic.Start
+	to := analytic.End
+	messages, replies, channels := personalCounters(analytic, userID)
+	channelsID := make([]string, 0, len(analytic.Channels))
+	for channelID := range analytic.Channels {
+		channelsID = append(channelsID, channelID)
+	}
+	analytic.RUnlock()
+	if to.IsZero() {
+		to = p.now()
+	}
+
+	stats := &PersonalStats{Messages: messages, Replies: replies, Channels: channels, Hours: make(map[string]int64)}
+	for _, channelID := range channelsID {
+		postList, appErr := p.API.GetPostsSince(channelID, from.UnixNano()/int64(time.Millisecond))
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't get posts of channel "+channelID)
+		}
+		for _, post := range postList.Posts {
+			createdAt := time.Unix(0, post.CreateAt*int64(time.Millisecond))
+			if createdAt.Before(from) || !createdAt.Before(to) || post.DeleteAt != 0 {
+				continue
+			}
+			if post.UserId == userID {
+				stats.Hours[createdAt.In(location).Format("15")]++
+			}
+			if !post.HasReactions {
+				continue
+			}
+			reactions, appErr := p.API.GetReactions(post.Id)
+			if appErr != nil {
+				p.API.LogWarn("can't get reactions of post, skip it", "post", post.Id, "err", appErr.Error())
+				continue
+			}
+			for _, reaction := range reactions {
+				if reaction.UserId == userID {
+					stats.ReactionsGiven++
+				} else if post.UserId == userID {
+					stats.ReactionsReceived++
+				}
+			}
+		}
+	}
+	return stats, nil
+}
+
+// userLocation return the timezone of a user, the one of the reports if it is not set
+func (p *Plugin) userLocation(user *model.User) *time.Location {
+	if timezone := user.GetPreferredTimezone(); timezone != "" {
+		if location, err := time.LoadLocation(timezone); err == nil {
+			return location
+		}
+	}
+	return p.getConfiguration().getLocation()
+}
+
+// getPersonalDescription render the statistics of a user since start, channels the user left are not named
+func (p *Plugin) getPersonalDescription(stats *PersonalStats, userID string, start time.Time, T translateFunc) string {
+	text := T("report.me.header", map[string]interface{}{"Date": start.Format(T("report.date_layout"))}) + "\n"
+	text += T("report.me.private") + "\n"
+	text += T("report.me.messages", map[string]interface{}{"Messages": stats.Messages, "Replies": stats.Replies}) + "\n"
+	text += T("report.me.reactions", map[string]interface{}{"Given": stats.ReactionsGiven, "Received": stats.ReactionsReceived}) + "\n"
+
+	if len(stats.Channels) > 0 {
+		text += "\n##### " + T("report.me.channels.title") + "\n"
+		left := int64(0)
+		shown := 0
+		for _, channel := range topCounters(stats.Channels, len(stats.Channels)) {
+			if _, appErr := p.API.GetChannelMember(channel.key, userID); appErr != nil {
+				left += channel.nb
+				continue
+			}
+			displayName, err := p.getChannelDisplayName(channel.key)
+			if err != nil || displayName == dmOrPrivateChannelName {
+				left += channel.nb
+				continue
+			}
+			if shown < maxPersonalChannels {
+				text += T("report.me.channels.line", map[string]interface{}{"Channel": displayName, "Messages": channel.nb}) + "\n"
+				shown++
+			}
+		}
+		if left > 0 {
+			text += T("report.me.channels.left", map[string]interface{}{"Messages": left}) + "\n"
+		}
+	}
+
+	if len(stats.Hours) > 0 {
+		hours := make([]string, 0, maxPersonalHours)
+		for _, hour := range topCounters(stats.Hours, maxPersonalHours) {
+			hours = append(hours, T("report.me.hours.line", map[string]interface{}{"Hour": hour.key, "Messages": hour.nb}))
+		}
+		text += "\n##### " + T("report.me.hours.title") + "\n" + strings.Join(hours, ", ") + "\n"
+	}
+	return text
+}
+
+// executeMeCommand handle `/analytics me [week|month]`, the statistics of the requesting user are sent to them by DM
+func (p *Plugin) executeMeCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if p.getConfiguration().AnonymousMode {
+		return ephemeralResponse("Personal analytics are not recorded in anonymous mode.")
+	}
+	period := "week"
+	if len(parameters) > 0 {
+		period = parameters[0]
+	}
+	if len(parameters) > 1 || (period != "week" && period != "month") {
+		return ephemeralResponse("Usage: /analytics me [week|month]")
+	}
+
+	analytic := p.currentAnalytic
+	if period == "month" {
+		month, err := p.monthAnalytic(p.now())
+		if err != nil {
+			p.API.LogError("can't merge sessions of the month", "err", err.Error())
+			return ephemeralResponse("An error occured!")
+		}
+		analytic = month
+	}
+	user, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		p.API.LogError("can't get user", "user", args.UserId, "err", appErr.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	stats, err := p.collectPersonalStats(analytic, args.UserId, p.userLocation(user))
+	if err != nil {
+		p.API.LogError("can't collect personal analytics", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	analytic.RLock()
+	start := analytic.Start
+	analytic.RUnlock()
+
+	channel, appErr := p.API.GetDirectChannel(p.BotUserID, args.UserId)
+	if appErr != nil {
+		p.API.LogError("can't get direct channel", "err", appErr.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	post := &model.Post{UserId: p.BotUserID, ChannelId: channel.Id, Message: p.getPersonalDescription(stats, args.UserId, start, p.translate())}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		p.API.LogError("can't post personal analytics", "err", appErr.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return ephemeralResponse("Your analytics were sent to you by direct message.")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPersonalCounters(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	analytic.Users = map[string]int64{"user1": 5, "user2": 2}
+	analytic.UsersReply = map[string]int64{"user1": 1}
+	analytic.ChannelsUsers = map[string]int64{"channel1:user1": 3, "channel2:user1": 2, "channel1:user2": 2}
+
+	messages, replies, channels := personalCounters(analytic, "user1")
+	assert.Equal(int64(5), messages)
+	assert.Equal(int64(1), replies)
+	assert.Equal(map[string]int64{"channel1": 3, "channel2": 2}, channels)
+
+	messages, replies, channels = personalCounters(analytic, "user3")
+	assert.Equal(int64(0), messages)
+	assert.Equal(int64(0), replies)
+	assert.Empty(channels)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/mattermost/mattermost-server/v5/plugin"
+)
+
+const maxMembershipChangesToDisplay = 5
+
+// UserHasJoinedChannel is called by mattermost when a user joined a channel
+// used to store membership trends of channels
+func (p *Plugin) UserHasJoinedChannel(c *plugin.Context, channelMember *model.ChannelMember, actor *model.User) {
+	p.recordMembership(dailyScopeChannelMembers, channelMember.ChannelId, DailyCounters{Joins: 1})
+}
+
+// UserHasLeftChannel is called by mattermost when a user left or was removed from a channel
+func (p *Plugin) UserHasLeftChannel(c *plugin.Context, channelMember *model.ChannelMember, actor *model.User) {
+	p.recordMembership(dailyScopeChannelMembers, channelMember.ChannelId, DailyCounters{Leaves: 1})
+}
+
+// UserHasJoinedTeam is called by mattermost when a user joined a team
+// used to store membership trends of teams
+func (p *Plugin) UserHasJoinedTeam(c *plugin.Context, teamMember *model.TeamMember, actor *model.User) {
+	p.recordMembership(dailyScopeTeamMembers, teamMember.TeamId, DailyCounters{Joins: 1})
+}
+
+// UserHasLeftTeam is called by mattermost when a user left or was removed from a team
+func (p *Plugin) UserHasLeftTeam(c *plugin.Context, teamMember *model.TeamMember, actor *model.User) {
+	p.recordMembership(dailyScopeTeamMembers, teamMember.TeamId, DailyCounters{Leaves: 1})
+}
+
+// recordMembership store a join or a leave in the daily bucket of a collected channel or of a team
+func (p *Plugin) recordMembership(scope string, id string, delta DailyCounters) {
+	if scope == dailyScopeChannelMembers && (!p.isChannelCollected(id) || !p.isChannelEnabled(id)) {
+		return
+	}
+	if err := p.incrementDaily(p.now(), scope, id, delta); err != nil {
+		p.API.LogError("can't store daily membership", "scope", scope, "id", id, "err", err.Error())
+	}
+}
+
+// membershipChange is the number of joins and leaves of a channel or a team
+type membershipChange struct {
+	id     string
+	joins  int64
+	leaves int64
+}
+
+func (m membershipChange) net() int64 {
+	return m.joins - m.leaves
+}
+
+// membershipChanges sum joins and leaves of buckets by id, ids rejected by include are ignored if include is not nil
+// sorted by biggest net change, growth before churn for the same size
+func membershipChanges(buckets []dailyBucket, include func(id string) bool) []membershipChange {
+	byID := make(map[string]*membershipChange)
+	for _, bucket := range buckets {
+		if include != nil && !include(bucket.ID) {
+			continue
+		}
+		if _, ok := byID[bucket.ID]; !ok {
+			byID[bucket.ID] = &membershipChange{id: bucket.ID}
+		}
+		byID[bucket.ID].joins += bucket.Counters.Joins
+		byID[bucket.ID].leaves += bucket.Counters.Leaves
+	}
+	changes := make([]membershipChange, 0, len(byID))
+	for _, change := range byID {
+		if change.joins+change.leaves > 0 {
+			changes = append(changes, *change)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := abs(changes[i].net()), abs(changes[j].net())
+		if a != b {
+			return a > b
+		}
+		if changes[i].net() != changes[j].net() {
+			return changes[i].net() > changes[j].net()
+		}
+		return changes[i].id < changes[j].id
+	})
+	return changes
+}
+
+// formatMembershipChange render a net change with joins and leaves, e.g. **+3** *(5 joined, 2 left)*
+func formatMembershipChange(change membershipChange) string {
+	return fmt.Sprintf("**%+d** *(%d joined, %d left)*", change.net(), change.joins, change.leaves)
+}
+
+// getMembershipDescription render net membership changes of teams and of the channels with the biggest changes
+// between from and to, teams are only shown in reports of all channels
+func (p *Plugin) getMembershipDescription(from time.Time, to time.Time, include func(channelID string) bool, T translateFunc) (string, error) {
+	channelBuckets, err := p.dailyBuckets(dailyScopeChannelMembers, from, to)
+	if err != nil {
+		return "", err
+	}
+	teams := make([]membershipChange, 0)
+	if include == nil {
+		teamBuckets, err := p.dailyBuckets(dailyScopeTeamMembers, from, to)
+		if err != nil {
+			return "", err
+		}
+		teams = membershipChanges(teamBuckets, nil)
+	}
+	channels := membershipChanges(channelBuckets, include)
+	if len(teams)+len(channels) == 0 {
+		return "", nil
+	}
+
+	m := sectionTitle(T, "report.membership.title")
+	for _, team := range teams {
+		name := team.id
+		if t, appErr := p.API.GetTeam(team.id); appErr == nil {
+			name = t.DisplayName
+		}
+		m += fmt.Sprintf("* %s: %s\n", name, formatMembershipChange(team))
+	}
+	for index, channel := range channels {
+		if index == maxMembershipChangesToDisplay {
+			break
+		}
+		_, displayName, link, err := p.getChannelName(channel.id)
+		if err != nil {
+			continue
+		}
+		m += fmt.Sprintf("* [~%s](%s): %s\n", displayName, link, formatMembershipChange(channel))
+	}
+	return m, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembershipChanges(t *testing.T) {
+	assert := assert.New(t)
+	day := time.Date(2019, time.April, 15, 0, 0, 0, 0, time.UTC)
+	buckets := []dailyBucket{
+		{Date: day, ID: "dev", Counters: DailyCounters{Joins: 3}},
+		{Date: day.AddDate(0, 0, 1), ID: "dev", Counters: DailyCounters{Joins: 1, Leaves: 1}},
+		{Date: day, ID: "ops", Counters: DailyCounters{Leaves: 3}},
+		{Date: day, ID: "hr", Counters: DailyCounters{Joins: 2, Leaves: 2}},
+		{Date: day, ID: "sales", Counters: DailyCounters{Joins: 1}},
+		{Date: day, ID: "idle", Counters: DailyCounters{Messages: 4}},
+	}
+
+	assert.Equal([]membershipChange{
+		{id: "dev", joins: 4, leaves: 1},
+		{id: "ops", leaves: 3},
+		{id: "sales", joins: 1},
+		{id: "hr", joins: 2, leaves: 2},
+	}, membershipChanges(buckets, nil))
+	assert.Equal([]membershipChange{{id: "ops", leaves: 3}}, membershipChanges(buckets, func(id string) bool { return id == "ops" }))
+	assert.Equal("**-3** *(0 joined, 3 left)*", formatMembershipChange(membershipChange{id: "ops", leaves: 3}))
+}
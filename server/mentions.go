@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	broadcastAll     = "all"
+	broadcastChannel = "channel"
+	broadcastHere    = "here"
+
+	// maxMentionedToDisplay is the number of most mentioned users in the mentions section
+	maxMentionedToDisplay = 3
+)
+
+// broadcastMentions are mentions notifying a whole channel instead of a user, in display order
+var broadcastMentions = []string{broadcastChannel, broadcastHere, broadcastAll}
+
+var mentionRegexp = regexp.MustCompile(`\B@([a-zA-Z0-9][a-zA-Z0-9._-]*)`)
+
+// parseMentions return lowercase usernames and broadcast mentions of a message, each one once
+func parseMentions(message string) ([]string, []string) {
+	users := make([]string, 0)
+	broadcasts := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range mentionRegexp.FindAllStringSubmatch(message, -1) {
+		mention := strings.ToLower(strings.TrimRight(match[1], ".-_"))
+		if mention == "" || seen[mention] {
+			continue
+		}
+		seen[mention] = true
+		switch mention {
+		case broadcastAll, broadcastChannel, broadcastHere:
+			broadcasts = append(broadcasts, mention)
+		default:
+			users = append(users, mention)
+		}
+	}
+	return users, broadcasts
+}
+
+// broadcastCounts sum broadcast mentions keyed by channelID:mention by mention and by channel
+func broadcastCounts(channelsBroadcasts map[string]int64) (map[string]int64, map[string]int64) {
+	byMention := make(map[string]int64)
+	byChannel := make(map[string]int64)
+	for key, nb := range channelsBroadcasts {
+		v := strings.SplitN(key, ":", 2)
+		if len(v) != 2 {
+			continue
+		}
+		byChannel[v[0]] += nb
+		byMention[v[1]] += nb
+	}
+	return byMention, byChannel
+}
+
+// getMentionsDescription render broadcast mentions usage, the channels using them the most and the most mentioned users
+// caller must hold the read lock of the analytic
+func (p *Plugin) getMentionsDescription(analytic *Analytic, T translateFunc) string {
+	byMention, byChannel := broadcastCounts(analytic.ChannelsBroadcasts)
+	if len(byChannel) == 0 && len(analytic.Mentions) == 0 {
+		return ""
+	}
+	m := sectionTitle(T, "report.mentions.title")
+	if len(byChannel) > 0 {
+		messages := int64(0)
+		for _, nb := range analytic.Channels {
+			messages += nb
+		}
+		total := int64(0)
+		usages := make([]string, 0, len(broadcastMentions))
+		for _, mention := range broadcastMentions {
+			total += byMention[mention]
+			usages = append(usages, fmt.Sprintf("@%s **%d**", mention, byMention[mention]))
+		}
+		m += fmt.Sprintf("Broadcast mentions: %s", strings.Join(usages, ", "))
+		if messages > 0 {
+			m += fmt.Sprintf(" *(%d%% of messages)*", total*100/messages)
+		}
+		m += ".\n"
+		for index, channel := range topCounters(byChannel, len(byChannel)) {
+			if index == maxChannelsToDisplay {
+				break
+			}
+			_, displayName, link, err := p.getChannelName(channel.key)
+			if err != nil {
+				continue
+			}
+			m += fmt.Sprintf("* [~%s](%s): **%d** broadcast mentions\n", displayName, link, channel.nb)
+		}
+	}
+	mentioned := make([]string, 0, maxMentionedToDisplay)
+	for _, user := range topCounters(analytic.Mentions, len(analytic.Mentions)) {
+		if len(mentioned) == maxMentionedToDisplay {
+			break
+		}
+		if _, appErr := p.API.GetUserByUsername(user.key); appErr != nil {
+			continue
+		}
+		mentioned = append(mentioned, fmt.Sprintf("@%s (%d)", user.key, user.nb))
+	}
+	if len(mentioned) > 0 {
+		m += fmt.Sprintf("\nMost mentioned: %s.\n", strings.Join(mentioned, ", "))
+	}
+	return m
+}
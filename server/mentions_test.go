@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	assert := assert.New(t)
+
+	users, broadcasts := parseMentions("@Alice can you ask @bob.smith. @alice")
+	assert.Equal([]string{"alice", "bob.smith"}, users)
+	assert.Empty(broadcasts)
+
+	users, broadcasts = parseMentions("@here @channel @all ping @carol_ @HERE")
+	assert.Equal([]string{"carol"}, users)
+	assert.Equal([]string{"here", "channel", "all"}, broadcasts)
+
+	users, broadcasts = parseMentions("write to alice@example.com")
+	assert.Empty(users)
+	assert.Empty(broadcasts)
+}
+
+func TestBroadcastCounts(t *testing.T) {
+	assert := assert.New(t)
+
+	byMention, byChannel := broadcastCounts(map[string]int64{
+		"channel1:here":    3,
+		"channel1:channel": 1,
+		"channel2:here":    2,
+		"channel2:all":     4,
+		"malformed":        5,
+	})
+	assert.Equal(map[string]int64{"here": 5, "channel": 1, "all": 4}, byMention)
+	assert.Equal(map[string]int64{"channel1": 4, "channel2": 6}, byChannel)
+}
@@ -2,22 +2,210 @@ package main
 
 import (
 	"io"
+	"time"
 
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin"
 )
 
+// automatedOutcome return the outcome of a post ignored because it was sent by a bot account or an incoming webhook
+// according to IgnoreBots and IgnoreWebhooks, empty if the post is counted
+func automatedOutcome(post *model.Post, isBot func(userID string) bool, ignoreBots bool, ignoreWebhooks bool) string {
+	// Mattermost 5.12 has no Post.GetProp, webhooks set the from_webhook prop to "true"
+	if fromWebhook, _ := post.Props["from_webhook"].(string); ignoreWebhooks && fromWebhook == "true" {
+		return outcomeWebhook
+	}
+	if ignoreBots && isBot(post.UserId) {
+		return outcomeBot
+	}
+	return ""
+}
+
+// isBot return true if userID is a bot account, users that can't be found are not bots
+func (p *Plugin) isBot(userID string) bool {
+	user, appErr := p.API.GetUser(userID)
+	return appErr == nil && user.IsBot
+}
+
 // MessageHasBeenPosted is called by mattermost when a message has been posted
 // used to store metrics on messages
 func (p *Plugin) MessageHasBeenPosted(c *plugin.Context, post *model.Post) {
+	switch {
+	case !p.isChannelCollected(post.ChannelId):
+		p.sample(collectorPosts, post.ChannelId, post.UserId, outcomeExcluded)
+		return
+	case !p.isChannelEnabled(post.ChannelId):
+		p.sample(collectorPosts, post.ChannelId, post.UserId, outcomeRollout)
+		return
+	case !p.hasConsent(post.ChannelId):
+		p.sample(collectorPosts, post.ChannelId, post.UserId, outcomeNoConsent)
+		return
+	}
+	config := p.getConfiguration()
+	if outcome := automatedOutcome(post, p.isBot, config.IgnoreBots, config.IgnoreWebhooks); outcome != "" {
+		p.sample(collectorPosts, post.ChannelId, post.UserId, outcome)
+		return
+	}
+	if teamID, err := p.getChannelTeamID(post.ChannelId); err == nil && teamID == "" {
+		p.countDirectMessage(post)
+		return
+	}
+	// in anonymous mode the author is never recorded, only channel aggregates
+	userID := post.UserId
+	if config.AnonymousMode {
+		userID = ""
+	}
+	p.sample(collectorPosts, post.ChannelId, userID, outcomeCounted)
+
+	now := p.now()
+	event := p.postEvent(post, userID, config)
+	event.Kind = journalPost
+	event.Date = now
+	delta := DailyCounters{Messages: 1, FilesSize: event.FilesSize}
+	if post.RootId != "" {
+		delta.Replies = 1
+	}
+
 	p.currentAnalytic.WLock()
-	defer p.currentAnalytic.WUnlock()
+	p.appendAndApply(event)
+	p.currentAnalytic.WUnlock()
+
+	p.recordDaily(now, post.ChannelId, userID, delta)
+	if err := p.trackResponseTime(post); err != nil {
+		p.API.LogError("can't track response time", "post", post.Id, "err", err.Error())
+	}
+	if err := p.markTracked(post.ChannelId, now); err != nil {
+		p.API.LogError("can't mark channel as tracked", "channel", post.ChannelId, "err", err.Error())
+	}
+}
+
+// postEvent return the event of a post with its counters, its kind and date are set by the caller
+func (p *Plugin) postEvent(post *model.Post, userID string, config *configuration) JournalEvent {
+	var filesSize int64
+	for _, fileID := range post.FileIds {
+		info, err := p.API.GetFileInfo(fileID)
+		if err != nil {
+			p.API.LogWarn("can't get file info", "file", fileID, "err", err.Error())
+			continue
+		}
+		filesSize += info.Size
+	}
+
+	words := defaultTokenizer.Tokenize(post.Message)
+	thresholds, err := parseLengthThresholds(config.MessageLengthThresholds)
+	if err != nil {
+		p.API.LogWarn("bad message length thresholds, use default ones", "err", err.Error())
+	}
+	mentions, broadcasts := parseMentions(post.Message)
+	if config.AnonymousMode {
+		mentions = nil
+	}
+	return JournalEvent{
+		ChannelID:  post.ChannelId,
+		UserID:     userID,
+		Reply:      post.RootId != "",
+		RootID:     post.RootId,
+		Words:      int64(len(words)),
+		Script:     detectScript(post.Message),
+		FilesSize:  filesSize,
+		Mentions:   mentions,
+		Broadcasts: broadcasts,
+		Length:     classifyMessage(post.Message, words, thresholds),
+	}
+}
+
+// isPostTracked return true if the post was counted by MessageHasBeenPosted, direct messages are counted without
+// content so their edits and deletions are not tracked
+func (p *Plugin) isPostTracked(post *model.Post, config *configuration) bool {
+	if !p.isChannelCollected(post.ChannelId) || !p.isChannelEnabled(post.ChannelId) || !p.hasConsent(post.ChannelId) {
+		return false
+	}
+	if automatedOutcome(post, p.isBot, config.IgnoreBots, config.IgnoreWebhooks) != "" {
+		return false
+	}
+	teamID, err := p.getChannelTeamID(post.ChannelId)
+	return err == nil && teamID != ""
+}
 
-	p.currentAnalytic.Users[post.UserId]++
-	p.currentAnalytic.Channels[post.ChannelId]++
-	if post.ParentId != "" {
-		p.currentAnalytic.UsersReply[post.UserId]++
-		p.currentAnalytic.ChannelsReply[post.ChannelId]++
+// MessageHasBeenUpdated is called by mattermost when a message has been updated
+// used to count edits, updates keeping the message and its files (e.g. pinning or reactions) are not edits
+func (p *Plugin) MessageHasBeenUpdated(c *plugin.Context, newPost, oldPost *model.Post) {
+	if newPost.Message == oldPost.Message && len(newPost.FileIds) == len(oldPost.FileIds) {
+		return
+	}
+	config := p.getConfiguration()
+	if !p.isPostTracked(newPost, config) {
+		return
+	}
+	userID := newPost.UserId
+	if config.AnonymousMode {
+		userID = ""
+	}
+	p.currentAnalytic.WLock()
+	p.appendAndApply(JournalEvent{Kind: journalEdit, Date: p.now(), ChannelID: newPost.ChannelId, UserID: userID})
+	p.currentAnalytic.WUnlock()
+}
+
+// MessageHasBeenDeleted is called by mattermost when a message has been deleted
+// used to count deletions and to remove the post from counters if it was posted during the current session, posts
+// of previous sessions were already reported
+// mattermost 5.18 doesn't invoke this hook, it is called by servers supporting it
+func (p *Plugin) MessageHasBeenDeleted(c *plugin.Context, post *model.Post) {
+	config := p.getConfiguration()
+	if !p.isPostTracked(post, config) {
+		return
+	}
+	userID := post.UserId
+	if config.AnonymousMode {
+		userID = ""
+	}
+	event := JournalEvent{Kind: journalDelete, ChannelID: post.ChannelId, UserID: userID}
+	postedAt := time.Unix(0, post.CreateAt*int64(time.Millisecond))
+
+	p.currentAnalytic.RLock()
+	retract := !postedAt.Before(p.currentAnalytic.Start)
+	p.currentAnalytic.RUnlock()
+	if retract {
+		event = p.postEvent(post, userID, config)
+		event.Kind = journalDelete
+		event.Retract = true
+		event.PostedAt = postedAt
+	}
+	event.Date = p.now()
+
+	p.currentAnalytic.WLock()
+	p.appendAndApply(event)
+	p.currentAnalytic.WUnlock()
+
+	if retract {
+		delta := DailyCounters{Messages: -1, FilesSize: -event.FilesSize}
+		if post.RootId != "" {
+			delta.Replies = -1
+		}
+		p.recordDaily(postedAt, post.ChannelId, userID, delta)
+	}
+}
+
+// countDirectMessage count a direct or group message in aggregate if TrackDirectMessages is on
+// neither the channel, the participants nor the content are recorded
+func (p *Plugin) countDirectMessage(post *model.Post) {
+	if !p.getConfiguration().TrackDirectMessages {
+		p.sample(collectorPosts, "", "", outcomeDirect)
+		return
+	}
+	p.sample(collectorPosts, "", "", outcomeCounted)
+
+	now := p.now()
+	p.currentAnalytic.WLock()
+	p.appendAndApply(JournalEvent{Kind: journalDirect, Date: now})
+	p.currentAnalytic.WUnlock()
+
+	delta := DailyCounters{Messages: 1}
+	if post.RootId != "" {
+		delta.Replies = 1
+	}
+	if err := p.incrementDaily(now, dailyScopeDirect, directMessagesID, delta); err != nil {
+		p.API.LogError("can't store daily direct messages analytics", "err", err.Error())
 	}
 }
 
@@ -27,7 +215,20 @@ func (p *Plugin) FileWillBeUploaded(c *plugin.Context, info *model.FileInfo, fil
 	p.currentAnalytic.WLock()
 	defer p.currentAnalytic.WUnlock()
 
-	p.currentAnalytic.FilesNb++
-	p.currentAnalytic.FilesSize += info.Size
+	p.sample(collectorFiles, "", info.CreatorId, outcomeCounted)
+	p.appendAndApply(JournalEvent{Kind: journalFile, Date: p.now(), FilesSize: info.Size})
 	return info, ""
 }
+
+// appendAndApply journal the event then aggregate it in the current session, caller must hold the write lock
+func (p *Plugin) appendAndApply(event JournalEvent) {
+	if err := p.journal.Append(event); err != nil {
+		p.API.LogError("can't append event to journal", "err", err.Error())
+	}
+	p.currentAnalytic.apply(event)
+	if p.clusterDelta != nil {
+		p.clusterDelta.apply(event)
+	}
+	p.bufferElasticsearchEvent(event)
+	p.checkAccumulatorSize()
+}
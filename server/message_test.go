@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutomatedOutcome(t *testing.T) {
+	assert := assert.New(t)
+	isBot := func(userID string) bool {
+		return userID == "bot1"
+	}
+	human := &model.Post{UserId: "user1"}
+	bot := &model.Post{UserId: "bot1"}
+	webhook := &model.Post{UserId: "user1", Props: model.StringInterface{"from_webhook": "true"}}
+
+	assert.Equal("", automatedOutcome(human, isBot, true, true))
+	assert.Equal(outcomeBot, automatedOutcome(bot, isBot, true, false))
+	assert.Equal("", automatedOutcome(bot, isBot, false, true))
+	assert.Equal(outcomeWebhook, automatedOutcome(webhook, isBot, false, true))
+	assert.Equal("", automatedOutcome(webhook, isBot, true, false))
+}
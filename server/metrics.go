@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// metricsReactionsTTL is the delay before reactions are collected again for metrics, collecting them reads every post
+const metricsReactionsTTL = 5 * time.Minute
+
+// metricSample is a value of a metric with its labels
+type metricSample struct {
+	Labels map[string]string
+	Value  int64
+}
+
+var metricLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels render labels sorted by name in prometheus text format, e.g. {channel="town-square",team="staff"}
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, name, metricLabelReplacer.Replace(labels[name])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// writeMetric write a metric family in prometheus text format, samples sorted by labels
+func writeMetric(w io.Writer, name string, kind string, help string, samples []metricSample) error {
+	lines := make([]string, 0, len(samples))
+	for _, sample := range samples {
+		lines = append(lines, fmt.Sprintf("%s%s %d\n", name, formatLabels(sample.Labels), sample.Value))
+	}
+	sort.Strings(lines)
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s", name, help, name, kind, strings.Join(lines, ""))
+	return err
+}
+
+// authorizeMetrics reply with an error and return false unless the request has the configured bearer token
+// without token, only system admins can read metrics
+func (p *Plugin) authorizeMetrics(w http.ResponseWriter, r *http.Request) bool {
+	token := p.getConfiguration().MetricsToken
+	if token == "" {
+		return p.authorizeAPI(w, r)
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// metricsReactions return reactions of the current session, collected at most once every metricsReactionsTTL
+func (p *Plugin) metricsReactions() (*ReactionStats, error) {
+	p.metricsLock.Lock()
+	defer p.metricsLock.Unlock()
+	if p.metricsReactionsStats != nil && p.now().Sub(p.metricsReactionsAt) < metricsReactionsTTL {
+		return p.metricsReactionsStats, nil
+	}
+	stats, err := p.collectReactions(p.currentAnalytic)
+	if err != nil {
+		return nil, err
+	}
+	p.metricsReactionsStats = stats
+	p.metricsReactionsAt = p.now()
+	return stats, nil
+}
+
+// handleMetrics serve `GET /metrics`, counters of the current session in prometheus text format
+// channel names are dropped by the drop_channel_names rule of the metrics masking policy
+func (p *Plugin) handleMetrics(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeMetrics(w, r) {
+		return nil
+	}
+	reactions, err := p.metricsReactions()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+
+	p.currentAnalytic.RLock()
+	channels := make(map[string]int64, len(p.currentAnalytic.Channels))
+	mergeCounters(channels, p.currentAnalytic.Channels)
+	channelsUsers := make(map[string]int64, len(p.currentAnalytic.ChannelsUsers))
+	mergeCounters(channelsUsers, p.currentAnalytic.ChannelsUsers)
+	p.currentAnalytic.RUnlock()
+
+	policy := p.maskingPolicy("metrics")
+	teamsName := make(map[string]string)
+	channelsTeam := make(map[string]string)
+	channelLabels := func(channelID string) map[string]string {
+		channel, appErr := p.API.GetChannel(channelID)
+		if appErr != nil || channel.IsGroupOrDirect() {
+			return map[string]string{"team": "", "channel": dmOrPrivateChannelName}
+		}
+		channelsTeam[channelID] = channel.TeamId
+		if _, ok := teamsName[channel.TeamId]; !ok {
+			teamsName[channel.TeamId] = channel.TeamId
+			if team, appErr := p.API.GetTeam(channel.TeamId); appErr == nil {
+				teamsName[channel.TeamId] = team.Name
+			}
+		}
+		row := policy.apply(exportRow{ChannelID: channelID, ChannelName: channel.Name}, "")
+		name := row.ChannelName
+		if name == "" {
+			name = channelID
+		}
+		return map[string]string{"team": teamsName[channel.TeamId], "channel": name}
+	}
+	// direct and group messages are summed in a single serie
+	bySeries := func(counts map[string]int64) []metricSample {
+		series := make(map[string]*metricSample)
+		for channelID, nb := range counts {
+			labels := channelLabels(channelID)
+			key := formatLabels(labels)
+			if _, ok := series[key]; !ok {
+				series[key] = &metricSample{Labels: labels}
+			}
+			series[key].Value += nb
+		}
+		samples := make([]metricSample, 0, len(series))
+		for _, sample := range series {
+			samples = append(samples, *sample)
+		}
+		return samples
+	}
+
+	messages := bySeries(channels)
+	reactionsSamples := bySeries(reactions.Channels)
+	activeUsers := make(map[string]int64)
+	for _, teams := range usersTeams(channelsUsers, channelsTeam) {
+		for teamID := range teams {
+			activeUsers[teamsName[teamID]]++
+		}
+	}
+	activeUsersSamples := make([]metricSample, 0, len(activeUsers))
+	for team, nb := range activeUsers {
+		activeUsersSamples = append(activeUsersSamples, metricSample{Labels: map[string]string{"team": team}, Value: nb})
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := writeMetric(w, "messages_total", "counter", "Messages posted since the start of the current session.", messages); err != nil {
+		return err
+	}
+	if err := writeMetric(w, "reactions_total", "counter", "Reactions on messages posted since the start of the current session.", reactionsSamples); err != nil {
+		return err
+	}
+	return writeMetric(w, "active_users", "gauge", "Users who posted in a team since the start of the current session.", activeUsersSamples)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteMetric(t *testing.T) {
+	assert := assert.New(t)
+	samples := []metricSample{
+		{Labels: map[string]string{"team": "staff", "channel": "town-square"}, Value: 12},
+		{Labels: map[string]string{"team": "", "channel": "say \"hi\""}, Value: 3},
+	}
+
+	var content bytes.Buffer
+	assert.Nil(writeMetric(&content, "messages_total", "counter", "Messages posted.", samples))
+	assert.Equal("# HELP messages_total Messages posted.\n"+
+		"# TYPE messages_total counter\n"+
+		"messages_total{channel=\"say \\\"hi\\\"\",team=\"\"} 3\n"+
+		"messages_total{channel=\"town-square\",team=\"staff\"} 12\n", content.String())
+	assert.Equal("", formatLabels(nil))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// parseOnCallRotations parse rotations in form Team/channel:username,username;Team/channel2:username
+// and return usernames of the rotation by TeamName/ChannelName
+func parseOnCallRotations(config string) (map[string][]string, error) {
+	rotations := make(map[string][]string)
+	if strings.TrimSpace(config) == "" {
+		return rotations, nil
+	}
+	for _, rotation := range strings.Split(config, ";") {
+		v := strings.SplitN(rotation, ":", 2)
+		if len(v) != 2 || strings.Count(v[0], "/") != 1 || strings.TrimSpace(v[1]) == "" {
+			return nil, fmt.Errorf("Bad formatted on-call rotation: %v", rotation)
+		}
+		channel := strings.TrimSpace(v[0])
+		for _, username := range strings.Split(v[1], ",") {
+			rotations[channel] = append(rotations[channel], strings.TrimPrefix(strings.TrimSpace(username), "@"))
+		}
+	}
+	return rotations, nil
+}
+
+// firstResponse is the first reply of a member of the on-call rotation to a request
+type firstResponse struct {
+	responderID string
+	delay       time.Duration
+}
+
+// firstResponses find the first reply of a member of the rotation to each root post created in [from, to)
+// by someone outside the rotation, return responses and the number of requests without response
+func firstResponses(posts *model.PostList, rotation map[string]bool, from time.Time, to time.Time) ([]firstResponse, int) {
+	requests := make(map[string]*model.Post)
+	for _, post := range posts.Posts {
+		createdAt := time.Unix(0, post.CreateAt*int64(time.Millisecond))
+		if post.RootId == "" && post.Type == "" && !rotation[post.UserId] && !createdAt.Before(from) && createdAt.Before(to) {
+			requests[post.Id] = post
+		}
+	}
+	firsts := make(map[string]*model.Post)
+	for _, post := range posts.Posts {
+		if _, ok := requests[post.RootId]; !ok || !rotation[post.UserId] {
+			continue
+		}
+		if first, ok := firsts[post.RootId]; !ok || post.CreateAt < first.CreateAt {
+			firsts[post.RootId] = post
+		}
+	}
+
+	responses := make([]firstResponse, 0, len(firsts))
+	for rootID, reply := range firsts {
+		delay := time.Duration(reply.CreateAt-requests[rootID].CreateAt) * time.Millisecond
+		responses = append(responses, firstResponse{responderID: reply.UserId, delay: delay})
+	}
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].delay < responses[j].delay
+	})
+	return responses, len(requests) - len(firsts)
+}
+
+// medianDelay return the median of delays sorted ascending
+func medianDelay(delays []time.Duration) time.Duration {
+	if len(delays) == 0 {
+		return 0
+	}
+	if len(delays)%2 == 1 {
+		return delays[len(delays)/2]
+	}
+	return (delays[len(delays)/2-1] + delays[len(delays)/2]) / 2
+}
+
+// buildOnCallReport render who responded first to requests of channelID since from, and how fast
+func (p *Plugin) buildOnCallReport(channelID string, usernames []string, from time.Time, to time.Time, T translateFunc) (string, error) {
+	rotation := make(map[string]bool)
+	for _, username := range usernames {
+		user, appErr := p.API.GetUserByUsername(username)
+		if appErr != nil {
+			return "", errors.Wrap(appErr, "can't find on-call user "+username)
+		}
+		rotation[user.Id] = true
+	}
+	posts, appErr := p.API.GetPostsSince(channelID, from.UnixNano()/int64(time.Millisecond))
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "can't get posts of channel "+channelID)
+	}
+	responses, unanswered := firstResponses(posts, rotation, from, to)
+
+	text := T("report.oncall.header", map[string]interface{}{"Date": from.Format(T("report.date_layout"))}) + "\n"
+	if len(responses)+unanswered == 0 {
+		return text + T("report.oncall.no_request"), nil
+	}
+	delays := make([]time.Duration, 0, len(responses))
+	byResponder := make(map[string][]time.Duration)
+	for _, response := range responses {
+		delays = append(delays, response.delay)
+		byResponder[response.responderID] = append(byResponder[response.responderID], response.delay)
+	}
+	if len(responses) > 0 {
+		text += T("report.oncall.summary", map[string]interface{}{
+			"Requests":   len(responses) + unanswered,
+			"Answered":   len(responses),
+			"Delay":      medianDelay(delays).Round(time.Minute).String(),
+			"Unanswered": unanswered,
+		}) + "\n"
+	} else {
+		text += T("report.oncall.summary_unanswered", map[string]interface{}{"Requests": unanswered, "Unanswered": unanswered}) + "\n"
+	}
+	// anonymous mode omits per user statistics
+	if len(byResponder) == 0 || p.getConfiguration().AnonymousMode {
+		return text, nil
+	}
+
+	counts := make(map[string]int64, len(byResponder))
+	for responderID, responderDelays := range byResponder {
+		counts[responderID] = int64(len(responderDelays))
+	}
+	text += "\n" + T("report.oncall.columns") + "\n|:--|--:|--:|\n"
+	for _, responder := range topCounters(counts, len(counts)) {
+		username, err := p.getUsername(responder.key)
+		if err != nil {
+			username = responder.key
+		}
+		text += fmt.Sprintf("| @%s | %d | %s |\n", username, responder.nb, medianDelay(byResponder[responder.key]).Round(time.Minute))
+	}
+	return text, nil
+}
+
+// sendOnCallReports post the on-call responsiveness report from from to to in each collected channel with a rotation
+// users named in the reports are recorded in named
+func (p *Plugin) sendOnCallReports(from time.Time, to time.Time, named *namedUsers) error {
+	rotations, err := parseOnCallRotations(p.getConfiguration().OnCallRotations)
+	if err != nil {
+		return err
+	}
+
+	for channel, usernames := range rotations {
+		channelsID, err := p.parseChannelsFromConfig(channel)
+		if err != nil {
+			p.API.LogError("can't find on-call channel", "channel", channel, "err", err.Error())
+			continue
+		}
+		// rotations don't bypass the channels excluded from analytics nor the consent of channel admins
+		if !p.isChannelCollected(channelsID[0]) || !p.isChannelEnabled(channelsID[0]) || !p.hasRecordedConsent(channelsID[0]) {
+			p.API.LogInfo("on-call channel is not collected, skip its report", "channel", channel)
+			continue
+		}
+		text, err := p.buildOnCallReport(channelsID[0], usernames, from, to, p.channelTranslate(channelsID[0]))
+		if err != nil {
+			p.API.LogError("can't build on-call report", "channel", channel, "err", err.Error())
+			continue
+		}
+		post := &model.Post{
+			UserId:    p.BotUserID,
+			ChannelId: channelsID[0],
+			Message:   text,
+		}
+		if _, appErr := p.API.CreatePost(post); appErr != nil {
+			return errors.Wrap(appErr, "can't post on-call report")
+		}
+		named.add(channelsID[0], text)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOnCallRotations(t *testing.T) {
+	assert := assert.New(t)
+
+	rotations, err := parseOnCallRotations("team1/support: alice, @bob;team2/ops:carol")
+	assert.Nil(err)
+	assert.Equal(map[string][]string{"team1/support": {"alice", "bob"}, "team2/ops": {"carol"}}, rotations)
+
+	_, err = parseOnCallRotations("support:alice")
+	assert.NotNil(err)
+	_, err = parseOnCallRotations("team1/support:")
+	assert.NotNil(err)
+}
+
+func TestFirstResponses(t *testing.T) {
+	assert := assert.New(t)
+	from := time.Date(2019, time.April, 15, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	at := func(minutes int) int64 {
+		return from.Add(time.Duration(minutes)*time.Minute).UnixNano() / int64(time.Millisecond)
+	}
+	posts := model.NewPostList()
+	for _, post := range []*model.Post{
+		{Id: "request1", UserId: "customer", CreateAt: at(10)},
+		{Id: "reply1", UserId: "customer", RootId: "request1", CreateAt: at(12)},
+		{Id: "reply2", UserId: "bob", RootId: "request1", CreateAt: at(40)},
+		{Id: "reply3", UserId: "alice", RootId: "request1", CreateAt: at(25)},
+		{Id: "request2", UserId: "customer", CreateAt: at(60)},
+		{Id: "reply4", UserId: "bob", RootId: "request2", CreateAt: at(65)},
+		{Id: "request3", UserId: "customer", CreateAt: at(100)},
+		{Id: "announce", UserId: "alice", CreateAt: at(120)},
+		{Id: "old", UserId: "customer", CreateAt: at(-10)},
+	} {
+		posts.AddPost(post)
+	}
+
+	responses, unanswered := firstResponses(posts, map[string]bool{"alice": true, "bob": true}, from, to)
+	assert.Equal([]firstResponse{
+		{responderID: "bob", delay: 5 * time.Minute},
+		{responderID: "alice", delay: 15 * time.Minute},
+	}, responses)
+	assert.Equal(1, unanswered)
+	assert.Equal(10*time.Minute, medianDelay([]time.Duration{5 * time.Minute, 15 * time.Minute}))
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const commandHelp = "#### Analytics commands\n" +
+	"* `/analytics` or `/analytics week` - post analytics of the current session in this channel\n" +
+	"* `/analytics month` - post analytics of the last 30 days in this channel\n" +
+	"* `/analytics channel ~channel-name` - post analytics of a channel of this team in this channel\n" +
+	"* `/analytics leaderboard [posters|reactors|mentioned|replied]` - post leaderboards of the current session in this channel\n" +
+	"* `/analytics me [week|month]` - receive your own analytics by direct message\n" +
+	"* `/analytics help` - display this help\n\n" +
+	"System admins can also use `status`, `preview [week|month]`, `share [week|month|quarterly] [days]`, `share list`, `share revoke <id>`, `audit-report <from> <to> <all|team|~channel|@user> <reference>`, `lineage [~from ~to|remove ~from]`, `diagnostics [repair <class>]`, `feedback`, `pause YYYY-MM-DD`, `resume`, `quarterly`, `chargeback`, `seats`, `capacity`, `overlap`, `backfill <days>`, `export [days]`, `forget @username`, `purge YYYY-MM-DD YYYY-MM-DD`, `purge undo`, `simulate YYYY-MM-DD` and `debug sample <collector>`."
+
+// monthAnalytic merge archived sessions of the last 30 days with the current one
+func (p *Plugin) monthAnalytic(now time.Time) (*Analytic, error) {
+	sessions, err := p.allSessions()
+	if err != nil {
+		return nil, err
+	}
+	from := now.AddDate(0, 0, -30)
+	month := make([]*Analytic, 0, len(sessions)+1)
+	for _, session := range sessions {
+		if session.End.After(from) {
+			month = append(month, session)
+		}
+	}
+	month = append(month, p.currentAnalytic)
+	return mergeAnalytics(month), nil
+}
+
+// buildChannelAttachments return the report of channelID inside the analytic
+func (p *Plugin) buildChannelAttachments(analytic *Analytic, channelID string, T translateFunc) ([]*model.SlackAttachment, error) {
+	_, displayName, link, err := p.getChannelName(channelID)
+	if err != nil {
+		return nil, err
+	}
+	last30Days := ""
+	now := p.now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -29)
+	if counters, err := p.dailyCounters(dailyScopeChannel, channelID, from, now); err != nil {
+		p.API.LogWarn("can't get daily analytics", "channel", channelID, "err", err.Error())
+	} else {
+		note := partialNote(p.partialSince(channelID, from), T)
+		if p.backfillProgress() != nil {
+			note = T("report.channel.backfill")
+		}
+		last30Days = T("report.channel.last_30_days", map[string]interface{}{
+			"Note":      note,
+			"Messages":  counters.Messages,
+			"Replies":   counters.Replies,
+			"FilesSize": byteCountDecimal(counters.FilesSize),
+		})
+	}
+
+	analytic.RLock()
+	since := analytic.Start
+	posters := channelsPosters(analytic.ChannelsUsers)[channelID]
+	analytic.RUnlock()
+	readership := ""
+	if channelReadership, err := p.channelReadership(channelID, since, posters); err != nil {
+		p.API.LogWarn("can't get readership", "channel", channelID, "err", err.Error())
+	} else {
+		readership = T("report.channel.readership", map[string]interface{}{
+			"Readers":     channelReadership.Readers,
+			"Members":     channelReadership.Members,
+			"Date":        since.Format(T("report.short_date_layout")),
+			"Posters":     channelReadership.Posters,
+			"LurkerRatio": channelReadership.lurkerRatio(),
+		})
+	}
+
+	analytic.RLock()
+	defer analytic.RUnlock()
+
+	rank := 1
+	for key, nb := range analytic.Channels {
+		if key != channelID && nb > analytic.Channels[channelID] {
+			rank++
+		}
+	}
+	text := T("report.channel.header", map[string]interface{}{"Channel": displayName, "Link": link, "Date": analytic.Start.Format(T("report.date_layout"))}) + "\n"
+	if analytic.Channels[channelID] == 0 {
+		text += T("report.channel.no_message")
+	} else {
+		text += T("report.channel.activity", map[string]interface{}{
+			"Messages":  analytic.Channels[channelID],
+			"Words":     analytic.ChannelsWords[channelID],
+			"Replies":   analytic.ChannelsReply[channelID],
+			"FilesSize": byteCountDecimal(analytic.ChannelsFilesSize[channelID]),
+			"Note":      partialNote(p.partialSince(channelID, analytic.Start), T),
+			"Rank":      rank,
+			"Channels":  len(analytic.Channels),
+		})
+	}
+	if last30Days != "" {
+		text += "\n" + last30Days
+	}
+	if readership != "" {
+		text += "\n" + readership
+	}
+	return []*model.SlackAttachment{
+		{
+			Title: T("report.channel.title"),
+			Color: "#FF8000",
+			Text:  text,
+		},
+	}, nil
+}
+
+// executeReportCommand handle `/analytics week`, `/analytics month` and `/analytics channel ~name`
+// posting the report in the current channel
+func (p *Plugin) executeReportCommand(args *model.CommandArgs, period string, parameters []string) *model.CommandResponse {
+	if p.isPostingPaused() {
+		return ephemeralResponse(fmt.Sprintf("Analytics posting is paused until %s.", p.pausedUntil().Format("January 2, 2006")))
+	}
+
+	var attachments []*model.SlackAttachment
+	var err error
+	switch period {
+	case "week":
+		attachments, err = p.buildFilteredAttachments(p.currentAnalytic, false, nil, p.channelTranslate(args.ChannelId))
+	case "month":
+		var month *Analytic
+		if month, err = p.monthAnalytic(p.now()); err == nil {
+			attachments, err = p.buildFilteredAttachments(month, false, nil, p.channelTranslate(args.ChannelId))
+		}
+	case "channel":
+		if len(parameters) != 1 {
+			return ephemeralResponse("Usage: /analytics channel ~channel-name")
+		}
+		name := strings.TrimPrefix(parameters[0], "~")
+		channel, appErr := p.API.GetChannelByName(args.TeamId, name, false)
+		if appErr != nil || !p.API.HasPermissionToChannel(args.UserId, channel.Id, model.PERMISSION_READ_CHANNEL) {
+			return ephemeralResponse(fmt.Sprintf("Unable to find channel ~%s.", name))
+		}
+		attachments, err = p.buildChannelAttachments(p.currentAnalytic, channel.Id, p.channelTranslate(args.ChannelId))
+	}
+	if err == nil {
+		_, err = p.postAnalytics(args.ChannelId, "", attachments, nil)
+	}
+	if err != nil {
+		p.API.LogError("can't send on-demand analytics", "period", period, "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return &model.CommandResponse{}
+}
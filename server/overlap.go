@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const maxTeamPairsToDisplay = 10
+
+// teamPair is two team ids sorted, with the number of users active in both
+type teamPair struct {
+	first  string
+	second string
+	users  int64
+}
+
+// usersTeams return teams where each user posted, from messages by channel and user id (formatted as channelID:userID)
+// channels without team (direct and group messages) are ignored
+func usersTeams(channelsUsers map[string]int64, channelsTeam map[string]string) map[string]map[string]bool {
+	teams := make(map[string]map[string]bool)
+	for key, nb := range channelsUsers {
+		v := strings.SplitN(key, ":", 2)
+		if len(v) != 2 || nb == 0 || channelsTeam[v[0]] == "" {
+			continue
+		}
+		if _, ok := teams[v[1]]; !ok {
+			teams[v[1]] = make(map[string]bool)
+		}
+		teams[v[1]][channelsTeam[v[0]]] = true
+	}
+	return teams
+}
+
+// teamPairs count users active in both teams of each pair of teams, sorted by number of users
+func teamPairs(usersTeams map[string]map[string]bool) []*teamPair {
+	counts := make(map[[2]string]int64)
+	for _, teams := range usersTeams {
+		ids := make([]string, 0, len(teams))
+		for teamID := range teams {
+			ids = append(ids, teamID)
+		}
+		sort.Strings(ids)
+		for i := range ids {
+			for j := i + 1; j < len(ids); j++ {
+				counts[[2]string{ids[i], ids[j]}]++
+			}
+		}
+	}
+	pairs := make([]*teamPair, 0, len(counts))
+	for key, nb := range counts {
+		pairs = append(pairs, &teamPair{first: key[0], second: key[1], users: nb})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].users == pairs[j].users {
+			return pairs[i].first+pairs[i].second < pairs[j].first+pairs[j].second
+		}
+		return pairs[i].users > pairs[j].users
+	})
+	return pairs
+}
+
+// executeOverlapCommand handle `/analytics overlap`, users active in several teams during the last 30 days
+func (p *Plugin) executeOverlapCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can see cross-team activity.")
+	}
+	month, err := p.monthAnalytic(p.now())
+	if err != nil {
+		p.API.LogError("can't get last 30 days analytics", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+
+	channelsTeam := make(map[string]string)
+	for channelID := range month.Channels {
+		channel, appErr := p.API.GetChannel(channelID)
+		if appErr != nil {
+			p.API.LogWarn("can't get channel, skip it", "channel", channelID, "err", appErr.Error())
+			continue
+		}
+		channelsTeam[channelID] = channel.TeamId
+	}
+	teamsName := make(map[string]string)
+	teamName := func(teamID string) string {
+		if _, ok := teamsName[teamID]; !ok {
+			teamsName[teamID] = teamID
+			if team, appErr := p.API.GetTeam(teamID); appErr == nil {
+				teamsName[teamID] = team.DisplayName
+			}
+		}
+		return teamsName[teamID]
+	}
+
+	T := p.channelTranslate(args.ChannelId)
+	users := usersTeams(month.ChannelsUsers, channelsTeam)
+	if len(users) == 0 {
+		return ephemeralResponse(T("report.overlap.no_activity"))
+	}
+	activeUsers := make(map[string]int64)
+	crossTeamUsers := make(map[string]int64)
+	nbCrossTeam := 0
+	for _, teams := range users {
+		for teamID := range teams {
+			activeUsers[teamID]++
+			if len(teams) > 1 {
+				crossTeamUsers[teamID]++
+			}
+		}
+		if len(teams) > 1 {
+			nbCrossTeam++
+		}
+	}
+
+	text := T("report.overlap.title") + "\n"
+	text += T("report.overlap.summary", map[string]interface{}{"CrossTeam": nbCrossTeam, "Users": len(users), "Percent": nbCrossTeam * 100 / len(users)}) + "\n\n"
+	text += T("report.overlap.columns") + "\n|:--|--:|--:|\n"
+	for _, team := range topCounters(activeUsers, len(activeUsers)) {
+		text += fmt.Sprintf("| %s | %d | %d *(%d%%)* |\n", teamName(team.key), team.nb, crossTeamUsers[team.key], crossTeamUsers[team.key]*100/team.nb)
+	}
+	if pairs := teamPairs(users); len(pairs) > 0 {
+		text += "\n" + T("report.overlap.pairs.title") + "\n" + T("report.overlap.pairs.columns") + "\n|:--|--:|\n"
+		for index, pair := range pairs {
+			if index == maxTeamPairsToDisplay {
+				break
+			}
+			text += fmt.Sprintf("| %s & %s | %d |\n", teamName(pair.first), teamName(pair.second), pair.users)
+		}
+	}
+	return ephemeralResponse(text)
+}
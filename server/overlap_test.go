@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTeamPairs(t *testing.T) {
+	assert := assert.New(t)
+	channelsUsers := map[string]int64{
+		channelUserKey("channel1", "user1"): 3,
+		channelUserKey("channel2", "user1"): 1,
+		channelUserKey("channel3", "user1"): 1,
+		channelUserKey("channel1", "user2"): 2,
+		channelUserKey("channel2", "user2"): 2,
+		channelUserKey("dm", "user3"):       5,
+	}
+	channelsTeam := map[string]string{"channel1": "teamA", "channel2": "teamB", "channel3": "teamC", "dm": ""}
+
+	users := usersTeams(channelsUsers, channelsTeam)
+	assert.Equal(map[string]map[string]bool{
+		"user1": {"teamA": true, "teamB": true, "teamC": true},
+		"user2": {"teamA": true, "teamB": true},
+	}, users)
+
+	pairs := teamPairs(users)
+	assert.Len(pairs, 3)
+	assert.Equal(&teamPair{first: "teamA", second: "teamB", users: 2}, pairs[0])
+	assert.Equal(&teamPair{first: "teamA", second: "teamC", users: 1}, pairs[1])
+	assert.Equal(&teamPair{first: "teamB", second: "teamC", users: 1}, pairs[2])
+}
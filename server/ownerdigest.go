@@ -0,0 +1,150 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	digestDeliveryCentral   = "central"
+	digestDeliveryInChannel = "in-channel"
+	digestDeliveryDM        = "dm"
+	digestDeliveryAll       = "all"
+
+	// channelMembersPerPage is the page size when listing members of a channel for its admins
+	channelMembersPerPage = 200
+)
+
+// isDigestDelivery return true if value is a DigestDelivery, empty is central
+func isDigestDelivery(value string) bool {
+	switch value {
+	case "", digestDeliveryCentral, digestDeliveryInChannel, digestDeliveryDM, digestDeliveryAll:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliversCentral return true if scheduled digests are posted in report channels
+func (c *configuration) deliversCentral() bool {
+	return c.DigestDelivery == "" || c.DigestDelivery == digestDeliveryCentral || c.DigestDelivery == digestDeliveryAll
+}
+
+// deliversInChannel return true if each active channel gets its own digest posted in it
+func (c *configuration) deliversInChannel() bool {
+	return c.DigestDelivery == digestDeliveryInChannel || c.DigestDelivery == digestDeliveryAll
+}
+
+// deliversDM return true if admins of each active channel receive its digest by DM
+func (c *configuration) deliversDM() bool {
+	return c.DigestDelivery == digestDeliveryDM || c.DigestDelivery == digestDeliveryAll
+}
+
+// ownerDigestChannels return channels of the analytic getting their own digest, report channels already receiving the
+// full digest are left out
+// caller must hold the read lock of the analytic
+func ownerDigestChannels(analytic *Analytic, reportChannelsID []string) []string {
+	reported := make(map[string]bool, len(reportChannelsID))
+	for _, channelID := range reportChannelsID {
+		reported[channelID] = true
+	}
+	channelsID := make([]string, 0, len(analytic.Channels))
+	for _, channel := range topCounters(analytic.Channels, len(analytic.Channels)) {
+		if !reported[channel.key] {
+			channelsID = append(channelsID, channel.key)
+		}
+	}
+	return channelsID
+}
+
+// channelAdmins return ids of the admins of channelID, bots excluded
+func (p *Plugin) channelAdmins(channelID string) ([]string, error) {
+	admins := make([]string, 0)
+	for page := 0; ; page++ {
+		members, appErr := p.API.GetChannelMembers(channelID, page, channelMembersPerPage)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't get members of channel "+channelID)
+		}
+		if members == nil {
+			break
+		}
+		for _, member := range *members {
+			if member.SchemeAdmin && member.UserId != p.BotUserID && !p.isBot(member.UserId) {
+				admins = append(admins, member.UserId)
+			}
+		}
+		if len(*members) < channelMembersPerPage {
+			break
+		}
+	}
+	return admins, nil
+}
+
+// sendOwnerDigests deliver to each active channel the digest of its own analytics according to DigestDelivery, posted
+// in the channel and/or sent by DM to its channel admins, users named in delivered digests are recorded in named
+func (p *Plugin) sendOwnerDigests(analytic *Analytic, reportChannelsID []string, period string, named *namedUsers) error {
+	config := p.getConfiguration()
+	if !config.deliversInChannel() && !config.deliversDM() {
+		return nil
+	}
+	if config.CanaryMode {
+		p.API.LogInfo("canary mode is enabled, skip digests of channel owners")
+		return nil
+	}
+	analytic.RLock()
+	channelsID := ownerDigestChannels(analytic, reportChannelsID)
+	start := analytic.Start
+	analytic.RUnlock()
+	shrink := p.shouldShrinkDigest()
+
+	limiter := time.NewTicker(time.Second / reportsPerSecond)
+	defer limiter.Stop()
+	return runBounded(channelsID, reportWorkers, limiter.C, func(channelID string) error {
+		include := func(id string) bool { return id == channelID }
+		T := p.channelTranslate(channelID)
+		attachments, err := p.buildFilteredAttachments(analytic, shrink, include, T)
+		if err != nil {
+			return errors.Wrap(err, "can't build analytics attachments of channel "+channelID)
+		}
+		if config.deliversInChannel() {
+			if err := p.postChannelReport(channelID, period, attachments, nil); err != nil {
+				return err
+			}
+			named.addAttachments(channelID, attachments, nil)
+		}
+		if !config.deliversDM() {
+			return nil
+		}
+		admins, err := p.channelAdmins(channelID)
+		if err != nil {
+			return err
+		}
+		_, displayName, _, err := p.getChannelName(channelID)
+		if err != nil {
+			return err
+		}
+		for _, userID := range admins {
+			direct, appErr := p.API.GetDirectChannel(p.BotUserID, userID)
+			if appErr != nil {
+				p.API.LogWarn("can't get direct channel of channel admin, skip it", "user", userID, "err", appErr.Error())
+				continue
+			}
+			post := &model.Post{
+				UserId:    p.BotUserID,
+				ChannelId: direct.Id,
+				Message:   T("report.owner_digest", map[string]interface{}{"Channel": displayName, "Date": start.Format(T("report.date_layout"))}),
+				Props: map[string]interface{}{
+					"attachments": attachments,
+				},
+			}
+			if _, appErr := p.API.CreatePost(post); appErr != nil {
+				p.API.LogWarn("can't send digest to channel admin", "user", userID, "channel", channelID, "err", appErr.Error())
+				continue
+			}
+			named.addAttachments(channelID, attachments, nil)
+		}
+		return nil
+	})
+}
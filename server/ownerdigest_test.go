@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestDelivery(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, test := range []struct {
+		delivery  string
+		central   bool
+		inChannel bool
+		dm        bool
+	}{
+		{"", true, false, false},
+		{digestDeliveryCentral, true, false, false},
+		{digestDeliveryInChannel, false, true, false},
+		{digestDeliveryDM, false, false, true},
+		{digestDeliveryAll, true, true, true},
+	} {
+		config := &configuration{DigestDelivery: test.delivery}
+		assert.True(isDigestDelivery(test.delivery), test.delivery)
+		assert.Equal(test.central, config.deliversCentral(), test.delivery)
+		assert.Equal(test.inChannel, config.deliversInChannel(), test.delivery)
+		assert.Equal(test.dm, config.deliversDM(), test.delivery)
+	}
+	assert.False(isDigestDelivery("email"))
+}
+
+func TestOwnerDigestChannels(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	analytic.Channels = map[string]int64{"channel1": 2, "channel2": 5, "report": 1}
+
+	assert.Equal([]string{"channel2", "channel1"}, ownerDigestChannels(analytic, []string{"report"}))
+	assert.Equal([]string{"channel2", "channel1", "report"}, ownerDigestChannels(analytic, nil))
+}
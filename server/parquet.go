@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// parquetMagic start and end parquet files
+	parquetMagic       = "PAR1"
+	parquetContentType = "application/vnd.apache.parquet"
+)
+
+// parquet physical types, converted types and codec, see parquet.thrift of apache/parquet-format
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetNoConvertedType = -1
+	parquetUTF8            = 0
+	parquetDate            = 6
+
+	parquetRequired      = 0
+	parquetPlain         = 0
+	parquetRLE           = 3
+	parquetDataPage      = 0
+	parquetCodecGzip     = 2
+	parquetFormatVersion = 1
+)
+
+// thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encode structs with the thrift compact protocol, the one of parquet metadata
+type thriftWriter struct {
+	buf bytes.Buffer
+	// lastField is the id of the last field of each struct being written, the innermost last
+	lastField []int16
+}
+
+func newThriftWriter() *thriftWriter {
+	return &thriftWriter{lastField: []int16{0}}
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	last := &t.lastField[len(t.lastField)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.zigzag(int64(id))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.rawBinary(v)
+}
+
+func (t *thriftWriter) rawBinary(v string) {
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+// list write the header of a list of size elements of kind, elements are written after it without field header
+func (t *thriftWriter) list(id int16, kind byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | kind)
+		return
+	}
+	t.buf.WriteByte(0xf0 | kind)
+	t.varint(uint64(size))
+}
+
+// beginStruct start a struct field, or a struct element of a list when id is 0
+func (t *thriftWriter) beginStruct(id int16) {
+	if id != 0 {
+		t.field(id, thriftStruct)
+	}
+	t.lastField = append(t.lastField, 0)
+}
+
+func (t *thriftWriter) endStruct() {
+	t.buf.WriteByte(0)
+	t.lastField = t.lastField[:len(t.lastField)-1]
+}
+
+// parquetColumn is a required column of a parquet file, with its plain encoded values
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+	values        bytes.Buffer
+}
+
+func (c *parquetColumn) appendInt32(v int32) {
+	binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) appendInt64(v int64) {
+	binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) appendByteArray(v string) {
+	binary.Write(&c.values, binary.LittleEndian, uint32(len(v)))
+	c.values.WriteString(v)
+}
+
+// parquetDays return the number of days between the unix epoch and the day of date, the parquet date type
+func parquetDays(date time.Time) int32 {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return int32(day.Unix() / (24 * 60 * 60))
+}
+
+// exportParquetColumns return the columns of rows, same as the columns of csv exports
+func exportParquetColumns(rows []exportRow) []*parquetColumn {
+	columns := []*parquetColumn{
+		{name: exportHeader[0], physicalType: parquetInt32, convertedType: parquetDate},
+		{name: exportHeader[1], physicalType: parquetByteArray, convertedType: parquetUTF8},
+		{name: exportHeader[2], physicalType: parquetByteArray, convertedType: parquetUTF8},
+		{name: exportHeader[3], physicalType: parquetByteArray, convertedType: parquetUTF8},
+		{name: exportHeader[4], physicalType: parquetByteArray, convertedType: parquetUTF8},
+		{name: exportHeader[5], physicalType: parquetInt64, convertedType: parquetNoConvertedType},
+		{name: exportHeader[6], physicalType: parquetInt64, convertedType: parquetNoConvertedType},
+		{name: exportHeader[7], physicalType: parquetInt64, convertedType: parquetNoConvertedType},
+	}
+	for _, row := range rows {
+		columns[0].appendInt32(parquetDays(row.Date))
+		columns[1].appendByteArray(row.ChannelID)
+		columns[2].appendByteArray(row.ChannelName)
+		columns[3].appendByteArray(row.UserID)
+		columns[4].appendByteArray(row.Username)
+		columns[5].appendInt64(row.Messages)
+		columns[6].appendInt64(row.Replies)
+		columns[7].appendInt64(row.FilesSize)
+	}
+	return columns
+}
+
+// writeExportParquet write rows as a parquet file of one row group, each column in one gzipped data page
+func writeExportParquet(w io.Writer, rows []exportRow) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+	columns := exportParquetColumns(rows)
+	type chunk struct {
+		offset           int64
+		uncompressedSize int64
+		compressedSize   int64
+	}
+	chunks := make([]chunk, 0, len(columns))
+	for _, column := range columns {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		if _, err := writer.Write(column.values.Bytes()); err != nil {
+			return errors.Wrap(err, "can't compress column "+column.name)
+		}
+		if err := writer.Close(); err != nil {
+			return errors.Wrap(err, "can't compress column "+column.name)
+		}
+
+		header := newThriftWriter()
+		header.i32(1, parquetDataPage)
+		header.i32(2, int32(column.values.Len()))
+		header.i32(3, int32(compressed.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(len(rows)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.buf.WriteByte(0)
+
+		chunks = append(chunks, chunk{
+			offset:           int64(file.Len()),
+			uncompressedSize: int64(header.buf.Len() + column.values.Len()),
+			compressedSize:   int64(header.buf.Len() + compressed.Len()),
+		})
+		file.Write(header.buf.Bytes())
+		file.Write(compressed.Bytes())
+	}
+
+	metadata := newThriftWriter()
+	metadata.i32(1, parquetFormatVersion)
+	metadata.list(2, thriftStruct, len(columns)+1)
+	metadata.beginStruct(0)
+	metadata.binary(4, "schema")
+	metadata.i32(5, int32(len(columns)))
+	metadata.endStruct()
+	for _, column := range columns {
+		metadata.beginStruct(0)
+		metadata.i32(1, column.physicalType)
+		metadata.i32(3, parquetRequired)
+		metadata.binary(4, column.name)
+		if column.convertedType != parquetNoConvertedType {
+			metadata.i32(6, column.convertedType)
+		}
+		metadata.endStruct()
+	}
+	metadata.i64(3, int64(len(rows)))
+	metadata.list(4, thriftStruct, 1)
+	metadata.beginStruct(0)
+	metadata.list(1, thriftStruct, len(columns))
+	var totalSize int64
+	for index, column := range columns {
+		metadata.beginStruct(0)
+		metadata.i64(2, chunks[index].offset)
+		metadata.beginStruct(3)
+		metadata.i32(1, column.physicalType)
+		metadata.list(2, thriftI32, 1)
+		metadata.zigzag(parquetPlain)
+		metadata.list(3, thriftBinary, 1)
+		metadata.rawBinary(column.name)
+		metadata.i32(4, parquetCodecGzip)
+		metadata.i64(5, int64(len(rows)))
+		metadata.i64(6, chunks[index].uncompressedSize)
+		metadata.i64(7, chunks[index].compressedSize)
+		metadata.i64(9, chunks[index].offset)
+		metadata.endStruct()
+		metadata.endStruct()
+		totalSize += chunks[index].uncompressedSize
+	}
+	metadata.i64(2, totalSize)
+	metadata.i64(3, int64(len(rows)))
+	metadata.endStruct()
+	metadata.binary(6, "mattermost-plugin-analytics")
+	metadata.buf.WriteByte(0)
+
+	file.Write(metadata.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(metadata.buf.Len()))
+	file.WriteString(parquetMagic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
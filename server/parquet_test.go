@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThriftWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	writer := newThriftWriter()
+	writer.i32(1, 1)
+	writer.i64(3, -2)
+	writer.binary(20, "ab")
+	writer.list(21, thriftI32, 1)
+	writer.zigzag(3)
+	writer.beginStruct(22)
+	writer.i32(1, 0)
+	writer.endStruct()
+	writer.buf.WriteByte(0)
+	assert.Equal([]byte{
+		0x15, 0x02, // field 1 i32 1
+		0x26, 0x03, // field 3 i64 -2
+		0x08, 0x28, 0x02, 'a', 'b', // field 20 binary with long form id
+		0x19, 0x15, 0x06, // field 21 list of one i32 3
+		0x1c, 0x15, 0x00, 0x00, // field 22 struct of field 1 i32 0
+		0x00,
+	}, writer.buf.Bytes())
+}
+
+func TestParquetDays(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(int32(0), parquetDays(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(int32(18337), parquetDays(time.Date(2020, 3, 16, 23, 0, 0, 0, time.FixedZone("UTC-10", -10*60*60))))
+}
+
+func TestWriteExportParquet(t *testing.T) {
+	assert := assert.New(t)
+
+	var content bytes.Buffer
+	rows := []exportRow{
+		{Date: time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local), ChannelID: "channel1", ChannelName: "town-square", Messages: 3, Replies: 1},
+		{Date: time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local), UserID: "user1", Username: "alice", Messages: 3},
+	}
+	assert.Nil(writeExportParquet(&content, rows))
+	file := content.Bytes()
+	assert.Equal(parquetMagic, string(file[:4]))
+	assert.Equal(parquetMagic, string(file[len(file)-4:]))
+	length := int(binary.LittleEndian.Uint32(file[len(file)-8 : len(file)-4]))
+	metadata := file[len(file)-8-length : len(file)-8]
+	// the footer is a thrift struct starting with the format version and ending with created_by
+	assert.Equal([]byte{0x15, 0x02}, metadata[:2])
+	assert.True(bytes.HasSuffix(metadata, []byte("mattermost-plugin-analytics\x00")))
+	assert.True(bytes.Contains(metadata, []byte("files_size")))
+
+	columns := exportParquetColumns(rows)
+	assert.Len(columns, len(exportHeader))
+	assert.Equal([]byte{0x08, 0, 0, 0, 'c', 'h', 'a', 'n', 'n', 'e', 'l', '1', 0, 0, 0, 0}, columns[1].values.Bytes())
+	assert.Equal([]byte{3, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0, 0, 0, 0, 0, 0}, columns[5].values.Bytes())
+}
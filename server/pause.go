@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const pausedUntilKey = "paused_until"
+
+// pausedUntil return the date until which posting is paused, time.Zero if not paused
+func (p *Plugin) pausedUntil() time.Time {
+	j, err := p.API.KVGet(pausedUntilKey)
+	if err != nil {
+		p.API.LogError("can't get pause date from kv", "err", err.Error())
+		return time.Time{}
+	}
+	var until time.Time
+	if j == nil {
+		return until
+	}
+	if err := until.UnmarshalText(j); err != nil {
+		p.API.LogError("can't parse pause date", "err", err.Error())
+		return time.Time{}
+	}
+	return until
+}
+
+// isPostingPaused return true if an admin paused all analytics posting
+// collection continues while paused
+func (p *Plugin) isPostingPaused() bool {
+	return p.now().Before(p.pausedUntil())
+}
+
+// pausePosting pause all analytics posting until the given date
+func (p *Plugin) pausePosting(userID string, until time.Time) error {
+	j, err := until.MarshalText()
+	if err != nil {
+		return errors.Wrap(err, "can't marshal pause date")
+	}
+	if err := p.API.KVSet(pausedUntilKey, j); err != nil {
+		return errors.Wrap(err, "can't save pause date")
+	}
+	p.audit("posting_paused", userID, map[string]string{"until": until.Format(time.RFC3339)})
+	return nil
+}
+
+// resumePosting cancel a pause of analytics posting
+func (p *Plugin) resumePosting(userID string) error {
+	if err := p.API.KVDelete(pausedUntilKey); err != nil {
+		return errors.Wrap(err, "can't delete pause date")
+	}
+	p.audit("posting_resumed", userID, nil)
+	return nil
+}
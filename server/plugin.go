@@ -5,7 +5,9 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/mattermost/go-i18n/i18n/bundle"
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/mattermost/mattermost-server/v5/plugin"
 	"github.com/pkg/errors"
@@ -30,8 +32,114 @@ type Plugin struct {
 
 	cron *Cron
 
-	BotUserID  string
+	scheduler *Scheduler
+
+	// clock is the wall clock unless a test travels in time
+	clock Clock
+
+	// translations of reports, loaded from the plugin bundle on activation
+	translations *bundle.Bundle
+
+	// journal is disabled until JournalDirectory is configured
+	journal Journal
+
+	BotUserID string
+	// botProfile is the profile last applied to the bot, it's applied again only when the configuration changes it
+	botProfileLock sync.Mutex
+	botProfile     botProfile
+
 	ChannelsID []string
+	// ReportRoutes are channels receiving only analytics of some teams or channels, by channel id
+	ReportRoutes map[string]*reportRoute
+	// CanaryChannelID is the sandbox channel receiving every digest when canary mode is on
+	CanaryChannelID string
+	// RolloutTeamsID is the set of teams explicitly enabled during a progressive rollout
+	RolloutTeamsID map[string]bool
+	// ExcludedChannelsID is the set of channels never collected
+	ExcludedChannelsID map[string]bool
+	// IncludedChannelsID is the set of the only collected channels, all channels are collected when empty
+	IncludedChannelsID map[string]bool
+	// CriticalChannelsID is the set of channels which must have daily activity
+	CriticalChannelsID map[string]bool
+	// ChannelLanguages are languages of report channels overriding Language, by channel id
+	ChannelLanguages map[string]string
+	// GlossaryChannelsID is the set of report channels whose digests end with a glossary of their metrics
+	GlossaryChannelsID map[string]bool
+	// DeliveryWindows are the times of day report channels accept reports, by channel id
+	DeliveryWindows map[string]*deliveryWindow
+	// AnomalyChannelID is the channel receiving anomaly alerts, system admins receive them by DM when empty
+	AnomalyChannelID string
+	// SurveyChannelsID are channels where pulse surveys are posted
+	SurveyChannelsID []string
+
+	channelTeamsLock sync.RWMutex
+	channelTeams     map[string]string
+
+	consentsLock sync.Mutex
+	consents     map[string]*ChannelConsent
+
+	auditLock sync.Mutex
+
+	feedbackLock sync.Mutex
+
+	surveysLock sync.Mutex
+
+	purgesLock sync.Mutex
+
+	pendingReportsLock sync.Mutex
+
+	// elasticsearchEvents are events waiting for the next flush to elasticsearch, the oldest ones are dropped beyond
+	// maxElasticsearchEvents and counted in elasticsearchDropped
+	elasticsearchEventsLock sync.Mutex
+	elasticsearchEvents     []JournalEvent
+	elasticsearchDropped    int
+
+	backfillLock    sync.Mutex
+	backfillRunning *BackfillProgress
+
+	trackedSinceLock sync.Mutex
+	trackedSince     map[string]time.Time
+
+	collectorsLock sync.Mutex
+	collectors     map[string]*collectorStats
+
+	externalCollectorsLock sync.Mutex
+
+	deletedBucketsLock sync.Mutex
+
+	// dailyStore persist daily buckets in the configured StorageBackend, the kv store when nil
+	dailyStoreLock sync.RWMutex
+	dailyStore     dailyStore
+	// dailyStoreSwitching is the backend buckets are being copied to, dailyStoreGeneration stop the copy when it changes
+	dailyStoreSwitching  string
+	dailyStoreGeneration int
+
+	// writeBuffer are increments of daily buckets waiting for the next flush, by daily key
+	writeBufferLock    sync.Mutex
+	writeBuffer        map[string]*dailyBucket
+	writeBufferEvents  int
+	writeBufferFlushed time.Time
+	writeBufferStop    chan struct{}
+
+	// accumulatorEvents count events applied to the current session, guarded by its write lock
+	accumulatorEvents int64
+	spillLock         sync.Mutex
+	// spilling is set while a spill of the current session runs, only one runs at a time
+	spilling int32
+
+	sharesLock  sync.Mutex
+	lineageLock sync.Mutex
+
+	// nodeID identify this node in high availability mode, leader is true while it holds the leader lease
+	nodeID     string
+	leaderLock sync.Mutex
+	leader     bool
+	// clusterDelta are events counted by this node since its last merge in the shared session, nil outside of a cluster
+	clusterDelta *Analytic
+
+	metricsLock           sync.Mutex
+	metricsReactionsStats *ReactionStats
+	metricsReactionsAt    time.Time
 }
 
 // CommandTrigger is the string used by user to interact with this plugin
@@ -47,7 +155,67 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		}, nil
 	}
 
-	if err := p.sendAnalytics([]string{args.ChannelId}); err != nil {
+	fields := strings.Fields(args.Command)
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "help":
+			return ephemeralResponse(commandHelp), nil
+		case "week", "month", "channel":
+			return p.executeReportCommand(args, fields[1], fields[2:]), nil
+		case "me":
+			return p.executeMeCommand(args, fields[2:]), nil
+		case "share":
+			return p.executeShareCommand(args, fields[2:]), nil
+		case "preview":
+			return p.executePreviewCommand(args, fields[2:]), nil
+		case "pause":
+			return p.executePauseCommand(args, fields[2:]), nil
+		case "resume":
+			return p.executeResumeCommand(args), nil
+		case "diagnostics":
+			return p.executeDiagnosticsCommand(args, fields[2:]), nil
+		case "feedback":
+			return p.executeFeedbackCommand(args), nil
+		case "quarterly":
+			return p.executeQuarterlyCommand(args), nil
+		case "chargeback":
+			return p.executeChargebackCommand(args), nil
+		case "seats":
+			return p.executeSeatsCommand(args), nil
+		case "capacity":
+			return p.executeCapacityCommand(args), nil
+		case "overlap":
+			return p.executeOverlapCommand(args), nil
+		case "leaderboard":
+			return p.executeLeaderboardCommand(args, fields[2:]), nil
+		case "status":
+			return p.executeStatusCommand(args), nil
+		case "debug":
+			return p.executeDebugCommand(args, fields[2:]), nil
+		case "simulate":
+			return p.executeSimulateCommand(args, fields[2:]), nil
+		case "backfill":
+			return p.executeBackfillCommand(args, fields[2:]), nil
+		case "lineage":
+			return p.executeLineageCommand(args, fields[2:]), nil
+		case "audit-report":
+			return p.executeAuditReportCommand(args, fields[2:]), nil
+		case "export":
+			return p.executeExportCommand(args, fields[2:]), nil
+		case "forget":
+			return p.executeForgetCommand(args, fields[2:]), nil
+		case "purge":
+			return p.executePurgeCommand(args, fields[2:]), nil
+		default:
+			return ephemeralResponse(fmt.Sprintf("Unknown subcommand %s.\n\n%s", fields[1], commandHelp)), nil
+		}
+	}
+
+	if p.isPostingPaused() {
+		return ephemeralResponse(fmt.Sprintf("Analytics posting is paused until %s.", p.pausedUntil().Format("January 2, 2006"))), nil
+	}
+
+	if err := p.sendAnalytics(p.currentAnalytic, []string{args.ChannelId}); err != nil {
 		p.API.LogError("can't send analytics", "err", err.Error())
 		return &model.CommandResponse{
 			ResponseType: model.COMMAND_RESPONSE_TYPE_EPHEMERAL,
@@ -67,6 +235,8 @@ type analyticsData struct {
 	link        string
 	nb          int64
 	reply       int64
+	// partialSince is set when data is collected only since this date, after the start of the period
+	partialSince time.Time
 }
 
 type preparedData struct {
@@ -76,17 +246,19 @@ type preparedData struct {
 	channels             []analyticsData
 }
 
-func (p *Plugin) prepareData() (*preparedData, error) {
-	p.currentAnalytic.RLock()
-	defer p.currentAnalytic.RUnlock()
+func (p *Plugin) prepareData(analytic *Analytic) (*preparedData, error) {
+	analytic.RLock()
+	defer analytic.RUnlock()
 
 	totalMessagesPublic := int64(0)
 	totalMessagesPrivate := int64(0)
 	users := make([]analyticsData, 0)
 	channels := make([]analyticsData, 0)
-	channels = append(channels, analyticsData{id: "none", name: dmOrPrivateChannelName, displayName: dmOrPrivateChannelName, link: "", nb: 0, reply: 0})
+	// direct messages are only counted in aggregate, previous sessions may still have them by channel
+	totalMessagesPrivate += analytic.DirectMessages
+	channels = append(channels, analyticsData{id: "none", name: dmOrPrivateChannelName, displayName: dmOrPrivateChannelName, link: "", nb: analytic.DirectMessages, reply: 0})
 
-	for key, nb := range p.currentAnalytic.Channels {
+	for key, nb := range analytic.Channels {
 		channelName, channelDisplayName, link, err := p.getChannelName(key)
 		if err != nil {
 			return nil, err
@@ -96,24 +268,24 @@ func (p *Plugin) prepareData() (*preparedData, error) {
 			channels[0].nb = channels[0].nb + nb
 		} else {
 			totalMessagesPublic += nb
-			channels = p.updateOrAppend(channels, analyticsData{id: key, displayName: channelDisplayName, name: channelName, link: link, nb: nb, reply: 0})
+			channels = p.updateOrAppend(channels, analyticsData{id: key, displayName: channelDisplayName, name: channelName, link: link, nb: nb, reply: 0, partialSince: p.partialSince(key, analytic.Start)})
 		}
 	}
-	for key, nb := range p.currentAnalytic.ChannelsReply {
+	for key, nb := range analytic.ChannelsReply {
 		channelName, channelDisplayName, link, err := p.getChannelName(key)
 		if err != nil {
 			return nil, err
 		}
 		channels = p.updateOrAppend(channels, analyticsData{id: key, displayName: channelDisplayName, name: channelName, link: link, nb: 0, reply: nb})
 	}
-	for key, nb := range p.currentAnalytic.Users {
+	for key, nb := range analytic.Users {
 		displayKey, err := p.getUsername(key)
 		if err != nil {
 			return nil, err
 		}
 		users = p.updateOrAppend(users, analyticsData{id: key, displayName: displayKey, name: displayKey, nb: nb, reply: 0})
 	}
-	for key, nb := range p.currentAnalytic.UsersReply {
+	for key, nb := range analytic.UsersReply {
 		displayKey, err := p.getUsername(key)
 		if err != nil {
 			return nil, err
@@ -150,6 +322,9 @@ func (p *Plugin) updateOrAppend(originals []analyticsData, upsert analyticsData)
 		if upsert.reply == 0 {
 			upsert.reply = original.reply
 		}
+		if upsert.partialSince.IsZero() {
+			upsert.partialSince = original.partialSince
+		}
 	}
 	return append(originals, upsert)
 }
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/mattermost/mattermost-server/v5/model"
 	"github.com/pkg/errors"
@@ -13,28 +15,196 @@ const (
 	maxUsersToDisplay    = 10
 )
 
-func (p *Plugin) buildAnalyticAttachments() ([]*model.SlackAttachment, error) {
+// buildAnalyticAttachments build the report, a shrinked report has no charts
+func (p *Plugin) buildAnalyticAttachments(analytic *Analytic, shrink bool) ([]*model.SlackAttachment, error) {
+	return p.buildFilteredAttachments(analytic, shrink, nil, p.translate())
+}
+
+// buildFilteredAttachments build the report of channels accepted by include, of all channels if include is nil, translated by T
+func (p *Plugin) buildFilteredAttachments(analytic *Analytic, shrink bool, include func(channelID string) bool, T translateFunc) ([]*model.SlackAttachment, error) {
+	if include != nil {
+		analytic = filterAnalytic(analytic, include)
+	}
 	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+	rtl := p.digestRTL()
+	analytic.RLock()
+	// a closed session is reported as it was on its end
+	asOf := analytic.End
+	analytic.RUnlock()
+	if asOf.IsZero() {
+		asOf = p.now()
+	}
 
-	data, err := p.prepareData()
+	data, err := p.prepareData(analytic)
 	if err != nil {
 		return nil, err
 	}
 
-	p.currentAnalytic.RLock()
-	text := fmt.Sprintf("## Analytics since %s, at %s.\n", p.currentAnalytic.Start.Format("January 2, 2006"), p.currentAnalytic.Start.Format("15:04"))
-	p.currentAnalytic.RUnlock()
-	if data.totalMessagesPublic+data.totalMessagesPrivate > 0 {
-		text += fmt.Sprintf("#### **%d users** sent **%d messages** in **%d channels**. **%d** *(%d%%)* of the messages were in public channels, **%d** *(%d%%)* in private.\n", len(data.users), data.totalMessagesPublic+data.totalMessagesPrivate, len(data.channels), data.totalMessagesPublic, (data.totalMessagesPublic*100)/(data.totalMessagesPublic+data.totalMessagesPrivate), data.totalMessagesPrivate, (data.totalMessagesPrivate*100)/(data.totalMessagesPublic+data.totalMessagesPrivate))
-		text += fmt.Sprintf("#### Moreover, **%d files** were sent for a total uppload size of **%s**.\n", p.currentAnalytic.FilesNb, byteCountDecimal(p.currentAnalytic.FilesSize))
+	// reactions, readership, health and highlights are collected before locking the analytic, collectors lock it too
+	reactions := ""
+	readership := ""
+	health := ""
+	highlights := ""
+	var previous *periodTotals
+	var stats *ReactionStats
+	var interactions *InteractionStats
+	// anonymous mode omits leaderboards and per user statistics
+	anonymous := p.getConfiguration().AnonymousMode
+	leaderboardSize := p.getConfiguration().LeaderboardSize
+	if anonymous {
+		leaderboardSize = 0
+	}
+	if !shrink {
+		var errReactions error
+		if stats, errReactions = p.collectReactions(analytic); errReactions != nil {
+			p.API.LogWarn("can't collect reactions", "err", errReactions.Error())
+		} else {
+			if anonymous {
+				stats.Users = make(map[string]int64)
+				stats.ChannelsUsers = make(map[string]int64)
+			}
+			reactions = p.getReactionsDescription(*siteURL, stats, T)
+		}
+		if readerships, errReadership := p.collectReadership(analytic); errReadership != nil {
+			p.API.LogWarn("can't collect readership", "err", errReadership.Error())
+		} else {
+			readership = p.getReadershipDescription(readerships, T)
+		}
+		if current, previous, errHealth := p.collectChannelsHealth(analytic, include); errHealth != nil {
+			p.API.LogWarn("can't collect channels health", "err", errHealth.Error())
+		} else {
+			health = p.getHealthDescription(current, previous, T)
+		}
+		var errHighlights error
+		if highlights, errHighlights = p.getHighlightsDescription(analytic, include, T); errHighlights != nil {
+			p.API.LogWarn("can't get notable changes", "err", errHighlights.Error())
+		}
+		var errPrevious error
+		if previous, errPrevious = p.collectPreviousTotals(analytic, asOf, include); errPrevious != nil {
+			p.API.LogWarn("can't collect totals of the previous period", "err", errPrevious.Error())
+		}
+		if leaderboardSize > 0 {
+			var errInteractions error
+			if interactions, errInteractions = p.collectInteractions(analytic); errInteractions != nil {
+				p.API.LogWarn("can't collect interactions", "err", errInteractions.Error())
+			}
+		}
+	}
+
+	current := analyticTotals(analytic)
+	threshold := p.getConfiguration().deltaThreshold()
+	change := func(metric func(periodTotals) int64) string {
+		return changeNote(current, previous, metric, threshold, T)
 	}
 
-	fields := append(getUsersFields(*siteURL, data), getChannelsFields(*siteURL, data)...)
-	sessions, err := p.getSessionsFields(*siteURL)
-	if err != nil {
-		return nil, err
+	analytic.RLock()
+	defer analytic.RUnlock()
+	text := T("report.header", map[string]interface{}{
+		"Date": analytic.Start.Format(T("report.date_layout")),
+		"Time": analytic.Start.Format("15:04"),
+	}) + "\n"
+	if total := data.totalMessagesPublic + data.totalMessagesPrivate; total > 0 {
+		messages := "report.messages"
+		if anonymous {
+			messages = "report.messages_anonymous"
+		}
+		text += T(messages, map[string]interface{}{
+			"Users":          len(data.users),
+			"Messages":       total,
+			"Channels":       len(data.channels),
+			"Public":         data.totalMessagesPublic,
+			"PublicPercent":  (data.totalMessagesPublic * 100) / total,
+			"Private":        data.totalMessagesPrivate,
+			"PrivatePercent": (data.totalMessagesPrivate * 100) / total,
+			"UsersChange":    change(func(t periodTotals) int64 { return t.Users }),
+			"MessagesChange": change(func(t periodTotals) int64 { return t.Messages }),
+			"ChannelsChange": change(func(t periodTotals) int64 { return t.Channels }),
+		}) + "\n"
+		text += T("report.files", map[string]interface{}{
+			"Files":       analytic.FilesNb,
+			"Size":        byteCountDecimal(analytic.FilesSize),
+			"FilesChange": change(func(t periodTotals) int64 { return t.Files }),
+			"SizeChange":  change(func(t periodTotals) int64 { return t.FilesSize }),
+		}) + "\n"
+		if churn := getChurnDescription(analytic.ChannelsEdits, analytic.ChannelsDeletions, T); churn != "" {
+			text += churn
+		}
+		if scripts := getScriptsDescription(analytic.Scripts, T); scripts != "" {
+			text += scripts
+		}
+	}
+
+	var fields []*model.SlackAttachmentField
+	if shrink {
+		if !anonymous {
+			fields = append(fields, &model.SlackAttachmentField{Short: true, Value: getUsersDescription(data, T)})
+		}
+		fields = append(fields, &model.SlackAttachmentField{Short: true, Value: getChannelsDescription(data, T)})
+	} else {
+		if highlights != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: false, Value: highlights})
+		}
+		if !anonymous {
+			fields = append(fields, getUsersFields(*siteURL, data, rtl, T)...)
+		}
+		fields = append(fields, getChannelsFields(*siteURL, data, rtl, T)...)
+		sessions, err := p.getSessionsFields(*siteURL, rtl, asOf, include)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, sessions...)
+		if threads := p.getThreadsDescription(*siteURL, analytic, T); threads != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: true, Value: threads})
+		}
+		if mentions := p.getMentionsDescription(analytic, T); mentions != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: true, Value: mentions})
+		}
+		// thresholds are validated with the configuration, a bad value falls back to the default ones
+		thresholds, _ := parseLengthThresholds(p.getConfiguration().MessageLengthThresholds)
+		if lengths := getLengthsDescription(analytic.Lengths, thresholds, T); lengths != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: true, Value: lengths})
+		}
+		if responses, err := p.getResponseTimesDescription(analytic.Start, asOf, include, T); err != nil {
+			p.API.LogWarn("can't get response times", "err", err.Error())
+		} else if responses != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: true, Value: responses})
+		}
+		if heatmap := formatHeatmap(hourlyHeatmap(analytic.Hourly), p.getConfiguration().calendar().weekStart, T); heatmap != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: false, Value: heatmap})
+		}
+		if reactions != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: false, Value: reactions})
+		}
+		if roles := p.getRolesDescription(analytic, stats, T); roles != "" && !anonymous {
+			fields = append(fields, &model.SlackAttachmentField{Short: true, Value: roles})
+		}
+		if readership != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: false, Value: readership})
+		}
+		if membership, err := p.getMembershipDescription(analytic.Start, asOf, include, T); err != nil {
+			p.API.LogWarn("can't get membership changes", "err", err.Error())
+		} else if membership != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: true, Value: membership})
+		}
+		if health != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: false, Value: health})
+		}
+		if surveys, err := p.getSurveysDescription(analytic.Start, asOf, include, T); err != nil {
+			p.API.LogWarn("can't get pulse surveys", "err", err.Error())
+		} else if surveys != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: false, Value: surveys})
+		}
+		if collectors, err := p.getExternalCollectors(); err != nil {
+			p.API.LogWarn("can't get external collectors", "err", err.Error())
+		} else if external := getExternalDescription(analytic.External, collectors, T); external != "" {
+			fields = append(fields, &model.SlackAttachmentField{Short: true, Value: external})
+		}
+		if leaderboardSize > 0 {
+			if leaderboard := p.getLeaderboardDescription(boardsCounts(analytic, stats, interactions), boards, leaderboardSize, T); leaderboard != "" {
+				fields = append(fields, &model.SlackAttachmentField{Short: false, Value: leaderboard})
+			}
+		}
 	}
-	fields = append(fields, sessions...)
 
 	attachments := make([]*model.SlackAttachment, 1)
 	attachments[0] = &model.SlackAttachment{
@@ -42,46 +212,70 @@ func (p *Plugin) buildAnalyticAttachments() ([]*model.SlackAttachment, error) {
 		Text:   text,
 		Fields: fields,
 	}
+	if rtl {
+		rtlAttachments(attachments)
+	}
 
 	return attachments, nil
 }
 
-func (p *Plugin) sendAnalytics(ChannelsID []string) error {
-	attachments, err := p.buildAnalyticAttachments()
+func (p *Plugin) sendAnalytics(analytic *Analytic, ChannelsID []string) error {
+	attachments, err := p.buildAnalyticAttachments(analytic, false)
 	if err != nil {
 		return errors.Wrap(err, "can't build analytics attachments")
 	}
 	for _, channelID := range ChannelsID {
-		post := &model.Post{
-			UserId:    p.BotUserID,
-			ChannelId: channelID,
-			Props: map[string]interface{}{
-				"from_webhook":      "true",
-				"override_username": p.getConfiguration().BotUsername,
-				"override_icon_url": p.getConfiguration().BotIconURL,
-				"attachments":       attachments,
-			},
-		}
-
-		if _, err := p.API.CreatePost(post); err != nil {
-			return errors.Wrap(err, "can't post mesage")
+		if _, err := p.postAnalytics(channelID, "", attachments, nil); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func getUsersFields(siteURL string, data *preparedData) []*model.SlackAttachmentField {
-	m := "### Top Users\n"
-	if len(data.users) > 0 {
-		m = m + fmt.Sprintf("* :1st_place_medal: @%s: **%d** messages *(%d%% of total)* with %d replies.\n", data.users[0].name, data.users[0].nb, getPercentComparingToPublicMessages(data, data.users[0]), data.users[0].reply)
+func (p *Plugin) postAnalytics(channelID string, rootID string, attachments []*model.SlackAttachment, fileIDs []string) (*model.Post, error) {
+	post := &model.Post{
+		UserId:    p.BotUserID,
+		ChannelId: channelID,
+		RootId:    rootID,
+		FileIds:   fileIDs,
+		Props: map[string]interface{}{
+			"attachments": attachments,
+		},
 	}
-	if len(data.users) > 1 {
-		m = m + fmt.Sprintf("* :2nd_place_medal: @%s: **%d** messages *(%d%% of total)* with %d replies.\n", data.users[1].name, data.users[1].nb, getPercentComparingToPublicMessages(data, data.users[1]), data.users[1].reply)
+
+	created, err := p.API.CreatePost(post)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't post mesage")
+	}
+	return created, nil
+}
+
+// reportChannels return channels where scheduled digests must be posted
+// in canary mode all digests go to the sandbox channel only
+func (p *Plugin) reportChannels() []string {
+	if p.getConfiguration().CanaryMode && p.CanaryChannelID != "" {
+		return []string{p.CanaryChannelID}
 	}
-	if len(data.users) > 2 {
-		m = m + fmt.Sprintf("* :3rd_place_medal: @%s: **%d** messages *(%d%% of total)* with %d replies.\n", data.users[2].name, data.users[2].nb, getPercentComparingToPublicMessages(data, data.users[2]), data.users[2].reply)
+	channelsID := make([]string, 0, len(p.ChannelsID)+len(p.ReportRoutes))
+	for _, channelID := range p.ChannelsID {
+		if p.isChannelEnabled(channelID) {
+			channelsID = append(channelsID, channelID)
+		}
 	}
+	for channelID := range p.ReportRoutes {
+		if p.isChannelEnabled(channelID) {
+			channelsID = append(channelsID, channelID)
+		}
+	}
+	return channelsID
+}
+
+// medals are prefixes of the first lines of top users and channels
+var medals = []string{":1st_place_medal:", ":2nd_place_medal:", ":3rd_place_medal:"}
+
+func getUsersFields(siteURL string, data *preparedData, rtl bool, T translateFunc) []*model.SlackAttachmentField {
+	m := getUsersDescription(data, T)
 	urlChart, _ := url.Parse(siteURL + "/plugins/com.github.manland.mattermost-plugin-analytics/pie.svg")
 	parametersURL := url.Values{}
 	for index, c := range data.users {
@@ -90,21 +284,29 @@ func getUsersFields(siteURL string, data *preparedData) []*model.SlackAttachment
 		}
 		parametersURL.Add(c.displayName, fmt.Sprintf("%d", c.nb))
 	}
+	addRTLChartParameter(parametersURL, rtl)
 	urlChart.RawQuery = parametersURL.Encode()
 	return buildSlackAttachmentField(m, "users pie chart", urlChart)
 }
 
-func getChannelsFields(siteURL string, data *preparedData) []*model.SlackAttachmentField {
-	m := "### Top Channels\n"
-	if len(data.channels) > 0 {
-		m = m + fmt.Sprintf("* :1st_place_medal: %s: **%d** messages *(%d%% of total)* with %d replies.\n", getChannelLink(data.channels[0]), data.channels[0].nb, getPercentComparingToAllMessages(data, data.channels[0]), data.channels[0].reply)
-	}
-	if len(data.channels) > 1 {
-		m = m + fmt.Sprintf("* :2nd_place_medal: %s: **%d** messages *(%d%% of total)* with %d replies.\n", getChannelLink(data.channels[1]), data.channels[1].nb, getPercentComparingToAllMessages(data, data.channels[1]), data.channels[1].reply)
-	}
-	if len(data.channels) > 2 {
-		m = m + fmt.Sprintf("* :3rd_place_medal: %s: **%d** messages *(%d%% of total)* with %d replies.\n", getChannelLink(data.channels[2]), data.channels[2].nb, getPercentComparingToAllMessages(data, data.channels[2]), data.channels[2].reply)
+func getUsersDescription(data *preparedData, T translateFunc) string {
+	m := sectionTitle(T, "report.top_users.title")
+	for index, user := range data.users {
+		if index == len(medals) {
+			break
+		}
+		m += fmt.Sprintf("* %s %s\n", medals[index], T("report.top_users.line", map[string]interface{}{
+			"Username": user.name,
+			"Messages": user.nb,
+			"Percent":  getPercentComparingToPublicMessages(data, user),
+			"Replies":  user.reply,
+		}))
 	}
+	return m
+}
+
+func getChannelsFields(siteURL string, data *preparedData, rtl bool, T translateFunc) []*model.SlackAttachmentField {
+	m := getChannelsDescription(data, T)
 	urlChart, _ := url.Parse(siteURL + "/plugins/com.github.manland.mattermost-plugin-analytics/pie.svg")
 	parametersURL := url.Values{}
 	for index, c := range data.channels {
@@ -113,25 +315,65 @@ func getChannelsFields(siteURL string, data *preparedData) []*model.SlackAttachm
 		}
 		parametersURL.Add(c.displayName, fmt.Sprintf("%d", c.nb))
 	}
+	addRTLChartParameter(parametersURL, rtl)
 	urlChart.RawQuery = parametersURL.Encode()
 	return buildSlackAttachmentField(m, "channels pie chart", urlChart)
 }
 
-func (p *Plugin) getSessionsFields(siteURL string) ([]*model.SlackAttachmentField, error) {
-	allSessions, _ := p.allSessions()
+func getChannelsDescription(data *preparedData, T translateFunc) string {
+	m := sectionTitle(T, "report.top_channels.title")
+	for index, channel := range data.channels {
+		if index == len(medals) {
+			break
+		}
+		m += fmt.Sprintf("* %s %s\n", medals[index], T("report.top_channels.line", map[string]interface{}{
+			"Channel":  getChannelLink(channel),
+			"Messages": channel.nb,
+			"Percent":  getPercentComparingToAllMessages(data, channel),
+			"Replies":  channel.reply,
+			"Partial":  partialNote(channel.partialSince, T),
+		}))
+	}
+	return m
+}
+
+// getSessionsFields chart all sessions started before asOf, with channels accepted by include or all if include is nil
+func (p *Plugin) getSessionsFields(siteURL string, rtl bool, asOf time.Time, include func(channelID string) bool) ([]*model.SlackAttachmentField, error) {
+	allSessions := make([]*Analytic, 0)
+	sessions, _ := p.allSessions()
+	for _, session := range sessions {
+		if !session.Start.Before(asOf) {
+			continue
+		}
+		if include != nil {
+			session = filterAnalytic(session, include)
+		}
+		allSessions = append(allSessions, session)
+	}
 	urlChart, _ := url.Parse(siteURL + "/plugins/com.github.manland.mattermost-plugin-analytics/line.svg")
 	parametersURL := url.Values{}
-	allChannels := make(map[string]bool, 0)
+	// merged channels are charted as part of the channel they continue in
+	lineage, err := p.channelLineage()
+	if err != nil {
+		return nil, err
+	}
+	sessionsChannels := make([]map[string]int64, 0, len(allSessions))
 	for _, session := range allSessions {
-		for key := range session.Channels {
+		session.RLock()
+		sessionsChannels = append(sessionsChannels, stitchCounters(session.Channels, lineage))
+		session.RUnlock()
+	}
+	allChannels := make(map[string]bool, 0)
+	for _, channels := range sessionsChannels {
+		for key := range channels {
 			allChannels[key] = true
 		}
 	}
-	for _, session := range allSessions {
+	for index, session := range allSessions {
 		for key := range allChannels {
 			allChannels[key] = false //init with not found
 		}
-		for key, value := range session.Channels {
+		for key, value := range sessionsChannels[index] {
 			displayKey, err := p.getChannelDisplayName(key)
 			if err != nil {
 				return nil, err
@@ -150,6 +392,7 @@ func (p *Plugin) getSessionsFields(siteURL string) ([]*model.SlackAttachmentFiel
 		}
 		parametersURL.Add("date", fmt.Sprintf("%d", session.Start.Unix()))
 	}
+	addRTLChartParameter(parametersURL, rtl)
 	urlChart.RawQuery = parametersURL.Encode()
 	return buildSlackAttachmentField("", "all sessions line chart", urlChart), nil
 }
@@ -181,3 +424,35 @@ func buildSlackAttachmentField(description string, chartTitle string, chartURL *
 	},
 	)
 }
+
+// getChurnDescription render the number of edits and deleted messages, empty without any
+func getChurnDescription(edits map[string]int64, deletions map[string]int64, T translateFunc) string {
+	total := func(counters map[string]int64) int64 {
+		sum := int64(0)
+		for _, nb := range counters {
+			sum += nb
+		}
+		return sum
+	}
+	nbEdits, nbDeletions := total(edits), total(deletions)
+	if nbEdits == 0 && nbDeletions == 0 {
+		return ""
+	}
+	return T("report.churn", map[string]interface{}{"Edits": nbEdits, "Deletions": nbDeletions}) + "\n"
+}
+
+// getScriptsDescription render the share of messages by writing system, empty if all messages use the same one
+func getScriptsDescription(scripts map[string]int64, T translateFunc) string {
+	if len(scripts) < 2 {
+		return ""
+	}
+	total := int64(0)
+	for _, nb := range scripts {
+		total += nb
+	}
+	shares := make([]string, 0, len(scripts))
+	for _, script := range topCounters(scripts, len(scripts)) {
+		shares = append(shares, fmt.Sprintf("%s *(%d%%)*", script.key, script.nb*100/total))
+	}
+	return T("report.scripts", map[string]interface{}{"Scripts": strings.Join(shares, ", ")}) + "\n"
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// nextReportAt return when the next scheduled report is posted after now, on the ReportSchedule or every week
+func nextReportAt(config *configuration, now time.Time) (time.Time, error) {
+	spec := config.ReportSchedule
+	if strings.TrimSpace(spec) == "" {
+		spec = config.calendar().weeklyCronSpec()
+	}
+	schedules, err := parseReportSchedule(spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var next time.Time
+	for _, schedule := range schedules {
+		if at := schedule.Next(now.In(config.getLocation())); next.IsZero() || at.Before(next) {
+			next = at
+		}
+	}
+	return next, nil
+}
+
+// previewTargets describe the channels where the next report is posted, with their route and language
+func (p *Plugin) previewTargets() string {
+	channelsID := p.reportChannels()
+	if len(channelsID) == 0 {
+		return "* No report channel, check the Report channels setting.\n"
+	}
+	text := ""
+	for _, channelID := range channelsID {
+		name, displayName, _, err := p.getChannelName(channelID)
+		if err != nil {
+			text += fmt.Sprintf("* %s: can't find channel\n", channelID)
+			continue
+		}
+		text += fmt.Sprintf("* ~%s (%s)", name, displayName)
+		if route, ok := p.ReportRoutes[channelID]; ok {
+			text += fmt.Sprintf(", only analytics of %d teams and %d channels", len(route.TeamsID), len(route.ChannelsID))
+		}
+		if language, ok := p.ChannelLanguages[channelID]; ok && language != p.getConfiguration().Language {
+			text += ", translated in " + language
+		}
+		if _, ok := p.DeliveryWindows[channelID]; ok {
+			text += ", held until its delivery window"
+		}
+		text += "\n"
+	}
+	return text
+}
+
+// executePreviewCommand handle `/analytics preview [week|month]`, the next scheduled report is sent only to the admin
+func (p *Plugin) executePreviewCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can preview reports.")
+	}
+	period := "week"
+	if len(parameters) > 0 {
+		period = parameters[0]
+	}
+	if len(parameters) > 1 || (period != "week" && period != "month") {
+		return ephemeralResponse("Usage: /analytics preview [week|month]")
+	}
+
+	analytic := p.currentAnalytic
+	if period == "month" {
+		month, err := p.monthAnalytic(p.now())
+		if err != nil {
+			p.API.LogError("can't merge sessions of the month", "err", err.Error())
+			return ephemeralResponse("An error occured!")
+		}
+		analytic = month
+	}
+	shrink := p.shouldShrinkDigest()
+	attachments, err := p.buildAnalyticAttachments(analytic, shrink)
+	if err != nil {
+		p.API.LogError("can't build analytics attachments", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+
+	config := p.getConfiguration()
+	text := "#### Report preview\nOnly you can see this preview, nothing is posted.\n"
+	if next, err := nextReportAt(config, p.now()); err == nil {
+		text += fmt.Sprintf("* Next report on %s.\n", next.Format("January 2, 2006 15:04 MST"))
+	}
+	if p.isPostingPaused() {
+		text += fmt.Sprintf("* Posting is paused until %s, the next report is skipped.\n", p.pausedUntil().Format("January 2, 2006"))
+	}
+	if config.CanaryMode {
+		text += "* Canary mode is enabled, the report is posted only in the canary channel.\n"
+	}
+	if shrink {
+		text += "* The report is shrunk, recent digests were not found useful.\n"
+	}
+	if config.AttachChartImages && !shrink {
+		text += "* Chart images are attached to the report, they are not shown in this preview.\n"
+	}
+	if config.deliversInChannel() {
+		text += "* Each active channel also gets the report of its own analytics.\n"
+	}
+	if config.deliversDM() {
+		text += "* Admins of each active channel also receive the report of its analytics by direct message.\n"
+	}
+	if config.deliversCentral() {
+		text += "\nThe report will be posted in:\n" + p.previewTargets()
+	}
+
+	p.API.SendEphemeralPost(args.UserId, &model.Post{
+		UserId:    p.BotUserID,
+		ChannelId: args.ChannelId,
+		Message:   text,
+		Props: map[string]interface{}{
+			"attachments": attachments,
+		},
+	})
+	return &model.CommandResponse{}
+}
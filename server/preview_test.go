@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNextReportAt(t *testing.T) {
+	assert := assert.New(t)
+	sunday := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+
+	next, err := nextReportAt(&configuration{ReportSchedule: "0 9 * * MON; 0 18 * * SUN", location: time.UTC}, sunday)
+	assert.Nil(err)
+	assert.Equal(time.Date(2019, time.April, 21, 18, 0, 0, 0, time.UTC), next)
+
+	next, err = nextReportAt(&configuration{WeekStart: "monday", location: time.UTC}, sunday)
+	assert.Nil(err)
+	assert.Equal(time.Date(2019, time.April, 22, 0, 0, 0, 0, time.UTC), next)
+
+	_, err = nextReportAt(&configuration{ReportSchedule: "0 9 * MON"}, sunday)
+	assert.NotNil(err)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"strings"
+)
+
+const pprofPathPrefix = "/debug/pprof/"
+
+// RuntimeStats are memory and scheduling stats of the plugin process
+type RuntimeStats struct {
+	Goroutines     int    `json:"goroutines"`
+	HeapAllocBytes uint64 `json:"heap_alloc_bytes"`
+	HeapInuseBytes uint64 `json:"heap_inuse_bytes"`
+	HeapObjects    uint64 `json:"heap_objects"`
+	SysBytes       uint64 `json:"sys_bytes"`
+	NumGC          uint32 `json:"num_gc"`
+	PauseTotalNs   uint64 `json:"pause_total_ns"`
+	// AccumulatorBytes is the estimated memory of the counters of the current session
+	AccumulatorBytes int64 `json:"accumulator_bytes"`
+}
+
+// authorizeProfiling return true if profiling is enabled and the user is a system admin, otherwise write the error
+// profiling is reported as not found when disabled so the endpoints are not discoverable
+func (p *Plugin) authorizeProfiling(w http.ResponseWriter, r *http.Request) bool {
+	if !p.getConfiguration().EnableProfiling {
+		http.NotFound(w, r)
+		return false
+	}
+	return p.authorizeAPI(w, r)
+}
+
+// handlePprof serve `GET /debug/pprof/` and its profiles from net/http/pprof
+func (p *Plugin) handlePprof(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeProfiling(w, r) {
+		return nil
+	}
+	switch strings.TrimPrefix(r.URL.Path, pprofPathPrefix) {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		// the index also serves named profiles, e.g. heap or goroutine
+		pprof.Index(w, r)
+	}
+	return nil
+}
+
+// handleRuntimeStats serve `GET /api/v1/runtime`, memory stats of the plugin process
+func (p *Plugin) handleRuntimeStats(w http.ResponseWriter, r *http.Request) error {
+	if !p.authorizeProfiling(w, r) {
+		return nil
+	}
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	p.currentAnalytic.RLock()
+	accumulator := p.currentAnalytic.estimatedSize()
+	p.currentAnalytic.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(&RuntimeStats{
+		Goroutines:       runtime.NumGoroutine(),
+		HeapAllocBytes:   memStats.HeapAlloc,
+		HeapInuseBytes:   memStats.HeapInuse,
+		HeapObjects:      memStats.HeapObjects,
+		SysBytes:         memStats.Sys,
+		NumGC:            memStats.NumGC,
+		PauseTotalNs:     memStats.PauseTotalNs,
+		AccumulatorBytes: accumulator,
+	})
+}
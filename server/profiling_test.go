@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfilingDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	p := &Plugin{}
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/api/v1/runtime"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Mattermost-User-Id", "admin")
+		if path == "/api/v1/runtime" {
+			assert.Nil(p.handleRuntimeStats(w, r))
+		} else {
+			assert.Nil(p.handlePprof(w, r))
+		}
+		assert.Equal(http.StatusNotFound, w.Code, path)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// tombstoneKeyPrefix prefix keys of purged daily buckets kept until the end of the grace period
+	tombstoneKeyPrefix = "tombstone:"
+	purgesKey          = "purges"
+)
+
+// Purge is a deletion of the daily buckets of a range of days, restorable until ExpireAt
+type Purge struct {
+	UserID   string
+	From     string
+	To       string
+	PurgedAt time.Time
+	ExpireAt time.Time
+	// Keys are the purged daily buckets, their values are kept in tombstone keys
+	Keys []string
+}
+
+// inDailyRange return true if key is a daily bucket of a day between from and to included, in form YYYY-MM-DD
+func inDailyRange(key string, from string, to string) bool {
+	if !strings.HasPrefix(key, dailyKeyPrefix) {
+		return false
+	}
+	day := strings.SplitN(strings.TrimPrefix(key, dailyKeyPrefix), ":", 2)[0]
+	if _, err := time.Parse(dailyKeyFormat, day); err != nil {
+		return false
+	}
+	return day >= from && day <= to
+}
+
+// restorablePurges return purges whose grace period is not over at now, oldest first
+func restorablePurges(purges []*Purge, now time.Time) []*Purge {
+	restorable := make([]*Purge, 0, len(purges))
+	for _, purge := range purges {
+		if now.Before(purge.ExpireAt) {
+			restorable = append(restorable, purge)
+		}
+	}
+	return restorable
+}
+
+// purgeRange delete daily buckets of days between from and to included, they are moved to tombstone keys
+// for PurgeGraceDays days so the purge can be undone, a grace period of 0 delete them for good
+func (p *Plugin) purgeRange(userID string, from string, to string) (*Purge, error) {
+	now := p.now()
+	graceDays := p.getConfiguration().PurgeGraceDays
+	purge := &Purge{UserID: userID, From: from, To: to, PurgedAt: now, ExpireAt: now.AddDate(0, 0, graceDays), Keys: make([]string, 0)}
+
+	location := p.getConfiguration().getLocation()
+	first, err := time.ParseInLocation(dailyKeyFormat, from, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad purge start")
+	}
+	last, err := time.ParseInLocation(dailyKeyFormat, to, location)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad purge end")
+	}
+	if err := p.flushWriteBuffer(); err != nil {
+		return nil, err
+	}
+	// list all buckets before deleting, deleting while listing would shift pages
+	buckets, err := p.store().list(allScopes, first, last)
+	if err != nil {
+		return nil, err
+	}
+	for _, bucket := range buckets {
+		purge.Keys = append(purge.Keys, dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+	}
+
+	// the purge is recorded first so an interrupted purge can still be undone
+	if graceDays > 0 && len(purge.Keys) > 0 {
+		p.purgesLock.Lock()
+		purges := make([]*Purge, 0)
+		err := p.kvGetJSON(purgesKey, &purges)
+		if err == nil {
+			err = p.kvSetJSON(purgesKey, append(restorablePurges(purges, now), purge))
+		}
+		p.purgesLock.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	for index, bucket := range buckets {
+		if graceDays > 0 {
+			key := purge.Keys[index]
+			value, err := json.Marshal(bucket.Counters)
+			if err != nil {
+				return nil, errors.Wrap(err, "can't marshal "+key)
+			}
+			if appErr := p.API.KVSetWithExpiry(tombstoneKeyPrefix+key, value, int64(purge.ExpireAt.Sub(now)/time.Second)); appErr != nil {
+				return nil, errors.Wrap(appErr, "can't save tombstone of "+key)
+			}
+		}
+		if err := p.store().delete(buckets[index : index+1]); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.recordDeletedBuckets(purge.Keys, now); err != nil {
+		p.API.LogWarn("can't record deleted buckets for delta syncs", "err", err.Error())
+	}
+	p.audit("range_purged", userID, map[string]string{"from": from, "to": to, "keys": strconv.Itoa(len(purge.Keys))})
+	return purge, nil
+}
+
+// undoPurge restore daily buckets of the last purge still in its grace period, buckets written again since the purge
+// are kept, return the undone purge and the number of restored buckets, nil if there is no purge to undo
+func (p *Plugin) undoPurge(userID string) (*Purge, int, error) {
+	p.purgesLock.Lock()
+	defer p.purgesLock.Unlock()
+	purges := make([]*Purge, 0)
+	if err := p.kvGetJSON(purgesKey, &purges); err != nil {
+		return nil, 0, err
+	}
+	purges = restorablePurges(purges, p.now())
+	if len(purges) == 0 {
+		return nil, 0, nil
+	}
+	purge := purges[len(purges)-1]
+
+	restored := 0
+	for _, key := range purge.Keys {
+		value, appErr := p.API.KVGet(tombstoneKeyPrefix + key)
+		if appErr != nil {
+			return nil, restored, errors.Wrap(appErr, "can't get tombstone of "+key)
+		}
+		bucket, ok := parseDailyKey(key, p.getConfiguration().getLocation())
+		if value == nil || !ok {
+			continue
+		}
+		if err := json.Unmarshal(value, &bucket.Counters); err != nil {
+			return nil, restored, errors.Wrap(err, "can't unmarshal tombstone of "+key)
+		}
+		// a restored bucket is a change for delta syncs
+		bucket.Counters.UpdatedAt = changeStamp(p.now())
+		saved, err := p.store().restore(bucket)
+		if err != nil {
+			return nil, restored, err
+		}
+		if saved {
+			restored++
+		}
+		if appErr := p.API.KVDelete(tombstoneKeyPrefix + key); appErr != nil {
+			return nil, restored, errors.Wrap(appErr, "can't delete tombstone of "+key)
+		}
+	}
+	if err := p.kvSetJSON(purgesKey, purges[:len(purges)-1]); err != nil {
+		return nil, restored, err
+	}
+	p.audit("purge_undone", userID, map[string]string{"from": purge.From, "to": purge.To, "keys": strconv.Itoa(restored)})
+	return purge, restored, nil
+}
+
+// executePurgeCommand handle `/analytics purge YYYY-MM-DD YYYY-MM-DD` and `/analytics purge undo`
+func (p *Plugin) executePurgeCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can purge analytics.")
+	}
+	if len(parameters) == 1 && parameters[0] == "undo" {
+		purge, restored, err := p.undoPurge(args.UserId)
+		if err != nil {
+			p.API.LogError("can't undo purge", "err", err.Error())
+			return ephemeralResponse("An error occured!")
+		}
+		if purge == nil {
+			return ephemeralResponse("No purge to undo.")
+		}
+		return ephemeralResponse(fmt.Sprintf("Purge of %s to %s undone, %d daily buckets restored.", purge.From, purge.To, restored))
+	}
+	if len(parameters) != 2 {
+		return ephemeralResponse("Usage: /analytics purge YYYY-MM-DD YYYY-MM-DD or /analytics purge undo")
+	}
+	for _, parameter := range parameters {
+		if _, err := time.Parse(dailyKeyFormat, parameter); err != nil {
+			return ephemeralResponse(fmt.Sprintf("Bad date %s, expected YYYY-MM-DD.", parameter))
+		}
+	}
+	if parameters[0] > parameters[1] {
+		return ephemeralResponse(fmt.Sprintf("Bad range, %s is after %s.", parameters[0], parameters[1]))
+	}
+	purge, err := p.purgeRange(args.UserId, parameters[0], parameters[1])
+	if err != nil {
+		p.API.LogError("can't purge analytics", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	text := fmt.Sprintf("%d daily buckets from %s to %s purged.", len(purge.Keys), purge.From, purge.To)
+	if graceDays := p.getConfiguration().PurgeGraceDays; graceDays > 0 && len(purge.Keys) > 0 {
+		text += fmt.Sprintf(" Use `/analytics purge undo` to restore them until %s.", purge.ExpireAt.Format("January 2, 2006"))
+	}
+	return ephemeralResponse(text)
+}
+
+// forgetPurgedKeys remove daily buckets of userID from purges still in their grace period
+func (p *Plugin) forgetPurgedKeys(userID string) error {
+	p.purgesLock.Lock()
+	defer p.purgesLock.Unlock()
+	purges := make([]*Purge, 0)
+	if err := p.kvGetJSON(purgesKey, &purges); err != nil || len(purges) == 0 {
+		return err
+	}
+	for _, purge := range purges {
+		keys := make([]string, 0, len(purge.Keys))
+		for _, key := range purge.Keys {
+			if !isUserDailyKey(key, userID) {
+				keys = append(keys, key)
+			}
+		}
+		purge.Keys = keys
+	}
+	return p.kvSetJSON(purgesKey, purges)
+}
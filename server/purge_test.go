@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInDailyRange(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(inDailyRange("analytics:2019-05-01:c:channel1", "2019-05-01", "2019-05-31"))
+	assert.True(inDailyRange("analytics:2019-05-31:u:user1", "2019-05-01", "2019-05-31"))
+	assert.False(inDailyRange("analytics:2019-06-01:c:channel1", "2019-05-01", "2019-05-31"))
+	assert.False(inDailyRange("analytics:2019-04-30:c:channel1", "2019-05-01", "2019-05-31"))
+	assert.False(inDailyRange("tombstone:analytics:2019-05-02:c:channel1", "2019-05-01", "2019-05-31"))
+	assert.False(inDailyRange("analytics", "2019-05-01", "2019-05-31"))
+}
+
+func TestRestorablePurges(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2019, time.May, 10, 12, 0, 0, 0, time.UTC)
+	expired := &Purge{From: "2019-04-01", To: "2019-04-02", ExpireAt: now.Add(-time.Hour)}
+	restorable := &Purge{From: "2019-04-03", To: "2019-04-04", ExpireAt: now.Add(time.Hour)}
+	assert.Equal([]*Purge{restorable}, restorablePurges([]*Purge{expired, restorable}, now))
+	assert.Empty(restorablePurges(nil, now))
+}
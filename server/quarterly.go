@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// quarterTotals is the rollup of all sessions started in a fiscal quarter
+type quarterTotals struct {
+	Period    string
+	Messages  int64
+	Replies   int64
+	Users     int64
+	Channels  int64
+	FilesNb   int64
+	FilesSize int64
+}
+
+// quarterlyRow is a line of the quarterly report comparing a metric to previous quarter and previous year
+type quarterlyRow struct {
+	Name     string
+	Value    string
+	QoQ      string
+	YoY      string
+	Previous string
+	LastYear string
+}
+
+var quarterlyTemplate = template.Must(template.New("quarterly").Parse(`<!DOCTYPE html>
+<html dir="{{.Dir}}">
+<head>
+<meta charset="utf-8">
+<title>Analytics {{.Period}}</title>
+<style>
+body { font-family: sans-serif; margin: 40px; color: #333; }
+h1 { color: #FF8000; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 8px; text-align: end; }
+th:first-child, td:first-child { text-align: start; }
+</style>
+</head>
+<body>
+<h1>Analytics {{.Period}}</h1>
+<p>From {{.From}} to {{.To}}, compared to {{.PreviousPeriod}} and {{.LastYearPeriod}}.</p>
+<table>
+<tr><th>Metric</th><th>{{.Period}}</th><th>{{.PreviousPeriod}}</th><th>QoQ</th><th>{{.LastYearPeriod}}</th><th>YoY</th></tr>
+{{range .Rows}}<tr><td>{{.Name}}</td><td><bdi>{{.Value}}</bdi></td><td><bdi>{{.Previous}}</bdi></td><td><bdi>{{.QoQ}}</bdi></td><td><bdi>{{.LastYear}}</bdi></td><td><bdi>{{.YoY}}</bdi></td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// rollupQuarter sum all archived sessions started during the fiscal quarter of date
+func (p *Plugin) rollupQuarter(cal calendar, date time.Time) (*quarterTotals, error) {
+	sessions, err := p.allSessions()
+	if err != nil {
+		return nil, err
+	}
+	from := cal.startOfQuarter(date)
+	to := from.AddDate(0, 3, 0)
+
+	totals := &quarterTotals{Period: cal.quarterPeriod(date)}
+	users := make(map[string]bool)
+	channels := make(map[string]bool)
+	for _, session := range sessions {
+		if session.Start.Before(from) || !session.Start.Before(to) {
+			continue
+		}
+		for key, nb := range session.Channels {
+			channels[key] = true
+			totals.Messages += nb
+		}
+		for _, nb := range session.ChannelsReply {
+			totals.Replies += nb
+		}
+		for key := range session.Users {
+			users[key] = true
+		}
+		totals.FilesNb += session.FilesNb
+		totals.FilesSize += session.FilesSize
+	}
+	totals.Users = int64(len(users))
+	totals.Channels = int64(len(channels))
+	return totals, nil
+}
+
+// deltaThreshold is the minimum evolution annotated with ▲ or ▼, both the relative and the absolute change must be reached
+type deltaThreshold struct {
+	Percent  int64
+	Absolute int64
+}
+
+// deltaThreshold return the significance threshold of evolutions
+func (c *configuration) deltaThreshold() deltaThreshold {
+	return deltaThreshold{Percent: int64(c.DeltaMinPercent), Absolute: int64(c.DeltaMinAbsolute)}
+}
+
+// percentChange return a humanized evolution between two values, e.g. ▲ 12%
+// an evolution below threshold is not annotated, e.g. +3%
+func percentChange(current int64, previous int64, threshold deltaThreshold) string {
+	if previous == 0 {
+		return "n/a"
+	}
+	change := ((current - previous) * 100) / previous
+	significant := abs(change) >= threshold.Percent && abs(current-previous) >= threshold.Absolute
+	switch {
+	case change == 0:
+		return "="
+	case !significant:
+		return fmt.Sprintf("%+d%%", change)
+	case change > 0:
+		return fmt.Sprintf("▲ %d%%", change)
+	default:
+		return fmt.Sprintf("▼ %d%%", -change)
+	}
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// buildQuarterlyReport render as html the report of the fiscal quarter of date
+func (p *Plugin) buildQuarterlyReport(date time.Time) (string, []byte, error) {
+	cal := p.getConfiguration().calendar()
+	start := cal.startOfQuarter(date)
+	current, err := p.rollupQuarter(cal, start)
+	if err != nil {
+		return "", nil, err
+	}
+	previous, err := p.rollupQuarter(cal, start.AddDate(0, -3, 0))
+	if err != nil {
+		return "", nil, err
+	}
+	lastYear, err := p.rollupQuarter(cal, start.AddDate(-1, 0, 0))
+	if err != nil {
+		return "", nil, err
+	}
+
+	threshold := p.getConfiguration().deltaThreshold()
+	row := func(name string, value func(*quarterTotals) int64, format func(int64) string) quarterlyRow {
+		return quarterlyRow{
+			Name:     name,
+			Value:    format(value(current)),
+			Previous: format(value(previous)),
+			LastYear: format(value(lastYear)),
+			QoQ:      percentChange(value(current), value(previous), threshold),
+			YoY:      percentChange(value(current), value(lastYear), threshold),
+		}
+	}
+	number := func(v int64) string { return fmt.Sprintf("%d", v) }
+
+	dir := "ltr"
+	if p.digestRTL() {
+		dir = "rtl"
+	}
+	var html bytes.Buffer
+	err = quarterlyTemplate.Execute(&html, map[string]interface{}{
+		"Dir":            dir,
+		"Period":         current.Period,
+		"PreviousPeriod": previous.Period,
+		"LastYearPeriod": lastYear.Period,
+		"From":           start.Format("January 2, 2006"),
+		"To":             start.AddDate(0, 3, -1).Format("January 2, 2006"),
+		"Rows": []quarterlyRow{
+			row("Messages", func(t *quarterTotals) int64 { return t.Messages }, number),
+			row("Replies", func(t *quarterTotals) int64 { return t.Replies }, number),
+			row("Active users", func(t *quarterTotals) int64 { return t.Users }, number),
+			row("Active channels", func(t *quarterTotals) int64 { return t.Channels }, number),
+			row("Files", func(t *quarterTotals) int64 { return t.FilesNb }, number),
+			row("Files size", func(t *quarterTotals) int64 { return t.FilesSize }, byteCountDecimal),
+		},
+	})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "can't render quarterly report")
+	}
+	return current.Period, html.Bytes(), nil
+}
+
+// sendQuarterlyReport DM the report of the fiscal quarter of date as an html file to each user
+func (p *Plugin) sendQuarterlyReport(usersID []string, date time.Time) error {
+	period, html, err := p.buildQuarterlyReport(date)
+	if err != nil {
+		return err
+	}
+	T := p.translate()
+	for _, userID := range usersID {
+		channel, appErr := p.API.GetDirectChannel(p.BotUserID, userID)
+		if appErr != nil {
+			return errors.Wrap(appErr, "can't get direct channel")
+		}
+		fileInfo, appErr := p.API.UploadFile(html, channel.Id, "analytics-"+period+".html")
+		if appErr != nil {
+			return errors.Wrap(appErr, "can't upload quarterly report")
+		}
+		post := &model.Post{
+			UserId:    p.BotUserID,
+			ChannelId: channel.Id,
+			Message:   T("report.quarterly.message", map[string]interface{}{"Period": period}),
+			FileIds:   []string{fileInfo.Id},
+		}
+		if _, appErr := p.API.CreatePost(post); appErr != nil {
+			return errors.Wrap(appErr, "can't post quarterly report")
+		}
+	}
+	return nil
+}
+
+// executiveUsers return ids of users configured to receive the quarterly report
+func (p *Plugin) executiveUsers() ([]string, error) {
+	usersID := make([]string, 0)
+	config := p.getConfiguration().ExecutiveUsernames
+	if strings.TrimSpace(config) == "" {
+		return usersID, nil
+	}
+	for _, username := range strings.Split(config, ",") {
+		user, appErr := p.API.GetUserByUsername(strings.TrimPrefix(strings.TrimSpace(username), "@"))
+		if appErr != nil {
+			return nil, fmt.Errorf("Unable to find user with configured executive username: %v", username)
+		}
+		usersID = append(usersID, user.Id)
+	}
+	return usersID, nil
+}
+
+// sendQuarterlyReportIfNeeded send the report of the previous quarter on the first day of a fiscal quarter
+func (p *Plugin) sendQuarterlyReportIfNeeded(now time.Time) error {
+	cal := p.getConfiguration().calendar()
+	if !cal.startOfQuarter(now).Equal(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())) {
+		return nil
+	}
+	if p.isPostingPaused() {
+		return nil
+	}
+	usersID, err := p.executiveUsers()
+	if err != nil || len(usersID) == 0 {
+		return err
+	}
+	claimed, err := p.claimReport(reportID("quarterly", cal.quarterPeriod(now.AddDate(0, 0, -1))))
+	if err != nil || !claimed {
+		return err
+	}
+	return p.sendQuarterlyReport(usersID, now.AddDate(0, 0, -1))
+}
+
+// executeQuarterlyCommand handle `/analytics quarterly`, sending the last completed quarter to the admin
+func (p *Plugin) executeQuarterlyCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can generate the quarterly report.")
+	}
+	cal := p.getConfiguration().calendar()
+	lastQuarter := cal.startOfQuarter(p.now()).AddDate(0, 0, -1)
+	if err := p.sendQuarterlyReport([]string{args.UserId}, lastQuarter); err != nil {
+		p.API.LogError("can't send quarterly report", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return ephemeralResponse(fmt.Sprintf("Quarterly report %s sent by direct message.", cal.quarterPeriod(lastQuarter)))
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentChange(t *testing.T) {
+	assert := assert.New(t)
+	threshold := deltaThreshold{Percent: 10, Absolute: 20}
+
+	assert.Equal("▲ 50%", percentChange(150, 100, threshold))
+	assert.Equal("▼ 25%", percentChange(300, 400, threshold))
+	assert.Equal("+5%", percentChange(105, 100, threshold), "below relative threshold")
+	assert.Equal("-50%", percentChange(5, 10, threshold), "below absolute threshold")
+	assert.Equal("=", percentChange(100, 100, threshold))
+	assert.Equal("n/a", percentChange(100, 0, threshold))
+	assert.Equal("▲ 5%", percentChange(105, 100, deltaThreshold{}))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	maxEmojisToDisplay       = 5
+	maxReactedPostsToDisplay = 3
+)
+
+// ReactionStats are reactions counted on posts of a period, by emoji, by reacting user, by channel and by post
+// mattermost 5.12 has no reaction hooks so they are collected from posts when the report is built
+type ReactionStats struct {
+	Emojis   map[string]int64
+	Users    map[string]int64
+	Channels map[string]int64
+	Posts    map[string]int64
+	// PostsChannel is the channel id of each reacted post
+	PostsChannel map[string]string
+	// ChannelsUsers store number of reactions by channel and reacting user id (formatted as channelID:userID)
+	ChannelsUsers map[string]int64
+}
+
+// counter is a key and its count, used to sort maps of counts
+type counter struct {
+	key string
+	nb  int64
+}
+
+// topCounters return the max first keys of counts sorted by count
+func topCounters(counts map[string]int64, max int) []counter {
+	sorted := make([]counter, 0, len(counts))
+	for key, nb := range counts {
+		sorted = append(sorted, counter{key: key, nb: nb})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].nb == sorted[j].nb {
+			return sorted[i].key < sorted[j].key
+		}
+		return sorted[i].nb > sorted[j].nb
+	})
+	if len(sorted) > max {
+		sorted = sorted[:max]
+	}
+	return sorted
+}
+
+// collectReactions count reactions on posts created during the analytic in its channels
+func (p *Plugin) collectReactions(analytic *Analytic) (*ReactionStats, error) {
+	analytic.RLock()
+	from := analytic.Start
+	to := analytic.End
+	channelsID := make([]string, 0, len(analytic.Channels))
+	for channelID := range analytic.Channels {
+		channelsID = append(channelsID, channelID)
+	}
+	analytic.RUnlock()
+	if to.IsZero() {
+		to = p.now()
+	}
+
+	keepVariants := p.getConfiguration().KeepEmojiVariants
+	stats := &ReactionStats{
+		Emojis:       make(map[string]int64),
+		Users:        make(map[string]int64),
+		Channels:     make(map[string]int64),
+		Posts:        make(map[string]int64),
+		PostsChannel: make(map[string]string),
+
+		ChannelsUsers: make(map[string]int64),
+	}
+	for _, channelID := range channelsID {
+		postList, appErr := p.API.GetPostsSince(channelID, from.UnixNano()/int64(time.Millisecond))
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't get posts of channel "+channelID)
+		}
+		for _, post := range postList.Posts {
+			createdAt := time.Unix(0, post.CreateAt*int64(time.Millisecond))
+			if !post.HasReactions || createdAt.Before(from) || !createdAt.Before(to) {
+				continue
+			}
+			reactions, appErr := p.API.GetReactions(post.Id)
+			if appErr != nil {
+				p.API.LogWarn("can't get reactions of post, skip it", "post", post.Id, "err", appErr.Error())
+				continue
+			}
+			for _, reaction := range reactions {
+				emoji := reaction.EmojiName
+				if !keepVariants {
+					emoji = normalizeEmoji(emoji)
+				}
+				stats.Emojis[emoji]++
+				stats.Users[reaction.UserId]++
+				stats.Channels[channelID]++
+				stats.Posts[post.Id]++
+				stats.PostsChannel[post.Id] = channelID
+				stats.ChannelsUsers[channelUserKey(channelID, reaction.UserId)]++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// getReactionsDescription render top emojis, most reactive user and most reacted posts of public channels
+func (p *Plugin) getReactionsDescription(siteURL string, stats *ReactionStats, T translateFunc) string {
+	if len(stats.Emojis) == 0 {
+		return ""
+	}
+	m := sectionTitle(T, "report.reactions.title")
+	for _, emoji := range topCounters(stats.Emojis, maxEmojisToDisplay) {
+		m += fmt.Sprintf("* :%s: **%d** reactions\n", emoji.key, emoji.nb)
+	}
+	if users := topCounters(stats.Users, 1); len(users) > 0 {
+		if username, err := p.getUsername(users[0].key); err == nil {
+			m += fmt.Sprintf("\n@%s reacted the most with **%d** reactions.\n", username, users[0].nb)
+		}
+	}
+
+	posts := ""
+	nbPosts := 0
+	for _, post := range topCounters(stats.Posts, len(stats.Posts)) {
+		if nbPosts == maxReactedPostsToDisplay {
+			break
+		}
+		link, ok := p.publicPostLink(siteURL, stats.PostsChannel[post.key], post.key)
+		if !ok {
+			continue
+		}
+		posts += fmt.Sprintf("* %s with **%d** reactions\n", link, post.nb)
+		nbPosts++
+	}
+	if posts != "" {
+		m += sectionTitle(T, "report.reactions.posts_title") + posts
+	}
+	return m
+}
+
+// publicPostLink return a markdown permalink to a post, false if the post is not in a public channel
+func (p *Plugin) publicPostLink(siteURL string, channelID string, postID string) (string, bool) {
+	channel, appErr := p.API.GetChannel(channelID)
+	if appErr != nil || channel.Type != model.CHANNEL_OPEN {
+		return "", false
+	}
+	team, appErr := p.API.GetTeam(channel.TeamId)
+	if appErr != nil {
+		return "", false
+	}
+	return fmt.Sprintf("[post in ~%s](%s/%s/pl/%s)", channel.DisplayName, siteURL, team.Name, postID), true
+}
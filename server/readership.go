@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// ChannelReadership estimate how many members of a channel read it and how many posted in it during a period
+// readers are members who viewed the channel since the start of the period, mattermost only keep the last view
+// so a member who read the channel during the period then left it is not counted
+type ChannelReadership struct {
+	ChannelID string
+	Members   int64
+	Readers   int64
+	Posters   int64
+}
+
+// channelUserKey return the key of a user in a channel in Analytic.ChannelsUsers
+func channelUserKey(channelID string, userID string) string {
+	return channelID + ":" + userID
+}
+
+// channelsPosters return the number of users who posted by channel id
+func channelsPosters(channelsUsers map[string]int64) map[string]int64 {
+	posters := make(map[string]int64)
+	for key, nb := range channelsUsers {
+		channelID := strings.SplitN(key, ":", 2)[0]
+		if nb > 0 {
+			posters[channelID]++
+		}
+	}
+	return posters
+}
+
+// lurkerRatio return the percentage of readers who didn't post
+func (r *ChannelReadership) lurkerRatio() int64 {
+	if r.Readers == 0 {
+		return 0
+	}
+	return (r.Readers - r.Posters) * 100 / r.Readers
+}
+
+// channelReadership count members of channelID and those who viewed it since
+func (p *Plugin) channelReadership(channelID string, since time.Time, posters int64) (*ChannelReadership, error) {
+	readership := &ChannelReadership{ChannelID: channelID, Posters: posters}
+	sinceMillis := since.UnixNano() / int64(time.Millisecond)
+	perPage := 200
+	for page := 0; ; page++ {
+		members, appErr := p.API.GetChannelMembers(channelID, page, perPage)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't get members of channel "+channelID)
+		}
+		for _, member := range *members {
+			readership.Members++
+			if member.LastViewedAt >= sinceMillis {
+				readership.Readers++
+			}
+		}
+		if len(*members) < perPage {
+			break
+		}
+	}
+	// posting in a channel views it, but a poster may have left since
+	if readership.Readers < readership.Posters {
+		readership.Readers = readership.Posters
+	}
+	return readership, nil
+}
+
+// collectReadership estimate readership of public channels with messages during the analytic, sorted by readers
+func (p *Plugin) collectReadership(analytic *Analytic) ([]*ChannelReadership, error) {
+	analytic.RLock()
+	since := analytic.Start
+	posters := channelsPosters(analytic.ChannelsUsers)
+	channelsID := make([]string, 0, len(analytic.Channels))
+	for channelID := range analytic.Channels {
+		channelsID = append(channelsID, channelID)
+	}
+	analytic.RUnlock()
+
+	readerships := make([]*ChannelReadership, 0, len(channelsID))
+	for _, channelID := range channelsID {
+		channel, appErr := p.API.GetChannel(channelID)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't get channel "+channelID)
+		}
+		if channel.Type != model.CHANNEL_OPEN {
+			continue
+		}
+		readership, err := p.channelReadership(channelID, since, posters[channelID])
+		if err != nil {
+			return nil, err
+		}
+		readerships = append(readerships, readership)
+	}
+	sort.Slice(readerships, func(i, j int) bool {
+		if readerships[i].Readers == readerships[j].Readers {
+			return readerships[i].ChannelID < readerships[j].ChannelID
+		}
+		return readerships[i].Readers > readerships[j].Readers
+	})
+	return readerships, nil
+}
+
+// getReadershipDescription render readers, posters and lurker ratio of the most read public channels
+func (p *Plugin) getReadershipDescription(readerships []*ChannelReadership, T translateFunc) string {
+	readers := int64(0)
+	posters := int64(0)
+	for _, readership := range readerships {
+		readers += readership.Readers
+		posters += readership.Posters
+	}
+	if readers == 0 {
+		return ""
+	}
+
+	m := sectionTitle(T, "report.readership.title")
+	m += fmt.Sprintf("**%d%%** of readers of public channels didn't post *(estimated from last views)*.\n", (readers-posters)*100/readers)
+	for index, readership := range readerships {
+		if index == maxChannelsToDisplay {
+			break
+		}
+		_, displayName, link, err := p.getChannelName(readership.ChannelID)
+		if err != nil {
+			continue
+		}
+		m += fmt.Sprintf("* [~%s](%s): **%d** readers of %d members for **%d** posters, **%d%%** lurkers\n", displayName, link, readership.Readers, readership.Members, readership.Posters, readership.lurkerRatio())
+	}
+	return m
+}
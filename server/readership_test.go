@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelsPosters(t *testing.T) {
+	assert := assert.New(t)
+	channelsUsers := map[string]int64{
+		channelUserKey("channel1", "user1"): 3,
+		channelUserKey("channel1", "user2"): 1,
+		channelUserKey("channel2", "user1"): 2,
+	}
+
+	assert.Equal(map[string]int64{"channel1": 2, "channel2": 1}, channelsPosters(channelsUsers))
+}
+
+func TestLurkerRatio(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(int64(75), (&ChannelReadership{Readers: 20, Posters: 5}).lurkerRatio())
+	assert.Equal(int64(0), (&ChannelReadership{Readers: 5, Posters: 5}).lurkerRatio())
+	assert.Equal(int64(0), (&ChannelReadership{}).lurkerRatio())
+}
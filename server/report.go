@@ -0,0 +1,167 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	reportKeyPrefix = "report_"
+
+	// reportWorkers is the number of reports generated at once by a scheduled run
+	reportWorkers = 4
+	// reportsPerSecond limit the number of reports started per second by all workers
+	reportsPerSecond = 5
+)
+
+// reportID return a deterministic id for the report of a scope (e.g. a channel) for a period
+func reportID(scope string, period string) string {
+	return scope + "_" + period
+}
+
+// claimReport atomically mark a report as posted, return false if it was already claimed
+// by a previous run or another server of the cluster
+func (p *Plugin) claimReport(id string) (bool, error) {
+	claimed, appErr := p.API.KVCompareAndSet(reportKeyPrefix+id, nil, []byte(p.now().Format(time.RFC3339)))
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "can't claim report "+id)
+	}
+	return claimed, nil
+}
+
+// releaseReport remove the claim of a report so it can be posted again
+func (p *Plugin) releaseReport(id string) {
+	if appErr := p.API.KVDelete(reportKeyPrefix + id); appErr != nil {
+		p.API.LogError("can't release report", "report", id, "err", appErr.Error())
+	}
+}
+
+// runBounded call fn for each channel id with at most workers calls at once, each call waiting a tick of limiter
+// remaining channels are skipped after the first error, which is returned
+func runBounded(channelsID []string, workers int, limiter <-chan time.Time, fn func(channelID string) error) error {
+	channels := make(chan string)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for channelID := range channels {
+				<-limiter
+				if err := fn(channelID); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	var err error
+send:
+	for _, channelID := range channelsID {
+		select {
+		case channels <- channelID:
+		case err = <-errs:
+			break send
+		}
+	}
+	close(channels)
+	<-done
+	if err == nil && len(errs) > 0 {
+		err = <-errs
+	}
+	return err
+}
+
+// sendScheduledAnalytics post the report of period in every channel, at most once per channel and period
+// reports are generated by a bounded pool of workers so many report channels don't spike the server load
+// users named in the reports are recorded in named
+func (p *Plugin) sendScheduledAnalytics(analytic *Analytic, channelsID []string, period string, named *namedUsers) error {
+	shrink := p.shouldShrinkDigest()
+	attachments, err := p.buildAnalyticAttachments(analytic, shrink)
+	if err != nil {
+		return errors.Wrap(err, "can't build analytics attachments")
+	}
+	var images []*chartImage
+	if p.getConfiguration().AttachChartImages && !shrink {
+		if images, err = p.buildReportCharts(analytic, p.digestRTL()); err != nil {
+			p.API.LogWarn("can't build chart images, report is posted without them", "err", err.Error())
+		}
+	}
+
+	limiter := time.NewTicker(time.Second / reportsPerSecond)
+	defer limiter.Stop()
+	return runBounded(channelsID, reportWorkers, limiter.C, func(channelID string) error {
+		return p.sendChannelReport(analytic, channelID, period, shrink, attachments, images, named)
+	})
+}
+
+// sendChannelReport post the report of period in channelID, filtered by its route and translated in its language if any
+// users named in the report are recorded in named
+func (p *Plugin) sendChannelReport(analytic *Analytic, channelID string, period string, shrink bool, attachments []*model.SlackAttachment, images []*chartImage, named *namedUsers) error {
+	var err error
+	route, routed := p.ReportRoutes[channelID]
+	language, translated := p.ChannelLanguages[channelID]
+	translated = translated && language != p.getConfiguration().Language
+	if (routed || translated) && !p.getConfiguration().CanaryMode {
+		var include func(channelID string) bool
+		if routed {
+			include = func(channelID string) bool { return p.routeIncludes(route, channelID) }
+		}
+		if attachments, err = p.buildFilteredAttachments(analytic, shrink, include, p.channelTranslate(channelID)); err != nil {
+			return errors.Wrap(err, "can't build routed analytics attachments")
+		}
+		if routed && len(images) > 0 {
+			if images, err = p.buildReportCharts(filterAnalytic(analytic, include), p.digestRTL()); err != nil {
+				p.API.LogWarn("can't build chart images, report is posted without them", "err", err.Error())
+			}
+		}
+	}
+	if p.GlossaryChannelsID[channelID] && !shrink {
+		attachments = withGlossary(attachments, p.digestRTL(), p.channelTranslate(channelID))
+	}
+	named.addAttachments(channelID, attachments, images)
+	if window, ok := p.DeliveryWindows[channelID]; ok && !p.getConfiguration().CanaryMode {
+		if now := p.now(); !window.contains(now) {
+			return p.deferReport(&PendingReport{ChannelID: channelID, Period: period, DueAt: window.nextSlot(now), Attachments: attachments, Images: images})
+		}
+	}
+	return p.postChannelReport(channelID, period, attachments, images)
+}
+
+// postChannelReport post a report in channelID, at most once per channel and period
+func (p *Plugin) postChannelReport(channelID string, period string, attachments []*model.SlackAttachment, images []*chartImage) error {
+	id := reportID(channelID, period)
+	claimed, err := p.claimReport(id)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		p.API.LogInfo("report already posted, skip it", "report", id)
+		return nil
+	}
+	post, err := p.deliverAnalytics(channelID, attachments, images)
+	if err != nil {
+		p.releaseReport(id)
+		p.API.LogError("can't deliver report, sent to system admins", "report", id, "err", err.Error())
+		return nil
+	}
+	if err := p.recordDigestPost(post, period); err != nil {
+		p.API.LogWarn("can't record digest post for feedback", "post", post.Id, "err", err.Error())
+	}
+	go func() {
+		report := &WebhookReport{ReportID: id, ChannelID: channelID, Period: period, PostID: post.Id, SentAt: p.now(), Attachments: attachments}
+		if err := p.pushReportToWebhook(report); err != nil {
+			p.API.LogError("can't push report to outgoing webhook", "report", id, "err", err.Error())
+		}
+	}()
+	return nil
+}
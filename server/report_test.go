@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBounded(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter := make(chan time.Time)
+	close(limiter)
+	channelsID := []string{"channel1", "channel2", "channel3", "channel4", "channel5", "channel6"}
+
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	done := make(map[string]bool)
+	err := runBounded(channelsID, 2, limiter, func(channelID string) error {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		lock.Lock()
+		running--
+		done[channelID] = true
+		lock.Unlock()
+		return nil
+	})
+	assert.Nil(err)
+	assert.Len(done, len(channelsID))
+	assert.True(maxRunning <= 2)
+
+	calls := 0
+	err = runBounded(channelsID, 1, limiter, func(channelID string) error {
+		calls++
+		if channelID == "channel2" {
+			return errors.New("can't post")
+		}
+		return nil
+	})
+	assert.EqualError(err, "can't post")
+	assert.Equal(2, calls)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	openThreadKeyPrefix = "open_thread:"
+	// answeredThread replace an open thread once its first response is measured, until it is deleted or expires
+	answeredThread = "answered"
+	// openThreadMaxAge is the time a root post waits for its first reply, later replies are not measured
+	openThreadMaxAge = 7 * 24 * time.Hour
+	// minResponsesToReport is the number of first responses of a channel needed to report its response times
+	minResponsesToReport = 3
+)
+
+// openThread is a root post waiting for a reply of someone else than its author
+type openThread struct {
+	ChannelID string
+	// Author is the hashed user id of the author of the root post
+	Author   string
+	CreateAt int64
+}
+
+// trackResponseTime open a thread for a root post, or measure the first response to its root for a reply of
+// someone else and store it in the daily bucket of the channel, system messages are ignored
+func (p *Plugin) trackResponseTime(post *model.Post) error {
+	if post.Type != "" {
+		return nil
+	}
+	author := hashIdentifier(post.UserId, p.API.GetDiagnosticId())
+	if post.RootId == "" {
+		j, err := json.Marshal(openThread{ChannelID: post.ChannelId, Author: author, CreateAt: post.CreateAt})
+		if err != nil {
+			return errors.Wrap(err, "can't marshal open thread")
+		}
+		if appErr := p.API.KVSetWithExpiry(openThreadKeyPrefix+post.Id, j, int64(openThreadMaxAge/time.Second)); appErr != nil {
+			return errors.Wrap(appErr, "can't save open thread")
+		}
+		return nil
+	}
+
+	key := openThreadKeyPrefix + post.RootId
+	value, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't get open thread")
+	}
+	if value == nil || string(value) == answeredThread {
+		return nil
+	}
+	var thread openThread
+	if err := json.Unmarshal(value, &thread); err != nil {
+		return errors.Wrap(err, "can't unmarshal open thread")
+	}
+	if thread.Author == author {
+		return nil
+	}
+	// only the reply answering the open thread is the first response, KVCompareAndDelete would need mattermost 5.16
+	answered, appErr := p.API.KVCompareAndSet(key, value, []byte(answeredThread))
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't answer open thread")
+	}
+	if !answered {
+		return nil
+	}
+	if appErr := p.API.KVDelete(key); appErr != nil {
+		p.API.LogWarn("can't delete answered thread, it will expire", "key", key, "err", appErr.Error())
+	}
+	delay := (post.CreateAt - thread.CreateAt) / 1000
+	return p.incrementDaily(p.now(), dailyScopeResponseTimes, thread.ChannelID, DailyCounters{ResponseTimes: []int64{delay}})
+}
+
+// percentileDelay return the nearest-rank percentile of delays sorted ascending
+func percentileDelay(delays []time.Duration, percentile int) time.Duration {
+	if len(delays) == 0 {
+		return 0
+	}
+	rank := (percentile*len(delays) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return delays[rank-1]
+}
+
+// channelResponseTimes are the first response delays of a channel sorted ascending
+type channelResponseTimes struct {
+	ChannelID string
+	Delays    []time.Duration
+}
+
+// responseTimes group first response delays of buckets by channel, channels rejected by include are ignored if
+// include is not nil, channels with less than minResponsesToReport responses are dropped, sorted by slowest median
+func responseTimes(buckets []dailyBucket, include func(channelID string) bool) []channelResponseTimes {
+	byChannel := make(map[string][]time.Duration)
+	for _, bucket := range buckets {
+		if include != nil && !include(bucket.ID) {
+			continue
+		}
+		for _, seconds := range bucket.Counters.ResponseTimes {
+			byChannel[bucket.ID] = append(byChannel[bucket.ID], time.Duration(seconds)*time.Second)
+		}
+	}
+	times := make([]channelResponseTimes, 0, len(byChannel))
+	for channelID, delays := range byChannel {
+		if len(delays) < minResponsesToReport {
+			continue
+		}
+		sort.Slice(delays, func(i, j int) bool {
+			return delays[i] < delays[j]
+		})
+		times = append(times, channelResponseTimes{ChannelID: channelID, Delays: delays})
+	}
+	sort.Slice(times, func(i, j int) bool {
+		a, b := medianDelay(times[i].Delays), medianDelay(times[j].Delays)
+		if a != b {
+			return a > b
+		}
+		return times[i].ChannelID < times[j].ChannelID
+	})
+	return times
+}
+
+// getResponseTimesDescription render median and p90 first response times of channels between from and to
+func (p *Plugin) getResponseTimesDescription(from time.Time, to time.Time, include func(channelID string) bool, T translateFunc) (string, error) {
+	buckets, err := p.dailyBuckets(dailyScopeResponseTimes, from, to)
+	if err != nil {
+		return "", err
+	}
+	times := responseTimes(buckets, include)
+	if len(times) == 0 {
+		return "", nil
+	}
+	m := sectionTitle(T, "report.response_times.title")
+	for index, channel := range times {
+		if index == maxChannelsToDisplay {
+			break
+		}
+		_, displayName, link, err := p.getChannelName(channel.ChannelID)
+		if err != nil {
+			continue
+		}
+		m += fmt.Sprintf("* [~%s](%s): median **%s**, p90 %s *(%d threads)*\n", displayName, link, medianDelay(channel.Delays).Round(time.Minute), percentileDelay(channel.Delays, 90).Round(time.Minute), len(channel.Delays))
+	}
+	return m, nil
+}
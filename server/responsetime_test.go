@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPercentileDelay(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(time.Duration(0), percentileDelay(nil, 90))
+	delays := []time.Duration{1 * time.Minute, 2 * time.Minute, 3 * time.Minute, 4 * time.Minute, 5 * time.Minute,
+		6 * time.Minute, 7 * time.Minute, 8 * time.Minute, 9 * time.Minute, 60 * time.Minute}
+	assert.Equal(9*time.Minute, percentileDelay(delays, 90))
+	assert.Equal(60*time.Minute, percentileDelay(delays, 100))
+	assert.Equal(1*time.Minute, percentileDelay(delays, 0))
+	assert.Equal(5*time.Minute, percentileDelay(delays, 50))
+}
+
+func TestResponseTimes(t *testing.T) {
+	assert := assert.New(t)
+	day := time.Date(2019, time.May, 1, 0, 0, 0, 0, time.UTC)
+	buckets := []dailyBucket{
+		{Date: day, ID: "support", Counters: DailyCounters{ResponseTimes: []int64{600, 60}}},
+		{Date: day.AddDate(0, 0, 1), ID: "support", Counters: DailyCounters{ResponseTimes: []int64{3600}}},
+		{Date: day, ID: "dev", Counters: DailyCounters{ResponseTimes: []int64{120, 180, 60, 7200}}},
+		{Date: day, ID: "random", Counters: DailyCounters{ResponseTimes: []int64{30}}},
+		{Date: day, ID: "private", Counters: DailyCounters{ResponseTimes: []int64{10, 20, 30}}},
+	}
+
+	times := responseTimes(buckets, func(channelID string) bool { return channelID != "private" })
+	assert.Len(times, 2)
+	assert.Equal("support", times[0].ChannelID)
+	assert.Equal([]time.Duration{time.Minute, 10 * time.Minute, time.Hour}, times[0].Delays)
+	assert.Equal("dev", times[1].ChannelID)
+	assert.Equal(150*time.Second, medianDelay(times[1].Delays))
+
+	assert.Len(responseTimes(buckets, nil), 3)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+// expiredDailyKey return true if key is a daily bucket of a day before cutoff, in form YYYY-MM-DD
+func expiredDailyKey(key string, cutoff string) bool {
+	if !strings.HasPrefix(key, dailyKeyPrefix) {
+		return false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, dailyKeyPrefix), ":", 2)
+	if _, err := time.Parse(dailyKeyFormat, parts[0]); err != nil {
+		return false
+	}
+	return parts[0] < cutoff
+}
+
+// pruneDailyBuckets delete daily buckets older than RetentionDays days before now and return the number of deleted buckets
+// nothing is deleted when RetentionDays is 0
+func (p *Plugin) pruneDailyBuckets(now time.Time) (int, error) {
+	days := p.getConfiguration().RetentionDays
+	if days <= 0 {
+		return 0, nil
+	}
+	return p.store().deleteBefore(now.AddDate(0, 0, -days).Format(dailyKeyFormat))
+}
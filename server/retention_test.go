@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpiredDailyKey(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(expiredDailyKey("analytics:2019-04-30:c:channel1", "2019-05-01"))
+	assert.True(expiredDailyKey("analytics:2018-12-31:u:user1", "2019-05-01"))
+	assert.False(expiredDailyKey("analytics:2019-05-01:c:channel1", "2019-05-01"))
+	assert.False(expiredDailyKey("analytics:2019-06-01:cm:channel1", "2019-05-01"))
+	assert.False(expiredDailyKey("analytics", "2019-05-01"))
+	assert.False(expiredDailyKey("analytics:notadate:c:channel1", "2019-05-01"))
+	assert.False(expiredDailyKey("report:2019-04-30:channel1", "2019-05-01"))
+}
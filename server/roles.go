@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	roleStarter = "thread starters"
+	roleReplier = "repliers"
+	roleReactor = "reactors"
+	roleMixed   = "mixed"
+)
+
+// roles are conversation roles in display order
+var roles = []string{roleStarter, roleReplier, roleReactor, roleMixed}
+
+// classifyRole return the role of a user doing more than half of its activity by starting threads, replying or reacting
+// mixed otherwise
+func classifyRole(starts int64, replies int64, reactions int64) string {
+	total := starts + replies + reactions
+	switch {
+	case starts*2 > total:
+		return roleStarter
+	case replies*2 > total:
+		return roleReplier
+	case reactions*2 > total:
+		return roleReactor
+	default:
+		return roleMixed
+	}
+}
+
+// channelsRoles return the number of users by role by channel id, from messages, replies and reactions
+// by channel and user id (formatted as channelID:userID)
+func channelsRoles(messages map[string]int64, replies map[string]int64, reactions map[string]int64) map[string]map[string]int64 {
+	keys := make(map[string]bool)
+	for key := range messages {
+		keys[key] = true
+	}
+	for key := range reactions {
+		keys[key] = true
+	}
+	rolesByChannel := make(map[string]map[string]int64)
+	for key := range keys {
+		channelID := strings.SplitN(key, ":", 2)[0]
+		if _, ok := rolesByChannel[channelID]; !ok {
+			rolesByChannel[channelID] = make(map[string]int64)
+		}
+		rolesByChannel[channelID][classifyRole(messages[key]-replies[key], replies[key], reactions[key])]++
+	}
+	return rolesByChannel
+}
+
+// formatRoles render number of users by role, e.g. **3** thread starters, **5** repliers
+func formatRoles(counts map[string]int64) string {
+	parts := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if counts[role] > 0 {
+			parts = append(parts, fmt.Sprintf("**%d** %s", counts[role], role))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// getRolesDescription render conversation roles of users aggregated by team, a user active in several channels
+// of a team is counted once by channel, caller must hold the read lock of the analytic
+func (p *Plugin) getRolesDescription(analytic *Analytic, stats *ReactionStats, T translateFunc) string {
+	reactions := make(map[string]int64)
+	if stats != nil {
+		reactions = stats.ChannelsUsers
+	}
+	teamsRoles := make(map[string]map[string]int64)
+	for channelID, counts := range channelsRoles(analytic.ChannelsUsers, analytic.ChannelsUsersReply, reactions) {
+		channel, appErr := p.API.GetChannel(channelID)
+		if appErr != nil || channel.IsGroupOrDirect() {
+			continue
+		}
+		team, appErr := p.API.GetTeam(channel.TeamId)
+		if appErr != nil {
+			continue
+		}
+		if _, ok := teamsRoles[team.DisplayName]; !ok {
+			teamsRoles[team.DisplayName] = make(map[string]int64)
+		}
+		mergeCounters(teamsRoles[team.DisplayName], counts)
+	}
+	if len(teamsRoles) == 0 {
+		return ""
+	}
+
+	teams := make([]string, 0, len(teamsRoles))
+	for team := range teamsRoles {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	m := sectionTitle(T, "report.roles.title")
+	for _, team := range teams {
+		m += fmt.Sprintf("* %s: %s\n", team, formatRoles(teamsRoles[team]))
+	}
+	return m
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyRole(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(roleStarter, classifyRole(5, 1, 2))
+	assert.Equal(roleReplier, classifyRole(1, 5, 2))
+	assert.Equal(roleReactor, classifyRole(0, 0, 3))
+	assert.Equal(roleMixed, classifyRole(2, 2, 2))
+}
+
+func TestChannelsRoles(t *testing.T) {
+	assert := assert.New(t)
+	messages := map[string]int64{
+		channelUserKey("channel1", "user1"): 4,
+		channelUserKey("channel1", "user2"): 3,
+	}
+	replies := map[string]int64{
+		channelUserKey("channel1", "user2"): 3,
+	}
+	reactions := map[string]int64{
+		channelUserKey("channel1", "user3"): 2,
+		channelUserKey("channel2", "user1"): 1,
+	}
+
+	assert.Equal(map[string]map[string]int64{
+		"channel1": {roleStarter: 1, roleReplier: 1, roleReactor: 1},
+		"channel2": {roleReactor: 1},
+	}, channelsRoles(messages, replies, reactions))
+	assert.Equal("**1** thread starters, **2** repliers", formatRoles(map[string]int64{roleReplier: 2, roleStarter: 1}))
+}
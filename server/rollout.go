@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// rolloutPercentage return the percentage of teams of the rollout, 100 when it is not set, on upgraded installs or
+// before the configuration is loaded
+func (c *configuration) rolloutPercentage() int {
+	if c.RolloutPercentage == nil {
+		return 100
+	}
+	return *c.RolloutPercentage
+}
+
+// rolloutActive return true if collection and reporting are restricted to some teams, or disabled with a percentage
+// of 0 and no rollout team
+func (c *configuration) rolloutActive() bool {
+	return c.RolloutTeams != "" || c.rolloutPercentage() < 100
+}
+
+// parseRolloutTeams take a list of team names separated by comma and return a set of teams id
+func (p *Plugin) parseRolloutTeams(config string) (map[string]bool, error) {
+	teamsID := make(map[string]bool)
+	if config == "" {
+		return teamsID, nil
+	}
+	for _, teamName := range strings.Split(config, ",") {
+		team, err := p.API.GetTeamByName(strings.TrimSpace(teamName))
+		if err != nil {
+			return teamsID, fmt.Errorf("Unable to find team with configured rollout team: %v", teamName)
+		}
+		teamsID[team.Id] = true
+	}
+	return teamsID, nil
+}
+
+// isTeamEnabled return true if the team is part of the rollout, either by allowlist or by percentage
+// the allowlist is exclusive unless a percentage below 100 adds other teams
+func (p *Plugin) isTeamEnabled(teamID string) bool {
+	config := p.getConfiguration()
+	if !config.rolloutActive() {
+		return true
+	}
+	if teamID == "" {
+		// DM and group channels have no team, only collect them once rollout is complete
+		return false
+	}
+	if p.RolloutTeamsID[teamID] {
+		return true
+	}
+	if config.rolloutPercentage() >= 100 {
+		return false
+	}
+	return rolloutBucket(teamID) < config.rolloutPercentage()
+}
+
+// isChannelEnabled return true if the team owning this channel is part of the rollout
+func (p *Plugin) isChannelEnabled(channelID string) bool {
+	if !p.getConfiguration().rolloutActive() {
+		return true
+	}
+	teamID, err := p.getChannelTeamID(channelID)
+	if err != nil {
+		p.API.LogWarn("can't find team of channel", "channel", channelID, "err", err.Error())
+		return false
+	}
+	return p.isTeamEnabled(teamID)
+}
+
+// getChannelTeamID return the team id of a channel, cached as it's called on every post
+func (p *Plugin) getChannelTeamID(channelID string) (string, error) {
+	p.channelTeamsLock.RLock()
+	teamID, ok := p.channelTeams[channelID]
+	p.channelTeamsLock.RUnlock()
+	if ok {
+		return teamID, nil
+	}
+
+	channel, err := p.API.GetChannel(channelID)
+	if err != nil {
+		return "", err
+	}
+
+	p.channelTeamsLock.Lock()
+	defer p.channelTeamsLock.Unlock()
+	if p.channelTeams == nil {
+		p.channelTeams = make(map[string]string)
+	}
+	p.channelTeams[channelID] = channel.TeamId
+	return channel.TeamId, nil
+}
+
+// rolloutBucket place a team in a stable bucket between 0 and 99
+func rolloutBucket(teamID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(teamID))
+	return int(h.Sum32() % 100)
+}
+
+// parseChannelsSet take a list of TeamName/ChannelName separated by comma and return a set of channels id
+func (p *Plugin) parseChannelsSet(config string) (map[string]bool, error) {
+	channelsSet := make(map[string]bool)
+	if strings.TrimSpace(config) == "" {
+		return channelsSet, nil
+	}
+	channelsID, err := p.parseChannelsFromConfig(config)
+	if err != nil {
+		return channelsSet, err
+	}
+	for _, channelID := range channelsID {
+		channelsSet[channelID] = true
+	}
+	return channelsSet, nil
+}
+
+// isChannelCollected return true if admins didn't opt the channel out of collection
+func (p *Plugin) isChannelCollected(channelID string) bool {
+	if p.ExcludedChannelsID[channelID] {
+		return false
+	}
+	return len(p.IncludedChannelsID) == 0 || p.IncludedChannelsID[channelID]
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTeamEnabled(t *testing.T) {
+	assert := assert.New(t)
+
+	// team2 is in a lower bucket than team3, the percentage between them enables team2 only
+	assert.True(rolloutBucket("team2") < rolloutBucket("team3"))
+	percentage := rolloutBucket("team3")
+	zero, full := 0, 100
+
+	for _, test := range []struct {
+		name    string
+		config  *configuration
+		rollout map[string]bool
+		teamID  string
+		enabled bool
+		active  bool
+	}{
+		{name: "unset percentage", config: &configuration{}, teamID: "team2", enabled: true},
+		{name: "unset percentage, allowed team", config: &configuration{RolloutTeams: "team1"}, rollout: map[string]bool{"team1": true}, teamID: "team1", enabled: true, active: true},
+		{name: "unset percentage, other team", config: &configuration{RolloutTeams: "team1"}, rollout: map[string]bool{"team1": true}, teamID: "team2", active: true},
+		{name: "no rollout", config: &configuration{RolloutPercentage: &full}, teamID: "team2", enabled: true},
+		{name: "allowlist only, allowed team", config: &configuration{RolloutTeams: "team1", RolloutPercentage: &full}, rollout: map[string]bool{"team1": true}, teamID: "team1", enabled: true, active: true},
+		{name: "allowlist only, other team", config: &configuration{RolloutTeams: "team1", RolloutPercentage: &full}, rollout: map[string]bool{"team1": true}, teamID: "team2", active: true},
+		{name: "allowlist and percentage, allowed team", config: &configuration{RolloutTeams: "team1", RolloutPercentage: &percentage}, rollout: map[string]bool{"team1": true}, teamID: "team1", enabled: true, active: true},
+		{name: "allowlist and percentage, team in percentage", config: &configuration{RolloutTeams: "team1", RolloutPercentage: &percentage}, rollout: map[string]bool{"team1": true}, teamID: "team2", enabled: true, active: true},
+		{name: "allowlist and percentage, team out of percentage", config: &configuration{RolloutTeams: "team1", RolloutPercentage: &percentage}, rollout: map[string]bool{"team1": true}, teamID: "team3", active: true},
+		{name: "percentage only", config: &configuration{RolloutPercentage: &percentage}, teamID: "team2", enabled: true, active: true},
+		{name: "zero percentage", config: &configuration{RolloutPercentage: &zero}, teamID: "team2", active: true},
+		{name: "zero percentage, allowed team", config: &configuration{RolloutTeams: "team1", RolloutPercentage: &zero}, rollout: map[string]bool{"team1": true}, teamID: "team1", enabled: true, active: true},
+		{name: "zero percentage, other team", config: &configuration{RolloutTeams: "team1", RolloutPercentage: &zero}, rollout: map[string]bool{"team1": true}, teamID: "team2", active: true},
+	} {
+		p := &Plugin{RolloutTeamsID: test.rollout}
+		p.setConfiguration(test.config)
+		assert.Equal(test.active, test.config.rolloutActive(), test.name)
+		assert.Equal(test.enabled, p.isTeamEnabled(test.teamID), test.name)
+	}
+}
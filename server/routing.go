@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// reportRoute is the set of teams and channels whose analytics are posted in a report channel
+type reportRoute struct {
+	TeamsID    map[string]bool
+	ChannelsID map[string]bool
+}
+
+// parseReportRoutes parse TeamsChannels in form TeamName/ChannelName separated by commas, where an entry
+// TeamName/ChannelName:TeamName/* or TeamName/ChannelName:TeamName/ChannelName only receive analytics of the
+// channels on the right, return channels receiving all analytics and sources by routed channel
+func parseReportRoutes(config string) ([]string, map[string][]string, error) {
+	global := make([]string, 0)
+	routes := make(map[string][]string)
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		v := strings.SplitN(entry, ":", 2)
+		for _, part := range v {
+			if strings.Count(part, "/") != 1 || strings.HasPrefix(part, "/") || strings.HasSuffix(part, "/") {
+				return nil, nil, fmt.Errorf("Bad formatted TeamsChannels: %v, expected TeamName/ChannelName or TeamName/ChannelName:TeamName/*", entry)
+			}
+		}
+		if len(v) == 1 {
+			global = append(global, entry)
+			continue
+		}
+		routes[v[0]] = append(routes[v[0]], v[1])
+	}
+	return global, routes, nil
+}
+
+// resolveReportRoutes return the route of each routed report channel id
+func (p *Plugin) resolveReportRoutes(routes map[string][]string) (map[string]*reportRoute, error) {
+	resolved := make(map[string]*reportRoute)
+	for target, sources := range routes {
+		targetsID, err := p.parseChannelsFromConfig(target)
+		if err != nil {
+			return nil, err
+		}
+		route := &reportRoute{TeamsID: make(map[string]bool), ChannelsID: make(map[string]bool)}
+		for _, source := range sources {
+			v := strings.SplitN(source, "/", 2)
+			if v[1] != "*" {
+				channelsID, err := p.parseChannelsFromConfig(source)
+				if err != nil {
+					return nil, err
+				}
+				route.ChannelsID[channelsID[0]] = true
+				continue
+			}
+			team, appErr := p.API.GetTeamByName(v[0])
+			if appErr != nil {
+				return nil, errors.Wrap(appErr, "Unable to find team with configured team: "+v[0])
+			}
+			route.TeamsID[team.Id] = true
+		}
+		resolved[targetsID[0]] = route
+	}
+	return resolved, nil
+}
+
+// routeIncludes return true if analytics of channelID are posted in the routed report channel
+func (p *Plugin) routeIncludes(route *reportRoute, channelID string) bool {
+	if route.ChannelsID[channelID] {
+		return true
+	}
+	if len(route.TeamsID) == 0 {
+		return false
+	}
+	teamID, err := p.getChannelTeamID(channelID)
+	return err == nil && route.TeamsID[teamID]
+}
+
+// filterAnalytic return a copy of the analytic keeping only counters of channels accepted by include, users are
+// counted from their messages in these channels, counters without channel (hours, threads, scripts, lengths, mentions, files number, direct messages) are dropped
+func filterAnalytic(analytic *Analytic, include func(channelID string) bool) *Analytic {
+	analytic.RLock()
+	defer analytic.RUnlock()
+	filtered := NewAnalytic(analytic.Start)
+	filtered.End = analytic.End
+	filterCounters := func(to map[string]int64, from map[string]int64) {
+		for channelID, nb := range from {
+			if include(channelID) {
+				to[channelID] = nb
+			}
+		}
+	}
+	filterCounters(filtered.Channels, analytic.Channels)
+	filterCounters(filtered.ChannelsReply, analytic.ChannelsReply)
+	filterCounters(filtered.ChannelsFilesSize, analytic.ChannelsFilesSize)
+	filterCounters(filtered.ChannelsWords, analytic.ChannelsWords)
+	filterCounters(filtered.ChannelsEdits, analytic.ChannelsEdits)
+	filterCounters(filtered.ChannelsDeletions, analytic.ChannelsDeletions)
+	filterUsers := func(to map[string]int64, toUsers map[string]int64, from map[string]int64) {
+		for key, nb := range from {
+			v := strings.SplitN(key, ":", 2)
+			if len(v) == 2 && include(v[0]) {
+				to[key] = nb
+				toUsers[v[1]] += nb
+			}
+		}
+	}
+	filterUsers(filtered.ChannelsUsers, filtered.Users, analytic.ChannelsUsers)
+	filterUsers(filtered.ChannelsUsersReply, filtered.UsersReply, analytic.ChannelsUsersReply)
+	for key, nb := range analytic.ChannelsBroadcasts {
+		if v := strings.SplitN(key, ":", 2); len(v) == 2 && include(v[0]) {
+			filtered.ChannelsBroadcasts[key] = nb
+		}
+	}
+	for _, size := range filtered.ChannelsFilesSize {
+		filtered.FilesSize += size
+	}
+	return filtered
+}
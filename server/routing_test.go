@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReportRoutes(t *testing.T) {
+	assert := assert.New(t)
+
+	global, routes, err := parseReportRoutes("staff/town-square,TeamA/reports:TeamA/*,TeamA/reports:TeamB/dev, TeamB/reports:TeamB/*")
+	assert.Nil(err)
+	assert.Equal([]string{"staff/town-square"}, global)
+	assert.Equal(map[string][]string{
+		"TeamA/reports": {"TeamA/*", "TeamB/dev"},
+		"TeamB/reports": {"TeamB/*"},
+	}, routes)
+
+	_, _, err = parseReportRoutes("TeamA/reports:TeamA")
+	assert.NotNil(err)
+	_, _, err = parseReportRoutes("TeamA")
+	assert.NotNil(err)
+	_, _, err = parseReportRoutes("TeamA/reports,")
+	assert.NotNil(err)
+}
+
+func TestFilterAnalytic(t *testing.T) {
+	assert := assert.New(t)
+	analytic := NewAnalytic(time.Now())
+	analytic.Channels = map[string]int64{"channel1": 3, "channel2": 5}
+	analytic.ChannelsReply = map[string]int64{"channel1": 1, "channel2": 2}
+	analytic.ChannelsFilesSize = map[string]int64{"channel1": 10, "channel2": 20}
+	analytic.ChannelsUsers = map[string]int64{
+		channelUserKey("channel1", "user1"): 2,
+		channelUserKey("channel1", "user2"): 1,
+		channelUserKey("channel2", "user1"): 5,
+	}
+	analytic.ChannelsUsersReply = map[string]int64{channelUserKey("channel1", "user1"): 1}
+	analytic.Users = map[string]int64{"user1": 7, "user2": 1}
+	analytic.FilesNb = 4
+	analytic.FilesSize = 30
+
+	filtered := filterAnalytic(analytic, func(channelID string) bool { return channelID == "channel1" })
+	assert.Equal(map[string]int64{"channel1": 3}, filtered.Channels)
+	assert.Equal(map[string]int64{"channel1": 1}, filtered.ChannelsReply)
+	assert.Equal(map[string]int64{"user1": 2, "user2": 1}, filtered.Users)
+	assert.Equal(map[string]int64{"user1": 1}, filtered.UsersReply)
+	assert.Equal(int64(10), filtered.FilesSize)
+	assert.Equal(int64(0), filtered.FilesNb)
+	assert.Equal(analytic.Start, filtered.Start)
+}
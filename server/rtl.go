@@ -0,0 +1,148 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/wcharczuk/go-chart/drawing"
+)
+
+const (
+	// rtlMark is the unicode right-to-left mark, it sets the direction of a line starting with neutral characters
+	rtlMark = "\u200f"
+	// rtlChartParameter is added to chart urls to mirror them
+	rtlChartParameter = "rtl"
+)
+
+// markdownLineMarkerRegexp match titles and list items markers at the start of a line
+var markdownLineMarkerRegexp = regexp.MustCompile(`^(#+ |[*-] |\d+\. )`)
+
+// rtlLocales are languages written from right to left
+var rtlLocales = []string{"ar", "fa", "he", "ur", "yi"}
+
+// isRTLLocale return true if the locale (e.g. he or ar-SA) is written from right to left
+func isRTLLocale(locale string) bool {
+	language := strings.ToLower(strings.SplitN(strings.Replace(locale, "_", "-", 1), "-", 2)[0])
+	for _, rtl := range rtlLocales {
+		if language == rtl {
+			return true
+		}
+	}
+	return false
+}
+
+// digestRTL return true if digests must be rendered from right to left, following the default locale of users
+func (p *Plugin) digestRTL() bool {
+	locale := p.API.GetConfig().LocalizationSettings.DefaultClientLocale
+	return locale != nil && isRTLLocale(*locale)
+}
+
+// rtlMarkdown add a right-to-left mark at the start of each line of text, after markdown list and title markers
+// so lines starting with a number, a username or an emoji are displayed from right to left, tables of charts are untouched
+func rtlMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+	for index, line := range lines {
+		if line == "" || strings.HasPrefix(line, "|") {
+			continue
+		}
+		marker := markdownLineMarkerRegexp.FindString(line)
+		lines[index] = marker + rtlMark + line[len(marker):]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rtlAttachments mirror the text of attachments and their fields
+func rtlAttachments(attachments []*model.SlackAttachment) {
+	for _, attachment := range attachments {
+		attachment.Text = rtlMarkdown(attachment.Text)
+		for _, field := range attachment.Fields {
+			if value, ok := field.Value.(string); ok {
+				field.Value = rtlMarkdown(value)
+			}
+		}
+	}
+}
+
+// addRTLChartParameter ask the chart handler to mirror the chart
+func addRTLChartParameter(parametersURL url.Values, rtl bool) {
+	if rtl {
+		parametersURL.Set(rtlChartParameter, "1")
+	}
+}
+
+// isChartParameter return true for query parameters which are not chart values
+func isChartParameter(key string) bool {
+	return key == "amp" || key == rtlChartParameter
+}
+
+// legendRTL is chart.Legend mirrored, anchored at the right of the chart with lines on the left of labels
+func legendRTL(c *chart.Chart) chart.Renderable {
+	return func(r chart.Renderer, cb chart.Box, chartDefaults chart.Style) {
+		legendStyle := chartDefaults.InheritFrom(chart.Style{
+			FillColor:   drawing.ColorWhite,
+			FontColor:   chart.DefaultTextColor,
+			FontSize:    8.0,
+			StrokeColor: chart.DefaultAxisColor,
+			StrokeWidth: chart.DefaultAxisLineWidth,
+		})
+		padding := 5
+		lineTextGap := 5
+		lineLengthMinimum := 25
+
+		labels := make([]string, 0)
+		colors := make([]drawing.Color, 0)
+		for index, s := range c.Series {
+			if s.GetName() == "" {
+				continue
+			}
+			labels = append(labels, s.GetName())
+			colors = append(colors, c.GetColorPalette().GetSeriesColor(index))
+		}
+		if len(labels) == 0 {
+			return
+		}
+
+		legendStyle.GetTextOptions().WriteToRenderer(r)
+		width := 0
+		height := 0
+		for index, label := range labels {
+			tb := r.MeasureText(label)
+			if index > 0 {
+				height += chart.DefaultMinimumTickVerticalSpacing
+			}
+			height += tb.Height()
+			if tb.Width() > width {
+				width = tb.Width()
+			}
+		}
+		legend := chart.Box{
+			Top:    cb.Top,
+			Right:  cb.Right,
+			Left:   cb.Right - (width + lineTextGap + lineLengthMinimum + 2*padding),
+			Bottom: cb.Top + height + 2*padding,
+		}
+		chart.Draw.Box(r, legend, legendStyle)
+
+		legendStyle.GetTextOptions().WriteToRenderer(r)
+		ycursor := legend.Top + padding
+		for index, label := range labels {
+			if index > 0 {
+				ycursor += chart.DefaultMinimumTickVerticalSpacing
+			}
+			tb := r.MeasureText(label)
+			ty := ycursor + tb.Height()
+			r.Text(label, legend.Right-padding-tb.Width(), ty)
+
+			ly := ty - (tb.Height() >> 1)
+			r.SetStrokeColor(colors[index])
+			r.SetStrokeWidth(chart.DefaultSeriesLineWidth)
+			r.MoveTo(legend.Left+padding, ly)
+			r.LineTo(legend.Right-padding-tb.Width()-lineTextGap, ly)
+			r.Stroke()
+			ycursor += tb.Height()
+		}
+	}
+}
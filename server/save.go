@@ -11,15 +11,22 @@ func (p *Plugin) retreiveData() error {
 	if err != nil {
 		return errors.Wrap(err, "failed to get analytics from kv")
 	}
-	p.currentAnalytic = NewAnalytic()
+	p.currentAnalytic = NewAnalytic(p.now())
 	if err := json.Unmarshal(j, p.currentAnalytic); err != nil {
 		p.API.LogError("failed to unmarshal analytics from kv use new one", "err", err.Error())
-		p.currentAnalytic = NewAnalytic()
+		p.currentAnalytic = NewAnalytic(p.now())
+	}
+	p.clusterDelta = nil
+	if p.clusterEnabled() {
+		p.clusterDelta = NewAnalytic(p.now())
 	}
 	return nil
 }
 
 func (p *Plugin) saveCurrentAnalytic() error {
+	if p.clusterDelta != nil {
+		return p.saveClusterSession()
+	}
 	p.currentAnalytic.RLock()
 	defer p.currentAnalytic.RUnlock()
 
@@ -30,6 +37,25 @@ func (p *Plugin) saveCurrentAnalytic() error {
 	if err := p.API.KVSet("analytics", j); err != nil {
 		return errors.Wrap(err, "can't save analytics data")
 	}
+	if err := p.journal.Checkpoint(); err != nil {
+		p.API.LogError("can't checkpoint journal", "err", err.Error())
+	}
+	p.resetPending()
+	return nil
+}
+
+// saveClusterSession merge events of this node in the session shared by all nodes of the cluster
+func (p *Plugin) saveClusterSession() error {
+	p.currentAnalytic.WLock()
+	defer p.currentAnalytic.WUnlock()
+
+	if err := p.mergeClusterSession(nil, false); err != nil {
+		return err
+	}
+	if err := p.journal.Checkpoint(); err != nil {
+		p.API.LogError("can't checkpoint journal", "err", err.Error())
+	}
+	p.resetPending()
 	return nil
 }
 
@@ -54,17 +80,56 @@ func (p *Plugin) newSession() {
 	p.currentAnalytic.WLock()
 	defer p.currentAnalytic.WUnlock()
 
+	// in a cluster the session archived is the one of all nodes, events of other nodes not merged yet count in the
+	// new session
+	if p.clusterDelta != nil {
+		if err := p.mergeClusterSession(nil, true); err != nil {
+			p.API.LogError("can't merge cluster session", "err", err.Error())
+			return
+		}
+	}
+
 	allAnalytics, err := p.allSessions()
 	if err != nil {
 		p.API.LogWarn("can't get all sessions", "err", err.Error())
 	}
 
-	j2, err2 := json.Marshal(append(allAnalytics, p.currentAnalytic.Close()))
+	j2, err2 := json.Marshal(append(allAnalytics, p.currentAnalytic.Close(p.now())))
 	if err2 != nil {
 		p.API.LogWarn("can't marshal internal analytics data")
 	}
 	if err := p.API.KVSet("allAnalytics", j2); err != nil {
 		p.API.LogError("failed to send allAnalytics to kv", "err", err.Error())
 	}
-	p.currentAnalytic.Init()
+	p.currentAnalytic.Init(p.now())
+	if err := p.journal.Checkpoint(); err != nil {
+		p.API.LogError("can't checkpoint journal", "err", err.Error())
+	}
+}
+
+// kvGetJSON unmarshal the value of key in value, value is untouched if key doesn't exist
+func (p *Plugin) kvGetJSON(key string, value interface{}) error {
+	j, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return errors.Wrap(appErr, "can't get "+key+" from kv")
+	}
+	if j == nil {
+		return nil
+	}
+	if err := json.Unmarshal(j, value); err != nil {
+		return errors.Wrap(err, "can't unmarshal "+key)
+	}
+	return nil
+}
+
+// kvSetJSON marshal value and save it in key
+func (p *Plugin) kvSetJSON(key string, value interface{}) error {
+	j, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal "+key)
+	}
+	if appErr := p.API.KVSet(key, j); appErr != nil {
+		return errors.Wrap(appErr, "can't save "+key)
+	}
+	return nil
 }
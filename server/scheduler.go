@@ -0,0 +1,244 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron"
+)
+
+// Scheduler post analytics reports on the cadence configured in ReportSchedule
+// jobs are replaced each time the configuration changes
+type Scheduler struct {
+	p    *Plugin
+	lock sync.Mutex
+	c    *cron.Cron
+}
+
+// parseReportSchedule parse cron expressions (e.g. `0 9 * * MON`) separated by semicolons
+func parseReportSchedule(config string) ([]cron.Schedule, error) {
+	schedules := make([]cron.Schedule, 0)
+	for _, spec := range strings.Split(config, ";") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		schedule, err := cron.ParseStandard(spec)
+		if err != nil {
+			return nil, errors.Wrapf(err, "bad report schedule %s", spec)
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+// NewScheduler return a scheduler running jobs of the current configuration
+func NewScheduler(p *Plugin) (*Scheduler, error) {
+	s := &Scheduler{p: p}
+	if err := s.Replace(p.getConfiguration()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reportCadence is how often a report schedule fires, it sets the period of its reports and the window they cover
+type reportCadence int
+
+const (
+	dailyCadence reportCadence = iota
+	weeklyCadence
+	monthlyCadence
+)
+
+// cadenceOf classify schedule by the shortest gap between its next fire times after now
+func cadenceOf(schedule cron.Schedule, now time.Time) reportCadence {
+	shortest := time.Duration(0)
+	next := schedule.Next(now)
+	for i := 0; i < 8 && !next.IsZero(); i++ {
+		following := schedule.Next(next)
+		if following.IsZero() {
+			break
+		}
+		if gap := following.Sub(next); shortest == 0 || gap < shortest {
+			shortest = gap
+		}
+		next = following
+	}
+	switch {
+	case shortest > 0 && shortest < 2*24*time.Hour:
+		return dailyCadence
+	case shortest > 0 && shortest < 28*24*time.Hour:
+		return weeklyCadence
+	default:
+		return monthlyCadence
+	}
+}
+
+// period return an id for the period reported by a report of the cadence fired at now, i.e. the previous day, week
+// or month, e.g. D2019-04-21, W2019-04-21 or M2019-04
+func (c reportCadence) period(cal calendar, now time.Time) string {
+	switch c {
+	case dailyCadence:
+		return "D" + now.AddDate(0, 0, -1).Format("2006-01-02")
+	case weeklyCadence:
+		return cal.weekPeriod(now.AddDate(0, 0, -1))
+	default:
+		return "M" + time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).AddDate(0, -1, 0).Format("2006-01")
+	}
+}
+
+// windowStart return the start of the window covered by a report of the cadence fired at now
+func (c reportCadence) windowStart(now time.Time) time.Time {
+	switch c {
+	case dailyCadence:
+		return now.AddDate(0, 0, -1)
+	case weeklyCadence:
+		return now.AddDate(0, 0, -7)
+	default:
+		return now.AddDate(0, -1, 0)
+	}
+}
+
+// Replace stop all jobs and register the ones of the configuration
+// each report schedule posts the report of its own cadence, only the schedule with the shortest cadence starts a new
+// session, the others report the sessions merged over their window
+func (s *Scheduler) Replace(config *configuration) error {
+	spec := config.ReportSchedule
+	cal := config.calendar()
+	if strings.TrimSpace(spec) == "" {
+		spec = cal.weeklyCronSpec()
+	}
+	schedules, err := parseReportSchedule(spec)
+	if err != nil {
+		return err
+	}
+	now := s.p.now().In(config.getLocation())
+	cadences := make([]reportCadence, len(schedules))
+	rotating := 0
+	for index, schedule := range schedules {
+		cadences[index] = cadenceOf(schedule, now)
+		if cadences[index] < cadences[rotating] {
+			rotating = index
+		}
+	}
+
+	surveySchedules, err := parseReportSchedule(config.SurveySchedule)
+	if err != nil {
+		return err
+	}
+
+	c := cron.NewWithLocation(config.getLocation())
+	for index, schedule := range schedules {
+		cadence, rotate := cadences[index], index == rotating
+		c.Schedule(schedule, cron.FuncJob(func() {
+			if !s.p.isLeader() {
+				return
+			}
+			s.p.runScheduledReport(cal, cadence, rotate)
+		}))
+	}
+	for _, schedule := range surveySchedules {
+		c.Schedule(schedule, cron.FuncJob(func() {
+			if !s.p.isLeader() {
+				return
+			}
+			if err := s.p.postSurveys(); err != nil {
+				s.p.API.LogError("can't post pulse surveys", "err", err.Error())
+			}
+		}))
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.c != nil {
+		s.c.Stop()
+	}
+	s.c = c
+	s.c.Start()
+	return nil
+}
+
+// Stop all jobs
+func (s *Scheduler) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.c != nil {
+		s.c.Stop()
+	}
+}
+
+// runScheduledReport post the report of cadence in report channels, the report of the rotating schedule covers the
+// current session and starts a new one, other reports cover the sessions merged over the window of their cadence
+func (p *Plugin) runScheduledReport(cal calendar, cadence reportCadence, rotate bool) {
+	// in a cluster the report includes events of all nodes merged so far
+	if p.clusterDelta != nil {
+		if err := p.saveClusterSession(); err != nil {
+			p.API.LogError("can't merge cluster session", "err", err.Error())
+		}
+	}
+	if err := p.restoreSpilled(); err != nil {
+		p.API.LogError("can't restore spilled counters", "err", err.Error())
+	}
+	now := p.now().In(p.getConfiguration().getLocation())
+	period := cadence.period(cal, now)
+	analytic := p.currentAnalytic
+	if !rotate {
+		var err error
+		if analytic, err = p.windowAnalytic(cadence.windowStart(now)); err != nil {
+			p.API.LogError("can't merge sessions of scheduled report", "period", period, "err", err.Error())
+			return
+		}
+	}
+	analytic.RLock()
+	from := analytic.Start
+	analytic.RUnlock()
+
+	channelsID := p.reportChannels()
+	if !p.getConfiguration().deliversCentral() {
+		channelsID = nil
+	}
+	if err := p.collectFeedback(period); err != nil {
+		p.API.LogError("can't collect digest feedback", "err", err.Error())
+	}
+	named := newNamedUsers(p.translate())
+	if p.isPostingPaused() {
+		p.API.LogInfo("analytics posting is paused, skip scheduled report", "until", p.pausedUntil().String())
+	} else if err := p.sendScheduledAnalytics(analytic, channelsID, period, named); err != nil {
+		p.API.LogError("can't send post", "err", err.Error())
+	} else {
+		if err := p.sendOwnerDigests(analytic, channelsID, period, named); err != nil {
+			p.API.LogError("can't send digests of channel owners", "err", err.Error())
+		}
+		if err := p.sendOnCallReports(from, now, named); err != nil {
+			p.API.LogError("can't send on-call reports", "err", err.Error())
+		}
+		// users named in any digest of the report are told where they appear
+		if err := p.sendTransparencyMessages(named, from, now); err != nil {
+			p.API.LogError("can't send transparency messages", "err", err.Error())
+		}
+		if err := p.publishDigestPosted(digestSummary(analytic, period, len(channelsID))); err != nil {
+			p.API.LogError("can't publish digest posted event", "err", err.Error())
+		}
+	}
+	if rotate {
+		p.newSession()
+	}
+}
+
+// windowAnalytic return the archived sessions started since from merged with the current session
+func (p *Plugin) windowAnalytic(from time.Time) (*Analytic, error) {
+	sessions, err := p.allSessions()
+	if err != nil {
+		return nil, err
+	}
+	window := make([]*Analytic, 0, len(sessions)+1)
+	for _, session := range sessions {
+		if !session.Start.Before(from) {
+			window = append(window, session)
+		}
+	}
+	window = append(window, p.currentAnalytic)
+	return mergeAnalytics(window), nil
+}
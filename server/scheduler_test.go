@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReportSchedule(t *testing.T) {
+	assert := assert.New(t)
+
+	schedules, err := parseReportSchedule("0 9 * * MON; @daily")
+	assert.Nil(err)
+	assert.Len(schedules, 2)
+	sunday := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+	assert.Equal(time.Date(2019, time.April, 22, 9, 0, 0, 0, time.UTC), schedules[0].Next(sunday))
+	assert.Equal(time.Date(2019, time.April, 22, 0, 0, 0, 0, time.UTC), schedules[1].Next(sunday))
+
+	schedules, err = parseReportSchedule("")
+	assert.Nil(err)
+	assert.Empty(schedules)
+
+	_, err = parseReportSchedule("0 9 * MON")
+	assert.NotNil(err)
+}
+
+func TestReportCadence(t *testing.T) {
+	assert := assert.New(t)
+
+	cal := calendar{weekStart: time.Sunday, fiscalYearStart: time.January}
+	sunday := time.Date(2019, time.April, 21, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		spec    string
+		cadence reportCadence
+		period  string
+		start   time.Time
+	}{
+		{"@daily", dailyCadence, "D2019-04-20", time.Date(2019, time.April, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 9,17 * * *", dailyCadence, "D2019-04-20", time.Date(2019, time.April, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 9 * * MON-FRI", dailyCadence, "D2019-04-20", time.Date(2019, time.April, 20, 12, 0, 0, 0, time.UTC)},
+		{"0 9 * * MON", weeklyCadence, "W2019-04-14", time.Date(2019, time.April, 14, 12, 0, 0, 0, time.UTC)},
+		{"0 9 1,15 * *", weeklyCadence, "W2019-04-14", time.Date(2019, time.April, 14, 12, 0, 0, 0, time.UTC)},
+		{"0 9 1 * *", monthlyCadence, "M2019-03", time.Date(2019, time.March, 21, 12, 0, 0, 0, time.UTC)},
+	} {
+		schedules, err := parseReportSchedule(test.spec)
+		assert.Nil(err, test.spec)
+		cadence := cadenceOf(schedules[0], sunday)
+		assert.Equal(test.cadence, cadence, test.spec)
+		assert.Equal(test.period, cadence.period(cal, sunday), test.spec)
+		assert.Equal(test.start, cadence.windowStart(sunday), test.spec)
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	assert := assert.New(t)
+
+	location, err := loadLocation("")
+	assert.Nil(err)
+	assert.Equal(time.Local, location)
+
+	location, err = loadLocation("America/New_York")
+	assert.Nil(err)
+	date := time.Date(2019, time.April, 22, 3, 30, 0, 0, time.UTC).In(location)
+	assert.Equal("2019-04-21", date.Format(dailyKeyFormat))
+
+	_, err = loadLocation("Mars/Olympus_Mons")
+	assert.NotNil(err)
+}
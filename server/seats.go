@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	seatsKey = "seats"
+
+	nbMonthsOfSeats = 12
+)
+
+// countProvisionedUsers return the number of active human accounts
+func (p *Plugin) countProvisionedUsers() (int64, error) {
+	nb := int64(0)
+	perPage := 200
+	for page := 0; ; page++ {
+		users, appErr := p.API.GetUsers(&model.UserGetOptions{Page: page, PerPage: perPage})
+		if appErr != nil {
+			return 0, errors.Wrap(appErr, "can't get users")
+		}
+		for _, user := range users {
+			if user.DeleteAt == 0 && !user.IsBot {
+				nb++
+			}
+		}
+		if len(users) < perPage {
+			return nb, nil
+		}
+	}
+}
+
+// recordProvisionedUsers keep the highest number of provisioned users of the month
+func (p *Plugin) recordProvisionedUsers(now time.Time) error {
+	nb, err := p.countProvisionedUsers()
+	if err != nil {
+		return err
+	}
+	seats := make(map[string]int64)
+	if err := p.kvGetJSON(seatsKey, &seats); err != nil {
+		return err
+	}
+	month := now.Format("2006-01")
+	if nb > seats[month] {
+		seats[month] = nb
+	}
+	return p.kvSetJSON(seatsKey, seats)
+}
+
+// monthlyActiveUsers return the number of users who posted at least once by month
+func (p *Plugin) monthlyActiveUsers() (map[string]int64, error) {
+	sessions, err := p.allSessions()
+	if err != nil {
+		return nil, err
+	}
+	p.currentAnalytic.RLock()
+	sessions = append(sessions, &Analytic{Start: p.currentAnalytic.Start, Users: p.currentAnalytic.Users})
+	activeUsers := make(map[string]map[string]bool)
+	for _, session := range sessions {
+		month := session.Start.Format("2006-01")
+		if activeUsers[month] == nil {
+			activeUsers[month] = make(map[string]bool)
+		}
+		for userID := range session.Users {
+			activeUsers[month][userID] = true
+		}
+	}
+	p.currentAnalytic.RUnlock()
+
+	mau := make(map[string]int64)
+	for month, users := range activeUsers {
+		mau[month] = int64(len(users))
+	}
+	return mau, nil
+}
+
+// executeSeatsCommand handle `/analytics seats`
+func (p *Plugin) executeSeatsCommand(args *model.CommandArgs) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can see seat utilization.")
+	}
+	if err := p.recordProvisionedUsers(p.now()); err != nil {
+		p.API.LogError("can't record provisioned users", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	seats := make(map[string]int64)
+	if err := p.kvGetJSON(seatsKey, &seats); err != nil {
+		p.API.LogError("can't get seats", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	mau, err := p.monthlyActiveUsers()
+	if err != nil {
+		p.API.LogError("can't get monthly active users", "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+
+	months := make([]string, 0, len(seats))
+	for month := range seats {
+		months = append(months, month)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(months)))
+	if len(months) > nbMonthsOfSeats {
+		months = months[:nbMonthsOfSeats]
+	}
+
+	T := p.channelTranslate(args.ChannelId)
+	text := T("report.seats.title") + "\n"
+	if license := p.API.GetLicense(); license != nil && license.Features != nil && license.Features.Users != nil && *license.Features.Users > 0 {
+		text += T("report.seats.licensed", map[string]interface{}{"Seats": *license.Features.Users}) + "\n"
+	}
+	text += T("report.seats.columns") + "\n|:--|--:|--:|--:|\n"
+	for _, month := range months {
+		utilization := int64(0)
+		if seats[month] > 0 {
+			utilization = (mau[month] * 100) / seats[month]
+		}
+		text += fmt.Sprintf("| %s | %d | %d | %d%% |\n", month, seats[month], mau[month], utilization)
+	}
+	return ephemeralResponse(text)
+}
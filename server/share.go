@@ -0,0 +1,365 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	// sharedReportsKey store the shared report links, revoked and expired links are kept until pruned
+	sharedReportsKey = "shared_reports"
+	// sharedReportKeyPrefix prefix keys of the html of shared reports, by link id
+	sharedReportKeyPrefix = "shared_report:"
+	// shareSecretKey store the secret signing shared report links
+	shareSecretKey = "share_secret"
+	// sharedPathPrefix prefix the path of shared reports, followed by the link id
+	sharedPathPrefix = "/shared/"
+	// defaultShareDays and maxShareDays are the validity of shared report links
+	defaultShareDays = 7
+	maxShareDays     = 30
+)
+
+// SharedReport is a signed link giving access to a report rendered when it was shared, until ExpiresAt
+type SharedReport struct {
+	ID        string
+	Kind      string
+	Title     string
+	UserID    string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	RevokedAt time.Time
+}
+
+// active return true if the link can be opened at now
+func (s *SharedReport) active(now time.Time) bool {
+	return s.RevokedAt.IsZero() && now.Before(s.ExpiresAt)
+}
+
+// shareSignature return the signature of a link id valid until expires, in unix seconds
+func shareSignature(secret string, id string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + "|" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validShareSignature return true if signature is the one of the link id valid until expires
+func validShareSignature(secret string, id string, expires int64, signature string) bool {
+	return hmac.Equal([]byte(shareSignature(secret, id, expires)), []byte(signature))
+}
+
+// prunedSharedReports return links which can still be opened at now, revoked and expired ones are dropped
+func prunedSharedReports(shares []*SharedReport, now time.Time) []*SharedReport {
+	pruned := make([]*SharedReport, 0, len(shares))
+	for _, share := range shares {
+		if share.active(now) {
+			pruned = append(pruned, share)
+		}
+	}
+	return pruned
+}
+
+// shareSecret return the secret signing links, created on first use
+func (p *Plugin) shareSecret() (string, error) {
+	return p.signingSecret(shareSecretKey)
+}
+
+// signingSecret return the secret stored at key, created on first use
+func (p *Plugin) signingSecret(key string) (string, error) {
+	secret, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "can't get secret "+key)
+	}
+	if secret != nil {
+		return string(secret), nil
+	}
+	// another node may create the secret meanwhile, the first one wins
+	if _, appErr := p.API.KVCompareAndSet(key, nil, []byte(model.NewRandomString(64))); appErr != nil {
+		return "", errors.Wrap(appErr, "can't save secret "+key)
+	}
+	if secret, appErr = p.API.KVGet(key); appErr != nil {
+		return "", errors.Wrap(appErr, "can't get secret "+key)
+	}
+	return string(secret), nil
+}
+
+// sharedReportURL return the signed url of a shared report
+func (p *Plugin) sharedReportURL(share *SharedReport) (string, error) {
+	secret, err := p.shareSecret()
+	if err != nil {
+		return "", err
+	}
+	expires := share.ExpiresAt.Unix()
+	return fmt.Sprintf("%s/plugins/%s%s%s?expires=%d&signature=%s", *p.API.GetConfig().ServiceSettings.SiteURL, manifest.Id,
+		sharedPathPrefix, share.ID, expires, shareSignature(secret, share.ID, expires)), nil
+}
+
+var digestTemplate = template.Must(template.New("digest").Parse(`<!DOCTYPE html>
+<html dir="{{.Dir}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 40px; color: #333; }
+h1 { color: #FF8000; }
+section { margin-bottom: 24px; }
+.text { white-space: pre-wrap; }
+table { border-collapse: collapse; }
+th, td { border-bottom: 1px solid #ddd; padding: 8px; text-align: start; vertical-align: top; }
+td { white-space: pre-wrap; }
+img { display: block; max-width: 100%; margin-bottom: 16px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Attachments}}<section>
+{{if .Title}}<h2>{{.Title}}</h2>{{end}}
+{{if .Text}}<div class="text">{{.Text}}</div>{{end}}
+{{if .Fields}}<table>{{range .Fields}}<tr><th>{{.Title}}</th><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+</section>
+{{end}}{{range .Charts}}<img src="{{.}}" alt="chart">
+{{end}}</body>
+</html>
+`))
+
+// buildDigestReport render as html the digest of the analytic with its charts
+func (p *Plugin) buildDigestReport(title string, analytic *Analytic) ([]byte, error) {
+	attachments, err := p.buildAnalyticAttachments(analytic, false)
+	if err != nil {
+		return nil, err
+	}
+	rtl := p.digestRTL()
+	images, err := p.buildReportCharts(analytic, rtl)
+	if err != nil {
+		p.API.LogWarn("can't build chart images, report is shared without them", "err", err.Error())
+	}
+	charts := make([]template.URL, 0, len(images))
+	for _, image := range images {
+		charts = append(charts, template.URL("data:image/png;base64,"+base64.StdEncoding.EncodeToString(image.Content)))
+	}
+	dir := "ltr"
+	if rtl {
+		dir = "rtl"
+	}
+	var html bytes.Buffer
+	err = digestTemplate.Execute(&html, map[string]interface{}{
+		"Dir":         dir,
+		"Title":       title,
+		"Attachments": attachments,
+		"Charts":      charts,
+	})
+	return html.Bytes(), err
+}
+
+// shareReport render the report of kind (week, month or quarterly) and return its link, valid for days
+func (p *Plugin) shareReport(userID string, kind string, days int) (*SharedReport, string, error) {
+	now := p.now()
+	var title string
+	var html []byte
+	var err error
+	switch kind {
+	case "week":
+		title = "Analytics since " + p.currentAnalytic.Start.Format("January 2, 2006")
+		html, err = p.buildDigestReport(title, p.currentAnalytic)
+	case "month":
+		var month *Analytic
+		if month, err = p.monthAnalytic(now); err == nil {
+			title = "Analytics of the last 30 days"
+			html, err = p.buildDigestReport(title, month)
+		}
+	case "quarterly":
+		var period string
+		if period, html, err = p.buildQuarterlyReport(now); err == nil {
+			title = "Analytics " + period
+		}
+	default:
+		return nil, "", fmt.Errorf("unknown report %s", kind)
+	}
+	if err != nil {
+		return nil, "", errors.Wrap(err, "can't render report")
+	}
+
+	share := &SharedReport{ID: model.NewId(), Kind: kind, Title: title, UserID: userID, CreatedAt: now, ExpiresAt: now.AddDate(0, 0, days)}
+	if appErr := p.API.KVSetWithExpiry(sharedReportKeyPrefix+share.ID, html, int64(days*24*60*60)); appErr != nil {
+		return nil, "", errors.Wrap(appErr, "can't save shared report")
+	}
+	p.sharesLock.Lock()
+	shares := make([]*SharedReport, 0)
+	err = p.kvGetJSON(sharedReportsKey, &shares)
+	if err == nil {
+		err = p.kvSetJSON(sharedReportsKey, append(prunedSharedReports(shares, now), share))
+	}
+	p.sharesLock.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+	url, err := p.sharedReportURL(share)
+	if err != nil {
+		return nil, "", err
+	}
+	p.audit("report_shared", userID, map[string]string{"share_id": share.ID, "kind": kind, "days": strconv.Itoa(days)})
+	return share, url, nil
+}
+
+// revokeSharedReport revoke the link of id, its report is deleted, return false if there is no such active link
+func (p *Plugin) revokeSharedReport(userID string, id string) (bool, error) {
+	p.sharesLock.Lock()
+	defer p.sharesLock.Unlock()
+	shares := make([]*SharedReport, 0)
+	if err := p.kvGetJSON(sharedReportsKey, &shares); err != nil {
+		return false, err
+	}
+	now := p.now()
+	var revoked *SharedReport
+	for _, share := range shares {
+		if share.ID == id && share.active(now) {
+			share.RevokedAt = now
+			revoked = share
+		}
+	}
+	if revoked == nil {
+		return false, nil
+	}
+	if err := p.kvSetJSON(sharedReportsKey, shares); err != nil {
+		return false, err
+	}
+	if appErr := p.API.KVDelete(sharedReportKeyPrefix + id); appErr != nil {
+		return false, errors.Wrap(appErr, "can't delete shared report")
+	}
+	p.audit("share_revoked", userID, map[string]string{"share_id": id})
+	return true, nil
+}
+
+// activeSharedReport return the link of id if it can be opened at now, nil otherwise
+func (p *Plugin) activeSharedReport(id string, now time.Time) (*SharedReport, error) {
+	shares := make([]*SharedReport, 0)
+	if err := p.kvGetJSON(sharedReportsKey, &shares); err != nil {
+		return nil, err
+	}
+	for _, share := range shares {
+		if share.ID == id && share.active(now) {
+			return share, nil
+		}
+	}
+	return nil, nil
+}
+
+// handleSharedReport serve `GET /shared/<id>?expires=&signature=` the report of a link to anyone with a valid signature,
+// without mattermost session
+func (p *Plugin) handleSharedReport(w http.ResponseWriter, r *http.Request) error {
+	id := strings.TrimPrefix(r.URL.Path, sharedPathPrefix)
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil || !model.IsValidId(id) {
+		http.NotFound(w, r)
+		return nil
+	}
+	secret, err := p.shareSecret()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	if !validShareSignature(secret, id, expires, r.URL.Query().Get("signature")) {
+		http.NotFound(w, r)
+		return nil
+	}
+	now := p.now()
+	share, err := p.activeSharedReport(id, now)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+	if share == nil || !now.Before(time.Unix(expires, 0)) {
+		http.Error(w, "this link has expired or was revoked", http.StatusGone)
+		return nil
+	}
+	html, appErr := p.API.KVGet(sharedReportKeyPrefix + id)
+	if appErr != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return appErr
+	}
+	if html == nil {
+		http.Error(w, "this link has expired or was revoked", http.StatusGone)
+		return nil
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	_, err = w.Write(html)
+	return err
+}
+
+// executeShareCommand handle `/analytics share [week|month|quarterly] [days]`, `/analytics share list` and
+// `/analytics share revoke <id>`
+func (p *Plugin) executeShareCommand(args *model.CommandArgs, parameters []string) *model.CommandResponse {
+	if !p.isSystemAdmin(args.UserId) {
+		return ephemeralResponse("Only system admins can share reports.")
+	}
+	const usage = "Usage: /analytics share [week|month|quarterly] [days], /analytics share list or /analytics share revoke <id>"
+	kind := "week"
+	if len(parameters) > 0 {
+		kind = parameters[0]
+	}
+
+	switch kind {
+	case "list":
+		shares := make([]*SharedReport, 0)
+		if err := p.kvGetJSON(sharedReportsKey, &shares); err != nil {
+			p.API.LogError("can't get shared reports", "err", err.Error())
+			return ephemeralResponse("An error occured!")
+		}
+		shares = prunedSharedReports(shares, p.now())
+		if len(shares) == 0 {
+			return ephemeralResponse("No shared report link is active.")
+		}
+		text := "#### Shared report links\n"
+		for _, share := range shares {
+			text += fmt.Sprintf("* `%s` %s, expires on %s\n", share.ID, share.Title, share.ExpiresAt.Format("January 2, 2006 15:04"))
+		}
+		return ephemeralResponse(text)
+	case "revoke":
+		if len(parameters) != 2 {
+			return ephemeralResponse(usage)
+		}
+		revoked, err := p.revokeSharedReport(args.UserId, parameters[1])
+		if err != nil {
+			p.API.LogError("can't revoke shared report", "err", err.Error())
+			return ephemeralResponse("An error occured!")
+		}
+		if !revoked {
+			return ephemeralResponse(fmt.Sprintf("No active shared report link %s.", parameters[1]))
+		}
+		return ephemeralResponse(fmt.Sprintf("Shared report link %s is revoked.", parameters[1]))
+	case "week", "month", "quarterly":
+	default:
+		return ephemeralResponse(usage)
+	}
+
+	days := defaultShareDays
+	if len(parameters) > 1 {
+		var err error
+		if days, err = strconv.Atoi(parameters[1]); err != nil || days < 1 || days > maxShareDays {
+			return ephemeralResponse(fmt.Sprintf("Bad number of days %s, expected 1 to %d.", parameters[1], maxShareDays))
+		}
+	}
+	if len(parameters) > 2 {
+		return ephemeralResponse(usage)
+	}
+	share, url, err := p.shareReport(args.UserId, kind, days)
+	if err != nil {
+		p.API.LogError("can't share report", "kind", kind, "err", err.Error())
+		return ephemeralResponse("An error occured!")
+	}
+	return ephemeralResponse(fmt.Sprintf("Anyone with this link can see the report until %s, revoke it with `/analytics share revoke %s`:\n%s",
+		share.ExpiresAt.Format("January 2, 2006 15:04"), share.ID, url))
+}
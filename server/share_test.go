@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShareSignature(t *testing.T) {
+	assert := assert.New(t)
+
+	signature := shareSignature("secret", "id", 1600000000)
+	assert.Len(signature, 64)
+	assert.True(validShareSignature("secret", "id", 1600000000, signature))
+	assert.False(validShareSignature("secret", "id", 1700000000, signature))
+	assert.False(validShareSignature("secret", "other", 1600000000, signature))
+	assert.False(validShareSignature("other", "id", 1600000000, signature))
+	assert.False(validShareSignature("secret", "id", 1600000000, ""))
+}
+
+func TestPrunedSharedReports(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	active := &SharedReport{ID: "active", ExpiresAt: now.Add(time.Hour)}
+	expired := &SharedReport{ID: "expired", ExpiresAt: now}
+	revoked := &SharedReport{ID: "revoked", ExpiresAt: now.Add(time.Hour), RevokedAt: now.Add(-time.Minute)}
+
+	assert.True(active.active(now))
+	assert.False(expired.active(now))
+	assert.False(revoked.active(now))
+	assert.Equal([]*SharedReport{active}, prunedSharedReports([]*SharedReport{expired, active, revoked}, now))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	silenceAlertsKey = "silence_alerts"
+	// defaultSilenceHours is the silence of a critical channel before an alert when SilenceHours is not set
+	defaultSilenceHours = 24
+)
+
+// silentChannels return channels without post for longer than threshold, except those already alerted for
+// the same last post, lastPosts and alerted are last post dates in milliseconds by channel id
+func silentChannels(lastPosts map[string]int64, alerted map[string]int64, now time.Time, threshold time.Duration) []string {
+	silent := make([]string, 0)
+	for channelID, lastPostAt := range lastPosts {
+		if alerted[channelID] == lastPostAt {
+			continue
+		}
+		if now.Sub(time.Unix(0, lastPostAt*int64(time.Millisecond))) >= threshold {
+			silent = append(silent, channelID)
+		}
+	}
+	sort.Strings(silent)
+	return silent
+}
+
+// silenceThreshold return the configured silence before an alert
+func (c *configuration) silenceThreshold() time.Duration {
+	if c.SilenceHours <= 0 {
+		return defaultSilenceHours * time.Hour
+	}
+	return time.Duration(c.SilenceHours) * time.Hour
+}
+
+// checkSilentChannels DM system admins once for each critical channel silent for longer than the threshold
+func (p *Plugin) checkSilentChannels() error {
+	if len(p.CriticalChannelsID) == 0 {
+		return nil
+	}
+	alerted := make(map[string]int64)
+	if err := p.kvGetJSON(silenceAlertsKey, &alerted); err != nil {
+		return err
+	}
+	lastPosts := make(map[string]int64)
+	for channelID := range p.CriticalChannelsID {
+		channel, appErr := p.API.GetChannel(channelID)
+		if appErr != nil {
+			p.API.LogWarn("can't get critical channel", "channel", channelID, "err", appErr.Error())
+			continue
+		}
+		lastPosts[channelID] = channel.LastPostAt
+	}
+
+	threshold := p.getConfiguration().silenceThreshold()
+	silent := silentChannels(lastPosts, alerted, p.now(), threshold)
+	if len(silent) == 0 {
+		return nil
+	}
+	admins, err := p.getSystemAdmins()
+	if err != nil {
+		return err
+	}
+	for _, channelID := range silent {
+		_, displayName, link, err := p.getChannelName(channelID)
+		if err != nil {
+			return err
+		}
+		lastPost := time.Unix(0, lastPosts[channelID]*int64(time.Millisecond)).In(p.now().Location())
+		message := fmt.Sprintf(":warning: Critical channel [~%s](%s) has no message since %s, more than %d hours.", displayName, link, lastPost.Format("January 2, 2006 15:04"), int(threshold.Hours()))
+		for _, adminID := range admins {
+			if err := p.sendDirectMessage(adminID, message, nil); err != nil {
+				p.API.LogError("can't send silence alert to admin", "user", adminID, "err", err.Error())
+			}
+		}
+		alerted[channelID] = lastPosts[channelID]
+	}
+	return p.kvSetJSON(silenceAlertsKey, alerted)
+}
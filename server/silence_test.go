@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSilentChannels(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2019, time.April, 18, 12, 0, 0, 0, time.UTC)
+	millis := func(date time.Time) int64 {
+		return date.UnixNano() / int64(time.Millisecond)
+	}
+	lastPosts := map[string]int64{
+		"standup":  millis(now.Add(-30 * time.Hour)),
+		"ops":      millis(now.Add(-2 * time.Hour)),
+		"alerted":  millis(now.Add(-48 * time.Hour)),
+		"relapsed": millis(now.Add(-25 * time.Hour)),
+	}
+	alerted := map[string]int64{
+		"alerted":  millis(now.Add(-48 * time.Hour)),
+		"relapsed": millis(now.Add(-72 * time.Hour)),
+	}
+
+	assert.Equal([]string{"relapsed", "standup"}, silentChannels(lastPosts, alerted, now, 24*time.Hour))
+	assert.Equal(24*time.Hour, (&configuration{}).silenceThreshold())
+	assert.Equal(4*time.Hour, (&configuration{SilenceHours: 4}).silenceThreshold())
+}
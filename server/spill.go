@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	spilledCountersKey = "spilled_counters"
+
+	// accumulatorCheckEvents is the number of events between two estimations of the size of the current session
+	accumulatorCheckEvents = 500
+	// counterOverhead is the estimated memory used by a map entry besides its key
+	counterOverhead = 48
+)
+
+// namedCounters return the counters of the analytic by field name
+// caller must hold the lock of the analytic
+func (a *Analytic) namedCounters() map[string]*map[string]int64 {
+	return map[string]*map[string]int64{
+		"Channels": &a.Channels, "ChannelsReply": &a.ChannelsReply, "Users": &a.Users, "UsersReply": &a.UsersReply,
+		"ChannelsFilesSize": &a.ChannelsFilesSize, "Hourly": &a.Hourly, "Threads": &a.Threads,
+		"ChannelsWords": &a.ChannelsWords, "Scripts": &a.Scripts, "ChannelsUsers": &a.ChannelsUsers,
+		"ChannelsUsersReply": &a.ChannelsUsersReply, "Mentions": &a.Mentions, "ChannelsBroadcasts": &a.ChannelsBroadcasts,
+		"Lengths": &a.Lengths, "External": &a.External, "ChannelsEdits": &a.ChannelsEdits,
+		"ChannelsDeletions": &a.ChannelsDeletions, "RetractedThreads": &a.RetractedThreads,
+	}
+}
+
+// countersSize return an estimation in bytes of the memory used by counters
+func countersSize(counters map[string]int64) int64 {
+	size := int64(0)
+	for key := range counters {
+		size += int64(len(key)) + counterOverhead
+	}
+	return size
+}
+
+// estimatedSize return an estimation in bytes of the memory used by the counters of the analytic
+// caller must hold the read lock of the analytic
+func (a *Analytic) estimatedSize() int64 {
+	size := int64(0)
+	for _, counters := range a.namedCounters() {
+		size += countersSize(*counters)
+	}
+	return size
+}
+
+// spillThreads remove the threads with the fewest replies from the analytic until its estimated size is at most
+// target and return them by field name, other counters are read by reports, the on-demand commands and the API so
+// they are never spilled, the longest threads shown in reports are kept
+// caller must hold the write lock of the analytic
+func (a *Analytic) spillThreads(target int64) map[string]map[string]int64 {
+	size := a.estimatedSize()
+	if size <= target || len(a.Threads) <= maxThreadsToDisplay {
+		return nil
+	}
+	threads := topCounters(a.Threads, len(a.Threads))
+	tail := make(map[string]int64)
+	for index := len(threads) - 1; index >= maxThreadsToDisplay && size > target; index-- {
+		tail[threads[index].key] = threads[index].nb
+		delete(a.Threads, threads[index].key)
+		size -= int64(len(threads[index].key)) + counterOverhead
+	}
+	return map[string]map[string]int64{"Threads": tail}
+}
+
+// mergeNamedCounters add counters by field name to the counters of the analytic
+// caller must hold the write lock of the analytic
+func (a *Analytic) mergeNamedCounters(spilled map[string]map[string]int64) {
+	named := a.namedCounters()
+	for name, counters := range spilled {
+		if to, ok := named[name]; ok {
+			mergeCounters(*to, counters)
+		}
+	}
+}
+
+// restoreCounters merge spilled counters by field name back in the analytic and retract from spilled threads the
+// replies deleted while they were spilled
+// caller must hold the write lock of the analytic
+func (a *Analytic) restoreCounters(spilled map[string]map[string]int64) {
+	a.mergeNamedCounters(spilled)
+	for rootID, nb := range a.RetractedThreads {
+		if _, ok := spilled["Threads"][rootID]; ok {
+			decrementCounter(a.Threads, rootID, nb)
+		}
+	}
+	a.RetractedThreads = make(map[string]int64)
+}
+
+// checkAccumulatorSize spill the current session in background when it exceeds AccumulatorMemoryLimit, unless a
+// spill is already running
+// caller must hold the write lock of the current analytic
+func (p *Plugin) checkAccumulatorSize() {
+	p.accumulatorEvents++
+	limit := int64(p.getConfiguration().AccumulatorMemoryLimit) * 1000 * 1000
+	if limit <= 0 || p.accumulatorEvents%accumulatorCheckEvents != 0 || p.currentAnalytic.estimatedSize() <= limit {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&p.spilling, 0, 1) {
+		return
+	}
+	go func() {
+		defer atomic.StoreInt32(&p.spilling, 0)
+		if err := p.spillAccumulator(limit / 2); err != nil {
+			p.API.LogError("can't spill current analytic", "err", err.Error())
+		}
+	}()
+}
+
+// spillAccumulator move the shortest threads of the current session to kv until its estimated size is at most
+// target, so it doesn't spill again right away, they are restored before the scheduled report so reports generated
+// meanwhile skip spilled threads
+func (p *Plugin) spillAccumulator(target int64) error {
+	p.spillLock.Lock()
+	defer p.spillLock.Unlock()
+
+	p.currentAnalytic.WLock()
+	counters := p.currentAnalytic.spillThreads(target)
+	p.currentAnalytic.WUnlock()
+	if len(counters) == 0 {
+		return nil
+	}
+	restore := func() {
+		p.currentAnalytic.WLock()
+		p.currentAnalytic.mergeNamedCounters(counters)
+		p.currentAnalytic.WUnlock()
+	}
+
+	// the session is saved without spilled counters first, a crash before they are spilled loses them instead of
+	// counting them twice
+	if err := p.saveCurrentAnalytic(); err != nil {
+		restore()
+		return err
+	}
+	spilled := make(map[string]map[string]int64)
+	if err := p.kvGetJSON(spilledCountersKey, &spilled); err != nil {
+		restore()
+		return err
+	}
+	names := make([]string, 0, len(counters))
+	for name, values := range counters {
+		if spilled[name] == nil {
+			spilled[name] = make(map[string]int64)
+		}
+		mergeCounters(spilled[name], values)
+		names = append(names, name)
+	}
+	if err := p.kvSetJSON(spilledCountersKey, spilled); err != nil {
+		restore()
+		return err
+	}
+	sort.Strings(names)
+	p.API.LogInfo("current analytic exceeded its memory limit, counters spilled to kv", "counters", strings.Join(names, ","), "threads", fmt.Sprintf("%d", len(counters["Threads"])))
+	return nil
+}
+
+// restoreSpilled merge counters spilled to kv back in the current session, replies deleted meanwhile are retracted
+func (p *Plugin) restoreSpilled() error {
+	p.spillLock.Lock()
+	defer p.spillLock.Unlock()
+
+	spilled := make(map[string]map[string]int64)
+	if err := p.kvGetJSON(spilledCountersKey, &spilled); err != nil {
+		return err
+	}
+	if len(spilled) == 0 {
+		return nil
+	}
+	p.currentAnalytic.WLock()
+	p.currentAnalytic.restoreCounters(spilled)
+	p.currentAnalytic.WUnlock()
+	if appErr := p.API.KVDelete(spilledCountersKey); appErr != nil {
+		return errors.Wrap(appErr, "can't delete spilled counters")
+	}
+	return nil
+}
+
+// scrubSpilled remove counters of userID and mentions of username from counters spilled to kv, see scrubUser
+func (p *Plugin) scrubSpilled(userID string, username string) (int, error) {
+	p.spillLock.Lock()
+	defer p.spillLock.Unlock()
+
+	spilled := make(map[string]map[string]int64)
+	if err := p.kvGetJSON(spilledCountersKey, &spilled); err != nil {
+		return 0, err
+	}
+	if len(spilled) == 0 {
+		return 0, nil
+	}
+	analytic := NewAnalytic(p.now())
+	analytic.mergeNamedCounters(spilled)
+	removed := scrubUser(analytic, userID, username)
+	if removed == 0 {
+		return 0, nil
+	}
+	for name, counters := range analytic.namedCounters() {
+		if _, ok := spilled[name]; ok {
+			spilled[name] = *counters
+		}
+	}
+	return removed, p.kvSetJSON(spilledCountersKey, spilled)
+}
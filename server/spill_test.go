@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimatedSize(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	assert.Equal(int64(0), analytic.estimatedSize())
+
+	analytic.Channels["channel1"] = 10
+	analytic.Threads["root1"] = 3
+	analytic.ChannelsUsers["channel1:user1"] = 10
+	assert.Equal(int64(len("channel1")+len("root1")+len("channel1:user1")+3*counterOverhead), analytic.estimatedSize())
+
+	analytic.Threads["root1"] = 4
+	assert.Equal(int64(len("channel1")+len("root1")+len("channel1:user1")+3*counterOverhead), analytic.estimatedSize())
+}
+
+func TestNamedCounters(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	counters := 0
+	analyticType := reflect.TypeOf(analytic).Elem()
+	for index := 0; index < analyticType.NumField(); index++ {
+		if field := analyticType.Field(index); field.Type.Kind() == reflect.Map {
+			counters++
+			assert.Contains(analytic.namedCounters(), field.Name)
+		}
+	}
+	assert.Len(analytic.namedCounters(), counters)
+}
+
+func TestSpillThreads(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	analytic.Channels["channel1"] = 10
+	for index, nb := range []int64{5, 1, 4, 2, 3} {
+		analytic.Threads[fmt.Sprintf("root%d", index+1)] = nb
+	}
+	analytic.ChannelsUsers["channel1:user1"] = 6
+
+	assert.Empty(analytic.spillThreads(analytic.estimatedSize()))
+
+	// the shortest thread is spilled first
+	spilled := analytic.spillThreads(analytic.estimatedSize() - 1)
+	assert.Equal(map[string]map[string]int64{"Threads": {"root2": 1}}, spilled)
+
+	// the longest threads shown in reports and other counters are never spilled
+	spilled = analytic.spillThreads(0)
+	assert.Equal(map[string]map[string]int64{"Threads": {"root4": 2}}, spilled)
+	assert.Equal(map[string]int64{"root1": 5, "root3": 4, "root5": 3}, analytic.Threads)
+	assert.Equal(map[string]int64{"channel1": 10}, analytic.Channels)
+	assert.Equal(map[string]int64{"channel1:user1": 6}, analytic.ChannelsUsers)
+	assert.Empty(analytic.spillThreads(0))
+}
+
+func TestRestoreCounters(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	analytic.Threads["root1"] = 5
+	analytic.Threads["root2"] = 1
+	analytic.Threads["root3"] = 4
+	analytic.Threads["root4"] = 3
+	analytic.Threads["root5"] = 2
+	spilled := analytic.spillThreads(0)
+	assert.Equal(map[string]map[string]int64{"Threads": {"root2": 1, "root5": 2}}, spilled)
+
+	// replies deleted from spilled threads are retracted once they are restored
+	analytic.apply(JournalEvent{Kind: journalDelete, ChannelID: "channel1", RootID: "root5", Retract: true})
+	analytic.apply(JournalEvent{Kind: journalDelete, ChannelID: "channel1", RootID: "root1", Retract: true})
+	assert.Equal(map[string]int64{"root5": 1}, analytic.RetractedThreads)
+	analytic.apply(JournalEvent{Kind: journalPost, ChannelID: "channel1", RootID: "root2", Reply: true})
+
+	analytic.restoreCounters(spilled)
+	assert.Equal(map[string]int64{"root1": 4, "root2": 2, "root3": 4, "root4": 3, "root5": 1}, analytic.Threads)
+	assert.Empty(analytic.RetractedThreads)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql" // mysql driver of the mattermost database
+	_ "github.com/lib/pq"              // postgres driver of the mattermost database
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	storageBackendKV  = "kv"
+	storageBackendSQL = "sql"
+
+	// sqlDailyTable store daily buckets in the mattermost database when StorageBackend is sql
+	sqlDailyTable = "AnalyticsDailyBuckets"
+)
+
+// sqlDailyStore keep each bucket in a row of sqlDailyTable, counters are in json so new metrics don't need migrations
+type sqlDailyStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// openSQLDailyStore connect to the mattermost database and create the table of daily buckets if needed
+func openSQLDailyStore(settings model.SqlSettings) (*sqlDailyStore, error) {
+	if settings.DriverName == nil || settings.DataSource == nil {
+		return nil, errors.New("no database settings")
+	}
+	driver := *settings.DriverName
+	if driver != model.DATABASE_DRIVER_POSTGRES && driver != model.DATABASE_DRIVER_MYSQL {
+		return nil, errors.New("unsupported database driver " + driver)
+	}
+	db, err := sql.Open(driver, *settings.DataSource)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't open database")
+	}
+	db.SetMaxOpenConns(4)
+	s := &sqlDailyStore{db: db, driver: driver}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + sqlDailyTable + ` (
+		Day VARCHAR(10) NOT NULL,
+		Scope VARCHAR(8) NOT NULL,
+		BucketId VARCHAR(190) NOT NULL,
+		Counters TEXT NOT NULL,
+		UpdatedAt BIGINT NOT NULL,
+		PRIMARY KEY (Day, Scope, BucketId)
+	)`); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "can't create table "+sqlDailyTable)
+	}
+	return s, nil
+}
+
+// rebind replace ? placeholders of query by $1, $2... for postgres
+func rebind(driver string, query string) string {
+	if driver != model.DATABASE_DRIVER_POSTGRES {
+		return query
+	}
+	var rebound strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			rebound.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		rebound.WriteRune(r)
+	}
+	return rebound.String()
+}
+
+// insertIgnoreQuery return the query inserting a bucket unless it already exists
+func insertIgnoreQuery(driver string) string {
+	if driver == model.DATABASE_DRIVER_POSTGRES {
+		return rebind(driver, `INSERT INTO `+sqlDailyTable+` (Day, Scope, BucketId, Counters, UpdatedAt) VALUES (?, ?, ?, ?, ?) ON CONFLICT (Day, Scope, BucketId) DO NOTHING`)
+	}
+	return `INSERT IGNORE INTO ` + sqlDailyTable + ` (Day, Scope, BucketId, Counters, UpdatedAt) VALUES (?, ?, ?, ?, ?)`
+}
+
+// upsertQuery return the query inserting a bucket or replacing its counters
+func upsertQuery(driver string) string {
+	if driver == model.DATABASE_DRIVER_POSTGRES {
+		return rebind(driver, `INSERT INTO `+sqlDailyTable+` (Day, Scope, BucketId, Counters, UpdatedAt) VALUES (?, ?, ?, ?, ?) ON CONFLICT (Day, Scope, BucketId) DO UPDATE SET Counters = EXCLUDED.Counters, UpdatedAt = EXCLUDED.UpdatedAt`)
+	}
+	return `INSERT INTO ` + sqlDailyTable + ` (Day, Scope, BucketId, Counters, UpdatedAt) VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE Counters = VALUES(Counters), UpdatedAt = VALUES(UpdatedAt)`
+}
+
+func (s *sqlDailyStore) close() error {
+	return s.db.Close()
+}
+
+func (s *sqlDailyStore) increment(date time.Time, scope string, id string, delta DailyCounters, stamp int64) error {
+	day := date.Format(dailyKeyFormat)
+	tx, err := s.db.Begin()
+	if err != nil {
+		return errors.Wrap(err, "can't begin transaction")
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(insertIgnoreQuery(s.driver), day, scope, id, "{}", stamp); err != nil {
+		return errors.Wrap(err, "can't insert "+dailyKey(date, scope, id))
+	}
+	var value string
+	if err := tx.QueryRow(rebind(s.driver, `SELECT Counters FROM `+sqlDailyTable+` WHERE Day = ? AND Scope = ? AND BucketId = ? FOR UPDATE`), day, scope, id).Scan(&value); err != nil {
+		return errors.Wrap(err, "can't lock "+dailyKey(date, scope, id))
+	}
+	var counters DailyCounters
+	if err := json.Unmarshal([]byte(value), &counters); err != nil {
+		return errors.Wrap(err, "can't unmarshal "+dailyKey(date, scope, id))
+	}
+	counters.add(delta)
+	counters.UpdatedAt = stamp
+	j, err := json.Marshal(counters)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal "+dailyKey(date, scope, id))
+	}
+	if _, err := tx.Exec(rebind(s.driver, `UPDATE `+sqlDailyTable+` SET Counters = ?, UpdatedAt = ? WHERE Day = ? AND Scope = ? AND BucketId = ?`), string(j), stamp, day, scope, id); err != nil {
+		return errors.Wrap(err, "can't save "+dailyKey(date, scope, id))
+	}
+	return errors.Wrap(tx.Commit(), "can't commit "+dailyKey(date, scope, id))
+}
+
+func (s *sqlDailyStore) get(date time.Time, scope string, id string) (DailyCounters, error) {
+	var counters DailyCounters
+	var value string
+	err := s.db.QueryRow(rebind(s.driver, `SELECT Counters FROM `+sqlDailyTable+` WHERE Day = ? AND Scope = ? AND BucketId = ?`), date.Format(dailyKeyFormat), scope, id).Scan(&value)
+	if err == sql.ErrNoRows {
+		return counters, nil
+	} else if err != nil {
+		return counters, errors.Wrap(err, "can't get "+dailyKey(date, scope, id))
+	}
+	if err := json.Unmarshal([]byte(value), &counters); err != nil {
+		return counters, errors.Wrap(err, "can't unmarshal "+dailyKey(date, scope, id))
+	}
+	return counters, nil
+}
+
+func (s *sqlDailyStore) set(bucket dailyBucket) error {
+	j, err := json.Marshal(bucket.Counters)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal "+dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+	}
+	if _, err := s.db.Exec(upsertQuery(s.driver), bucket.Date.Format(dailyKeyFormat), bucket.Scope, bucket.ID, string(j), bucket.Counters.UpdatedAt); err != nil {
+		return errors.Wrap(err, "can't save "+dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+	}
+	return nil
+}
+
+func (s *sqlDailyStore) restore(bucket dailyBucket) (bool, error) {
+	j, err := json.Marshal(bucket.Counters)
+	if err != nil {
+		return false, errors.Wrap(err, "can't marshal "+dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+	}
+	result, err := s.db.Exec(insertIgnoreQuery(s.driver), bucket.Date.Format(dailyKeyFormat), bucket.Scope, bucket.ID, string(j), bucket.Counters.UpdatedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "can't restore "+dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "can't restore "+dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+	}
+	return inserted == 1, nil
+}
+
+func (s *sqlDailyStore) list(include func(scope string) bool, from time.Time, to time.Time) ([]dailyBucket, error) {
+	rows, err := s.db.Query(rebind(s.driver, `SELECT Day, Scope, BucketId, Counters FROM `+sqlDailyTable+` WHERE Day >= ? AND Day <= ?`), from.Format(dailyKeyFormat), to.Format(dailyKeyFormat))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't list daily buckets")
+	}
+	defer rows.Close()
+	buckets := make([]dailyBucket, 0)
+	for rows.Next() {
+		var day, value string
+		var bucket dailyBucket
+		if err := rows.Scan(&day, &bucket.Scope, &bucket.ID, &value); err != nil {
+			return nil, errors.Wrap(err, "can't read daily bucket")
+		}
+		if !include(bucket.Scope) {
+			continue
+		}
+		if bucket.Date, err = time.ParseInLocation(dailyKeyFormat, day, from.Location()); err != nil {
+			continue
+		}
+		if err := json.Unmarshal([]byte(value), &bucket.Counters); err != nil {
+			return nil, errors.Wrap(err, "can't unmarshal "+dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, errors.Wrap(rows.Err(), "can't list daily buckets")
+}
+
+func (s *sqlDailyStore) delete(buckets []dailyBucket) error {
+	for _, bucket := range buckets {
+		if _, err := s.db.Exec(rebind(s.driver, `DELETE FROM `+sqlDailyTable+` WHERE Day = ? AND Scope = ? AND BucketId = ?`), bucket.Date.Format(dailyKeyFormat), bucket.Scope, bucket.ID); err != nil {
+			return errors.Wrap(err, "can't delete "+dailyKey(bucket.Date, bucket.Scope, bucket.ID))
+		}
+	}
+	return nil
+}
+
+func (s *sqlDailyStore) deleteBefore(cutoff string) (int, error) {
+	result, err := s.db.Exec(rebind(s.driver, `DELETE FROM `+sqlDailyTable+` WHERE Day < ?`), cutoff)
+	if err != nil {
+		return 0, errors.Wrap(err, "can't delete daily buckets before "+cutoff)
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), errors.Wrap(err, "can't count daily buckets deleted before "+cutoff)
+}
+
+// configureDailyStore switch daily buckets to the storage backend of configuration, buckets are copied in the
+// background to the other store when they are missing or older there, then served from it, so both stores stay in
+// sync across switches, the kv store is also the fallback of the database while it fails, see fallbackDailyStore
+func (p *Plugin) configureDailyStore(configuration *configuration) error {
+	p.dailyStoreLock.Lock()
+	defer p.dailyStoreLock.Unlock()
+	backend := storageBackendKV
+	if configuration.StorageBackend == storageBackendSQL {
+		backend = storageBackendSQL
+	}
+	if p.dailyStoreSwitching == backend {
+		return nil
+	}
+	if p.dailyStoreSwitching != "" {
+		// stop the switch to the other backend, its progress is dropped by the next switch
+		p.dailyStoreGeneration++
+		p.dailyStoreSwitching = ""
+	}
+
+	if p.dailyStore == nil {
+		stored := storageBackendKV
+		if err := p.kvGetJSON(dailyStoreBackendKey, &stored); err != nil {
+			return err
+		}
+		if stored == storageBackendSQL {
+			// latest buckets are in the database, after a restart or a switch to the kv store not complete yet
+			s, err := p.openDailyDatabase()
+			if err != nil {
+				return err
+			}
+			p.dailyStore = s
+		}
+	}
+	serving := storageBackendKV
+	if p.dailyStore != nil {
+		serving = storageBackendSQL
+	}
+	if serving == backend {
+		return nil
+	}
+
+	var current, next dailyStore = &kvDailyStore{p: p}, &kvDailyStore{p: p}
+	if p.dailyStore != nil {
+		current = p.dailyStore
+	}
+	if backend == storageBackendSQL {
+		s, err := p.openDailyDatabase()
+		if err != nil {
+			return err
+		}
+		next = s
+	}
+	p.dailyStoreGeneration++
+	p.dailyStoreSwitching = backend
+	p.API.LogInfo("switching daily buckets to storage backend", "backend", backend)
+	go p.switchDailyStore(backend, current, next, p.dailyStoreGeneration)
+	return nil
+}
+
+// closeDailyStore stop a running switch of storage backend and close the database connection of the sql storage
+// backend, if any
+func (p *Plugin) closeDailyStore() {
+	p.dailyStoreLock.Lock()
+	defer p.dailyStoreLock.Unlock()
+	p.dailyStoreGeneration++
+	p.dailyStoreSwitching = ""
+	if err := closeDailyDatabase(p.dailyStore); err != nil {
+		p.API.LogError("can't close database", "err", err.Error())
+	}
+	p.dailyStore = nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	assert := assert.New(t)
+
+	query := "SELECT Counters FROM T WHERE Day = ? AND Scope = ? AND BucketId = ?"
+	assert.Equal(query, rebind("mysql", query))
+	assert.Equal("SELECT Counters FROM T WHERE Day = $1 AND Scope = $2 AND BucketId = $3", rebind("postgres", query))
+	assert.Equal("DELETE FROM T", rebind("postgres", "DELETE FROM T"))
+}
+
+func TestUpsertQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Contains(upsertQuery("postgres"), "VALUES ($1, $2, $3, $4, $5) ON CONFLICT")
+	assert.Contains(upsertQuery("mysql"), "VALUES (?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE")
+	assert.Contains(insertIgnoreQuery("postgres"), "DO NOTHING")
+	assert.Contains(insertIgnoreQuery("mysql"), "INSERT IGNORE")
+}
+
+func TestParseDailyKey(t *testing.T) {
+	assert := assert.New(t)
+
+	location, err := loadLocation("America/New_York")
+	assert.Nil(err)
+	bucket, ok := parseDailyKey("analytics:2020-03-16:x:com.example.jira.issues_created", location)
+	assert.True(ok)
+	assert.Equal("2020-03-16", bucket.Date.Format(dailyKeyFormat))
+	assert.Equal(location, bucket.Date.Location())
+	assert.Equal(dailyScopeExternal, bucket.Scope)
+	assert.Equal("com.example.jira.issues_created", bucket.ID)
+
+	for _, bad := range []string{"analytics:2020-03-16:c", "analytics:16-03-2020:c:channel1", "tombstone:analytics:2020-03-16:c:channel1"} {
+		_, ok = parseDailyKey(bad, location)
+		assert.False(ok, bad)
+	}
+}
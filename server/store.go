@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	dailyKeyPrefix    = "analytics:"
+	dailyKeyFormat    = "2006-01-02"
+	dailyScopeChannel = "c"
+	dailyScopeUser    = "u"
+	// dailyScopeChannelMembers and dailyScopeTeamMembers store joins and leaves, apart from channel buckets rebuilt by backfill
+	dailyScopeChannelMembers = "cm"
+	dailyScopeTeamMembers    = "tm"
+	// dailyScopeResponseTimes store delays before the first reply to root posts of a channel
+	dailyScopeResponseTimes = "rt"
+	// dailyScopeDirect store the number of direct and group messages of all users under directMessagesID
+	dailyScopeDirect = "dm"
+	directMessagesID = "all"
+	// dailyScopeExternal store values of collectors registered by other plugins by collector key
+	dailyScopeExternal = "x"
+
+	// maxIncrementAttempts is the number of compare and set tries before giving up an increment
+	maxIncrementAttempts = 10
+)
+
+// DailyCounters are the metrics of a channel or a user during a day, they survive restarts and upgrades
+type DailyCounters struct {
+	Messages  int64
+	Replies   int64
+	FilesSize int64
+	// Joins and Leaves are membership changes of a channel or a team
+	Joins  int64 `json:",omitempty"`
+	Leaves int64 `json:",omitempty"`
+	// ResponseTimes are seconds before the first reply of someone else to root posts of a channel
+	ResponseTimes []int64 `json:",omitempty"`
+	// Value is the sum of the values of an external collector
+	Value int64 `json:",omitempty"`
+	// UpdatedAt is the stamp of the last change of the bucket, see changeStamp
+	UpdatedAt int64 `json:",omitempty"`
+}
+
+// add counters of other to c
+func (c *DailyCounters) add(other DailyCounters) {
+	c.Messages += other.Messages
+	c.Replies += other.Replies
+	c.FilesSize += other.FilesSize
+	c.Joins += other.Joins
+	c.Leaves += other.Leaves
+	c.ResponseTimes = append(c.ResponseTimes, other.ResponseTimes...)
+	c.Value += other.Value
+}
+
+// dailyKey return the kv key of the bucket of id in scope for the day of date, e.g. analytics:2019-05-01:c:channelID
+func dailyKey(date time.Time, scope string, id string) string {
+	return dailyKeyPrefix + date.Format(dailyKeyFormat) + ":" + scope + ":" + id
+}
+
+// incrementDaily atomically add delta to the bucket of id in scope for the day of date, or to the write buffer
+// when WriteBufferSeconds is set, a flush starts once WriteBufferEvents increments are buffered
+func (p *Plugin) incrementDaily(date time.Time, scope string, id string, delta DailyCounters) error {
+	config := p.getConfiguration()
+	if config.WriteBufferSeconds <= 0 {
+		return p.store().increment(date, scope, id, delta, changeStamp(p.now()))
+	}
+	if events := p.bufferDaily(date, scope, id, delta); events == config.WriteBufferEvents {
+		go func() {
+			if err := p.flushWriteBuffer(); err != nil {
+				p.API.LogError("can't flush daily analytics", "err", err.Error())
+			}
+		}()
+	}
+	return nil
+}
+
+// recordDaily store the delta of a post in daily buckets of its channel and its author, if any
+func (p *Plugin) recordDaily(date time.Time, channelID string, userID string, delta DailyCounters) {
+	if err := p.incrementDaily(date, dailyScopeChannel, channelID, delta); err != nil {
+		p.API.LogError("can't store daily channel analytics", "channel", channelID, "err", err.Error())
+	}
+	if userID == "" {
+		return
+	}
+	if err := p.incrementDaily(date, dailyScopeUser, userID, delta); err != nil {
+		p.API.LogError("can't store daily user analytics", "user", userID, "err", err.Error())
+	}
+}
+
+// dailyCounters sum the buckets of id in scope for each day between from and to included
+func (p *Plugin) dailyCounters(scope string, id string, from time.Time, to time.Time) (DailyCounters, error) {
+	var total DailyCounters
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		counters, err := p.store().get(day, scope, id)
+		if err != nil {
+			return total, err
+		}
+		total.add(counters)
+	}
+	return total, nil
+}
+
+// dailyBucket is the counters of a channel or a user during a day
+type dailyBucket struct {
+	Date     time.Time
+	Scope    string
+	ID       string
+	Counters DailyCounters
+}
+
+// dailyBuckets return all buckets of scope for days between from and to included
+func (p *Plugin) dailyBuckets(scope string, from time.Time, to time.Time) ([]dailyBucket, error) {
+	return p.listDailyBuckets(func(s string) bool { return s == scope }, from, to)
+}
+
+// listDailyBuckets return all buckets of the scopes accepted by include for days between from and to included
+func (p *Plugin) listDailyBuckets(include func(scope string) bool, from time.Time, to time.Time) ([]dailyBucket, error) {
+	return p.store().list(include, from, to)
+}
+
+// listAllDailyBuckets return buckets of the scopes accepted by include for every stored day
+func (p *Plugin) listAllDailyBuckets(include func(scope string) bool) ([]dailyBucket, error) {
+	return listAllDays(p.store(), include, p.getConfiguration().getLocation())
+}
+
+// listAllDays return buckets of s of the scopes accepted by include for every stored day, dated in location
+func listAllDays(s dailyStore, include func(scope string) bool, location *time.Location) ([]dailyBucket, error) {
+	return s.list(include, time.Date(1, 1, 1, 0, 0, 0, 0, location), time.Date(9999, 12, 31, 0, 0, 0, 0, location))
+}
+
+// parseDailyKey return the empty bucket of a daily key dated in location, false if key is not a daily key
+func parseDailyKey(key string, location *time.Location) (dailyBucket, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(key, dailyKeyPrefix), ":", 3)
+	if !strings.HasPrefix(key, dailyKeyPrefix) || len(parts) != 3 {
+		return dailyBucket{}, false
+	}
+	date, err := time.ParseInLocation(dailyKeyFormat, parts[0], location)
+	if err != nil {
+		return dailyBucket{}, false
+	}
+	return dailyBucket{Date: date, Scope: parts[1], ID: parts[2]}, true
+}
+
+// allScopes include buckets of every scope
+func allScopes(string) bool {
+	return true
+}
+
+// dailyStore persist daily buckets, in the kv store by default or in tables of the mattermost database
+type dailyStore interface {
+	// increment atomically add delta to a bucket and stamp it
+	increment(date time.Time, scope string, id string, delta DailyCounters, stamp int64) error
+	// get return a bucket, empty if it doesn't exist
+	get(date time.Time, scope string, id string) (DailyCounters, error)
+	// set replace a bucket
+	set(bucket dailyBucket) error
+	// restore create a bucket if it doesn't exist, return false if it exists
+	restore(bucket dailyBucket) (bool, error)
+	// list return buckets of the scopes accepted by include for days between from and to included
+	list(include func(scope string) bool, from time.Time, to time.Time) ([]dailyBucket, error)
+	// delete remove buckets
+	delete(buckets []dailyBucket) error
+	// deleteBefore remove buckets of days before cutoff, in form YYYY-MM-DD, and return their number
+	deleteBefore(cutoff string) (int, error)
+}
+
+// store return the daily store of the configured backend
+func (p *Plugin) store() dailyStore {
+	p.dailyStoreLock.RLock()
+	defer p.dailyStoreLock.RUnlock()
+	if p.dailyStore == nil {
+		return &kvDailyStore{p: p}
+	}
+	return p.dailyStore
+}
+
+// kvDailyStore keep each bucket under its daily key in the kv store
+type kvDailyStore struct {
+	p *Plugin
+}
+
+func (s *kvDailyStore) increment(date time.Time, scope string, id string, delta DailyCounters, stamp int64) error {
+	key := dailyKey(date, scope, id)
+	for attempt := 0; attempt < maxIncrementAttempts; attempt++ {
+		old, appErr := s.p.API.KVGet(key)
+		if appErr != nil {
+			return errors.Wrap(appErr, "can't get "+key+" from kv")
+		}
+		var counters DailyCounters
+		if old != nil {
+			if err := json.Unmarshal(old, &counters); err != nil {
+				return errors.Wrap(err, "can't unmarshal "+key)
+			}
+		}
+		counters.add(delta)
+		counters.UpdatedAt = stamp
+		j, err := json.Marshal(counters)
+		if err != nil {
+			return errors.Wrap(err, "can't marshal "+key)
+		}
+		saved, appErr := s.p.API.KVCompareAndSet(key, old, j)
+		if appErr != nil {
+			return errors.Wrap(appErr, "can't save "+key)
+		}
+		if saved {
+			return nil
+		}
+	}
+	return errors.New("too many concurrent updates of " + key)
+}
+
+func (s *kvDailyStore) get(date time.Time, scope string, id string) (DailyCounters, error) {
+	var counters DailyCounters
+	err := s.p.kvGetJSON(dailyKey(date, scope, id), &counters)
+	return counters, err
+}
+
+func (s *kvDailyStore) set(bucket dailyBucket) error {
+	return s.p.kvSetJSON(dailyKey(bucket.Date, bucket.Scope, bucket.ID), bucket.Counters)
+}
+
+func (s *kvDailyStore) restore(bucket dailyBucket) (bool, error) {
+	key := dailyKey(bucket.Date, bucket.Scope, bucket.ID)
+	j, err := json.Marshal(bucket.Counters)
+	if err != nil {
+		return false, errors.Wrap(err, "can't marshal "+key)
+	}
+	saved, appErr := s.p.API.KVCompareAndSet(key, nil, j)
+	if appErr != nil {
+		return false, errors.Wrap(appErr, "can't restore "+key)
+	}
+	return saved, nil
+}
+
+func (s *kvDailyStore) list(include func(scope string) bool, from time.Time, to time.Time) ([]dailyBucket, error) {
+	buckets := make([]dailyBucket, 0)
+	first := from.Format(dailyKeyFormat)
+	last := to.Format(dailyKeyFormat)
+	perPage := 100
+	for page := 0; ; page++ {
+		keys, appErr := s.p.API.KVList(page, perPage)
+		if appErr != nil {
+			return nil, errors.Wrap(appErr, "can't list kv keys")
+		}
+		for _, key := range keys {
+			if !inDailyRange(key, first, last) {
+				continue
+			}
+			parts := strings.SplitN(strings.TrimPrefix(key, dailyKeyPrefix), ":", 3)
+			if len(parts) != 3 || !include(parts[1]) {
+				continue
+			}
+			date, err := time.ParseInLocation(dailyKeyFormat, parts[0], from.Location())
+			if err != nil {
+				continue
+			}
+			bucket := dailyBucket{Date: date, Scope: parts[1], ID: parts[2]}
+			if err := s.p.kvGetJSON(key, &bucket.Counters); err != nil {
+				return nil, err
+			}
+			buckets = append(buckets, bucket)
+		}
+		if len(keys) < perPage {
+			return buckets, nil
+		}
+	}
+}
+
+func (s *kvDailyStore) delete(buckets []dailyBucket) error {
+	for _, bucket := range buckets {
+		key := dailyKey(bucket.Date, bucket.Scope, bucket.ID)
+		if appErr := s.p.API.KVDelete(key); appErr != nil {
+			return errors.Wrap(appErr, "can't delete key "+key)
+		}
+	}
+	return nil
+}
+
+func (s *kvDailyStore) deleteBefore(cutoff string) (int, error) {
+	// list all keys before deleting, deleting while listing would shift pages
+	expired := make([]string, 0)
+	perPage := 100
+	for page := 0; ; page++ {
+		keys, appErr := s.p.API.KVList(page, perPage)
+		if appErr != nil {
+			return 0, errors.Wrap(appErr, "can't list kv keys")
+		}
+		for _, key := range keys {
+			if expiredDailyKey(key, cutoff) {
+				expired = append(expired, key)
+			}
+		}
+		if len(keys) < perPage {
+			break
+		}
+	}
+	for index, key := range expired {
+		if appErr := s.p.API.KVDelete(key); appErr != nil {
+			return index, errors.Wrap(appErr, "can't delete key "+key)
+		}
+	}
+	return len(expired), nil
+}
+
+// sumDailyBuckets return counters of scope between from and to included, by channel or user id
+func (p *Plugin) sumDailyBuckets(scope string, from time.Time, to time.Time) (map[string]DailyCounters, error) {
+	buckets, err := p.dailyBuckets(scope, from, to)
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]DailyCounters)
+	for _, bucket := range buckets {
+		total := totals[bucket.ID]
+		total.add(bucket.Counters)
+		totals[bucket.ID] = total
+	}
+	return totals, nil
+}
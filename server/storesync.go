@@ -0,0 +1,168 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/pkg/errors"
+)
+
+const (
+	// dailyStoreBackendKey store the backend holding the latest daily buckets, set once a switch is complete
+	dailyStoreBackendKey = "daily_store_backend"
+	// dailyStoreSyncKey store the progress of the running switch of backend, so it resumes after a restart
+	dailyStoreSyncKey = "daily_store_sync"
+	// sqlBackendMinServerVersion is the first version giving plugins the database settings, GetUnsanitizedConfig
+	sqlBackendMinServerVersion = "5.16.0"
+)
+
+// DailyStoreSync is the progress of the copy of daily buckets to the store of Backend
+type DailyStoreSync struct {
+	Backend string
+	// Day is the last day copied, in form YYYY-MM-DD
+	Day string
+	// StartedAt is the stamp of the start of the copy, buckets changed since are copied again before the switch
+	StartedAt int64
+}
+
+// newerBucket return true if counters should replace current in the other store, when current is missing or older
+func newerBucket(counters DailyCounters, current DailyCounters) bool {
+	if current.UpdatedAt == 0 && current.Messages == 0 && current.Replies == 0 && current.FilesSize == 0 &&
+		current.Joins == 0 && current.Leaves == 0 && len(current.ResponseTimes) == 0 && current.Value == 0 {
+		return true
+	}
+	return current.UpdatedAt < counters.UpdatedAt
+}
+
+// syncDailyBuckets copy buckets to target when they are missing or older in target, days until after included are
+// skipped as well as buckets changed before since, done is called after each day and stop the copy on error, return
+// the number of copied buckets
+func syncDailyBuckets(buckets []dailyBucket, target dailyStore, after string, since int64, done func(day string) error) (int, error) {
+	sort.SliceStable(buckets, func(i, j int) bool { return buckets[i].Date.Before(buckets[j].Date) })
+	copied := 0
+	for index, bucket := range buckets {
+		day := bucket.Date.Format(dailyKeyFormat)
+		if day <= after {
+			continue
+		}
+		if bucket.Counters.UpdatedAt >= since {
+			current, err := target.get(bucket.Date, bucket.Scope, bucket.ID)
+			if err != nil {
+				return copied, err
+			}
+			if newerBucket(bucket.Counters, current) {
+				if err := target.set(bucket); err != nil {
+					return copied, err
+				}
+				copied++
+			}
+		}
+		if index == len(buckets)-1 || buckets[index+1].Date.Format(dailyKeyFormat) != day {
+			if err := done(day); err != nil {
+				return copied, err
+			}
+		}
+	}
+	return copied, nil
+}
+
+// openDailyDatabase open the sql store of daily buckets, wrapped to fall back to the kv store while it fails
+func (p *Plugin) openDailyDatabase() (*fallbackDailyStore, error) {
+	version, err := semver.ParseTolerant(p.API.GetServerVersion())
+	if err != nil {
+		return nil, errors.Wrap(err, "can't parse server version")
+	}
+	if version.LT(semver.MustParse(sqlBackendMinServerVersion)) {
+		return nil, errors.New("the sql storage backend needs mattermost " + sqlBackendMinServerVersion)
+	}
+	s, err := openSQLDailyStore(p.API.GetUnsanitizedConfig().SqlSettings)
+	if err != nil {
+		return nil, err
+	}
+	return &fallbackDailyStore{primary: s, secondary: &kvDailyStore{p: p}, now: p.now, log: p.API.LogWarn}, nil
+}
+
+// closeDailyDatabase close the database connection of s if it is the sql store
+func closeDailyDatabase(s dailyStore) error {
+	if database, ok := s.(*fallbackDailyStore); ok {
+		return database.primary.(*sqlDailyStore).close()
+	}
+	return nil
+}
+
+// switchDailyStore copy buckets of current to next then serve buckets from next, the copy resumes from its cursor
+// after a restart and is stopped when the configured backend changes again
+// buckets changed during the copy are copied again once writes are held by the switch
+func (p *Plugin) switchDailyStore(backend string, current dailyStore, next dailyStore, generation int) {
+	aborted := func() bool {
+		p.dailyStoreLock.RLock()
+		defer p.dailyStoreLock.RUnlock()
+		return p.dailyStoreGeneration != generation
+	}
+	progress := &DailyStoreSync{}
+	err := p.kvGetJSON(dailyStoreSyncKey, progress)
+	if err == nil && progress.Backend != backend {
+		progress = &DailyStoreSync{Backend: backend, StartedAt: changeStamp(p.now())}
+		err = p.kvSetJSON(dailyStoreSyncKey, progress)
+	}
+	location := p.getConfiguration().getLocation()
+	var buckets []dailyBucket
+	if err == nil {
+		buckets, err = listAllDays(current, allScopes, location)
+	}
+	copied := 0
+	if err == nil {
+		copied, err = syncDailyBuckets(buckets, next, progress.Day, 0, func(day string) error {
+			if aborted() {
+				return errors.New("storage backend changed")
+			}
+			progress.Day = day
+			return p.kvSetJSON(dailyStoreSyncKey, progress)
+		})
+	}
+
+	p.dailyStoreLock.Lock()
+	defer p.dailyStoreLock.Unlock()
+	if p.dailyStoreGeneration != generation {
+		p.API.LogInfo("switch of storage backend stopped", "backend", backend)
+		if err := closeDailyDatabase(next); err != nil {
+			p.API.LogError("can't close database", "err", err.Error())
+		}
+		return
+	}
+	p.dailyStoreSwitching = ""
+	if err == nil {
+		// writes are held, only days since the start of the copy are listed again, the day before covers timezones
+		startedAt := time.Unix(0, progress.StartedAt*int64(time.Millisecond)).In(location).AddDate(0, 0, -1)
+		buckets, err = current.list(allScopes, startedAt, time.Date(9999, 12, 31, 0, 0, 0, 0, location))
+		if err == nil {
+			var changed int
+			changed, err = syncDailyBuckets(buckets, next, "", progress.StartedAt, func(string) error { return nil })
+			copied += changed
+		}
+	}
+	if err == nil {
+		err = p.kvSetJSON(dailyStoreBackendKey, backend)
+	}
+	if err != nil {
+		p.API.LogError("can't switch storage backend, it resumes on next configuration change", "backend", backend, "err", err.Error())
+		if err := closeDailyDatabase(next); err != nil {
+			p.API.LogError("can't close database", "err", err.Error())
+		}
+		return
+	}
+	if appErr := p.API.KVDelete(dailyStoreSyncKey); appErr != nil {
+		p.API.LogWarn("can't delete progress of storage backend switch", "err", appErr.Error())
+	}
+
+	p.dailyStore = nil
+	if backend == storageBackendSQL {
+		p.dailyStore = next
+	}
+	if err := closeDailyDatabase(current); err != nil {
+		p.API.LogError("can't close database", "err", err.Error())
+	}
+	p.API.LogInfo("daily buckets switched to storage backend", "backend", backend, "buckets", strconv.Itoa(copied))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncDailyBuckets(t *testing.T) {
+	assert := assert.New(t)
+
+	day1 := time.Date(2020, 3, 16, 0, 0, 0, 0, time.Local)
+	day2 := day1.AddDate(0, 0, 1)
+	target := &memoryDailyStore{increments: map[string]DailyCounters{
+		"analytics:2020-03-16:c:channel1": {Messages: 3, UpdatedAt: 20},
+		"analytics:2020-03-17:c:channel1": {Messages: 1, UpdatedAt: 5},
+	}}
+	buckets := []dailyBucket{
+		{Date: day1, Scope: dailyScopeChannel, ID: "channel1", Counters: DailyCounters{Messages: 2, UpdatedAt: 10}},
+		{Date: day1, Scope: dailyScopeUser, ID: "user1", Counters: DailyCounters{Messages: 2, UpdatedAt: 10}},
+		{Date: day2, Scope: dailyScopeChannel, ID: "channel1", Counters: DailyCounters{Messages: 4, UpdatedAt: 30}},
+	}
+
+	// missing and older buckets are copied, newer ones of the target are kept
+	days := make([]string, 0)
+	copied, err := syncDailyBuckets(buckets, target, "", 0, func(day string) error {
+		days = append(days, day)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(2, copied)
+	assert.Equal([]string{"2020-03-16", "2020-03-17"}, days)
+	assert.Equal(int64(3), target.increments["analytics:2020-03-16:c:channel1"].Messages)
+	assert.Equal(int64(2), target.increments["analytics:2020-03-16:u:user1"].Messages)
+	assert.Equal(int64(4), target.increments["analytics:2020-03-17:c:channel1"].Messages)
+
+	// days until the cursor and buckets changed before since are skipped
+	buckets[2].Counters = DailyCounters{Messages: 5, UpdatedAt: 40}
+	buckets[1].Counters = DailyCounters{Messages: 6, UpdatedAt: 50}
+	copied, err = syncDailyBuckets(buckets, target, "2020-03-16", 0, func(string) error { return nil })
+	assert.Nil(err)
+	assert.Equal(1, copied)
+	assert.Equal(int64(2), target.increments["analytics:2020-03-16:u:user1"].Messages)
+	assert.Equal(int64(5), target.increments["analytics:2020-03-17:c:channel1"].Messages)
+
+	copied, err = syncDailyBuckets(buckets, target, "", 45, func(string) error { return nil })
+	assert.Nil(err)
+	assert.Equal(1, copied)
+	assert.Equal(int64(6), target.increments["analytics:2020-03-16:u:user1"].Messages)
+}
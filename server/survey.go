@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+const (
+	surveysKey = "surveys"
+
+	defaultSurveyQuestion = "How would you rate collaboration in this channel lately?"
+	maxSurveyScore        = 5
+	// surveysRetention is the age of surveys removed from kv when a new survey is posted
+	surveysRetention = 90 * 24 * time.Hour
+)
+
+// Survey is a pulse survey posted in a channel with its answers
+type Survey struct {
+	PostID    string
+	ChannelID string
+	Question  string
+	CreatedAt time.Time
+	// Members is the number of members of the channel when the survey was posted
+	Members int64
+	// Answers store the score between 1 and 5 of each user id, or of its hash in anonymous mode
+	Answers map[string]int
+}
+
+// SurveyResult aggregate answers of the surveys of a channel
+type SurveyResult struct {
+	ChannelID string
+	Surveys   int
+	Members   int64
+	Answers   int64
+	Sum       int64
+}
+
+// ResponseRate return the percentage of members who answered
+func (r *SurveyResult) ResponseRate() int64 {
+	if r.Members == 0 {
+		return 0
+	}
+	return (r.Answers * 100) / r.Members
+}
+
+// Average return the mean score, 0 without answer
+func (r *SurveyResult) Average() float64 {
+	if r.Answers == 0 {
+		return 0
+	}
+	return float64(r.Sum) / float64(r.Answers)
+}
+
+// surveyResults aggregate by channel surveys created in [from, to) in channels accepted by include, sorted by channel id
+// include is optional
+func surveyResults(surveys []*Survey, from time.Time, to time.Time, include func(channelID string) bool) []*SurveyResult {
+	byChannel := make(map[string]*SurveyResult)
+	for _, survey := range surveys {
+		if survey.CreatedAt.Before(from) || !survey.CreatedAt.Before(to) || (include != nil && !include(survey.ChannelID)) {
+			continue
+		}
+		result, ok := byChannel[survey.ChannelID]
+		if !ok {
+			result = &SurveyResult{ChannelID: survey.ChannelID}
+			byChannel[survey.ChannelID] = result
+		}
+		result.Surveys++
+		result.Members += survey.Members
+		for _, score := range survey.Answers {
+			result.Answers++
+			result.Sum += int64(score)
+		}
+	}
+	results := make([]*SurveyResult, 0, len(byChannel))
+	for _, result := range byChannel {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ChannelID < results[j].ChannelID
+	})
+	return results
+}
+
+// surveyAttachment render the question with a button for each score
+func (p *Plugin) surveyAttachment(question string) *model.SlackAttachment {
+	url := *p.API.GetConfig().ServiceSettings.SiteURL + "/plugins/" + manifest.Id + "/survey"
+	actions := make([]*model.PostAction, 0, maxSurveyScore)
+	for score := 1; score <= maxSurveyScore; score++ {
+		actions = append(actions, &model.PostAction{
+			Name: strconv.Itoa(score),
+			Integration: &model.PostActionIntegration{
+				URL:     url,
+				Context: map[string]interface{}{"score": strconv.Itoa(score)},
+			},
+		})
+	}
+	return &model.SlackAttachment{
+		Color:   "#FF8000",
+		Title:   "Pulse survey",
+		Text:    question + "\n*1 is the worst, 5 the best. Answers are anonymous, only response rates and averages are reported.*",
+		Actions: actions,
+	}
+}
+
+// postSurveys post the pulse survey in all survey channels and remember them to collect answers
+func (p *Plugin) postSurveys() error {
+	if len(p.SurveyChannelsID) == 0 || p.isPostingPaused() {
+		return nil
+	}
+	question := p.getConfiguration().SurveyQuestion
+	if question == "" {
+		question = defaultSurveyQuestion
+	}
+
+	p.surveysLock.Lock()
+	defer p.surveysLock.Unlock()
+	surveys := make([]*Survey, 0)
+	if err := p.kvGetJSON(surveysKey, &surveys); err != nil {
+		return err
+	}
+	now := p.now()
+	kept := make([]*Survey, 0, len(surveys)+len(p.SurveyChannelsID))
+	for _, survey := range surveys {
+		if now.Sub(survey.CreatedAt) < surveysRetention {
+			kept = append(kept, survey)
+		}
+	}
+	for _, channelID := range p.SurveyChannelsID {
+		var members int64
+		if stats, appErr := p.API.GetChannelStats(channelID); appErr != nil {
+			p.API.LogWarn("can't get channel stats", "channel", channelID, "err", appErr.Error())
+		} else {
+			members = stats.MemberCount
+		}
+		post, err := p.postAnalytics(channelID, "", []*model.SlackAttachment{p.surveyAttachment(question)}, nil)
+		if err != nil {
+			return err
+		}
+		kept = append(kept, &Survey{PostID: post.Id, ChannelID: channelID, Question: question, CreatedAt: now, Members: members, Answers: make(map[string]int)})
+	}
+	return p.kvSetJSON(surveysKey, kept)
+}
+
+// handleSurvey is called when a user click on a score button of a pulse survey, a new answer replace the previous one
+func (p *Plugin) handleSurvey(w http.ResponseWriter, r *http.Request) error {
+	request := model.PostActionIntegrationRequestFromJson(r.Body)
+	if request == nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return errors.New("can't decode survey request")
+	}
+	userID := r.Header.Get("Mattermost-User-Id")
+	scoreContext, _ := request.Context["score"].(string)
+	score, err := strconv.Atoi(scoreContext)
+	if userID == "" || err != nil || score < 1 || score > maxSurveyScore {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return errors.New("missing user or bad score in survey request")
+	}
+
+	// in anonymous mode answers are keyed by a hash so a new answer still replaces the previous one
+	answerKey := userID
+	if p.getConfiguration().AnonymousMode {
+		answerKey = hashIdentifier(userID, p.API.GetDiagnosticId())
+	}
+
+	p.surveysLock.Lock()
+	surveys := make([]*Survey, 0)
+	err = p.kvGetJSON(surveysKey, &surveys)
+	found := false
+	if err == nil {
+		for _, survey := range surveys {
+			if survey.PostID == request.PostId {
+				if survey.Answers == nil {
+					survey.Answers = make(map[string]int)
+				}
+				survey.Answers[answerKey] = score
+				found = true
+			}
+		}
+		if found {
+			err = p.kvSetJSON(surveysKey, surveys)
+		}
+	}
+	p.surveysLock.Unlock()
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return err
+	}
+
+	text := fmt.Sprintf("Thanks, your answer **%d** was recorded.", score)
+	if !found {
+		text = "This survey is closed."
+	}
+	response := &model.PostActionIntegrationResponse{EphemeralText: text}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(response.ToJson())
+	return err
+}
+
+// getSurveysDescription render response rates and average scores of surveys posted during the analytic
+func (p *Plugin) getSurveysDescription(from time.Time, to time.Time, include func(channelID string) bool, T translateFunc) (string, error) {
+	p.surveysLock.Lock()
+	surveys := make([]*Survey, 0)
+	err := p.kvGetJSON(surveysKey, &surveys)
+	p.surveysLock.Unlock()
+	if err != nil {
+		return "", err
+	}
+	results := surveyResults(surveys, from, to, include)
+	if len(results) == 0 {
+		return "", nil
+	}
+	m := sectionTitle(T, "report.surveys.title")
+	for _, result := range results {
+		_, displayName, link, err := p.getChannelName(result.ChannelID)
+		if err != nil {
+			continue
+		}
+		if result.Answers == 0 {
+			m += fmt.Sprintf("* [~%s](%s): no answer to %d surveys\n", displayName, link, result.Surveys)
+			continue
+		}
+		m += fmt.Sprintf("* [~%s](%s): **%.1f**/%d from %d answers *(%d%% response rate)*\n", displayName, link, result.Average(), maxSurveyScore, result.Answers, result.ResponseRate())
+	}
+	return m, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSurveyResults(t *testing.T) {
+	assert := assert.New(t)
+	from := time.Date(2019, time.April, 14, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	surveys := []*Survey{
+		{ChannelID: "dev", CreatedAt: from.AddDate(0, 0, 1), Members: 4, Answers: map[string]int{"alice": 5, "bob": 3}},
+		{ChannelID: "dev", CreatedAt: from.AddDate(0, 0, 4), Members: 6, Answers: map[string]int{"alice": 4}},
+		{ChannelID: "ops", CreatedAt: from.AddDate(0, 0, 2), Members: 3, Answers: map[string]int{}},
+		{ChannelID: "ops", CreatedAt: from.AddDate(0, 0, -1), Members: 3, Answers: map[string]int{"carol": 1}},
+		{ChannelID: "hr", CreatedAt: to, Members: 3, Answers: map[string]int{"dave": 2}},
+	}
+
+	results := surveyResults(surveys, from, to, nil)
+	assert.Equal([]*SurveyResult{
+		{ChannelID: "dev", Surveys: 2, Members: 10, Answers: 3, Sum: 12},
+		{ChannelID: "ops", Surveys: 1, Members: 3},
+	}, results)
+	assert.Equal(int64(30), results[0].ResponseRate())
+	assert.Equal(4.0, results[0].Average())
+	assert.Equal(int64(0), results[1].ResponseRate())
+	assert.Equal(0.0, results[1].Average())
+
+	onlyOps := surveyResults(surveys, from, to, func(channelID string) bool { return channelID == "ops" })
+	assert.Len(onlyOps, 1)
+	assert.Equal("ops", onlyOps[0].ChannelID)
+}
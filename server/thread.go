@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// APIThread is the activity of a thread returned by the REST API
+type APIThread struct {
+	RootID       string `json:"root_id"`
+	Replies      int64  `json:"replies"`
+	Participants int64  `json:"participants"`
+	Reactions    int64  `json:"reactions"`
+	// FirstReplyAfter and LastReplyAfter are seconds between the root post and its first and last replies, 0 without reply
+	FirstReplyAfter int64  `json:"first_reply_after"`
+	LastReplyAfter  int64  `json:"last_reply_after"`
+	FirstReplyAt    string `json:"first_reply_at,omitempty"`
+	LastReplyAt     string `json:"last_reply_at,omitempty"`
+}
+
+// threadStats count replies and participants of the thread of rootID in posts, system messages excluded
+// participants include the author of the root post
+func threadStats(posts *model.PostList, rootID string) *APIThread {
+	thread := &APIThread{RootID: rootID}
+	root, ok := posts.Posts[rootID]
+	if !ok {
+		return thread
+	}
+	participants := map[string]bool{root.UserId: true}
+	var first, last int64
+	for _, post := range posts.Posts {
+		if post.RootId != rootID || post.Type != "" {
+			continue
+		}
+		thread.Replies++
+		participants[post.UserId] = true
+		if first == 0 || post.CreateAt < first {
+			first = post.CreateAt
+		}
+		if post.CreateAt > last {
+			last = post.CreateAt
+		}
+	}
+	thread.Participants = int64(len(participants))
+	if thread.Replies > 0 {
+		thread.FirstReplyAfter = (first - root.CreateAt) / 1000
+		thread.LastReplyAfter = (last - root.CreateAt) / 1000
+		thread.FirstReplyAt = time.Unix(0, first*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+		thread.LastReplyAt = time.Unix(0, last*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+	}
+	return thread
+}
+
+// handleThread serve `GET /api/v1/thread?post_id=` analytics of the thread of a post
+// unlike other endpoints, any user allowed to read the channel of the post can see them
+func (p *Plugin) handleThread(w http.ResponseWriter, r *http.Request) error {
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return nil
+	}
+	postID := r.URL.Query().Get("post_id")
+	if !model.IsValidId(postID) {
+		http.Error(w, "bad post_id", http.StatusBadRequest)
+		return nil
+	}
+	post, appErr := p.API.GetPost(postID)
+	if appErr != nil {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return nil
+	}
+	if !p.API.HasPermissionToChannel(userID, post.ChannelId, model.PERMISSION_READ_CHANNEL) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return nil
+	}
+	rootID := post.Id
+	if post.RootId != "" {
+		rootID = post.RootId
+	}
+	posts, appErr := p.API.GetPostThread(rootID)
+	if appErr != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return appErr
+	}
+
+	thread := threadStats(posts, rootID)
+	for _, post := range posts.Posts {
+		if !post.HasReactions || post.Type != "" {
+			continue
+		}
+		reactions, appErr := p.API.GetReactions(post.Id)
+		if appErr != nil {
+			p.API.LogWarn("can't get reactions of post, skip it", "post", post.Id, "err", appErr.Error())
+			continue
+		}
+		thread.Reactions += int64(len(reactions))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(thread)
+}
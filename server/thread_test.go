@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreadStats(t *testing.T) {
+	assert := assert.New(t)
+
+	posts := &model.PostList{Posts: map[string]*model.Post{
+		"root":   {Id: "root", UserId: "user1", CreateAt: 1556668800000},
+		"reply1": {Id: "reply1", UserId: "user2", RootId: "root", CreateAt: 1556668860000},
+		"reply2": {Id: "reply2", UserId: "user1", RootId: "root", CreateAt: 1556672400000},
+		"reply3": {Id: "reply3", UserId: "user3", RootId: "root", CreateAt: 1556669400000},
+		"joined": {Id: "joined", UserId: "user4", RootId: "root", CreateAt: 1556676000000, Type: model.POST_JOIN_CHANNEL},
+		"other":  {Id: "other", UserId: "user5", RootId: "other-root", CreateAt: 1556668900000},
+	}}
+
+	thread := threadStats(posts, "root")
+	assert.Equal(int64(3), thread.Replies)
+	assert.Equal(int64(3), thread.Participants)
+	assert.Equal(int64(60), thread.FirstReplyAfter)
+	assert.Equal(int64(3600), thread.LastReplyAfter)
+	assert.Equal("2019-05-01T00:01:00Z", thread.FirstReplyAt)
+	assert.Equal("2019-05-01T01:00:00Z", thread.LastReplyAt)
+
+	thread = threadStats(posts, "reply1")
+	assert.Equal(int64(0), thread.Replies)
+	assert.Equal(int64(1), thread.Participants)
+	assert.Equal("", thread.FirstReplyAt)
+
+	thread = threadStats(posts, "missing")
+	assert.Equal(&APIThread{RootID: "missing"}, thread)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+)
+
+const maxThreadsToDisplay = 3
+
+// getThreadsDescription render the share of replies, the average thread length and the longest threads of public channels
+// caller must hold the read lock of the analytic
+func (p *Plugin) getThreadsDescription(siteURL string, analytic *Analytic, T translateFunc) string {
+	messages := int64(0)
+	for _, nb := range analytic.Channels {
+		messages += nb
+	}
+	replies := int64(0)
+	for _, nb := range analytic.ChannelsReply {
+		replies += nb
+	}
+	if messages == 0 {
+		return ""
+	}
+
+	m := sectionTitle(T, "report.threads.title")
+	m += fmt.Sprintf("**%d%%** of messages are replies", replies*100/messages)
+	if len(analytic.Threads) > 0 {
+		threadsReplies := int64(0)
+		for _, nb := range analytic.Threads {
+			threadsReplies += nb
+		}
+		nbThreads := int64(len(analytic.Threads))
+		m += fmt.Sprintf(", threads have **%.1f** messages on average", float64(threadsReplies+nbThreads)/float64(nbThreads))
+	}
+	m += ".\n"
+
+	nbThreads := 0
+	for _, thread := range topCounters(analytic.Threads, len(analytic.Threads)) {
+		if nbThreads == maxThreadsToDisplay {
+			break
+		}
+		root, appErr := p.API.GetPost(thread.key)
+		if appErr != nil {
+			continue
+		}
+		link, ok := p.publicPostLink(siteURL, root.ChannelId, root.Id)
+		if !ok {
+			continue
+		}
+		m += fmt.Sprintf("* %s with **%d** replies\n", link, thread.nb)
+		nbThreads++
+	}
+	return m
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"regexp"
+	"unicode"
+)
+
+// Tokenizer split a message in words
+type Tokenizer interface {
+	Tokenize(text string) []string
+}
+
+// unicodeTokenizer split on spaces and punctuation, except for scripts written without spaces (chinese and japanese)
+// where each ideograph or kana is a word, emojis and :emoji: shortcodes are words too
+type unicodeTokenizer struct{}
+
+// defaultTokenizer is used to count words of messages
+var defaultTokenizer Tokenizer = unicodeTokenizer{}
+
+var emojiShortcodeRegexp = regexp.MustCompile(`:[a-z0-9_+\-]+:`)
+
+// isUnspacedScript return true for runes of scripts written without spaces between words
+func isUnspacedScript(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar)
+}
+
+// isEmoji return true for pictographic runes
+func isEmoji(r rune) bool {
+	return (r >= 0x1F000 && r <= 0x1FAFF) || (r >= 0x2600 && r <= 0x27BF) || (r >= 0x2B00 && r <= 0x2BFF)
+}
+
+// isEmojiJoiner return true for runes modifying or joining the previous emoji
+func isEmojiJoiner(r rune) bool {
+	return r == 0x200D || r == 0xFE0F || (r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+// Tokenize return words of text
+func (unicodeTokenizer) Tokenize(text string) []string {
+	tokens := make([]string, 0)
+	for _, loc := range emojiShortcodeRegexp.FindAllStringIndex(text, -1) {
+		tokens = append(tokens, text[loc[0]:loc[1]])
+	}
+	text = emojiShortcodeRegexp.ReplaceAllString(text, " ")
+
+	word := make([]rune, 0)
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	joining := false
+	for _, r := range text {
+		switch {
+		case isEmojiJoiner(r) && len(tokens) > 0 && len(word) == 0:
+			tokens[len(tokens)-1] += string(r)
+			joining = r == 0x200D
+		case isEmoji(r):
+			flush()
+			if joining {
+				tokens[len(tokens)-1] += string(r)
+			} else {
+				tokens = append(tokens, string(r))
+			}
+			joining = false
+		case isUnspacedScript(r):
+			flush()
+			tokens = append(tokens, string(r))
+			joining = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '\'' || r == '_':
+			word = append(word, r)
+			joining = false
+		default:
+			flush()
+			joining = false
+		}
+	}
+	flush()
+	return tokens
+}
+
+// scriptsByPriority are the scripts detected in messages, japanese kana are checked before han ideographs
+// as japanese mix both
+var scriptsByPriority = []struct {
+	name   string
+	tables []*unicode.RangeTable
+}{
+	{"Japanese", []*unicode.RangeTable{unicode.Hiragana, unicode.Katakana}},
+	{"Chinese", []*unicode.RangeTable{unicode.Han}},
+	{"Korean", []*unicode.RangeTable{unicode.Hangul}},
+	{"Arabic", []*unicode.RangeTable{unicode.Arabic}},
+	{"Hebrew", []*unicode.RangeTable{unicode.Hebrew}},
+	{"Cyrillic", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"Greek", []*unicode.RangeTable{unicode.Greek}},
+	{"Thai", []*unicode.RangeTable{unicode.Thai}},
+	{"Devanagari", []*unicode.RangeTable{unicode.Devanagari}},
+	{"Latin", []*unicode.RangeTable{unicode.Latin}},
+}
+
+// detectScript return the writing system used by most letters of a message, a cheap approximation of its language
+// empty if the message has no letter (e.g. only emojis)
+func detectScript(text string) string {
+	counts := make(map[string]int)
+	for _, r := range text {
+		for _, script := range scriptsByPriority {
+			if unicode.In(r, script.tables...) {
+				counts[script.name]++
+				break
+			}
+		}
+	}
+	if counts["Japanese"] > 0 {
+		counts["Japanese"] += counts["Chinese"]
+		counts["Chinese"] = 0
+	}
+	detected := ""
+	for _, script := range scriptsByPriority {
+		if counts[script.name] > counts[detected] {
+			detected = script.name
+		}
+	}
+	return detected
+}
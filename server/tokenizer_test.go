@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenize(t *testing.T) {
+	assert := assert.New(t)
+	tokenizer := unicodeTokenizer{}
+
+	assert.Equal([]string{"Hello", "world", "it's", "42"}, tokenizer.Tokenize("Hello, world! it's 42."))
+	assert.Equal([]string{"東", "京", "に", "行", "き", "ま", "す"}, tokenizer.Tokenize("東京に行きます。"))
+	assert.Equal([]string{"안녕하세요", "세계"}, tokenizer.Tokenize("안녕하세요 세계"))
+	assert.Equal([]string{":tada:", "great", "👍🏽", "👨‍👩‍👧"}, tokenizer.Tokenize("great :tada: 👍🏽 👨‍👩‍👧"))
+	assert.Equal([]string{"café", "naïve"}, tokenizer.Tokenize("café naïve"))
+	assert.Empty(tokenizer.Tokenize("  ...  "))
+}
+
+func TestDetectScript(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Latin", detectScript("Hello world"))
+	assert.Equal("Japanese", detectScript("東京に行きます"))
+	assert.Equal("Chinese", detectScript("我们明天见"))
+	assert.Equal("Korean", detectScript("안녕하세요 world"))
+	assert.Equal("Hebrew", detectScript("שלום עולם"))
+	assert.Equal("Arabic", detectScript("مرحبا بالعالم"))
+	assert.Equal("", detectScript("👍 42"))
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"time"
+)
+
+const trackedSinceKey = "tracked_since"
+
+// loadTrackedSince fill the cache of tracking dates from kv, caller must hold trackedSinceLock
+func (p *Plugin) loadTrackedSince() error {
+	if p.trackedSince != nil {
+		return nil
+	}
+	trackedSince := make(map[string]time.Time)
+	if err := p.kvGetJSON(trackedSinceKey, &trackedSince); err != nil {
+		return err
+	}
+	p.trackedSince = trackedSince
+	return nil
+}
+
+// markTracked record date as the start of collected data in the channel if it's older than the known one
+// called with the first counted post of a channel, and with the start of a backfill, admins of a channel tracked for
+// the first time are notified
+func (p *Plugin) markTracked(channelID string, date time.Time) error {
+	p.trackedSinceLock.Lock()
+	defer p.trackedSinceLock.Unlock()
+	if err := p.loadTrackedSince(); err != nil {
+		return err
+	}
+	since, tracked := p.trackedSince[channelID]
+	if tracked && !date.Before(since) {
+		return nil
+	}
+	p.trackedSince[channelID] = date
+	if err := p.kvSetJSON(trackedSinceKey, p.trackedSince); err != nil {
+		return err
+	}
+	if tracked {
+		return nil
+	}
+	return p.requestConsent(channelID)
+}
+
+// channelTrackedSince return the start of collected data in the channel, zero if unknown
+func (p *Plugin) channelTrackedSince(channelID string) time.Time {
+	p.trackedSinceLock.Lock()
+	defer p.trackedSinceLock.Unlock()
+	if err := p.loadTrackedSince(); err != nil {
+		p.API.LogWarn("can't get tracked channels", "err", err.Error())
+		return time.Time{}
+	}
+	return p.trackedSince[channelID]
+}
+
+// partialSince return the date from which data of the channel is complete if it's after the start of the period, zero otherwise
+func (p *Plugin) partialSince(channelID string, periodStart time.Time) time.Time {
+	since := p.channelTrackedSince(channelID)
+	if since.After(periodStart) {
+		return since
+	}
+	return time.Time{}
+}
+
+// partialNote return the annotation of a metric with partial data, empty if data is complete
+func partialNote(since time.Time, T translateFunc) string {
+	if since.IsZero() {
+		return ""
+	}
+	return T("report.partial", map[string]interface{}{"Date": since.Format(T("report.short_date_layout"))})
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+var (
+	// usersChartRegexp match the users pie chart of a digest, its query is keyed by usernames
+	usersChartRegexp = regexp.MustCompile(`!\[users pie chart\]\(([^)]*)\)`)
+	// tableSeparatorRegexp match the row separating the header of a markdown table from its rows
+	tableSeparatorRegexp = regexp.MustCompile(`^\|(\s*:?-*:?\s*\|)+$`)
+	// emojiRegexp match emoji codes, e.g. medals of top users
+	emojiRegexp = regexp.MustCompile(`:[a-z0-9_+-]+:`)
+)
+
+// namedUser is a user named in a digest, Reason is where the user is named, Chart is set if the user is drawn in a
+// chart with Value messages
+type namedUser struct {
+	Username string
+	Reason   string
+	Chart    bool
+	Value    string
+}
+
+// tableCells return trimmed cells of a markdown table row
+func tableCells(line string) []string {
+	cells := strings.Split(strings.Trim(strings.TrimSpace(line), "|"), "|")
+	for index := range cells {
+		cells[index] = strings.TrimSpace(cells[index])
+	}
+	return cells
+}
+
+// plainLine return line without markdown bold, italic, bullets and emoji codes
+func plainLine(line string) string {
+	line = emojiRegexp.ReplaceAllString(line, "")
+	line = strings.NewReplacer("**", "", "*", "").Replace(strings.TrimSpace(line))
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// namedUsernames return users named in text, mentioned or drawn in a users pie chart, once by reason
+// the reason of a mention is the line naming the user prefixed with the heading of its section, rows of a table are
+// labelled with its header
+func namedUsernames(text string) []namedUser {
+	named := make([]namedUser, 0)
+	seen := make(map[string]bool)
+	appendNamed := func(user namedUser) {
+		if key := user.Username + "\x00" + user.Reason + "\x00" + user.Value; !seen[key] {
+			seen[key] = true
+			named = append(named, user)
+		}
+	}
+	heading := ""
+	var header []string
+	lines := strings.Split(text, "\n")
+	for index, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			heading = plainLine(strings.TrimLeft(trimmed, "#"))
+			header = nil
+			continue
+		case tableSeparatorRegexp.MatchString(trimmed) && index > 0:
+			header = tableCells(lines[index-1])
+			continue
+		case !strings.HasPrefix(trimmed, "|"):
+			header = nil
+		}
+
+		for _, match := range usersChartRegexp.FindAllStringSubmatch(line, -1) {
+			chartURL, err := url.Parse(match[1])
+			if err != nil {
+				continue
+			}
+			query := chartURL.Query()
+			keys := make([]string, 0, len(query))
+			for key := range query {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if !isChartParameter(key) {
+					appendNamed(namedUser{Username: strings.ToLower(key), Chart: true, Value: query.Get(key)})
+				}
+			}
+		}
+		usernames, _ := parseMentions(usersChartRegexp.ReplaceAllString(line, ""))
+		if len(usernames) == 0 {
+			continue
+		}
+		reason := plainLine(trimmed)
+		if cells := tableCells(trimmed); header != nil && strings.HasPrefix(trimmed, "|") {
+			labelled := make([]string, 0, len(cells))
+			for column, cell := range cells {
+				if column < len(header) && header[column] != "" {
+					cell = header[column] + ": " + cell
+				}
+				labelled = append(labelled, cell)
+			}
+			reason = plainLine(strings.Join(labelled, ", "))
+		}
+		if heading != "" {
+			reason = heading + " - " + reason
+		}
+		for _, username := range usernames {
+			appendNamed(namedUser{Username: username, Reason: reason})
+		}
+	}
+	return named
+}
+
+// namedUsers collect users named in the digests of a scheduled report, with the reasons they are named in the
+// digest of each channel
+type namedUsers struct {
+	sync.Mutex
+	// T translate reasons of charts, in the language of transparency messages
+	T       translateFunc
+	reasons map[string]map[string][]string
+}
+
+func newNamedUsers(T translateFunc) *namedUsers {
+	return &namedUsers{T: T, reasons: make(map[string]map[string][]string)}
+}
+
+// record add reason to the reasons username is named in the digest of channelID, caller must hold the lock
+func (n *namedUsers) record(channelID string, username string, reason string) {
+	if n.reasons[username] == nil {
+		n.reasons[username] = make(map[string][]string)
+	}
+	for _, recorded := range n.reasons[username][channelID] {
+		if recorded == reason {
+			return
+		}
+	}
+	n.reasons[username][channelID] = append(n.reasons[username][channelID], reason)
+}
+
+// add record users named in text, part of the digest of channelID, with the reason they are named
+func (n *namedUsers) add(channelID string, text string) {
+	n.Lock()
+	defer n.Unlock()
+	for _, user := range namedUsernames(text) {
+		reason := user.Reason
+		if user.Chart {
+			reason = n.T("transparency.users_chart", map[string]interface{}{"Messages": user.Value})
+		}
+		n.record(channelID, user.Username, reason)
+	}
+}
+
+// addAttachments record users named in attachments and drawn in images of the digest of channelID
+func (n *namedUsers) addAttachments(channelID string, attachments []*model.SlackAttachment, images []*chartImage) {
+	for _, attachment := range attachments {
+		n.add(channelID, attachment.Pretext)
+		n.add(channelID, attachment.Text)
+		for _, field := range attachment.Fields {
+			if value, ok := field.Value.(string); ok {
+				n.add(channelID, value)
+			}
+		}
+	}
+	n.Lock()
+	defer n.Unlock()
+	for _, image := range images {
+		for _, username := range image.Usernames {
+			n.record(channelID, username, n.T("transparency.chart_image"))
+		}
+	}
+}
+
+// usernames return named usernames sorted
+func (n *namedUsers) usernames() []string {
+	n.Lock()
+	defer n.Unlock()
+	usernames := make([]string, 0, len(n.reasons))
+	for username := range n.reasons {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	return usernames
+}
+
+// reasonsOf return the reasons username is named by id of the channel of the digest, and these ids sorted
+func (n *namedUsers) reasonsOf(username string) (map[string][]string, []string) {
+	n.Lock()
+	defer n.Unlock()
+	reasons := make(map[string][]string, len(n.reasons[username]))
+	channelsID := make([]string, 0, len(n.reasons[username]))
+	for channelID, channelReasons := range n.reasons[username] {
+		reasons[channelID] = append([]string{}, channelReasons...)
+		channelsID = append(channelsID, channelID)
+	}
+	sort.Strings(channelsID)
+	return reasons, channelsID
+}
+
+// sendTransparencyMessages send a DM to every user named in the digests of the scheduled report covering from to to,
+// explaining where and why they appear
+func (p *Plugin) sendTransparencyMessages(named *namedUsers, from time.Time, to time.Time) error {
+	if !p.getConfiguration().TransparencyDM {
+		return nil
+	}
+
+	T := p.translate()
+	links := make(map[string]string)
+	for _, username := range named.usernames() {
+		user, appErr := p.API.GetUserByUsername(username)
+		if appErr != nil || user.IsBot {
+			continue
+		}
+		reasons, channelsID := named.reasonsOf(username)
+		message := T("transparency.message", map[string]interface{}{
+			"Username": user.Username,
+			"From":     from.Format(T("report.date_layout")),
+			"To":       to.Format(T("report.date_layout")),
+		}) + "\n"
+		listed := 0
+		for _, channelID := range channelsID {
+			link, ok := links[channelID]
+			if !ok {
+				_, channelDisplayName, channelLink, err := p.getChannelName(channelID)
+				if err != nil {
+					p.API.LogError("can't get channel of transparency message, skip it", "channel", channelID, "err", err.Error())
+					continue
+				}
+				link = fmt.Sprintf("[~%s](%s)", channelDisplayName, channelLink)
+				links[channelID] = link
+			}
+			message += fmt.Sprintf("* %s: %s\n", link, strings.Join(reasons[channelID], "; "))
+			listed++
+		}
+		if listed == 0 {
+			continue
+		}
+		if err := p.sendDirectMessage(user.Id, message, nil); err != nil {
+			p.API.LogError("can't send transparency message", "user", user.Id, "err", err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamedUsernames(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]namedUser{
+		{Username: "alice", Reason: "Top posters - 1. @alice: 42 messages"},
+		{Username: "bob", Reason: "On-call - First responder: @Bob, Requests: 3, Median first response: 12m0s"},
+	}, namedUsernames("#### Top posters\n1. @alice: **42** messages\n#### On-call\n| First responder | Requests | Median first response |\n|:--|--:|--:|\n| @Bob | 3 | 12m0s |\n@here **2** @channel **1**"))
+
+	assert.Equal([]namedUser{
+		{Username: "carol_smith", Reason: "Top Users - @carol_smith: 5 messages (30% of total)"},
+		{Username: "alice", Chart: true, Value: "5"},
+		{Username: "dave", Chart: true, Value: "2"},
+	}, namedUsernames("### Top Users\n* :1st_place_medal: @carol_smith: **5** messages *(30% of total)*\n| |\n|:-:|\n|![users pie chart](https://example.com/pie.svg?dave=2&alice=5&rtl=1)|"))
+
+	assert.Empty(namedUsernames("|![channels pie chart](https://example.com/pie.svg?town-square=8)|"))
+	assert.Empty(namedUsernames("write to alice@example.com"))
+}
+
+func TestNamedUsers(t *testing.T) {
+	assert := assert.New(t)
+
+	named := newNamedUsers(testTranslate(t, "en"))
+	named.addAttachments("channel2", []*model.SlackAttachment{{
+		Text:   "@bob",
+		Fields: []*model.SlackAttachmentField{{Value: "Most mentioned: @alice (3) and @all"}},
+	}}, []*chartImage{{Name: "top-users.png", Usernames: []string{"carol"}}})
+	named.add("channel1", "|![users pie chart](https://example.com/pie.svg?alice=5)|")
+	named.add("channel2", "Most mentioned: @alice (3) and @all")
+
+	assert.Equal([]string{"alice", "bob", "carol"}, named.usernames())
+	reasons, channelsID := named.reasonsOf("alice")
+	assert.Equal([]string{"channel1", "channel2"}, channelsID)
+	assert.Equal(map[string][]string{
+		"channel1": {"users pie chart, 5 messages"},
+		"channel2": {"Most mentioned: @alice (3) and @all"},
+	}, reasons)
+	reasons, _ = named.reasonsOf("carol")
+	assert.Equal(map[string][]string{"channel2": {"top users chart"}}, reasons)
+	_, channelsID = named.reasonsOf("dave")
+	assert.Empty(channelsID)
+}
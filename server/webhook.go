@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+	"github.com/pkg/errors"
+)
+
+// webhookSignatureHeader carry the hmac sha256 of the body of reports pushed to OutgoingWebhookURL
+const webhookSignatureHeader = "X-Analytics-Signature"
+
+// WebhookReport is the json body of a report pushed to OutgoingWebhookURL
+type WebhookReport struct {
+	ReportID    string                   `json:"report_id"`
+	ChannelID   string                   `json:"channel_id"`
+	Period      string                   `json:"period"`
+	PostID      string                   `json:"post_id"`
+	SentAt      time.Time                `json:"sent_at"`
+	Attachments []*model.SlackAttachment `json:"attachments"`
+}
+
+// signWebhookBody return the signature of body with secret, in form sha256=hex
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// pushReportToWebhook post a report as json to OutgoingWebhookURL if set, signed with OutgoingWebhookSecret
+// it is retried with backoff on network and server errors
+func (p *Plugin) pushReportToWebhook(report *WebhookReport) error {
+	config := p.getConfiguration()
+	if config.OutgoingWebhookURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return errors.Wrap(err, "can't marshal report")
+	}
+	signature := signWebhookBody(config.OutgoingWebhookSecret, body)
+	client := &http.Client{Timeout: 30 * time.Second}
+	delay := deliveryRetryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := postWebhook(client, config.OutgoingWebhookURL, signature, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == maxDeliveryAttempts {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// postWebhook send a signed body to url, return true with the error if it may succeed later
+func postWebhook(client *http.Client, url string, signature string, body []byte) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.Wrap(err, "can't create webhook request")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookSignatureHeader, signature)
+	response, err := client.Do(request)
+	if err != nil {
+		return true, errors.Wrap(err, "can't send webhook request")
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500 {
+		return true, errors.Errorf("webhook request failed, status %d", response.StatusCode)
+	}
+	if response.StatusCode >= 300 {
+		return false, errors.Errorf("webhook request failed, status %d", response.StatusCode)
+	}
+	return false, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignWebhookBody(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", signWebhookBody("key", []byte("The quick brown fox jumps over the lazy dog")))
+}
+
+func TestPushReportToWebhook(t *testing.T) {
+	assert := assert.New(t)
+
+	deliveryRetryDelay = 0
+	defer func() { deliveryRetryDelay = 2 * time.Second }()
+
+	requests := 0
+	var received WebhookReport
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		assert.Nil(err)
+		assert.Equal(signWebhookBody("secret", body), r.Header.Get(webhookSignatureHeader))
+		assert.Nil(json.Unmarshal(body, &received))
+	}))
+	defer server.Close()
+
+	p := &Plugin{}
+	assert.Nil(p.pushReportToWebhook(&WebhookReport{ReportID: "channel1_2020-W12"}))
+	assert.Equal(0, requests)
+
+	p.setConfiguration(&configuration{OutgoingWebhookURL: server.URL, OutgoingWebhookSecret: "secret"})
+	assert.Nil(p.pushReportToWebhook(&WebhookReport{ReportID: "channel1_2020-W12", ChannelID: "channel1", Period: "2020-W12"}))
+	assert.Equal(2, requests)
+	assert.Equal("channel1", received.ChannelID)
+	assert.Equal("2020-W12", received.Period)
+
+	p.setConfiguration(&configuration{OutgoingWebhookURL: server.URL + "/missing", OutgoingWebhookSecret: "secret"})
+	server.Config.Handler = http.NotFoundHandler()
+	assert.EqualError(p.pushReportToWebhook(&WebhookReport{}), "webhook request failed, status 404")
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+// digestPostedEvent is the websocket event sent to system admins when a scheduled report is posted
+// the webapp receives it as custom_<plugin id>_digest_posted
+const digestPostedEvent = "digest_posted"
+
+// digestSummary return the payload of the digest posted event for the analytic reported in period
+func digestSummary(analytic *Analytic, period string, reports int) map[string]interface{} {
+	analytic.RLock()
+	defer analytic.RUnlock()
+	var messages int64
+	for _, nb := range analytic.Channels {
+		messages += nb
+	}
+	return map[string]interface{}{
+		"period":       period,
+		"since":        analytic.Start.Format(time.RFC3339),
+		"messages":     messages,
+		"active_users": len(analytic.Users),
+		"channels":     len(analytic.Channels),
+		"reports":      reports,
+	}
+}
+
+// publishDigestPosted notify connected system admins that a new digest is available
+func (p *Plugin) publishDigestPosted(summary map[string]interface{}) error {
+	admins, err := p.getSystemAdmins()
+	if err != nil {
+		return err
+	}
+	for _, adminID := range admins {
+		p.API.PublishWebSocketEvent(digestPostedEvent, summary, &model.WebsocketBroadcast{UserId: adminID})
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	analytic := NewAnalytic(time.Now())
+	analytic.Start = time.Date(2019, time.May, 5, 0, 0, 0, 0, time.UTC)
+	analytic.Channels = map[string]int64{"channel1": 5, "channel2": 3}
+	analytic.Users = map[string]int64{"user1": 6, "user2": 1, "user3": 1}
+
+	assert.Equal(map[string]interface{}{
+		"period":       "2019-W19",
+		"since":        "2019-05-05T00:00:00Z",
+		"messages":     int64(8),
+		"active_users": 3,
+		"channels":     2,
+		"reports":      1,
+	}, digestSummary(analytic, "2019-W19", 1))
+}
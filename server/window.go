@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost-server/v5/model"
+)
+
+const pendingReportsKey = "pending_reports"
+
+// deliveryWindow is the time of day a report channel accepts reports, in the timezone of its members
+type deliveryWindow struct {
+	// Start and End are durations since midnight, a window ending before it starts spans midnight
+	Start    time.Duration
+	End      time.Duration
+	Location *time.Location
+}
+
+// parseDeliveryWindows parse windows in form TeamName/ChannelName=08:00-10:00@Europe/Paris separated by semicolons
+// the timezone is optional, location is used without it, return windows by TeamName/ChannelName
+func parseDeliveryWindows(config string, location *time.Location) (map[string]*deliveryWindow, error) {
+	windows := make(map[string]*deliveryWindow)
+	for _, entry := range strings.Split(config, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		v := strings.SplitN(entry, "=", 2)
+		if len(v) != 2 || strings.Count(v[0], "/") != 1 {
+			return nil, fmt.Errorf("Bad formatted delivery window: %v, expected TeamName/ChannelName=08:00-10:00", entry)
+		}
+		window := &deliveryWindow{Location: location}
+		hours := strings.TrimSpace(v[1])
+		if i := strings.Index(hours, "@"); i >= 0 {
+			zone, err := loadLocation(hours[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			window.Location = zone
+			hours = hours[:i]
+		}
+		bounds := strings.SplitN(hours, "-", 2)
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("Bad formatted delivery window: %v, expected TeamName/ChannelName=08:00-10:00", entry)
+		}
+		var err error
+		if window.Start, err = parseTimeOfDay(bounds[0]); err != nil {
+			return nil, err
+		}
+		if window.End, err = parseTimeOfDay(bounds[1]); err != nil {
+			return nil, err
+		}
+		if window.Start == window.End {
+			return nil, fmt.Errorf("Empty delivery window: %v", entry)
+		}
+		windows[strings.TrimSpace(v[0])] = window
+	}
+	return windows, nil
+}
+
+// parseTimeOfDay return the duration since midnight of a time in form 15:04
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("Bad time of day %v, expected 15:04", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// sinceMidnight return the local midnight of t and the duration since then
+func (w *deliveryWindow) sinceMidnight(t time.Time) (time.Time, time.Duration) {
+	local := t.In(w.Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.Location)
+	return midnight, local.Sub(midnight)
+}
+
+// contains return true if t is in the window
+func (w *deliveryWindow) contains(t time.Time) bool {
+	_, timeOfDay := w.sinceMidnight(t)
+	if w.Start < w.End {
+		return timeOfDay >= w.Start && timeOfDay < w.End
+	}
+	return timeOfDay >= w.Start || timeOfDay < w.End
+}
+
+// nextSlot return t if it is in the window, else the next opening of the window
+func (w *deliveryWindow) nextSlot(t time.Time) time.Time {
+	if w.contains(t) {
+		return t
+	}
+	midnight, timeOfDay := w.sinceMidnight(t)
+	if timeOfDay < w.Start {
+		return midnight.Add(w.Start)
+	}
+	return midnight.AddDate(0, 0, 1).Add(w.Start)
+}
+
+// resolveDeliveryWindows return windows by report channel id
+func (p *Plugin) resolveDeliveryWindows(windows map[string]*deliveryWindow) (map[string]*deliveryWindow, error) {
+	resolved := make(map[string]*deliveryWindow, len(windows))
+	for channel, window := range windows {
+		channelsID, err := p.parseChannelsFromConfig(channel)
+		if err != nil {
+			return nil, err
+		}
+		resolved[channelsID[0]] = window
+	}
+	return resolved, nil
+}
+
+// PendingReport is a report generated outside the delivery window of its channel, posted when the window opens
+type PendingReport struct {
+	ChannelID   string
+	Period      string
+	DueAt       time.Time
+	Attachments []*model.SlackAttachment
+	Images      []*chartImage
+}
+
+// deferReport keep a report until its due date
+func (p *Plugin) deferReport(report *PendingReport) error {
+	p.pendingReportsLock.Lock()
+	defer p.pendingReportsLock.Unlock()
+	pending := make([]*PendingReport, 0)
+	if err := p.kvGetJSON(pendingReportsKey, &pending); err != nil {
+		return err
+	}
+	p.API.LogInfo("report deferred to the delivery window of its channel", "report", reportID(report.ChannelID, report.Period), "due", report.DueAt.String())
+	return p.kvSetJSON(pendingReportsKey, append(pending, report))
+}
+
+// deliverPendingReports post pending reports whose delivery window is open, they are kept while posting is paused
+func (p *Plugin) deliverPendingReports() error {
+	if p.isPostingPaused() {
+		return nil
+	}
+	p.pendingReportsLock.Lock()
+	defer p.pendingReportsLock.Unlock()
+	pending := make([]*PendingReport, 0)
+	if err := p.kvGetJSON(pendingReportsKey, &pending); err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	now := p.now()
+	kept := make([]*PendingReport, 0, len(pending))
+	for _, report := range pending {
+		if report.DueAt.After(now) {
+			kept = append(kept, report)
+			continue
+		}
+		if err := p.postChannelReport(report.ChannelID, report.Period, report.Attachments, report.Images); err != nil {
+			p.API.LogError("can't post pending report", "report", reportID(report.ChannelID, report.Period), "err", err.Error())
+			kept = append(kept, report)
+		}
+	}
+	if len(kept) == len(pending) {
+		return nil
+	}
+	return p.kvSetJSON(pendingReportsKey, kept)
+}
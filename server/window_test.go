@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeliveryWindows(t *testing.T) {
+	assert := assert.New(t)
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	assert.Nil(err)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(err)
+
+	windows, err := parseDeliveryWindows("TeamA/reports=08:00-10:00@Asia/Tokyo; TeamB/digest = 22:30-01:00", paris)
+	assert.Nil(err)
+	assert.Equal(map[string]*deliveryWindow{
+		"TeamA/reports": {Start: 8 * time.Hour, End: 10 * time.Hour, Location: tokyo},
+		"TeamB/digest":  {Start: 22*time.Hour + 30*time.Minute, End: time.Hour, Location: paris},
+	}, windows)
+
+	windows, err = parseDeliveryWindows("", paris)
+	assert.Nil(err)
+	assert.Empty(windows)
+
+	for _, config := range []string{"TeamA/reports", "reports=08:00-10:00", "TeamA/reports=08:00", "TeamA/reports=8h-10h", "TeamA/reports=08:00-10:00@Mars/Olympus", "TeamA/reports=09:00-09:00"} {
+		_, err = parseDeliveryWindows(config, paris)
+		assert.NotNil(err, config)
+	}
+}
+
+func TestDeliveryWindowNextSlot(t *testing.T) {
+	assert := assert.New(t)
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.Nil(err)
+	morning := &deliveryWindow{Start: 8 * time.Hour, End: 10 * time.Hour, Location: tokyo}
+	night := &deliveryWindow{Start: 22 * time.Hour, End: 2 * time.Hour, Location: tokyo}
+
+	// 00:00 UTC is 09:00 in Tokyo
+	inside := time.Date(2020, time.March, 16, 0, 0, 0, 0, time.UTC)
+	assert.True(morning.contains(inside))
+	assert.Equal(inside, morning.nextSlot(inside))
+	before := time.Date(2020, time.March, 15, 20, 0, 0, 0, time.UTC)
+	assert.False(morning.contains(before))
+	assert.True(morning.nextSlot(before).Equal(time.Date(2020, time.March, 16, 8, 0, 0, 0, tokyo)))
+	after := time.Date(2020, time.March, 16, 1, 0, 0, 0, time.UTC)
+	assert.False(morning.contains(after))
+	assert.True(morning.nextSlot(after).Equal(time.Date(2020, time.March, 17, 8, 0, 0, 0, tokyo)))
+
+	assert.True(night.contains(time.Date(2020, time.March, 16, 23, 0, 0, 0, tokyo)))
+	assert.True(night.contains(time.Date(2020, time.March, 16, 1, 59, 0, 0, tokyo)))
+	assert.False(night.contains(time.Date(2020, time.March, 16, 2, 0, 0, 0, tokyo)))
+	assert.True(night.nextSlot(time.Date(2020, time.March, 16, 12, 0, 0, 0, tokyo)).Equal(time.Date(2020, time.March, 16, 22, 0, 0, 0, tokyo)))
+}
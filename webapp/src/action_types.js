@@ -0,0 +1,7 @@
+import {id as pluginId} from './manifest';
+
+export const OPEN_DASHBOARD = pluginId + '_open_dashboard';
+export const CLOSE_DASHBOARD = pluginId + '_close_dashboard';
+export const OPEN_THREAD_ANALYTICS = pluginId + '_open_thread_analytics';
+export const CLOSE_THREAD_ANALYTICS = pluginId + '_close_thread_analytics';
+export const DIGEST_POSTED = pluginId + '_digest_posted';
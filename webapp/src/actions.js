@@ -0,0 +1,14 @@
+import {OPEN_DASHBOARD, CLOSE_DASHBOARD, OPEN_THREAD_ANALYTICS, CLOSE_THREAD_ANALYTICS, DIGEST_POSTED} from './action_types';
+
+// openDashboard show the dashboard, with the state decoded from a link if any
+export const openDashboard = (state = null) => ({type: OPEN_DASHBOARD, state});
+
+export const closeDashboard = () => ({type: CLOSE_DASHBOARD});
+
+// openThreadAnalytics show analytics of the thread of postId
+export const openThreadAnalytics = (postId) => ({type: OPEN_THREAD_ANALYTICS, postId});
+
+export const closeThreadAnalytics = () => ({type: CLOSE_THREAD_ANALYTICS});
+
+// digestPosted is dispatched by the websocket event sent when a scheduled report is posted
+export const digestPosted = (summary) => ({type: DIGEST_POSTED, summary});
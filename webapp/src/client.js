@@ -0,0 +1,129 @@
+import {id as pluginId} from './manifest';
+
+// fetchAnalytics get a resource of the plugin REST API, only system admins are allowed
+// params are extra query parameters, e.g. {sort: 'name', q: 'town', page: 0}
+export const fetchAnalytics = async (resource, from, to, params = {}) => {
+    const query = [`from=${from}`, `to=${to}`, ...Object.keys(params).map((key) => `${key}=${encodeURIComponent(params[key])}`)];
+    const basename = window.basename || '';
+    const url = `${basename}/plugins/${pluginId}/api/v1/analytics/${resource}?${query.join('&')}`;
+    const response = await fetch(url, {
+        credentials: 'same-origin',
+        headers: {'X-Requested-With': 'XMLHttpRequest'},
+    });
+    if (response.status === 401 || response.status === 403) {
+        throw new Error('Only system admins can see analytics.');
+    }
+    if (!response.ok) {
+        throw new Error(await response.text());
+    }
+    return response.json();
+};
+
+// fetchSeries get messages by day of channels, of all channels without channels, to overlay them on the same chart
+// with compareFrom, series of the period of the same length starting at compareFrom are added
+export const fetchSeries = async (from, to, channels = [], compareFrom = '') => {
+    const params = [`from=${from}`, `to=${to}`, ...channels.map((channel) => `channel=${encodeURIComponent(channel)}`)];
+    if (compareFrom) {
+        params.push(`compare_from=${compareFrom}`);
+    }
+    const basename = window.basename || '';
+    const url = `${basename}/plugins/${pluginId}/api/v1/analytics/series?${params.join('&')}`;
+    const response = await fetch(url, {
+        credentials: 'same-origin',
+        headers: {'X-Requested-With': 'XMLHttpRequest'},
+    });
+    if (response.status === 401 || response.status === 403) {
+        throw new Error('Only system admins can see analytics.');
+    }
+    if (!response.ok) {
+        throw new Error(await response.text());
+    }
+    return response.json();
+};
+
+// fetchThread get analytics of the thread of a post, any member of its channel is allowed
+export const fetchThread = async (postId) => {
+    const basename = window.basename || '';
+    const url = `${basename}/plugins/${pluginId}/api/v1/thread?post_id=${encodeURIComponent(postId)}`;
+    const response = await fetch(url, {
+        credentials: 'same-origin',
+        headers: {'X-Requested-With': 'XMLHttpRequest'},
+    });
+    if (!response.ok) {
+        throw new Error(await response.text());
+    }
+    return response.json();
+};
+
+// fetchSettings get a resource of the admin console pickers, only system admins are allowed
+// params are query parameters, arrays are repeated, e.g. {id: ['a', 'b']}
+const fetchSettings = async (resource, params = {}) => {
+    const query = [];
+    Object.keys(params).forEach((key) => {
+        [].concat(params[key]).forEach((value) => query.push(`${key}=${encodeURIComponent(value)}`));
+    });
+    const basename = window.basename || '';
+    const url = `${basename}/plugins/${pluginId}/api/v1/settings/${resource}?${query.join('&')}`;
+    const response = await fetch(url, {
+        credentials: 'same-origin',
+        headers: {'X-Requested-With': 'XMLHttpRequest'},
+    });
+    if (!response.ok) {
+        throw new Error(await response.text());
+    }
+    return response.json();
+};
+
+// fetchSettingsTeams get all teams, sorted by display name
+export const fetchSettingsTeams = () => fetchSettings('teams');
+
+// searchSettingsChannels get channels of a team matching term, the first channels of the team without term
+export const searchSettingsChannels = (teamId, term) => fetchSettings('channels', {team_id: teamId, term});
+
+// fetchSettingsChannels get channels of ids, deleted channels are missing
+export const fetchSettingsChannels = (ids) => (ids.length ? fetchSettings('channels', {id: ids}) : Promise.resolve([]));
+
+// csrfToken return the csrf token of the session, sent with requests changing data
+const csrfToken = () => {
+    const match = (/(?:^|;\s*)MMCSRF=([^;]*)/).exec(document.cookie);
+    return match ? match[1] : '';
+};
+
+// exportChannel ask the server to send by DM the daily csv of a channel, only system admins and channel admins are allowed
+export const exportChannel = async (channelId, days = 30) => {
+    const basename = window.basename || '';
+    const url = `${basename}/plugins/${pluginId}/api/v1/export/channel?channel_id=${encodeURIComponent(channelId)}&days=${days}`;
+    const response = await fetch(url, {
+        method: 'POST',
+        credentials: 'same-origin',
+        headers: {'X-Requested-With': 'XMLHttpRequest', 'X-CSRF-Token': csrfToken()},
+    });
+    if (response.status === 403) {
+        throw new Error('Only system admins and channel admins can export analytics of this channel.');
+    }
+    if (!response.ok) {
+        throw new Error(await response.text());
+    }
+};
+
+// brandLogoUrl is the url of the uploaded logo, version is its id so a new logo is not cached
+export const brandLogoUrl = (version) => {
+    const basename = window.basename || '';
+    return `${basename}/plugins/${pluginId}/api/v1/branding/logo?v=${encodeURIComponent(version)}`;
+};
+
+// uploadBrandLogo store a png or jpeg file as the logo of charts and return its id, only system admins are allowed
+export const uploadBrandLogo = async (file) => {
+    const basename = window.basename || '';
+    const response = await fetch(`${basename}/plugins/${pluginId}/api/v1/branding/logo`, {
+        method: 'POST',
+        credentials: 'same-origin',
+        headers: {'X-Requested-With': 'XMLHttpRequest', 'X-CSRF-Token': csrfToken(), 'Content-Type': file.type},
+        body: file,
+    });
+    if (!response.ok) {
+        throw new Error(await response.text());
+    }
+    const {id} = await response.json();
+    return id;
+};
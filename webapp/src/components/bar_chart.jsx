@@ -0,0 +1,77 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+
+// BarChart draw horizontal bars sorted by the caller, hovering a bar shows its value
+export default class BarChart extends React.PureComponent {
+    static propTypes = {
+        bars: PropTypes.arrayOf(PropTypes.shape({
+            label: PropTypes.string.isRequired,
+            value: PropTypes.number.isRequired,
+        })).isRequired,
+        unit: PropTypes.string.isRequired,
+    };
+
+    state = {
+        hovered: null,
+    };
+
+    render() {
+        const {bars, unit} = this.props;
+        if (bars.length === 0) {
+            return <p>{'No data for this period.'}</p>;
+        }
+        const max = Math.max(1, ...bars.map((bar) => bar.value));
+
+        return (
+            <div>
+                {bars.map((bar, index) => (
+                    <div
+                        key={bar.label}
+                        style={style.row}
+                        onMouseEnter={() => this.setState({hovered: index})}
+                        onMouseLeave={() => this.setState({hovered: null})}
+                    >
+                        <span style={style.label}>{bar.label}</span>
+                        <div style={style.track}>
+                            <div
+                                style={{
+                                    ...style.bar,
+                                    width: `${(bar.value / max) * 100}%`,
+                                    opacity: this.state.hovered === null || this.state.hovered === index ? 1 : 0.5,
+                                }}
+                            />
+                        </div>
+                        <span style={style.value}>
+                            {this.state.hovered === index ? `${bar.value} ${unit}` : bar.value}
+                        </span>
+                    </div>
+                ))}
+            </div>
+        );
+    }
+}
+
+const style = {
+    row: {
+        display: 'flex',
+        alignItems: 'center',
+        marginBottom: '4px',
+    },
+    label: {
+        width: '200px',
+        overflow: 'hidden',
+        textOverflow: 'ellipsis',
+        whiteSpace: 'nowrap',
+    },
+    track: {
+        flex: 1,
+        margin: '0 10px',
+    },
+    bar: {
+        height: '16px',
+        background: '#166de0',
+    },
+    value: {
+        width: '120px',
+    },
+};
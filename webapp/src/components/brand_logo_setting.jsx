@@ -0,0 +1,92 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+
+import {brandLogoUrl, uploadBrandLogo} from '../client';
+
+// BrandLogoSetting is the admin console setting of the logo of charts, the file is uploaded when picked and used once
+// the settings are saved
+export default class BrandLogoSetting extends React.PureComponent {
+    static propTypes = {
+        id: PropTypes.string.isRequired,
+        label: PropTypes.node,
+        helpText: PropTypes.node,
+        value: PropTypes.string,
+        disabled: PropTypes.bool,
+        onChange: PropTypes.func.isRequired,
+    };
+
+    state = {
+        uploading: false,
+        error: null,
+    };
+
+    upload = async (e) => {
+        const file = e.target.files[0];
+        e.target.value = '';
+        if (!file) {
+            return;
+        }
+        this.setState({uploading: true, error: null});
+        try {
+            const logoId = await uploadBrandLogo(file);
+            this.setState({uploading: false});
+            this.props.onChange(this.props.id, logoId);
+        } catch (error) {
+            this.setState({uploading: false, error: error.message});
+        }
+    };
+
+    render() {
+        const {label, helpText, value, disabled} = this.props;
+        const {uploading, error} = this.state;
+        return (
+            <div className='form-group'>
+                <label className='control-label col-sm-4'>{label}</label>
+                <div className='col-sm-8'>
+                    {value && (
+                        <div style={style.preview}>
+                            <img
+                                src={brandLogoUrl(value)}
+                                alt='Brand logo'
+                                style={style.logo}
+                            />
+                            {!disabled && (
+                                <button
+                                    type='button'
+                                    className='btn btn-link'
+                                    onClick={() => this.props.onChange(this.props.id, '')}
+                                >
+                                    {'Remove'}
+                                </button>
+                            )}
+                        </div>
+                    )}
+                    <input
+                        type='file'
+                        accept='image/png,image/jpeg'
+                        disabled={disabled || uploading}
+                        onChange={this.upload}
+                    />
+                    {uploading && <p>{'Uploading...'}</p>}
+                    {error && <p style={style.error}>{error}</p>}
+                    <div className='help-text'>{helpText}</div>
+                </div>
+            </div>
+        );
+    }
+}
+
+const style = {
+    preview: {
+        display: 'flex',
+        alignItems: 'center',
+        marginBottom: '8px',
+    },
+    logo: {
+        height: '32px',
+        marginRight: '8px',
+    },
+    error: {
+        color: '#d24b4e',
+    },
+};
@@ -0,0 +1,151 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+
+import {fetchSeries} from '../client';
+
+import LineChart from './line_chart';
+
+const colors = ['#166de0', '#3db887', '#ffbc1f', '#d24b4e'];
+
+// Comparison overlay two channels, or a channel and the previous period, on the same chart with the same axes
+export default class Comparison extends React.PureComponent {
+    static propTypes = {
+        from: PropTypes.string.isRequired,
+        to: PropTypes.string.isRequired,
+        previousFrom: PropTypes.string.isRequired,
+        channels: PropTypes.arrayOf(PropTypes.shape({
+            id: PropTypes.string.isRequired,
+            label: PropTypes.string.isRequired,
+        })).isRequired,
+        first: PropTypes.string,
+        second: PropTypes.string,
+        previousPeriod: PropTypes.bool,
+        onChange: PropTypes.func,
+    };
+
+    static defaultProps = {
+        first: '',
+        second: '',
+        previousPeriod: false,
+    };
+
+    state = {
+        first: this.props.first,
+        second: this.props.second,
+        previousPeriod: this.props.previousPeriod,
+        loading: false,
+        error: null,
+        series: [],
+    };
+
+    componentDidMount() {
+        this.load();
+    }
+
+    componentDidUpdate(prevProps, prevState) {
+        if (prevProps.from !== this.props.from ||
+            prevState.first !== this.state.first ||
+            prevState.second !== this.state.second ||
+            prevState.previousPeriod !== this.state.previousPeriod) {
+            this.load();
+        }
+        if (this.props.onChange && (
+            prevState.first !== this.state.first ||
+            prevState.second !== this.state.second ||
+            prevState.previousPeriod !== this.state.previousPeriod)) {
+            this.props.onChange({
+                first: this.state.first,
+                second: this.state.second,
+                previousPeriod: this.state.previousPeriod,
+            });
+        }
+    }
+
+    load = async () => {
+        const {from, to, previousFrom} = this.props;
+        const {first, second, previousPeriod} = this.state;
+        const channels = [first, second].filter((channel) => channel !== '');
+        this.setState({loading: true, error: null});
+        try {
+            const response = await fetchSeries(from, to, channels, previousPeriod ? previousFrom : '');
+            this.setState({loading: false, series: response.series || []});
+        } catch (error) {
+            this.setState({loading: false, error: error.message});
+        }
+    };
+
+    renderSelect = (name, label) => (
+        <label style={style.control}>
+            {label}
+            <select
+                className='form-control'
+                value={this.state[name]}
+                onChange={(e) => this.setState({[name]: e.target.value})}
+            >
+                <option value=''>{name === 'first' ? 'All channels' : 'None'}</option>
+                {this.props.channels.map((channel) => (
+                    <option
+                        key={channel.id}
+                        value={channel.id}
+                    >
+                        {channel.label}
+                    </option>
+                ))}
+            </select>
+        </label>
+    );
+
+    render() {
+        const {loading, error, series, previousPeriod} = this.state;
+
+        let chart;
+        if (error) {
+            chart = <p style={style.error}>{error}</p>;
+        } else if (loading) {
+            chart = <p>{'Loading...'}</p>;
+        } else {
+            const days = series.length > 0 ? series[0].messages.length : 0;
+            chart = (
+                <LineChart
+                    labels={Array.from({length: days}, (_, index) => `Day ${index + 1}`)}
+                    series={series.map((serie, index) => ({
+                        name: serie.name,
+                        color: colors[index % colors.length],
+                        values: serie.messages,
+                    }))}
+                />
+            );
+        }
+
+        return (
+            <div>
+                <div style={style.controls}>
+                    {this.renderSelect('first', 'Channel')}
+                    {this.renderSelect('second', 'Compared with')}
+                    <label style={style.control}>
+                        <input
+                            type='checkbox'
+                            checked={previousPeriod}
+                            onChange={(e) => this.setState({previousPeriod: e.target.checked})}
+                        />
+                        {' Overlay the previous period'}
+                    </label>
+                </div>
+                {chart}
+            </div>
+        );
+    }
+}
+
+const style = {
+    controls: {
+        display: 'flex',
+        alignItems: 'flex-end',
+    },
+    control: {
+        marginRight: '20px',
+    },
+    error: {
+        color: '#d24b4e',
+    },
+};
@@ -0,0 +1,307 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+import {connect} from 'react-redux';
+
+import {id as pluginId} from '../manifest';
+import {closeDashboard} from '../actions';
+import {fetchAnalytics} from '../client';
+import {defaultState, locationWithState, ranges} from '../url_state';
+
+import LineChart from './line_chart';
+import BarChart from './bar_chart';
+import Comparison from './comparison';
+import DataTable from './data_table';
+
+const maxTopChannels = 10;
+
+const channelColumns = [
+    {key: 'name', label: 'Channel', sort: 'name', render: (channel) => channel.display_name || channel.name || channel.id},
+    {key: 'messages', label: 'Messages', sort: 'messages', render: (channel) => channel.messages},
+    {key: 'replies', label: 'Replies', sort: 'replies', render: (channel) => channel.replies},
+    {key: 'files_size', label: 'Files size', sort: 'files_size', render: (channel) => channel.files_size},
+];
+
+const userColumns = [
+    {key: 'username', label: 'User', sort: 'name', render: (user) => user.username || user.id},
+    {key: 'messages', label: 'Messages', sort: 'messages', render: (user) => user.messages},
+    {key: 'replies', label: 'Replies', sort: 'replies', render: (user) => user.replies},
+    {key: 'files_size', label: 'Files size', sort: 'files_size', render: (user) => user.files_size},
+];
+
+// volumeSeries are series of the volume chart by metric of the url state
+const volumeSeries = {
+    messages: {name: 'Messages', color: '#166de0'},
+    replies: {name: 'Replies', color: '#3db887'},
+    active_users: {name: 'Active users', color: '#ffbc1f'},
+};
+
+// formatDate return the YYYY-MM-DD date expected by the REST API
+export const formatDate = (date) => {
+    const pad = (n) => (n < 10 ? '0' + n : '' + n);
+    return `${date.getFullYear()}-${pad(date.getMonth() + 1)}-${pad(date.getDate())}`;
+};
+
+class Dashboard extends React.PureComponent {
+    static propTypes = {
+        visible: PropTypes.bool.isRequired,
+        initialState: PropTypes.object,
+        close: PropTypes.func.isRequired,
+    };
+
+    state = {
+        days: defaultState.days,
+        metrics: defaultState.metrics,
+        comparison: {first: '', second: '', previousPeriod: false},
+        linkCopied: false,
+        loading: false,
+        error: null,
+        volume: [],
+        channels: [],
+    };
+
+    componentDidMount() {
+        document.addEventListener('keydown', this.handleKeyDown);
+    }
+
+    componentDidUpdate(prevProps, prevState) {
+        if (this.props.visible && !prevProps.visible) {
+            const initial = this.props.initialState || defaultState;
+            const [first = '', second = ''] = initial.channels;
+            this.setState({
+                metrics: initial.metrics,
+                comparison: {first, second, previousPeriod: initial.previousPeriod},
+                linkCopied: false,
+            });
+            this.load(initial.days);
+            return;
+        }
+        if (!this.props.visible && prevProps.visible) {
+            window.history.replaceState(window.history.state, '', locationWithState(window.location, null));
+            return;
+        }
+        if (this.props.visible && (
+            prevState.days !== this.state.days ||
+            prevState.metrics !== this.state.metrics ||
+            prevState.comparison !== this.state.comparison)) {
+            window.history.replaceState(window.history.state, '', locationWithState(window.location, this.urlState()));
+        }
+    }
+
+    componentWillUnmount() {
+        document.removeEventListener('keydown', this.handleKeyDown);
+    }
+
+    handleKeyDown = (e) => {
+        if (this.props.visible && e.key === 'Escape') {
+            this.props.close();
+        }
+    };
+
+    // urlState return the state of the dashboard encoded in its link
+    urlState = () => {
+        const {days, metrics, comparison} = this.state;
+        return {
+            days,
+            metrics,
+            channels: [comparison.first, comparison.second].filter((channel) => channel !== ''),
+            previousPeriod: comparison.previousPeriod,
+        };
+    };
+
+    toggleMetric = (name) => {
+        const metric = Object.keys(volumeSeries).find((key) => volumeSeries[key].name === name);
+        const {metrics} = this.state;
+        if (metrics.includes(metric)) {
+            this.setState({metrics: metrics.filter((m) => m !== metric)});
+        } else {
+            this.setState({metrics: Object.keys(volumeSeries).filter((m) => m === metric || metrics.includes(m))});
+        }
+    };
+
+    copyLink = async () => {
+        const url = window.location.origin + locationWithState(window.location, this.urlState());
+        try {
+            await navigator.clipboard.writeText(url);
+            this.setState({linkCopied: true});
+        } catch (error) {
+            this.setState({error: error.message});
+        }
+    };
+
+    load = async (days) => {
+        const to = new Date();
+        const from = new Date(to.getFullYear(), to.getMonth(), to.getDate() - days + 1);
+        this.setState({days, loading: true, error: null});
+        try {
+            const [volume, channels] = await Promise.all([
+                fetchAnalytics('days', formatDate(from), formatDate(to)),
+                fetchAnalytics('channels', formatDate(from), formatDate(to), {per_page: maxTopChannels}),
+            ]);
+            this.setState({
+                loading: false,
+                volume: volume.days || [],
+                channels: (channels.channels || []).slice(0, maxTopChannels),
+            });
+        } catch (error) {
+            this.setState({loading: false, error: error.message});
+        }
+    };
+
+    render() {
+        if (!this.props.visible) {
+            return null;
+        }
+        const {days, metrics, comparison, linkCopied, loading, error, volume, channels} = this.state;
+        const to = new Date();
+        const from = new Date(to.getFullYear(), to.getMonth(), to.getDate() - days + 1);
+        const previousFrom = new Date(from.getFullYear(), from.getMonth(), from.getDate() - days);
+
+        let content;
+        if (error) {
+            content = <p style={style.error}>{error}</p>;
+        } else if (loading) {
+            content = <p>{'Loading...'}</p>;
+        } else {
+            content = (
+                <React.Fragment>
+                    <h3>{'Message volume and active users'}</h3>
+                    <LineChart
+                        labels={volume.map((day) => day.date)}
+                        series={Object.keys(volumeSeries).map((metric) => ({
+                            ...volumeSeries[metric],
+                            values: volume.map((day) => day[metric]),
+                        }))}
+                        hidden={Object.keys(volumeSeries).reduce((hidden, metric) => ({
+                            ...hidden,
+                            [volumeSeries[metric].name]: !metrics.includes(metric),
+                        }), {})}
+                        onToggle={this.toggleMetric}
+                    />
+                    <h3>{'Top channels'}</h3>
+                    <BarChart
+                        bars={channels.map((channel) => ({
+                            label: channel.display_name || channel.name || channel.id,
+                            value: channel.messages,
+                        }))}
+                        unit='messages'
+                    />
+                    <h3>{'Comparison'}</h3>
+                    <Comparison
+                        from={formatDate(from)}
+                        to={formatDate(to)}
+                        previousFrom={formatDate(previousFrom)}
+                        first={comparison.first}
+                        second={comparison.second}
+                        previousPeriod={comparison.previousPeriod}
+                        onChange={(state) => this.setState({comparison: state})}
+                        channels={channels.map((channel) => ({
+                            id: channel.id,
+                            label: channel.display_name || channel.name || channel.id,
+                        }))}
+                    />
+                    <DataTable
+                        title='Channels'
+                        resource='channels'
+                        from={formatDate(from)}
+                        to={formatDate(to)}
+                        columns={channelColumns}
+                    />
+                    <DataTable
+                        title='Users'
+                        resource='users'
+                        from={formatDate(from)}
+                        to={formatDate(to)}
+                        columns={userColumns}
+                    />
+                </React.Fragment>
+            );
+        }
+
+        return (
+            <div style={style.backdrop}>
+                <div
+                    style={style.modal}
+                    role='dialog'
+                    aria-modal='true'
+                    aria-label='Analytics'
+                >
+                    <div style={style.header}>
+                        <h2 style={style.title}>{'Analytics'}</h2>
+                        {ranges.map((range) => (
+                            <button
+                                key={range}
+                                className={range === days ? 'btn btn-primary' : 'btn btn-link'}
+                                onClick={() => this.load(range)}
+                            >
+                                {`${range} days`}
+                            </button>
+                        ))}
+                        <button
+                            className='btn btn-link'
+                            onClick={this.copyLink}
+                        >
+                            {linkCopied ? 'Link copied' : 'Copy link'}
+                        </button>
+                        <button
+                            className='close'
+                            style={style.close}
+                            aria-label='Close'
+                            onClick={this.props.close}
+                        >
+                            {'×'}
+                        </button>
+                    </div>
+                    {content}
+                </div>
+            </div>
+        );
+    }
+}
+
+const style = {
+    backdrop: {
+        position: 'fixed',
+        top: 0,
+        left: 0,
+        right: 0,
+        bottom: 0,
+        zIndex: 1000,
+        background: 'rgba(0, 0, 0, 0.5)',
+    },
+    modal: {
+        position: 'absolute',
+        top: '5%',
+        left: '5%',
+        right: '5%',
+        bottom: '5%',
+        overflow: 'auto',
+        padding: '20px',
+        background: '#fff',
+        borderRadius: '4px',
+    },
+    header: {
+        display: 'flex',
+        alignItems: 'center',
+    },
+    title: {
+        flex: 1,
+        margin: 0,
+    },
+    close: {
+        marginLeft: '20px',
+    },
+    error: {
+        color: '#d24b4e',
+    },
+};
+
+const mapStateToProps = (state) => ({
+    visible: Boolean(state['plugins-' + pluginId] && state['plugins-' + pluginId].dashboardVisible),
+    initialState: state['plugins-' + pluginId] ? state['plugins-' + pluginId].dashboardState : null,
+});
+
+const mapDispatchToProps = (dispatch) => ({
+    close: () => dispatch(closeDashboard()),
+});
+
+export default connect(mapStateToProps, mapDispatchToProps)(Dashboard);
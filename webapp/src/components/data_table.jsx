@@ -0,0 +1,155 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+
+import {fetchAnalytics} from '../client';
+
+const perPage = 20;
+const searchDelay = 300;
+
+// DataTable list channels or users of the REST API with server-side sorting, search and pagination
+// headers and page links are buttons so the table can be used with the keyboard only
+export default class DataTable extends React.PureComponent {
+    static propTypes = {
+        title: PropTypes.string.isRequired,
+        resource: PropTypes.oneOf(['channels', 'users']).isRequired,
+        from: PropTypes.string.isRequired,
+        to: PropTypes.string.isRequired,
+        columns: PropTypes.arrayOf(PropTypes.shape({
+            key: PropTypes.string.isRequired,
+            label: PropTypes.string.isRequired,
+            sort: PropTypes.string.isRequired,
+            render: PropTypes.func.isRequired,
+        })).isRequired,
+    };
+
+    state = {
+        sort: 'messages',
+        order: 'desc',
+        search: '',
+        page: 0,
+        loading: false,
+        error: null,
+        rows: [],
+        total: 0,
+    };
+
+    componentDidMount() {
+        this.load();
+    }
+
+    componentDidUpdate(prevProps, prevState) {
+        if (prevProps.from !== this.props.from ||
+            prevState.sort !== this.state.sort ||
+            prevState.order !== this.state.order ||
+            prevState.page !== this.state.page) {
+            this.load();
+        }
+    }
+
+    componentWillUnmount() {
+        clearTimeout(this.searchTimeout);
+    }
+
+    load = async () => {
+        const {resource, from, to} = this.props;
+        const {sort, order, search, page} = this.state;
+        this.setState({loading: true, error: null});
+        try {
+            const response = await fetchAnalytics(resource, from, to, {sort, order, q: search, page, per_page: perPage});
+            this.setState({loading: false, rows: response[resource] || [], total: response.total || 0});
+        } catch (error) {
+            this.setState({loading: false, error: error.message});
+        }
+    };
+
+    search = (e) => {
+        this.setState({search: e.target.value, page: 0});
+        clearTimeout(this.searchTimeout);
+        this.searchTimeout = setTimeout(this.load, searchDelay);
+    };
+
+    sortBy = (sort) => {
+        if (sort === this.state.sort) {
+            this.setState({order: this.state.order === 'asc' ? 'desc' : 'asc', page: 0});
+            return;
+        }
+        this.setState({sort, order: sort === 'name' ? 'asc' : 'desc', page: 0});
+    };
+
+    render() {
+        const {title, resource, columns} = this.props;
+        const {sort, order, search, page, loading, error, rows, total} = this.state;
+        const pages = Math.max(1, Math.ceil(total / perPage));
+        const ariaSort = order === 'asc' ? 'ascending' : 'descending';
+
+        return (
+            <div>
+                <h3 id={`analytics-${resource}-title`}>{title}</h3>
+                <input
+                    type='search'
+                    className='form-control'
+                    placeholder='Search'
+                    aria-label={`Search ${resource}`}
+                    value={search}
+                    onChange={this.search}
+                />
+                {error ? <p style={style.error}>{error}</p> : null}
+                <table
+                    className='table'
+                    aria-labelledby={`analytics-${resource}-title`}
+                    aria-busy={loading}
+                >
+                    <thead>
+                        <tr>
+                            {columns.map((column) => (
+                                <th
+                                    key={column.key}
+                                    scope='col'
+                                    aria-sort={column.sort === sort ? ariaSort : 'none'}
+                                >
+                                    <button
+                                        className='btn btn-link'
+                                        onClick={() => this.sortBy(column.sort)}
+                                    >
+                                        {column.label}
+                                        {column.sort === sort ? (order === 'asc' ? ' ▲' : ' ▼') : ''}
+                                    </button>
+                                </th>
+                            ))}
+                        </tr>
+                    </thead>
+                    <tbody>
+                        {rows.map((row) => (
+                            <tr key={row.id}>
+                                {columns.map((column) => <td key={column.key}>{column.render(row)}</td>)}
+                            </tr>
+                        ))}
+                    </tbody>
+                </table>
+                <nav aria-label={`${title} pages`}>
+                    <button
+                        className='btn btn-link'
+                        disabled={page === 0}
+                        onClick={() => this.setState({page: page - 1})}
+                    >
+                        {'Previous'}
+                    </button>
+                    <span aria-live='polite'>{`Page ${page + 1} of ${pages}, ${total} ${resource}`}</span>
+                    <button
+                        className='btn btn-link'
+                        disabled={page + 1 >= pages}
+                        onClick={() => this.setState({page: page + 1})}
+                    >
+                        {'Next'}
+                    </button>
+                </nav>
+            </div>
+        );
+    }
+}
+
+const style = {
+    error: {
+        color: '#d24b4e',
+    },
+};
@@ -0,0 +1,124 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+
+const width = 800;
+const height = 260;
+const padding = 40;
+
+// LineChart draw series of values by label, hovering a label shows its values and clicking a legend hides its serie
+// hidden series are kept by the parent when hidden and onToggle are set
+export default class LineChart extends React.PureComponent {
+    static propTypes = {
+        labels: PropTypes.arrayOf(PropTypes.string).isRequired,
+        series: PropTypes.arrayOf(PropTypes.shape({
+            name: PropTypes.string.isRequired,
+            color: PropTypes.string.isRequired,
+            values: PropTypes.arrayOf(PropTypes.number).isRequired,
+        })).isRequired,
+        hidden: PropTypes.object,
+        onToggle: PropTypes.func,
+    };
+
+    state = {
+        hovered: null,
+        hidden: {},
+    };
+
+    toggle = (name) => {
+        if (this.props.onToggle) {
+            this.props.onToggle(name);
+            return;
+        }
+        this.setState({hidden: {...this.state.hidden, [name]: !this.state.hidden[name]}});
+    };
+
+    render() {
+        const {labels, series} = this.props;
+        const {hovered} = this.state;
+        const hidden = this.props.hidden || this.state.hidden;
+        if (labels.length === 0) {
+            return <p>{'No data for this period.'}</p>;
+        }
+
+        const visible = series.filter((serie) => !hidden[serie.name]);
+        const max = Math.max(1, ...visible.map((serie) => Math.max(...serie.values)));
+        const step = labels.length > 1 ? (width - (2 * padding)) / (labels.length - 1) : 0;
+        const x = (index) => padding + (index * step);
+        const y = (value) => height - padding - ((value / max) * (height - (2 * padding)));
+
+        return (
+            <div>
+                <svg
+                    viewBox={`0 0 ${width} ${height}`}
+                    style={{width: '100%'}}
+                    onMouseLeave={() => this.setState({hovered: null})}
+                >
+                    <line
+                        x1={padding}
+                        y1={height - padding}
+                        x2={width - padding}
+                        y2={height - padding}
+                        stroke='#ccc'
+                    />
+                    <text
+                        x={padding - 5}
+                        y={padding}
+                        textAnchor='end'
+                        fontSize='10'
+                    >
+                        {max}
+                    </text>
+                    <text
+                        x={padding}
+                        y={height - padding + 15}
+                        fontSize='10'
+                    >
+                        {labels[0]}
+                    </text>
+                    <text
+                        x={width - padding}
+                        y={height - padding + 15}
+                        textAnchor='end'
+                        fontSize='10'
+                    >
+                        {labels[labels.length - 1]}
+                    </text>
+                    {visible.map((serie) => (
+                        <polyline
+                            key={serie.name}
+                            fill='none'
+                            stroke={serie.color}
+                            strokeWidth='2'
+                            points={serie.values.map((value, index) => `${x(index)},${y(value)}`).join(' ')}
+                        />
+                    ))}
+                    {labels.map((label, index) => (
+                        <rect
+                            key={label}
+                            x={x(index) - (step / 2)}
+                            y={padding}
+                            width={Math.max(step, 1)}
+                            height={height - (2 * padding)}
+                            fill={hovered === index ? 'rgba(0, 0, 0, 0.05)' : 'transparent'}
+                            onMouseEnter={() => this.setState({hovered: index})}
+                        />
+                    ))}
+                </svg>
+                <div>
+                    {series.map((serie) => (
+                        <button
+                            key={serie.name}
+                            className='btn btn-link'
+                            style={{color: serie.color, textDecoration: hidden[serie.name] ? 'line-through' : 'none'}}
+                            onClick={() => this.toggle(serie.name)}
+                        >
+                            {serie.name}
+                            {hovered === null ? '' : `: ${serie.values[hovered]}`}
+                        </button>
+                    ))}
+                    {hovered === null ? null : <span>{labels[hovered]}</span>}
+                </div>
+            </div>
+        );
+    }
+}
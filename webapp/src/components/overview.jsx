@@ -0,0 +1,159 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+import {connect} from 'react-redux';
+
+import {openDashboard} from '../actions';
+import {fetchAnalytics} from '../client';
+import {defaultState, ranges} from '../url_state';
+
+import BarChart from './bar_chart';
+import {formatDate} from './dashboard';
+
+const maxTopChannels = 5;
+
+const metrics = [
+    {key: 'messages', label: 'Messages'},
+    {key: 'replies', label: 'Replies'},
+    {key: 'active_users', label: 'Active users'},
+];
+
+// formatChange return the change between yesterday and today, e.g. +12 or -3
+const formatChange = (today, yesterday) => {
+    const change = today - yesterday;
+    return change >= 0 ? `+${change}` : `${change}`;
+};
+
+// Overview is the right-hand sidebar with the org-wide snapshot of today and quick links to the dashboard
+class Overview extends React.PureComponent {
+    static propTypes = {
+        openDashboard: PropTypes.func.isRequired,
+    };
+
+    state = {
+        loading: true,
+        error: null,
+        today: null,
+        yesterday: null,
+        channels: [],
+    };
+
+    componentDidMount() {
+        this.load();
+    }
+
+    load = async () => {
+        const today = new Date();
+        const yesterday = new Date(today.getFullYear(), today.getMonth(), today.getDate() - 1);
+        this.setState({loading: true, error: null});
+        try {
+            const [days, channels] = await Promise.all([
+                fetchAnalytics('days', formatDate(yesterday), formatDate(today)),
+                fetchAnalytics('channels', formatDate(today), formatDate(today), {per_page: maxTopChannels}),
+            ]);
+            const byDate = {};
+            (days.days || []).forEach((day) => {
+                byDate[day.date] = day;
+            });
+            const empty = {messages: 0, replies: 0, active_users: 0};
+            this.setState({
+                loading: false,
+                today: byDate[formatDate(today)] || empty,
+                yesterday: byDate[formatDate(yesterday)] || empty,
+                channels: (channels.channels || []).slice(0, maxTopChannels),
+            });
+        } catch (error) {
+            this.setState({loading: false, error: error.message});
+        }
+    };
+
+    render() {
+        const {loading, error, today, yesterday, channels} = this.state;
+
+        let content;
+        if (error) {
+            content = <p style={style.error}>{error}</p>;
+        } else if (loading) {
+            content = <p>{'Loading...'}</p>;
+        } else {
+            content = (
+                <React.Fragment>
+                    <table className='table'>
+                        <thead>
+                            <tr>
+                                <th/>
+                                <th>{'Today'}</th>
+                                <th>{'vs yesterday'}</th>
+                            </tr>
+                        </thead>
+                        <tbody>
+                            {metrics.map((metric) => (
+                                <tr key={metric.key}>
+                                    <th>{metric.label}</th>
+                                    <td>{today[metric.key]}</td>
+                                    <td>{formatChange(today[metric.key], yesterday[metric.key])}</td>
+                                </tr>
+                            ))}
+                        </tbody>
+                    </table>
+                    <h4>{'Top channels today'}</h4>
+                    <BarChart
+                        bars={channels.map((channel) => ({
+                            label: channel.display_name || channel.name || channel.id,
+                            value: channel.messages,
+                        }))}
+                        unit='messages'
+                    />
+                </React.Fragment>
+            );
+        }
+
+        return (
+            <div style={style.container}>
+                <div style={style.header}>
+                    <h3 style={style.title}>{'Today'}</h3>
+                    <button
+                        className='btn btn-link'
+                        onClick={this.load}
+                    >
+                        {'Refresh'}
+                    </button>
+                </div>
+                {content}
+                <h4>{'Full dashboard'}</h4>
+                {ranges.map((range) => (
+                    <button
+                        key={range}
+                        className='btn btn-link'
+                        onClick={() => this.props.openDashboard(range)}
+                    >
+                        {`Last ${range} days`}
+                    </button>
+                ))}
+            </div>
+        );
+    }
+}
+
+const style = {
+    container: {
+        padding: '12px',
+        overflow: 'auto',
+    },
+    header: {
+        display: 'flex',
+        alignItems: 'center',
+    },
+    title: {
+        flex: 1,
+        margin: 0,
+    },
+    error: {
+        color: '#d24b4e',
+    },
+};
+
+const mapDispatchToProps = (dispatch) => ({
+    openDashboard: (days) => dispatch(openDashboard({...defaultState, days})),
+});
+
+export default connect(null, mapDispatchToProps)(Overview);
@@ -0,0 +1,351 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+
+import {fetchSettingsChannels, fetchSettingsTeams, searchSettingsChannels} from '../client';
+
+// channelIds return ids of the report channels and of the channels restricting the analytics posted there
+const channelIds = (reportChannels) => reportChannels.reduce((ids, reportChannel) => ids.concat(
+    [reportChannel.channel_id],
+    reportChannel.source_channels_id || [],
+), []);
+
+// ChannelPicker search a channel of a team, the picked channel is passed to onPick
+class ChannelPicker extends React.PureComponent {
+    static propTypes = {
+        teams: PropTypes.array.isRequired,
+        disabled: PropTypes.bool,
+        placeholder: PropTypes.string,
+        onPick: PropTypes.func.isRequired,
+    };
+
+    state = {
+        teamId: '',
+        term: '',
+        results: [],
+        error: null,
+    };
+
+    search = async (teamId, term) => {
+        this.setState({teamId, term, error: null});
+        if (!teamId) {
+            this.setState({results: []});
+            return;
+        }
+        try {
+            const results = await searchSettingsChannels(teamId, term);
+            // a slower response of an older search must not replace the current results
+            if (this.state.teamId === teamId && this.state.term === term) {
+                this.setState({results});
+            }
+        } catch (error) {
+            this.setState({results: [], error: error.message});
+        }
+    };
+
+    pick = (channel) => {
+        this.setState({term: '', results: []});
+        this.props.onPick(channel);
+    };
+
+    render() {
+        const {teams, disabled, placeholder} = this.props;
+        const {teamId, term, results, error} = this.state;
+        return (
+            <div style={style.picker}>
+                <select
+                    className='form-control'
+                    style={style.team}
+                    value={teamId}
+                    disabled={disabled}
+                    onChange={(e) => this.search(e.target.value, '')}
+                >
+                    <option value=''>{'Team...'}</option>
+                    {teams.map((team) => (
+                        <option
+                            key={team.id}
+                            value={team.id}
+                        >
+                            {team.display_name}
+                        </option>
+                    ))}
+                </select>
+                <input
+                    className='form-control'
+                    style={style.term}
+                    type='text'
+                    placeholder={placeholder}
+                    value={term}
+                    disabled={disabled || !teamId}
+                    onChange={(e) => this.search(teamId, e.target.value)}
+                />
+                {error && <span style={style.error}>{error}</span>}
+                {teamId && results.length > 0 && (
+                    <ul style={style.results}>
+                        {results.map((channel) => (
+                            <li key={channel.id}>
+                                <a
+                                    href='#'
+                                    onClick={(e) => {
+                                        e.preventDefault();
+                                        this.pick(channel);
+                                    }}
+                                >
+                                    {`${channel.display_name} (~${channel.name})`}
+                                </a>
+                            </li>
+                        ))}
+                    </ul>
+                )}
+            </div>
+        );
+    }
+}
+
+// ReportChannelsSetting is the admin console setting of the channels receiving reports, each one with the teams and
+// channels whose analytics are posted there, all analytics without them
+export default class ReportChannelsSetting extends React.PureComponent {
+    static propTypes = {
+        id: PropTypes.string.isRequired,
+        label: PropTypes.node,
+        helpText: PropTypes.node,
+        value: PropTypes.array,
+        disabled: PropTypes.bool,
+        onChange: PropTypes.func.isRequired,
+    };
+
+    state = {
+        teams: [],
+        channels: {},
+        loaded: false,
+        error: null,
+    };
+
+    componentDidMount() {
+        this.load();
+    }
+
+    load = async () => {
+        try {
+            const [teams, channels] = await Promise.all([
+                fetchSettingsTeams(),
+                fetchSettingsChannels(channelIds(this.value())),
+            ]);
+            const byId = {};
+            channels.forEach((channel) => {
+                byId[channel.id] = channel;
+            });
+            this.setState({teams, channels: {...byId, ...this.state.channels}, loaded: true});
+        } catch (error) {
+            this.setState({error: error.message});
+        }
+    };
+
+    value = () => (Array.isArray(this.props.value) ? this.props.value : []);
+
+    change = (reportChannels, channel) => {
+        if (channel) {
+            this.setState({channels: {...this.state.channels, [channel.id]: channel}});
+        }
+        this.props.onChange(this.props.id, reportChannels);
+    };
+
+    update = (index, changes) => {
+        const reportChannels = this.value().slice();
+        reportChannels[index] = {...reportChannels[index], ...changes};
+        return reportChannels;
+    };
+
+    addReportChannel = (channel) => {
+        if (this.value().some((reportChannel) => reportChannel.channel_id === channel.id)) {
+            return;
+        }
+        this.change([...this.value(), {team_id: channel.team_id, channel_id: channel.id}], channel);
+    };
+
+    removeReportChannel = (index) => {
+        this.change(this.value().filter((reportChannel, i) => i !== index));
+    };
+
+    addSourceTeam = (index, teamId) => {
+        const teamsId = this.value()[index].source_teams_id || [];
+        if (teamId && !teamsId.includes(teamId)) {
+            this.change(this.update(index, {source_teams_id: [...teamsId, teamId]}));
+        }
+    };
+
+    addSourceChannel = (index, channel) => {
+        const channelsId = this.value()[index].source_channels_id || [];
+        if (!channelsId.includes(channel.id)) {
+            this.change(this.update(index, {source_channels_id: [...channelsId, channel.id]}), channel);
+        }
+    };
+
+    removeSource = (index, key, id) => {
+        this.change(this.update(index, {[key]: (this.value()[index][key] || []).filter((sourceId) => sourceId !== id)}));
+    };
+
+    teamName = (teamId) => {
+        const team = this.state.teams.find((t) => t.id === teamId);
+        return team ? team.display_name : teamId;
+    };
+
+    channelName = (channelId) => {
+        const channel = this.state.channels[channelId];
+        if (!channel) {
+            return this.state.loaded ? `${channelId} (deleted)` : channelId;
+        }
+        return `${channel.display_name} (${this.teamName(channel.team_id)})`;
+    };
+
+    renderSource(index, key, id, label) {
+        return (
+            <span
+                key={id}
+                style={style.chip}
+            >
+                {label}
+                {!this.props.disabled && (
+                    <a
+                        href='#'
+                        style={style.remove}
+                        onClick={(e) => {
+                            e.preventDefault();
+                            this.removeSource(index, key, id);
+                        }}
+                    >
+                        {'×'}
+                    </a>
+                )}
+            </span>
+        );
+    }
+
+    render() {
+        const {label, helpText, disabled} = this.props;
+        const {teams, error} = this.state;
+        const reportChannels = this.value();
+
+        return (
+            <div className='form-group'>
+                <label className='control-label col-sm-4'>{label}</label>
+                <div className='col-sm-8'>
+                    {error && <p style={style.error}>{error}</p>}
+                    {reportChannels.map((reportChannel, index) => {
+                        const teamsId = reportChannel.source_teams_id || [];
+                        const channelsId = reportChannel.source_channels_id || [];
+                        return (
+                            <div
+                                key={reportChannel.channel_id}
+                                style={style.reportChannel}
+                            >
+                                <div style={style.header}>
+                                    <strong style={style.title}>{this.channelName(reportChannel.channel_id)}</strong>
+                                    {!disabled && (
+                                        <button
+                                            type='button'
+                                            className='btn btn-link'
+                                            onClick={() => this.removeReportChannel(index)}
+                                        >
+                                            {'Remove'}
+                                        </button>
+                                    )}
+                                </div>
+                                <div>
+                                    {teamsId.length + channelsId.length === 0 ? 'Analytics of all channels' : 'Only analytics of: '}
+                                    {teamsId.map((teamId) => this.renderSource(index, 'source_teams_id', teamId, `${this.teamName(teamId)} (all channels)`))}
+                                    {channelsId.map((channelId) => this.renderSource(index, 'source_channels_id', channelId, this.channelName(channelId)))}
+                                </div>
+                                {!disabled && (
+                                    <div style={style.restrict}>
+                                        <select
+                                            className='form-control'
+                                            style={style.team}
+                                            value=''
+                                            onChange={(e) => this.addSourceTeam(index, e.target.value)}
+                                        >
+                                            <option value=''>{'Restrict to a team...'}</option>
+                                            {teams.map((team) => (
+                                                <option
+                                                    key={team.id}
+                                                    value={team.id}
+                                                >
+                                                    {team.display_name}
+                                                </option>
+                                            ))}
+                                        </select>
+                                        <ChannelPicker
+                                            teams={teams}
+                                            placeholder='Restrict to a channel...'
+                                            onPick={(channel) => this.addSourceChannel(index, channel)}
+                                        />
+                                    </div>
+                                )}
+                            </div>
+                        );
+                    })}
+                    {!disabled && (
+                        <ChannelPicker
+                            teams={teams}
+                            placeholder='Add a report channel...'
+                            onPick={this.addReportChannel}
+                        />
+                    )}
+                    <div className='help-text'>{helpText}</div>
+                </div>
+            </div>
+        );
+    }
+}
+
+const style = {
+    picker: {
+        display: 'flex',
+        flexWrap: 'wrap',
+        alignItems: 'flex-start',
+        marginBottom: '8px',
+    },
+    team: {
+        width: '200px',
+        marginRight: '8px',
+    },
+    term: {
+        flex: 1,
+        minWidth: '200px',
+    },
+    results: {
+        width: '100%',
+        maxHeight: '200px',
+        overflow: 'auto',
+        margin: '4px 0 0',
+        paddingLeft: '16px',
+    },
+    reportChannel: {
+        border: '1px solid rgba(0, 0, 0, 0.1)',
+        borderRadius: '4px',
+        padding: '8px 12px',
+        marginBottom: '8px',
+    },
+    header: {
+        display: 'flex',
+        alignItems: 'center',
+    },
+    title: {
+        flex: 1,
+    },
+    restrict: {
+        marginTop: '8px',
+    },
+    chip: {
+        display: 'inline-block',
+        padding: '2px 8px',
+        margin: '2px 4px 2px 0',
+        borderRadius: '10px',
+        background: 'rgba(0, 0, 0, 0.08)',
+    },
+    remove: {
+        marginLeft: '6px',
+    },
+    error: {
+        color: '#d24b4e',
+    },
+};
@@ -0,0 +1,170 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+import {connect} from 'react-redux';
+
+import {id as pluginId} from '../manifest';
+import {closeThreadAnalytics} from '../actions';
+import {fetchThread} from '../client';
+
+// formatDelay return a short human readable duration of seconds, e.g. 2h 5m
+export const formatDelay = (seconds) => {
+    if (seconds < 60) {
+        return `${seconds}s`;
+    }
+    const days = Math.floor(seconds / 86400);
+    const hours = Math.floor((seconds % 86400) / 3600);
+    const minutes = Math.floor((seconds % 3600) / 60);
+    return [days && `${days}d`, hours && `${hours}h`, minutes && `${minutes}m`].filter(Boolean).join(' ') || '0m';
+};
+
+// ThreadAnalytics is a small modal with replies, participants, reply delays and reactions of a thread
+class ThreadAnalytics extends React.PureComponent {
+    static propTypes = {
+        postId: PropTypes.string,
+        close: PropTypes.func.isRequired,
+    };
+
+    state = {
+        loading: false,
+        error: null,
+        thread: null,
+    };
+
+    componentDidMount() {
+        document.addEventListener('keydown', this.handleKeyDown);
+    }
+
+    componentDidUpdate(prevProps) {
+        if (this.props.postId && this.props.postId !== prevProps.postId) {
+            this.load(this.props.postId);
+        }
+    }
+
+    componentWillUnmount() {
+        document.removeEventListener('keydown', this.handleKeyDown);
+    }
+
+    handleKeyDown = (e) => {
+        if (this.props.postId && e.key === 'Escape') {
+            this.props.close();
+        }
+    };
+
+    load = async (postId) => {
+        this.setState({loading: true, error: null, thread: null});
+        try {
+            const thread = await fetchThread(postId);
+            this.setState({loading: false, thread});
+        } catch (error) {
+            this.setState({loading: false, error: error.message});
+        }
+    };
+
+    renderThread = (thread) => {
+        const rows = [
+            ['Replies', thread.replies],
+            ['Participants', thread.participants],
+            ['Reactions', thread.reactions],
+        ];
+        if (thread.replies > 0) {
+            rows.push(
+                ['First reply', `${formatDelay(thread.first_reply_after)} after the root post (${new Date(thread.first_reply_at).toLocaleString()})`],
+                ['Last reply', `${formatDelay(thread.last_reply_after)} after the root post (${new Date(thread.last_reply_at).toLocaleString()})`],
+            );
+        }
+        return (
+            <table className='table'>
+                <tbody>
+                    {rows.map(([label, value]) => (
+                        <tr key={label}>
+                            <th>{label}</th>
+                            <td>{value}</td>
+                        </tr>
+                    ))}
+                </tbody>
+            </table>
+        );
+    };
+
+    render() {
+        if (!this.props.postId) {
+            return null;
+        }
+        const {loading, error, thread} = this.state;
+
+        let content;
+        if (error) {
+            content = <p style={style.error}>{error}</p>;
+        } else if (loading || !thread) {
+            content = <p>{'Loading...'}</p>;
+        } else {
+            content = this.renderThread(thread);
+        }
+
+        return (
+            <div style={style.backdrop}>
+                <div
+                    style={style.modal}
+                    role='dialog'
+                    aria-modal='true'
+                    aria-label='Thread analytics'
+                >
+                    <div style={style.header}>
+                        <h3 style={style.title}>{'Thread analytics'}</h3>
+                        <button
+                            className='close'
+                            aria-label='Close'
+                            onClick={this.props.close}
+                        >
+                            {'×'}
+                        </button>
+                    </div>
+                    {content}
+                </div>
+            </div>
+        );
+    }
+}
+
+const style = {
+    backdrop: {
+        position: 'fixed',
+        top: 0,
+        left: 0,
+        right: 0,
+        bottom: 0,
+        zIndex: 1000,
+        background: 'rgba(0, 0, 0, 0.5)',
+    },
+    modal: {
+        position: 'absolute',
+        top: '20%',
+        left: '50%',
+        width: '420px',
+        marginLeft: '-210px',
+        padding: '20px',
+        background: '#fff',
+        borderRadius: '4px',
+    },
+    header: {
+        display: 'flex',
+        alignItems: 'center',
+    },
+    title: {
+        flex: 1,
+        margin: 0,
+    },
+    error: {
+        color: '#d24b4e',
+    },
+};
+
+const mapStateToProps = (state) => ({
+    postId: state['plugins-' + pluginId] ? state['plugins-' + pluginId].threadAnalyticsPostId : null,
+});
+
+const mapDispatchToProps = (dispatch) => ({
+    close: () => dispatch(closeThreadAnalytics()),
+});
+
+export default connect(mapStateToProps, mapDispatchToProps)(ThreadAnalytics);
@@ -0,0 +1,107 @@
+import React from 'react';
+import PropTypes from 'prop-types';
+import {connect} from 'react-redux';
+
+import {id as pluginId} from './manifest';
+import {digestPosted, openDashboard, openThreadAnalytics} from './actions';
+import reducer from './reducer';
+import {exportChannel} from './client';
+import {readStateFromLocation} from './url_state';
+import Dashboard from './components/dashboard';
+import ThreadAnalytics from './components/thread_analytics';
+import Overview from './components/overview';
+import BrandLogoSetting from './components/brand_logo_setting';
+import ReportChannelsSetting from './components/report_channels_setting';
+
+// Icon of the channel header button, with a dot when a new digest was posted since the dashboard was opened
+const DashboardIcon = ({newDigest}) => (
+    <span
+        style={{position: 'relative'}}
+        title={newDigest ? `New analytics available: ${newDigest.messages} messages since ${new Date(newDigest.since).toLocaleDateString()}` : 'Analytics'}
+    >
+        <i className='icon fa fa-bar-chart'/>
+        {newDigest && (
+            <span
+                style={{position: 'absolute', top: '-2px', right: '-4px', width: '7px', height: '7px', borderRadius: '50%', background: '#FF8000'}}
+            />
+        )}
+    </span>
+);
+
+DashboardIcon.propTypes = {
+    newDigest: PropTypes.object,
+};
+
+const Icon = connect((state) => ({
+    newDigest: state['plugins-' + pluginId] ? state['plugins-' + pluginId].newDigest : null,
+}))(DashboardIcon);
+
+class Plugin {
+    initialize(registry, store) {
+        registry.registerReducer(reducer);
+        registry.registerRootComponent(Dashboard);
+        registry.registerRootComponent(ThreadAnalytics);
+        registry.registerChannelHeaderButtonAction(
+            <Icon/>,
+            () => store.dispatch(openDashboard()),
+            'Analytics',
+        );
+
+        // the app bar and right-hand sidebar exist only in recent servers, the channel header button stays the entry point of older ones
+        if (registry.registerRightHandSidebarComponent) {
+            const {toggleRHSPlugin} = registry.registerRightHandSidebarComponent(Overview, 'Analytics');
+            if (registry.registerAppBarComponent) {
+                const basename = window.basename || '';
+                registry.registerAppBarComponent(
+                    `${basename}/plugins/${pluginId}/public/app-bar-icon.svg`,
+                    () => store.dispatch(toggleRHSPlugin),
+                    'Analytics overview',
+                );
+            }
+        }
+
+        if (registry.registerAdminConsoleCustomSetting) {
+            registry.registerAdminConsoleCustomSetting('ReportChannels', ReportChannelsSetting);
+            registry.registerAdminConsoleCustomSetting('BrandLogo', BrandLogoSetting);
+        }
+
+        registry.registerChannelHeaderMenuAction(
+            'Export this channel\'s analytics',
+            async (channelId) => {
+                try {
+                    await exportChannel(channelId);
+                    window.alert('The analytics of this channel will be sent to you by direct message.'); // eslint-disable-line no-alert
+                } catch (error) {
+                    window.alert(error.message); // eslint-disable-line no-alert
+                }
+            },
+        );
+        registry.registerPostDropdownMenuAction(
+            'View thread analytics',
+            (postId) => store.dispatch(openThreadAnalytics(postId)),
+        );
+
+        registry.registerWebSocketEventHandler(
+            `custom_${pluginId}_digest_posted`,
+            (message) => store.dispatch(digestPosted(message.data)),
+        );
+
+        // links to the dashboard open it, on load or when followed from a post
+        let lastSearch = null;
+        const openFromLocation = () => {
+            if (window.location.search === lastSearch) {
+                return;
+            }
+            lastSearch = window.location.search;
+            const pluginState = store.getState()['plugins-' + pluginId];
+            const state = readStateFromLocation(window.location);
+            if (state && !(pluginState && pluginState.dashboardVisible)) {
+                store.dispatch(openDashboard(state));
+            }
+        };
+        store.subscribe(openFromLocation);
+        openFromLocation();
+    }
+}
+
+window.registerPlugin(pluginId, new Plugin());
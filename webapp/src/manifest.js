@@ -0,0 +1,2 @@
+export const id = 'com.github.manland.mattermost-plugin-analytics';
+export const version = '0.2.0';
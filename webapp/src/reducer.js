@@ -0,0 +1,57 @@
+import {combineReducers} from 'redux';
+
+import {OPEN_DASHBOARD, CLOSE_DASHBOARD, OPEN_THREAD_ANALYTICS, CLOSE_THREAD_ANALYTICS, DIGEST_POSTED} from './action_types';
+
+const dashboardVisible = (state = false, action) => {
+    switch (action.type) {
+    case OPEN_DASHBOARD:
+        return true;
+    case CLOSE_DASHBOARD:
+        return false;
+    default:
+        return state;
+    }
+};
+
+// dashboardState is the state of the dashboard when opened from a link, null otherwise
+const dashboardState = (state = null, action) => {
+    switch (action.type) {
+    case OPEN_DASHBOARD:
+        return action.state;
+    case CLOSE_DASHBOARD:
+        return null;
+    default:
+        return state;
+    }
+};
+
+// threadAnalyticsPostId is the post whose thread analytics are shown, null when closed
+const threadAnalyticsPostId = (state = null, action) => {
+    switch (action.type) {
+    case OPEN_THREAD_ANALYTICS:
+        return action.postId;
+    case CLOSE_THREAD_ANALYTICS:
+        return null;
+    default:
+        return state;
+    }
+};
+
+// newDigest is the summary of the last digest posted since the dashboard was opened, null if none
+const newDigest = (state = null, action) => {
+    switch (action.type) {
+    case DIGEST_POSTED:
+        return action.summary;
+    case OPEN_DASHBOARD:
+        return null;
+    default:
+        return state;
+    }
+};
+
+export default combineReducers({
+    dashboardVisible,
+    dashboardState,
+    threadAnalyticsPostId,
+    newDigest,
+});
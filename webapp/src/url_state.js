@@ -0,0 +1,76 @@
+// The dashboard state is encoded in the analytics query parameter of the current url, e.g.
+// ?analytics=days:30;channels:id1,id2;metrics:messages,replies;previous:1
+// so views can be bookmarked or shared in messages and opened again from the link.
+
+export const stateParam = 'analytics';
+
+export const ranges = [7, 30, 90];
+export const metrics = ['messages', 'replies', 'active_users'];
+
+export const defaultState = {
+    days: 30,
+    channels: [],
+    metrics,
+    previousPeriod: false,
+};
+
+// encodeState return the value of the analytics query parameter of a dashboard state
+export const encodeState = (state) => {
+    const parts = [`days:${state.days}`];
+    if (state.channels.length > 0) {
+        parts.push(`channels:${state.channels.join(',')}`);
+    }
+    if (state.metrics.length !== metrics.length) {
+        parts.push(`metrics:${state.metrics.join(',')}`);
+    }
+    if (state.previousPeriod) {
+        parts.push('previous:1');
+    }
+    return parts.join(';');
+};
+
+// decodeState return the dashboard state of an analytics query parameter, unknown or invalid parts are ignored
+export const decodeState = (value) => {
+    const state = {...defaultState};
+    (value || '').split(';').forEach((part) => {
+        const [key, ...rest] = part.split(':');
+        const raw = rest.join(':');
+        switch (key) {
+        case 'days':
+            if (ranges.includes(Number(raw))) {
+                state.days = Number(raw);
+            }
+            break;
+        case 'channels':
+            state.channels = raw.split(',').filter((channel) => (/^[a-z0-9]+$/).test(channel)).slice(0, 2);
+            break;
+        case 'metrics':
+            state.metrics = raw.split(',').filter((metric) => metrics.includes(metric));
+            break;
+        case 'previous':
+            state.previousPeriod = raw === '1';
+            break;
+        }
+    });
+    return state;
+};
+
+// readStateFromLocation return the dashboard state encoded in location, null without analytics query parameter
+export const readStateFromLocation = (location) => {
+    const match = new RegExp(`[?&]${stateParam}=([^&]*)`).exec(location.search);
+    if (!match) {
+        return null;
+    }
+    return decodeState(decodeURIComponent(match[1]));
+};
+
+// locationWithState return the url of location with the dashboard state, without it if state is null
+// other query parameters and the path, and so the current team and channel, are kept
+export const locationWithState = (location, state) => {
+    const params = location.search.replace(/^\?/, '').split('&').filter((param) => param !== '' && !param.startsWith(stateParam + '='));
+    if (state) {
+        params.push(`${stateParam}=${encodeURIComponent(encodeState(state))}`);
+    }
+    const search = params.length > 0 ? '?' + params.join('&') : '';
+    return `${location.pathname}${search}${location.hash}`;
+};